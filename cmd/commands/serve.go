@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -74,14 +75,68 @@ func Serve() error {
 			AccountStorageLimit  ByteSize `conf:"default:-1"`
 			AccountProjectsLimit int      `conf:"default:-1"`
 			AccountLimiterConfig string
+			UsageSampleInterval  time.Duration `conf:"default:24h,help:Interval of sampling of accounts storage usage (0 to disable)"`
+			UsageRetention       time.Duration `conf:"default:8760h,help:Retention of storage usage samples (0 to keep forever)"`
 			LandingProject       string
 			ProjectCustomization bool
+			StrictValidation     bool
+			SchedulerInterval    time.Duration `conf:"default:1m"`
 			Extensions           string
+			NotificationStore    string `conf:"default:redis,help:Options [redis|file|postgres]"`
+			NotificationsFile    string `conf:"default:/var/lib/gisquick/notifications.json"`
 		}
 		Auth struct {
 			SessionExpiration    time.Duration `conf:"default:24h"`
 			EmailTokenExpiration time.Duration `conf:"default:72h"`
 			SecretKey            string        `conf:"default:secret-key,mask"`
+			RequireSuperuser2FA  bool          `conf:"help:Require two-factor authentication for superusers"`
+			LoginMaxFailures     int           `conf:"default:5,help:Failed logins leading to temporary lockout (0 to disable)"`
+			LoginMaxIPFailures   int           `conf:"default:50"`
+			LoginLockout         time.Duration `conf:"default:15m"`
+			PasswordResetLimit   int           `conf:"default:3,help:Password reset requests per email and hour"`
+			SessionStore         string        `conf:"default:redis,help:Options [redis|memory]"`
+			PasswordMinLength    int           `conf:"default:8"`
+			PasswordCharClasses  []string      `conf:"help:Required character classes [lower,upper,digit,symbol]"`
+			PasswordUserInfo     bool          `conf:"default:true,help:Reject passwords containing username or email"`
+			PasswordCommonList   bool          `conf:"default:true,help:Reject passwords from bundled list of common passwords"`
+			PasswordBlocklist    string        `conf:"help:File with sorted SHA-1 hashes of breached passwords (HASH[:COUNT] lines)"`
+			PasswordMaxAge       time.Duration `conf:"help:Maximal age of passwords of accounts with password expiry (0 to disable)"`
+			DeletionGracePeriod  time.Duration `conf:"default:168h,help:Time between confirmation of account deletion and the deletion"`
+			InvitationExpiration time.Duration `conf:"default:168h,help:Validity of invitations into projects"`
+			InvitationLimit      int           `conf:"default:20,help:Invitations into projects sent per user and hour"`
+			AuditRetention       time.Duration `conf:"default:8760h,help:Retention of security audit events (0 to keep forever)"`
+		}
+		LDAP struct {
+			URL                string `conf:"help:LDAP server URL (ldap://host:389 or ldaps://host:636)"`
+			StartTLS           bool
+			InsecureSkipVerify bool
+			BindDN             string
+			BindPassword       string `conf:"mask"`
+			BaseDN             string
+			UserFilter         string `conf:"default:(uid=%s)"`
+			UsernameAttr       string `conf:"default:uid"`
+			EmailAttr          string `conf:"default:mail"`
+			FirstNameAttr      string `conf:"default:givenName"`
+			LastNameAttr       string `conf:"default:sn"`
+			GroupBaseDN        string
+			GroupFilter        string `conf:"help:Group membership filter, e.g. (member=%s)"`
+			GroupNameAttr      string `conf:"default:cn"`
+			SuperuserGroup     string
+			LinkExisting       bool
+		}
+		OIDC struct {
+			Issuer         string
+			ClientID       string
+			ClientSecret   string `conf:"mask"`
+			Scopes         string `conf:"default:openid profile email"`
+			UsernameClaim  string `conf:"default:preferred_username"`
+			EmailClaim     string `conf:"default:email"`
+			FirstNameClaim string `conf:"default:given_name"`
+			LastNameClaim  string `conf:"default:family_name"`
+			GroupsClaim    string `conf:"default:groups"`
+			SuperuserGroup string
+			ProfileClaims  string `conf:"help:Claims stored into user profile [claim:key,...]"`
+			TwoFactorURL   string `conf:"default:/login,help:Page finishing login of users with two-factor authentication"`
 		}
 		Web struct {
 			ReadTimeout     time.Duration `conf:"default:5s"`
@@ -90,6 +145,7 @@ func Serve() error {
 			ShutdownTimeout time.Duration `conf:"default:20s"`
 			SiteURL         string        `conf:"default:http://localhost"`
 			APIHost         string        `conf:"default:0.0.0.0:3000"`
+			TrustedProxies  []string      `conf:"help:Networks (CIDR) of reverse proxies trusted to set X-Forwarded-For header, in addition to loopback and private networks"`
 		}
 		Postgres struct {
 			User               string `conf:"default:postgres"`
@@ -115,8 +171,20 @@ func Serve() error {
 			Username             string
 			Password             string `conf:"mask"`
 			Sender               string
-			ActivationSubject    string `conf:"default:Gisquick Registration"`
-			PasswordResetSubject string `conf:"default:Gisquick Password Reset"`
+			ActivationSubject    string        `conf:"default:Gisquick Registration"`
+			PasswordResetSubject string        `conf:"default:Gisquick Password Reset"`
+			DeletionSubject      string        `conf:"default:Gisquick Account Deletion"`
+			EmailChangeSubject   string        `conf:"default:Gisquick Email Change"`
+			InvitationSubject    string        `conf:"default:Gisquick Project Invitation"`
+			TemplatesDir         string        `conf:"default:./templates,help:Directory with email templates (subdirectories for other languages)"`
+			Outbox               bool          `conf:"default:true,help:Send emails from persistent queue with retries"`
+			MaxAttempts          int           `conf:"default:8"`
+			RetryDelay           time.Duration `conf:"default:1m"`
+			MaxRetryDelay        time.Duration `conf:"default:6h"`
+			PollInterval         time.Duration `conf:"default:30s"`
+			OutboxRetention      time.Duration `conf:"default:720h,help:How long are sent emails kept (0 - forever)"`
+			CampaignBatchSize    int           `conf:"default:50,help:Maximal number of campaign emails sent per interval"`
+			CampaignInterval     time.Duration `conf:"default:1m"`
 		}
 	}{}
 
@@ -166,14 +234,20 @@ func Serve() error {
 		dbConn.Close()
 	}()
 
-	// for unix socket, use Network: "unix" and Addr: "/var/run/redis/redis.sock"
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     cfg.Redis.Addr,
-		Network:  cfg.Redis.Network,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	})
-	defer rdb.Close()
+	if cfg.Auth.SessionStore != "redis" && cfg.Auth.SessionStore != "memory" {
+		return fmt.Errorf("invalid session store: %s", cfg.Auth.SessionStore)
+	}
+	var rdb *redis.Client
+	if cfg.Auth.SessionStore == "redis" || cfg.Gisquick.NotificationStore == "redis" {
+		// for unix socket, use Network: "unix" and Addr: "/var/run/redis/redis.sock"
+		rdb = redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Network:  cfg.Redis.Network,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		defer rdb.Close()
+	}
 
 	var es email.EmailService
 	encryptionMap := map[string]mail.Encryption{
@@ -187,17 +261,41 @@ func Serve() error {
 	if !ok {
 		encryption = mail.EncryptionNone
 	}
+	var outbox *email.Outbox
 	if cfg.Email.Host != "" {
-		es = &email.SmtpEmailService{
+		smtp := &email.SmtpEmailService{
 			Host:       cfg.Email.Host,
 			Port:       cfg.Email.Port,
 			Encryption: encryption,
 			Username:   cfg.Email.Username,
 			Password:   cfg.Email.Password,
 		}
+		es = smtp
+		if cfg.Email.Outbox {
+			outbox = email.NewOutbox(log, postgres.NewEmailOutbox(dbConn), smtp, email.OutboxConfig{
+				MaxAttempts:   cfg.Email.MaxAttempts,
+				RetryDelay:    cfg.Email.RetryDelay,
+				MaxRetryDelay: cfg.Email.MaxRetryDelay,
+				PollInterval:  cfg.Email.PollInterval,
+				Retention:     cfg.Email.OutboxRetention,
+			})
+			outbox.Start()
+			defer outbox.Stop()
+			es = outbox
+		}
 	}
 
-	notifications := project.NewRedisNotificationStore(log, rdb)
+	var notifications project.NotificationStore
+	switch cfg.Gisquick.NotificationStore {
+	case "redis":
+		notifications = project.NewRedisNotificationStore(log, rdb)
+	case "file":
+		notifications = project.NewFileNotificationStore(log, cfg.Gisquick.NotificationsFile)
+	case "postgres":
+		notifications = postgres.NewNotificationStore(log, dbConn)
+	default:
+		return fmt.Errorf("invalid notification store: %s", cfg.Gisquick.NotificationStore)
+	}
 
 	conf := server.Config{
 		Language:             cfg.Gisquick.Language,
@@ -210,22 +308,143 @@ func Serve() error {
 		SiteURL:              cfg.Web.SiteURL,
 		MaxProjectSize:       int64(cfg.Gisquick.ProjectSizeLimit),
 		ProjectCustomization: cfg.Gisquick.ProjectCustomization,
+		StrictValidation:     cfg.Gisquick.StrictValidation,
+	}
+	for _, cidr := range cfg.Web.TrustedProxies {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy network: %w", err)
+		}
+		conf.TrustedProxies = append(conf.TrustedProxies, ipNet)
 	}
 
 	// Services
 	accountsRepo := postgres.NewAccountsRepository(dbConn)
 	tokenGenerator := security.NewTokenGenerator(cfg.Auth.SecretKey, "signup", cfg.Auth.EmailTokenExpiration)
-	emailSender := email.NewAccountsEmailSender(
-		es,
-		cfg.Email.Sender,
-		cfg.Web.SiteURL,
-		cfg.Email.ActivationSubject,
-		cfg.Email.PasswordResetSubject,
-	)
+	emailTemplates, err := email.NewTemplates(email.TemplatesConfig{
+		Dir:             cfg.Email.TemplatesDir,
+		DefaultLanguage: cfg.Gisquick.Language,
+		Subjects: map[string]string{
+			email.ActivationTemplate:      cfg.Email.ActivationSubject,
+			email.InvitationTemplate:      cfg.Email.ActivationSubject,
+			email.PasswordResetTemplate:   cfg.Email.PasswordResetSubject,
+			email.AccountDeletionTemplate: cfg.Email.DeletionSubject,
+			email.AccountDeletionPostponed: cfg.Email.DeletionSubject,
+			email.EmailChangeTemplate:     cfg.Email.EmailChangeSubject,
+			email.EmailChangeNotice:       cfg.Email.EmailChangeSubject,
+			email.ProjectInvitation:       cfg.Email.InvitationSubject,
+		},
+	}, postgres.NewEmailTemplates(dbConn))
+	if err != nil {
+		return fmt.Errorf("loading email templates: %w", err)
+	}
+	emailSender := email.NewAccountsEmailSender(es, cfg.Email.Sender, cfg.Web.SiteURL, emailTemplates)
 	accountsService := application.NewAccountsService(emailSender, accountsRepo, tokenGenerator)
 
-	sessionStore := auth.NewRedisStore(rdb)
+	passwordPolicy := domain.PasswordPolicy{
+		MinLength:        cfg.Auth.PasswordMinLength,
+		DisallowUserInfo: cfg.Auth.PasswordUserInfo,
+		MaxAge:           cfg.Auth.PasswordMaxAge,
+	}
+	for _, class := range cfg.Auth.PasswordCharClasses {
+		if !domain.PasswordCharClasses.Has(class) {
+			return fmt.Errorf("invalid password character class: %s", class)
+		}
+		passwordPolicy.CharClasses = append(passwordPolicy.CharClasses, class)
+	}
+	if cfg.Auth.PasswordCommonList {
+		passwordPolicy.Blocklists = append(passwordPolicy.Blocklists, security.NewCommonPasswordsList())
+	}
+	if cfg.Auth.PasswordBlocklist != "" {
+		blocklist, err := security.OpenPasswordHashFile(cfg.Auth.PasswordBlocklist)
+		if err != nil {
+			return fmt.Errorf("opening password blocklist: %w", err)
+		}
+		defer blocklist.Close()
+		passwordPolicy.Blocklists = append(passwordPolicy.Blocklists, blocklist)
+	}
+	accountsService.PasswordPolicy = passwordPolicy
+
+	limitRules := map[string]auth.LimitRule{
+		auth.ActionLogin: {
+			MaxAttempts:   cfg.Auth.LoginMaxFailures,
+			MaxIPAttempts: cfg.Auth.LoginMaxIPFailures,
+			Window:        cfg.Auth.LoginLockout,
+			Lockout:       cfg.Auth.LoginLockout,
+			BaseDelay:     time.Second,
+			MaxDelay:      30 * time.Second,
+		},
+		auth.ActionPasswordReset: {
+			MaxAttempts:   cfg.Auth.PasswordResetLimit,
+			MaxIPAttempts: 5 * cfg.Auth.PasswordResetLimit,
+			Window:        time.Hour,
+			Lockout:       time.Hour,
+		},
+		auth.ActionTwoFactor: {
+			MaxAttempts:   cfg.Auth.LoginMaxFailures,
+			MaxIPAttempts: cfg.Auth.LoginMaxIPFailures,
+			Window:        cfg.Auth.LoginLockout,
+			Lockout:       cfg.Auth.LoginLockout,
+			BaseDelay:     time.Second,
+			MaxDelay:      30 * time.Second,
+		},
+		auth.ActionInvitation: {
+			MaxAttempts: cfg.Auth.InvitationLimit,
+			Window:      time.Hour,
+			Lockout:     time.Hour,
+		},
+	}
+	var sessionStore auth.SessionStore
+	var attemptsLimiter auth.AttemptsLimiter
+	if cfg.Auth.SessionStore == "memory" {
+		memoryStore := auth.NewMemoryStore()
+		memoryStore.StartCleanup(10 * time.Minute)
+		defer memoryStore.Stop()
+		sessionStore = memoryStore
+		attemptsLimiter = auth.NewMemoryAttemptsLimiter(limitRules)
+	} else {
+		sessionStore = auth.NewRedisStore(rdb)
+		attemptsLimiter = auth.NewRedisAttemptsLimiter(rdb, limitRules)
+	}
 	authServ := auth.NewAuthService(log, cfg.Auth.SessionExpiration, accountsRepo, sessionStore)
+	authServ.SetAPITokensRepository(postgres.NewAPITokensRepository(dbConn))
+	authServ.SetTwoFactorRepository(postgres.NewTwoFactorRepository(dbConn), cfg.Auth.RequireSuperuser2FA)
+	authServ.SetAttemptsLimiter(attemptsLimiter)
+	authServ.SetPasswordPolicy(passwordPolicy)
+	audit := application.NewAuditService(log, postgres.NewAuditRepository(dbConn), application.AuditConfig{
+		Retention: cfg.Auth.AuditRetention,
+		Interval:  6 * time.Hour,
+	})
+	audit.Start()
+	defer audit.Stop()
+	authServ.SetAuditRecorder(audit)
+	orgsRepo := postgres.NewOrganizationsRepository(dbConn)
+	authServ.SetOrganizationsRepository(orgsRepo)
+	groupsRepo := postgres.NewGroupsRepository(dbConn)
+	authServ.SetGroupsRepository(groupsRepo)
+	if cfg.LDAP.URL != "" {
+		ldapAccountsRepo := postgres.NewLDAPAccountsRepository(dbConn)
+		authServ.SetLDAPAccountsRepository(ldapAccountsRepo)
+		ldapAuth := auth.NewLDAPAuthenticator(log, auth.LDAPConfig{
+			URL:                cfg.LDAP.URL,
+			StartTLS:           cfg.LDAP.StartTLS,
+			InsecureSkipVerify: cfg.LDAP.InsecureSkipVerify,
+			BindDN:             cfg.LDAP.BindDN,
+			BindPassword:       cfg.LDAP.BindPassword,
+			BaseDN:             cfg.LDAP.BaseDN,
+			UserFilter:         cfg.LDAP.UserFilter,
+			UsernameAttr:       cfg.LDAP.UsernameAttr,
+			EmailAttr:          cfg.LDAP.EmailAttr,
+			FirstNameAttr:      cfg.LDAP.FirstNameAttr,
+			LastNameAttr:       cfg.LDAP.LastNameAttr,
+			GroupBaseDN:        cfg.LDAP.GroupBaseDN,
+			GroupFilter:        cfg.LDAP.GroupFilter,
+			GroupNameAttr:      cfg.LDAP.GroupNameAttr,
+			SuperuserGroup:     cfg.LDAP.SuperuserGroup,
+			LinkExisting:       cfg.LDAP.LinkExisting,
+		}, accountsRepo, ldapAccountsRepo)
+		authServ.SetAuthenticators(auth.NewLocalAuthenticator(accountsRepo), ldapAuth)
+	}
 
 	projectsRepo := project.NewDiskStorage(log, cfg.Gisquick.ProjectsRoot)
 	defaultAccountConfig := domain.AccountConfig{
@@ -239,10 +458,100 @@ func Serve() error {
 	} else {
 		limiter = project.NewSimpleProjectsLimiter(defaultAccountConfig)
 	}
+	limiter = application.NewOrganizationsLimiter(limiter, orgsRepo)
 	projectsServ := application.NewProjectsService(log, projectsRepo, limiter)
 
+	scheduler := application.NewPublishScheduler(log, projectsServ, cfg.Gisquick.SchedulerInterval)
+	scheduler.Start()
+	defer scheduler.Stop()
+
 	sws := ws.NewSettingsWS(log)
 	s := server.NewServer(log, conf, dbConn, authServ, accountsService, projectsServ, sws, limiter, notifications)
+	s.AddOrganizations(application.NewOrganizationsService(orgsRepo, accountsRepo, projectsServ))
+	s.AddUserGroups(groupsRepo)
+	s.AddEmailTemplates(emailTemplates)
+	s.AddAudit(audit)
+	usage := application.NewUsageService(log, postgres.NewUsageRepository(dbConn), accountsRepo, orgsRepo, projectsServ, limiter, application.UsageConfig{
+		Interval:  cfg.Gisquick.UsageSampleInterval,
+		Retention: cfg.Gisquick.UsageRetention,
+	})
+	usage.Start()
+	defer usage.Stop()
+	s.AddUsage(usage)
+	if outbox != nil {
+		s.AddEmailOutbox(outbox)
+	}
+	if es != nil {
+		campaigns := application.NewCampaignsService(log, postgres.NewCampaignsRepository(dbConn), accountsRepo, projectsServ, emailSender, application.CampaignsConfig{
+			BatchSize: cfg.Email.CampaignBatchSize,
+			Interval:  cfg.Email.CampaignInterval,
+		})
+		campaigns.ResolveUser = authServ.AccountUser
+		campaigns.Start()
+		defer campaigns.Stop()
+		s.AddCampaigns(campaigns)
+
+		deletionTokens := security.NewTokenGenerator(cfg.Auth.SecretKey, "account_deletion", cfg.Auth.EmailTokenExpiration)
+		accountDeletion := application.NewAccountDeletionService(log, postgres.NewAccountDeletionsRepository(dbConn), accountsService, orgsRepo, projectsServ, limiter, deletionTokens, application.AccountDeletionConfig{
+			GracePeriod: cfg.Auth.DeletionGracePeriod,
+			Interval:    10 * time.Minute,
+		})
+		accountDeletion.ResolveUser = authServ.AccountUser
+		accountDeletion.OnAccountDeleted = func(username string) {
+			audit.Record(domain.AuditEvent{
+				Type:     domain.AuditAccountDeleted,
+				Username: username,
+				Details:  map[string]interface{}{"reason": "self_service"},
+			})
+			if _, err := authServ.RevokeUserSessions(context.Background(), username, ""); err != nil {
+				log.Errorw("revoking sessions of deleted account", "user", username, zap.Error(err))
+			}
+		}
+		accountDeletion.Start()
+		defer accountDeletion.Stop()
+		s.AddAccountDeletion(accountDeletion)
+
+		invitations := application.NewInvitationsService(log, postgres.NewInvitationsRepository(dbConn), accountsService, projectsServ, application.InvitationsConfig{
+			Expiration: cfg.Auth.InvitationExpiration,
+		})
+		invitations.ResolveUser = authServ.AccountUser
+		accountsService.OnAccountActivated = func(account domain.Account) {
+			if err := invitations.AcceptPending(account.Username); err != nil {
+				log.Errorw("accepting invitations of activated account", "user", account.Username, zap.Error(err))
+			}
+		}
+		s.AddInvitations(invitations)
+	}
+
+	if cfg.OIDC.Issuer != "" {
+		profileClaims := make(map[string]string)
+		for _, item := range strings.Split(cfg.OIDC.ProfileClaims, ",") {
+			claim, key, found := strings.Cut(strings.TrimSpace(item), ":")
+			if claim == "" {
+				continue
+			}
+			if !found {
+				key = claim
+			}
+			profileClaims[claim] = key
+		}
+		oidcService := auth.NewOIDCService(log, auth.OIDCConfig{
+			Issuer:         cfg.OIDC.Issuer,
+			ClientID:       cfg.OIDC.ClientID,
+			ClientSecret:   cfg.OIDC.ClientSecret,
+			RedirectURL:    strings.TrimSuffix(cfg.Web.SiteURL, "/") + "/api/auth/oidc/callback",
+			Scopes:         strings.Fields(cfg.OIDC.Scopes),
+			UsernameClaim:  cfg.OIDC.UsernameClaim,
+			EmailClaim:     cfg.OIDC.EmailClaim,
+			FirstNameClaim: cfg.OIDC.FirstNameClaim,
+			LastNameClaim:  cfg.OIDC.LastNameClaim,
+			GroupsClaim:    cfg.OIDC.GroupsClaim,
+			SuperuserGroup: cfg.OIDC.SuperuserGroup,
+			ProfileClaims:  profileClaims,
+			TwoFactorURL:   cfg.OIDC.TwoFactorURL,
+		}, sessionStore, accountsRepo, postgres.NewOIDCIdentitiesRepository(dbConn))
+		s.AddOIDCAuth(oidcService)
+	}
 
 	if cfg.Gisquick.Extensions != "" {
 		extensionsList := strings.Split(cfg.Gisquick.Extensions, ",")
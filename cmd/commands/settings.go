@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ardanlabs/conf/v2"
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/infrastructure/project"
+	"go.uber.org/zap"
+)
+
+func migrateSettings(log *zap.SugaredLogger, projectsRoot string, dryRun bool) error {
+	storage := project.NewDiskStorage(log, projectsRoot)
+	defer storage.Close()
+
+	projects, err := storage.AllProjects(true)
+	if err != nil {
+		return err
+	}
+	migrated, failed := 0, 0
+	for _, projectName := range projects {
+		version, changed, err := storage.MigrateSettingsFile(projectName, dryRun)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %s\n", projectName, err)
+			continue
+		}
+		if changed {
+			migrated++
+			fmt.Printf("%s: %d -> %d\n", projectName, version, domain.SettingsVersion)
+		}
+	}
+	if dryRun {
+		fmt.Printf("%d project(s) would be migrated, %d failed\n", migrated, failed)
+	} else {
+		fmt.Printf("%d project(s) migrated, %d failed\n", migrated, failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("migration of %d project(s) failed", failed)
+	}
+	return nil
+}
+
+func Settings() error {
+	cfg := struct {
+		Gisquick struct {
+			ProjectsRoot string `conf:"default:/publish"`
+		}
+		DryRun bool `conf:"flag:dry-run"`
+		Args   conf.Args
+	}{}
+
+	help, err := conf.Parse("", &cfg)
+	if err != nil {
+		if errors.Is(err, conf.ErrHelpWanted) {
+			fmt.Println(help)
+			return nil
+		}
+		return fmt.Errorf("parsing config: %w", err)
+	}
+
+	switch cfg.Args.Num(0) {
+	case "migrate":
+		log, err := createLogger(zap.WarnLevel)
+		if err != nil {
+			return err
+		}
+		defer log.Sync()
+		return migrateSettings(log, cfg.Gisquick.ProjectsRoot, cfg.DryRun)
+	case "schema":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(application.SettingsSchema())
+	default:
+		return fmt.Errorf("unknown or missing settings command (migrate, schema)")
+	}
+}
@@ -6,12 +6,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ardanlabs/conf/v2"
+	"github.com/gisquick/gisquick-server/internal/application"
 	"github.com/gisquick/gisquick-server/internal/domain"
 	"github.com/gisquick/gisquick-server/internal/infrastructure/postgres"
 	"github.com/gisquick/gisquick-server/internal/server"
@@ -37,42 +42,58 @@ type Account struct {
 	Profile   map[string]any `json:"profile,omitempty"`
 }
 
-func runUserCommand(command func(dbConn *sqlx.DB, args conf.Args) error) error {
-	cfg := struct {
-		Postgres struct {
-			User               string `conf:"default:postgres"`
-			Password           string `conf:"default:postgres,mask"`
-			Host               string `conf:"default:postgres"`
-			Name               string `conf:"default:postgres,env:POSTGRES_DB"`
-			Port               int    `conf:"default:5432"`
-			SSLMode            string `conf:"default:prefer"`
-			StatementCacheMode string `conf:"default:prepare"`
-		}
-		Args conf.Args
-	}{}
+type postgresConfig struct {
+	User               string `conf:"default:postgres"`
+	Password           string `conf:"default:postgres,mask"`
+	Host               string `conf:"default:postgres"`
+	Name               string `conf:"default:postgres,env:POSTGRES_DB"`
+	Port               int    `conf:"default:5432"`
+	SSLMode            string `conf:"default:prefer"`
+	StatementCacheMode string `conf:"default:prepare"`
+}
+
+func openDB(cfg postgresConfig) (*sqlx.DB, error) {
+	dbConn, err := server.OpenDB(server.DBConfig{
+		User:               cfg.User,
+		Password:           cfg.Password,
+		Host:               cfg.Host,
+		Port:               cfg.Port,
+		Name:               cfg.Name,
+		MaxIdleConns:       1,
+		MaxOpenConns:       1,
+		SSLMode:            cfg.SSLMode,
+		StatementCacheMode: cfg.StatementCacheMode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to db: %w", err)
+	}
+	return dbConn, nil
+}
 
-	help, err := conf.Parse("", &cfg)
+// parseConfig parses command's configuration, returns false when only help was requested
+func parseConfig(cfg interface{}) (bool, error) {
+	help, err := conf.Parse("", cfg)
 	if err != nil {
 		if errors.Is(err, conf.ErrHelpWanted) {
 			fmt.Println(help)
-			return nil
+			return false, nil
 		}
-		return fmt.Errorf("parsing config: %w", err)
+		return false, fmt.Errorf("parsing config: %w", err)
 	}
-	// Database
-	dbConn, err := server.OpenDB(server.DBConfig{
-		User:               cfg.Postgres.User,
-		Password:           cfg.Postgres.Password,
-		Host:               cfg.Postgres.Host,
-		Port:               cfg.Postgres.Port,
-		Name:               cfg.Postgres.Name,
-		MaxIdleConns:       1,
-		MaxOpenConns:       1,
-		SSLMode:            cfg.Postgres.SSLMode,
-		StatementCacheMode: cfg.Postgres.StatementCacheMode,
-	})
+	return true, nil
+}
+
+func runUserCommand(command func(dbConn *sqlx.DB, args conf.Args) error) error {
+	cfg := struct {
+		Postgres postgresConfig
+		Args     conf.Args
+	}{}
+	if ok, err := parseConfig(&cfg); !ok {
+		return err
+	}
+	dbConn, err := openDB(cfg.Postgres)
 	if err != nil {
-		return fmt.Errorf("connecting to db: %w", err)
+		return err
 	}
 	defer func() {
 		// log.Infow("shutdown", "status", "stopping database support", "host", cfg.Postgres.Host)
@@ -121,6 +142,19 @@ func addUser(dbConn *sqlx.DB, args conf.Args) error {
 	return accountsRepo.Create(account)
 }
 
+// recordEvent saves audit event of the command, failures are only reported
+func recordEvent(dbConn *sqlx.DB, eventType, username string) {
+	event := domain.AuditEvent{
+		Type:     eventType,
+		Username: username,
+		Details:  map[string]interface{}{"source": "cli"},
+		Created:  time.Now().UTC(),
+	}
+	if err := postgres.NewAuditRepository(dbConn).Create(event); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to save audit event: %s\n", err)
+	}
+}
+
 func addSuperuser(dbConn *sqlx.DB, args conf.Args) error {
 	account, err := createAccount()
 	if err != nil {
@@ -128,7 +162,11 @@ func addSuperuser(dbConn *sqlx.DB, args conf.Args) error {
 	}
 	account.Superuser = true
 	accountsRepo := postgres.NewAccountsRepository(dbConn)
-	return accountsRepo.Create(account)
+	if err := accountsRepo.Create(account); err != nil {
+		return err
+	}
+	recordEvent(dbConn, domain.AuditSuperuserGranted, account.Username)
+	return nil
 }
 
 func utcTime(t *time.Time) *time.Time {
@@ -139,7 +177,18 @@ func utcTime(t *time.Time) *time.Time {
 	return &d
 }
 
-func dumpUsers(dbConn *sqlx.DB, args conf.Args) error {
+func dumpUsers(dbConn *sqlx.DB, format string) error {
+	if format == "csv" {
+		// without passwords
+		accounts, err := postgres.NewAccountsRepository(dbConn).GetAllAccounts()
+		if err != nil {
+			return fmt.Errorf("querying users: %w", err)
+		}
+		return application.WriteAccountsCSV(os.Stdout, accounts)
+	}
+	if format != "json" {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
 	var dbUsers []postgres.User
 	if err := dbConn.Select(&dbUsers, `SELECT * FROM users`); err != nil {
 		return fmt.Errorf("querying users: %w", err)
@@ -165,38 +214,135 @@ func dumpUsers(dbConn *sqlx.DB, args conf.Args) error {
 	return encoder.Encode(accounts)
 }
 
-func loadUsers(dbConn *sqlx.DB, args conf.Args) error {
-	path := args.Num(0)
+type loadUsersConfig struct {
+	Postgres postgresConfig
+	// csv or json (detected from the file extension by default)
+	Format string `conf:"flag:format"`
+	// list of "column:field" items separated by semicolon
+	Map    []string `conf:"flag:map"`
+	DryRun bool     `conf:"flag:dry-run"`
+	Invite bool     `conf:"flag:invite"`
+	// URL of the running server, accounts are imported through its admin API (with API token)
+	Server string `conf:"flag:server,env:GISQUICK_SERVER"`
+	Token  string `conf:"flag:token,env:GISQUICK_API_TOKEN,mask"`
+	Args   conf.Args
+}
+
+func importUsers(dbConn *sqlx.DB, cfg loadUsersConfig, path string) (application.AccountsImportReport, error) {
+	var report application.AccountsImportReport
+	mapping, err := application.ParseImportMapping(cfg.Map)
+	if err != nil {
+		return report, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return report, fmt.Errorf("reading input file: %w", err)
+	}
+	defer f.Close()
+	records, err := application.ReadAccounts(f, cfg.Format, mapping)
+	if err != nil {
+		return report, err
+	}
+	accountsService := application.NewAccountsService(nil, postgres.NewAccountsRepository(dbConn), nil)
+	return accountsService.ImportAccounts(records, application.AccountsImportOptions{DryRun: cfg.DryRun})
+}
+
+// importUsersRemote imports accounts through admin API of the server
+func importUsersRemote(cfg loadUsersConfig, path string) (application.AccountsImportReport, error) {
+	var report application.AccountsImportReport
+	f, err := os.Open(path)
+	if err != nil {
+		return report, fmt.Errorf("reading input file: %w", err)
+	}
+	defer f.Close()
+	u, err := url.Parse(strings.TrimRight(cfg.Server, "/") + "/api/admin/users/import")
+	if err != nil {
+		return report, fmt.Errorf("invalid server url: %w", err)
+	}
+	params := url.Values{
+		"format":  {cfg.Format},
+		"map":     cfg.Map,
+		"dry_run": {strconv.FormatBool(cfg.DryRun)},
+		"invite":  {strconv.FormatBool(cfg.Invite)},
+	}
+	u.RawQuery = params.Encode()
+	req, err := http.NewRequest(http.MethodPost, u.String(), f)
+	if err != nil {
+		return report, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	if cfg.Format == "json" {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "text/csv")
+	}
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return report, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var msg struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&msg)
+		return report, fmt.Errorf("server responded with status %d: %s", resp.StatusCode, msg.Message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return report, fmt.Errorf("parsing server response: %w", err)
+	}
+	return report, nil
+}
+
+// LoadUsers imports accounts from JSON (e.g. created by dumpusers command) or CSV file
+func LoadUsers() error {
+	cfg := loadUsersConfig{}
+	if ok, err := parseConfig(&cfg); !ok {
+		return err
+	}
+	path := cfg.Args.Num(0)
 	if path == "" {
 		return fmt.Errorf("missing file argument")
 	}
-	content, err := ioutil.ReadFile(path)
-	if err != nil {
-		return fmt.Errorf("reading project file: %w", err)
+	if cfg.Format == "" {
+		cfg.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
 	}
-	var users []Account
-	if err := json.Unmarshal(content, &users); err != nil {
-		return fmt.Errorf("parsing input file: %w", err)
+	var report application.AccountsImportReport
+	var err error
+	if cfg.Server != "" {
+		report, err = importUsersRemote(cfg, path)
+	} else {
+		if cfg.Invite {
+			return fmt.Errorf("invitation emails can be sent only through the server (--server option)")
+		}
+		var dbConn *sqlx.DB
+		dbConn, err = openDB(cfg.Postgres)
+		if err != nil {
+			return err
+		}
+		defer dbConn.Close()
+		report, err = importUsers(dbConn, cfg, path)
 	}
-	accountsRepo := postgres.NewAccountsRepository(dbConn)
-	for _, u := range users {
-		a := domain.Account{
-			Username:  u.Username,
-			Email:     u.Email,
-			Password:  []byte(u.Password),
-			FirstName: u.FirstName,
-			LastName:  u.LastName,
-			Superuser: u.Superuser,
-			Active:    u.Active,
-			Created:   u.Created,
-			Confirmed: u.Confirmed,
-			LastLogin: u.LastLogin,
-			Profile:   u.Profile,
+	if err != nil {
+		return err
+	}
+	for _, r := range report.Records {
+		for _, msg := range r.Errors {
+			fmt.Fprintf(os.Stderr, "row %d [%s]: %s\n", r.Row, r.Username, msg)
 		}
-		if err := accountsRepo.Create(a); err != nil {
-			fmt.Printf("failed to create account: %s (%s)\n", a.Username, err)
+		if r.Invited {
+			fmt.Printf("row %d [%s]: invitation email sent\n", r.Row, r.Username)
 		}
 	}
+	if report.DryRun {
+		fmt.Printf("%d account(s) would be created, %d updated, %d failed\n", report.Created, report.Updated, report.Failed)
+	} else {
+		fmt.Printf("%d account(s) created, %d updated, %d failed\n", report.Created, report.Updated, report.Failed)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("import of %d account(s) failed", report.Failed)
+	}
 	return nil
 }
 
@@ -206,7 +352,23 @@ func deleteUser(dbConn *sqlx.DB, args conf.Args) error {
 	}
 	username := args.Num(0)
 	accountsRepo := postgres.NewAccountsRepository(dbConn)
-	return accountsRepo.Delete(username)
+	if err := accountsRepo.Delete(username); err != nil {
+		return err
+	}
+	recordEvent(dbConn, domain.AuditAccountDeleted, username)
+	return nil
+}
+
+func resetTwoFactor(dbConn *sqlx.DB, args conf.Args) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Invalid number of arguments")
+	}
+	username := args.Num(0)
+	accountsRepo := postgres.NewAccountsRepository(dbConn)
+	if _, err := accountsRepo.GetByUsername(username); err != nil {
+		return err
+	}
+	return postgres.NewTwoFactorRepository(dbConn).Delete(username)
 }
 
 func AddUser() error {
@@ -217,14 +379,27 @@ func AddSuperuser() error {
 	return runUserCommand(addSuperuser)
 }
 
+// DumpUsers writes all accounts in JSON (including password hashes) or CSV format
 func DumpUsers() error {
-	return runUserCommand(dumpUsers)
-}
-
-func LoadUsers() error {
-	return runUserCommand(loadUsers)
+	cfg := struct {
+		Postgres postgresConfig
+		Format   string `conf:"default:json,flag:format"`
+	}{}
+	if ok, err := parseConfig(&cfg); !ok {
+		return err
+	}
+	dbConn, err := openDB(cfg.Postgres)
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+	return dumpUsers(dbConn, cfg.Format)
 }
 
 func DeleteUser() error {
 	return runUserCommand(deleteUser)
 }
+
+func ResetTwoFactor() error {
+	return runUserCommand(resetTwoFactor)
+}
@@ -16,7 +16,9 @@ func printCommandsList() {
 	fmt.Println("  dumpusers")
 	fmt.Println("  loadusers")
 	fmt.Println("  deleteuser")
+	fmt.Println("  reset2fa")
 	fmt.Println("  migrate")
+	fmt.Println("  settings")
 }
 
 func main() {
@@ -33,6 +35,8 @@ func main() {
 		runCommand(commands.AddUser)
 	case "deleteuser":
 		runCommand(commands.DeleteUser)
+	case "reset2fa":
+		runCommand(commands.ResetTwoFactor)
 	case "addsuperuser":
 		runCommand(commands.AddSuperuser)
 	case "dumpusers":
@@ -43,6 +47,8 @@ func main() {
 		runCommand(commands.Serve)
 	case "migrate":
 		runCommand(commands.Migrate)
+	case "settings":
+		runCommand(commands.Settings)
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", cmd)
 		printCommandsList()
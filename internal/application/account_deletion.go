@@ -0,0 +1,325 @@
+package application
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidTransferTarget = errors.New("Invalid target of projects transfer")
+
+// errDeletionPostponed is returned by deleteAccount when the projects cannot be transferred anymore
+var errDeletionPostponed = errors.New("account deletion postponed")
+
+type AccountDeletionConfig struct {
+	// time between confirmation of the request and deletion of the account
+	GracePeriod time.Duration
+	// interval of checking for accounts to be deleted
+	Interval time.Duration
+}
+
+// AccountDeletionService handles self-service deletion of accounts
+type AccountDeletionService struct {
+	log           *zap.SugaredLogger
+	repo          domain.AccountDeletionsRepository
+	accounts      *AccountsService
+	organizations domain.OrganizationsRepository
+	projects      ProjectService
+	limiter       AccountsLimiter
+	tokenGen      TokenGenerator
+	config        AccountDeletionConfig
+	// ResolveUser returns user of the account (with resolved memberships) for checking permissions
+	// to transfer projects to organization
+	ResolveUser func(account domain.Account) domain.User
+	// called after the account is deleted
+	OnAccountDeleted func(username string)
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewAccountDeletionService(log *zap.SugaredLogger, repo domain.AccountDeletionsRepository, accounts *AccountsService, organizations domain.OrganizationsRepository, projects ProjectService, limiter AccountsLimiter, tokenGen TokenGenerator, config AccountDeletionConfig) *AccountDeletionService {
+	return &AccountDeletionService{
+		log:           log,
+		repo:          repo,
+		accounts:      accounts,
+		organizations: organizations,
+		projects:      projects,
+		limiter:       limiter,
+		tokenGen:      tokenGen,
+		config:        config,
+		ResolveUser:   domain.AccountToUser,
+		stop:          make(chan struct{}),
+	}
+}
+
+// deletionClaims returns claims of the confirmation token. Request time is used with seconds
+// precision, so the claims are the same after loading the request from the database.
+func deletionClaims(d domain.AccountDeletion) string {
+	return fmt.Sprintf("%s:%s:%d", d.Username, d.TransferTo, d.Requested.Unix())
+}
+
+// formatDuration formats duration in days when possible
+func formatDuration(d time.Duration) string {
+	day := 24 * time.Hour
+	if d >= day && d%day == 0 {
+		if d == day {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", d/day)
+	}
+	return d.String()
+}
+
+// checkTransferTarget checks that projects can be transferred into the namespace, i.e. it is
+// an organization, whose projects the user can manage, and it has enough capacity for them.
+// Accounts of other users are not accepted, as they didn't agree with the transfer.
+func (s *AccountDeletionService) checkTransferTarget(account domain.Account, namespace string) error {
+	if namespace == account.Username {
+		return fmt.Errorf("%w: own account", ErrInvalidTransferTarget)
+	}
+	if _, err := s.organizations.Get(namespace); err != nil {
+		if errors.Is(err, domain.ErrOrganizationNotFound) {
+			return fmt.Errorf("%w: unknown organization", ErrInvalidTransferTarget)
+		}
+		return err
+	}
+	if !s.ResolveUser(account).CanManageNamespace(namespace) {
+		return fmt.Errorf("%w: missing permission to manage organization's projects", ErrInvalidTransferTarget)
+	}
+	return s.checkTransferLimits(account.Username, namespace)
+}
+
+// checkTransferLimits checks that projects of the user fit into limits of the target namespace
+func (s *AccountDeletionService) checkTransferLimits(username, namespace string) error {
+	projects, err := s.projects.GetUserProjects(username)
+	if err != nil {
+		return fmt.Errorf("listing projects: %w", err)
+	}
+	if len(projects) == 0 {
+		return nil
+	}
+	limits, err := s.limiter.GetAccountLimits(namespace)
+	if err != nil {
+		return fmt.Errorf("getting limits of %s: %w", namespace, err)
+	}
+	targetProjects, err := s.projects.GetUserProjects(namespace)
+	if err != nil {
+		return fmt.Errorf("listing projects of %s: %w", namespace, err)
+	}
+	if !limits.CheckProjectsLimit(len(targetProjects) + len(projects)) {
+		return fmt.Errorf("%w: projects limit of %s would be exceeded", ErrInvalidTransferTarget, namespace)
+	}
+	var size int64
+	for _, p := range append(targetProjects, projects...) {
+		size += p.Size
+	}
+	if !limits.CheckStorageLimit(size) {
+		return fmt.Errorf("%w: storage limit of %s would be exceeded", ErrInvalidTransferTarget, namespace)
+	}
+	return nil
+}
+
+// checkOrganizations checks that the user is not the last owner of some organization
+func (s *AccountDeletionService) checkOrganizations(username string) error {
+	memberships, err := s.organizations.GetUserMemberships(username)
+	if err != nil {
+		return fmt.Errorf("getting organizations: %w", err)
+	}
+	for org, role := range memberships.Organizations {
+		if role != domain.OrgRoleOwner {
+			continue
+		}
+		members, err := s.organizations.GetMembers(org)
+		if err != nil {
+			return fmt.Errorf("getting organization members: %w", err)
+		}
+		if countOwners(members) == 1 {
+			return fmt.Errorf("%w (%s)", ErrLastOrganizationOwner, org)
+		}
+	}
+	return nil
+}
+
+// Request creates (or replaces) request for deletion of the account and sends email with
+// confirmation link. Projects are transferred to the given organization or deleted
+// when transferTo is empty.
+func (s *AccountDeletionService) Request(account domain.Account, transferTo string) (domain.AccountDeletion, error) {
+	deletion := domain.AccountDeletion{Username: account.Username, TransferTo: transferTo}
+	if account.Email == "" {
+		return deletion, ErrEmailNotSet
+	}
+	if transferTo != "" {
+		if err := s.checkTransferTarget(account, transferTo); err != nil {
+			return deletion, err
+		}
+	}
+	if err := s.checkOrganizations(account.Username); err != nil {
+		return deletion, err
+	}
+	deletion.Requested = time.Now().Truncate(time.Second)
+	token, err := s.tokenGen.GenerateToken(deletionClaims(deletion))
+	if err != nil {
+		return deletion, fmt.Errorf("generating deletion token: %w", err)
+	}
+	if err := s.repo.Save(deletion); err != nil {
+		return deletion, fmt.Errorf("saving deletion request: %w", err)
+	}
+	uid := base64.URLEncoding.EncodeToString([]byte(account.Username))
+	data := map[string]interface{}{
+		"TransferTo":  transferTo,
+		"GracePeriod": formatDuration(s.config.GracePeriod),
+	}
+	if err := s.accounts.Email.SendAccountDeletionEmail(account, uid, token, data); err != nil {
+		return deletion, fmt.Errorf("sending account deletion email [%s]: %w", account.Email, err)
+	}
+	return deletion, nil
+}
+
+// Confirm confirms the deletion request and schedules deletion of the account after the grace period
+func (s *AccountDeletionService) Confirm(uid, token string) (domain.AccountDeletion, error) {
+	username, err := base64.URLEncoding.DecodeString(uid)
+	if err != nil {
+		return domain.AccountDeletion{}, ErrInvalidToken
+	}
+	deletion, err := s.repo.Get(string(username))
+	if err != nil {
+		if errors.Is(err, domain.ErrDeletionNotRequested) {
+			return deletion, ErrInvalidToken
+		}
+		return deletion, err
+	}
+	if err := s.tokenGen.CheckToken(token, deletionClaims(deletion)); err != nil {
+		return deletion, ErrInvalidToken
+	}
+	if deletion.Confirmed != nil {
+		return deletion, nil
+	}
+	now := time.Now()
+	deleteAt := now.Add(s.config.GracePeriod)
+	deletion.Confirmed = &now
+	deletion.DeleteAt = &deleteAt
+	if err := s.repo.Save(deletion); err != nil {
+		return deletion, fmt.Errorf("saving deletion request: %w", err)
+	}
+	return deletion, nil
+}
+
+func (s *AccountDeletionService) Get(username string) (domain.AccountDeletion, error) {
+	return s.repo.Get(username)
+}
+
+// Cancel cancels the deletion request (also the confirmed one)
+func (s *AccountDeletionService) Cancel(username string) error {
+	return s.repo.Delete(username)
+}
+
+// postponeDeletion moves deletion of the account by the grace period and notifies the user,
+// that the projects cannot be transferred to the requested organization
+func (s *AccountDeletionService) postponeDeletion(account domain.Account, deletion domain.AccountDeletion, reason error) error {
+	deleteAt := time.Now().Add(s.config.GracePeriod)
+	deletion.DeleteAt = &deleteAt
+	if err := s.repo.Save(deletion); err != nil {
+		return fmt.Errorf("saving deletion request: %w", err)
+	}
+	data := map[string]interface{}{
+		"TransferTo":  deletion.TransferTo,
+		"Reason":      reason.Error(),
+		"GracePeriod": formatDuration(s.config.GracePeriod),
+	}
+	if err := s.accounts.Email.SendAccountDeletionPostponed(account, data); err != nil {
+		s.log.Errorw("sending account deletion postponed email", "user", account.Username, zap.Error(err))
+	}
+	return fmt.Errorf("%w: %v", errDeletionPostponed, reason)
+}
+
+// deleteAccount transfers or deletes projects of the user and deletes the account. The target
+// of the transfer is checked again, as organization's memberships or limits could change during
+// the grace period.
+func (s *AccountDeletionService) deleteAccount(deletion domain.AccountDeletion) error {
+	if deletion.TransferTo != "" {
+		account, err := s.accounts.Repository.GetByUsername(deletion.Username)
+		if err != nil {
+			return fmt.Errorf("getting account: %w", err)
+		}
+		if err := s.checkTransferTarget(account, deletion.TransferTo); err != nil {
+			if errors.Is(err, ErrInvalidTransferTarget) {
+				return s.postponeDeletion(account, deletion, err)
+			}
+			return fmt.Errorf("checking transfer target: %w", err)
+		}
+	}
+	projects, err := s.projects.GetUserProjects(deletion.Username)
+	if err != nil {
+		return fmt.Errorf("listing projects: %w", err)
+	}
+	for _, p := range projects {
+		if deletion.TransferTo != "" {
+			newName, err := s.projects.Transfer(p.Name, deletion.TransferTo)
+			if err != nil {
+				return fmt.Errorf("transferring project %s: %w", p.Name, err)
+			}
+			s.log.Infow("account deletion: project transferred", "project", p.Name, "new_name", newName)
+		} else if err := s.projects.Delete(p.Name); err != nil {
+			return fmt.Errorf("deleting project %s: %w", p.Name, err)
+		}
+	}
+	if err := s.accounts.Repository.Delete(deletion.Username); err != nil {
+		return fmt.Errorf("deleting account: %w", err)
+	}
+	if s.OnAccountDeleted != nil {
+		s.OnAccountDeleted(deletion.Username)
+	}
+	return nil
+}
+
+// Process deletes accounts, whose deletion was confirmed and the grace period passed
+func (s *AccountDeletionService) Process(now time.Time) error {
+	deletions, err := s.repo.Due(now)
+	if err != nil {
+		return fmt.Errorf("getting due account deletions: %w", err)
+	}
+	for _, d := range deletions {
+		if err := s.deleteAccount(d); err != nil {
+			if errors.Is(err, errDeletionPostponed) {
+				s.log.Warnw("account deletion", "user", d.Username, zap.Error(err))
+				continue
+			}
+			// will be retried in the next run
+			s.log.Errorw("account deletion", "user", d.Username, zap.Error(err))
+			continue
+		}
+		s.log.Infow("account deleted", "user", d.Username)
+	}
+	return nil
+}
+
+// Start starts background processing of account deletions
+func (s *AccountDeletionService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Process(time.Now()); err != nil {
+				s.log.Errorw("account deletions processing", zap.Error(err))
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *AccountDeletionService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
@@ -0,0 +1,219 @@
+package application_test
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/infrastructure/postgres"
+	"github.com/gisquick/gisquick-server/internal/infrastructure/security"
+	"github.com/golang-migrate/migrate/v4"
+	migratepg "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// testDatabase returns connection to the database specified by GISQUICK_TEST_DATABASE_URL
+// environment variable with all migrations applied in a new schema, which is dropped after the test.
+func testDatabase(t *testing.T) *sqlx.DB {
+	dsn := os.Getenv("GISQUICK_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("GISQUICK_TEST_DATABASE_URL is not set")
+	}
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("dropping test schema: %v", err)
+		}
+	})
+
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.RuntimeParams["search_path"] = schema
+	db := sqlx.NewDb(stdlib.OpenDB(*config), "pgx")
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := migratepg.WithInstance(db.DB, &migratepg.Config{SchemaName: schema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://../../migrations", "postgres", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+	return db
+}
+
+type deletionEmails struct {
+	application.EmailService
+	token string
+}
+
+func (e *deletionEmails) SendAccountDeletionEmail(account domain.Account, uid, token string, data map[string]interface{}) error {
+	e.token = token
+	return nil
+}
+
+func TestAccountDeletionTokenRoundTrip(t *testing.T) {
+	db := testDatabase(t)
+	accountsRepo := postgres.NewAccountsRepository(db)
+	account, err := domain.NewAccount("jan", "jan@example.com", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	account.Active = true
+	if err := accountsRepo.Create(account); err != nil {
+		t.Fatal(err)
+	}
+
+	emails := &deletionEmails{}
+	accounts := application.NewAccountsService(emails, accountsRepo, nil)
+	tokens := security.NewTokenGenerator("secret", "account_deletion", time.Hour)
+	repo := postgres.NewAccountDeletionsRepository(db)
+	service := application.NewAccountDeletionService(zap.NewNop().Sugar(), repo, accounts, postgres.NewOrganizationsRepository(db), nil, nil, tokens, application.AccountDeletionConfig{
+		GracePeriod: 24 * time.Hour,
+	})
+
+	requested, err := service.Request(account, "")
+	if err != nil {
+		t.Fatalf("requesting deletion: %v", err)
+	}
+	// token must be valid for the request loaded from the database (timestamp precision)
+	saved, err := repo.Get("jan")
+	if err != nil || !saved.Requested.Equal(requested.Requested) {
+		t.Fatalf("saved request differs: %v != %v (%v)", saved.Requested, requested.Requested, err)
+	}
+	uid := base64.URLEncoding.EncodeToString([]byte("jan"))
+	if _, err := service.Confirm(uid, "invalid"); !errors.Is(err, application.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+	confirmed, err := service.Confirm(uid, emails.token)
+	if err != nil {
+		t.Fatalf("confirming deletion: %v", err)
+	}
+	if confirmed.Confirmed == nil || confirmed.DeleteAt == nil {
+		t.Errorf("deletion was not scheduled: %+v", confirmed)
+	}
+}
+
+type deletionsRepo struct {
+	domain.AccountDeletionsRepository
+	deletions map[string]domain.AccountDeletion
+}
+
+func (r *deletionsRepo) Save(d domain.AccountDeletion) error {
+	r.deletions[d.Username] = d
+	return nil
+}
+
+func (r *deletionsRepo) Due(now time.Time) ([]domain.AccountDeletion, error) {
+	var due []domain.AccountDeletion
+	for _, d := range r.deletions {
+		if d.DeleteAt != nil && !d.DeleteAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+type deletionAccounts struct {
+	domain.AccountsRepository
+	account domain.Account
+	deleted bool
+}
+
+func (r *deletionAccounts) GetByUsername(username string) (domain.Account, error) {
+	return r.account, nil
+}
+
+func (r *deletionAccounts) Delete(username string) error {
+	r.deleted = true
+	return nil
+}
+
+type deletionOrganizations struct {
+	domain.OrganizationsRepository
+}
+
+func (r deletionOrganizations) Get(name string) (domain.Organization, error) {
+	return domain.Organization{}, domain.ErrOrganizationNotFound
+}
+
+type deletionProjects struct {
+	application.ProjectService
+	transferred int
+}
+
+func (p *deletionProjects) GetUserProjects(username string) ([]domain.ProjectInfo, error) {
+	return []domain.ProjectInfo{{Name: username + "/p1"}}, nil
+}
+
+func (p *deletionProjects) Transfer(projectName, namespace string) (string, error) {
+	p.transferred++
+	return namespace + "/p1", nil
+}
+
+type postponedEmails struct {
+	application.EmailService
+	data map[string]interface{}
+}
+
+func (e *postponedEmails) SendAccountDeletionPostponed(account domain.Account, data map[string]interface{}) error {
+	e.data = data
+	return nil
+}
+
+func TestAccountDeletionPostponedWithInvalidTarget(t *testing.T) {
+	account, err := domain.NewAccount("jan", "jan@example.com", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountsRepo := &deletionAccounts{account: account}
+	emails := &postponedEmails{}
+	projects := &deletionProjects{}
+	confirmed := time.Now().Add(-2 * time.Hour)
+	deleteAt := time.Now().Add(-time.Hour)
+	repo := &deletionsRepo{deletions: map[string]domain.AccountDeletion{
+		"jan": {Username: "jan", TransferTo: "gisquick", Requested: confirmed, Confirmed: &confirmed, DeleteAt: &deleteAt},
+	}}
+	service := application.NewAccountDeletionService(zap.NewNop().Sugar(), repo, application.NewAccountsService(emails, accountsRepo, nil), deletionOrganizations{}, projects, nil, nil, application.AccountDeletionConfig{
+		GracePeriod: 24 * time.Hour,
+	})
+
+	now := time.Now()
+	if err := service.Process(now); err != nil {
+		t.Fatal(err)
+	}
+	if accountsRepo.deleted || projects.transferred != 0 {
+		t.Errorf("account was deleted (%v) or projects transferred (%d)", accountsRepo.deleted, projects.transferred)
+	}
+	d, ok := repo.deletions["jan"]
+	if !ok || d.DeleteAt == nil || !d.DeleteAt.After(now) {
+		t.Errorf("deletion was not postponed: %+v", d)
+	}
+	if emails.data == nil || emails.data["TransferTo"] != "gisquick" {
+		t.Errorf("user was not notified: %v", emails.data)
+	}
+}
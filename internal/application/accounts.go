@@ -25,13 +25,24 @@ type TokenGenerator interface {
 type EmailService interface {
 	SendActivationEmail(account domain.Account, uid, token string, data map[string]interface{}) error
 	SendPasswordResetEmail(account domain.Account, uid, token string) error
+	SendAccountDeletionEmail(account domain.Account, uid, token string, data map[string]interface{}) error
+	SendAccountDeletionPostponed(account domain.Account, data map[string]interface{}) error
+	SendEmailChangeEmail(account domain.Account, newEmail, uid, token string) error
+	SendEmailChangeNotice(account domain.Account, newEmail string) error
+	SendProjectInvitationEmail(account domain.Account, invitationID int64, data map[string]interface{}) error
+	ParseTemplates(html, text string) (*htmltemplate.Template, *texttemplate.Template, error)
+	SendEmail(account domain.Account, subject string, htmlTemplate *htmltemplate.Template, textTemplate *texttemplate.Template, data map[string]interface{}) error
 	SendBulkEmail(accounts []domain.Account, subject string, htmlTemplate *htmltemplate.Template, textTemplate *texttemplate.Template, data map[string]interface{}) error
 }
 
 type AccountsService struct {
 	Repository domain.AccountsRepository
 	Email      EmailService
-	tokenGen   TokenGenerator
+	// policy applied to all new passwords
+	PasswordPolicy domain.PasswordPolicy
+	// called after the account is activated by the user (activation link or initial password)
+	OnAccountActivated func(account domain.Account)
+	tokenGen           TokenGenerator
 }
 
 func NewAccountsService(email EmailService, accountsRepo domain.AccountsRepository, tokenGen TokenGenerator) *AccountsService {
@@ -55,11 +66,36 @@ func accountClaims(account domain.Account) string {
 	return fmt.Sprintf("%s:%s:%s:%s", account.Username, account.Email, string(account.Password), account.LastLogin)
 }
 
-func (s *AccountsService) NewAccount(username, email, firstName, lastName, password string) (domain.Account, error) {
-	account, err := domain.NewAccount(username, email, firstName, lastName, password)
+// SetPassword validates the new password against the password policy and sets it to the account.
+// Returns *domain.PasswordPolicyError when the password doesn't satisfy the policy.
+func (s *AccountsService) SetPassword(account *domain.Account, password string) error {
+	if err := s.PasswordPolicy.Validate(password, *account); err != nil {
+		return err
+	}
+	return account.SetPassword(password)
+}
+
+// CreateAccount creates a new account, validating its password (if set) against the password policy
+func (s *AccountsService) CreateAccount(username, email, firstName, lastName, password string) (domain.Account, error) {
+	account, err := domain.NewAccount(username, email, firstName, lastName, "")
+	if err != nil {
+		return account, err
+	}
+	if password != "" {
+		if err := s.SetPassword(&account, password); err != nil {
+			return account, err
+		}
+	}
+	return account, nil
+}
+
+// NewAccount creates and saves a new account and sends activation email to inactive accounts
+func (s *AccountsService) NewAccount(username, email, firstName, lastName, password string, profile domain.Profile) (domain.Account, error) {
+	account, err := s.CreateAccount(username, email, firstName, lastName, password)
 	if err != nil {
 		return account, err
 	}
+	account.Profile = profile
 	if err := s.Repository.Create(account); err != nil {
 		return account, err
 	}
@@ -70,7 +106,7 @@ func (s *AccountsService) NewAccount(username, email, firstName, lastName, passw
 			return account, err
 		}
 		if err := s.Email.SendActivationEmail(account, uid, token, nil); err != nil {
-			// TODO: should we delete account, or implement re-sending?
+			// emails are usually only enqueued, so this fails when the message couldn't be composed or stored
 			return account, fmt.Errorf("sending registration email [%s]: %w", email, err)
 		}
 	}
@@ -112,7 +148,17 @@ func (s *AccountsService) Activate(uid, token string) error {
 	if err := account.Activate(); err != nil {
 		return err
 	}
-	return s.Repository.Update(account)
+	if err := s.Repository.Update(account); err != nil {
+		return err
+	}
+	s.accountActivated(account)
+	return nil
+}
+
+func (s *AccountsService) accountActivated(account domain.Account) {
+	if s.OnAccountActivated != nil {
+		s.OnAccountActivated(account)
+	}
 }
 
 func (s *AccountsService) RequestPasswordReset(email string) error {
@@ -147,15 +193,85 @@ func (s *AccountsService) SetNewPassword(uid, token, newPassword string) error {
 	if err := s.tokenGen.CheckToken(token, accountClaims(account)); err != nil {
 		return ErrInvalidToken
 	}
-	if err := account.SetPassword(newPassword); err != nil {
+	if err := s.SetPassword(&account, newPassword); err != nil {
 		return fmt.Errorf("set new password: %w", err)
 	}
+	activated := false
 	if !account.Active {
 		if err := account.Activate(); err != nil {
 			return fmt.Errorf("activating account: %w", err)
 		}
+		activated = true
+	}
+	if err := s.Repository.Update(account); err != nil {
+		return err
+	}
+	if activated {
+		s.accountActivated(account)
+	}
+	return nil
+}
+
+func emailChangeClaims(account domain.Account, newEmail string) string {
+	return fmt.Sprintf("email-change:%s:%s:%s:%s", account.Username, account.Email, newEmail, string(account.Password))
+}
+
+// RequestEmailChange sends link for confirmation of the new email address to that address and
+// a notice to the current one. The address is changed only after the confirmation.
+func (s *AccountsService) RequestEmailChange(account domain.Account, newEmail string) error {
+	newEmail, err := domain.NormalizeEmail(newEmail)
+	if err != nil {
+		return err
+	}
+	if newEmail == account.Email {
+		return fmt.Errorf("%w: address is not changed", domain.ErrInvalidEmail)
+	}
+	exists, err := s.Repository.EmailExists(newEmail)
+	if err != nil {
+		return fmt.Errorf("checking email address: %w", err)
+	}
+	if exists {
+		return domain.ErrEmailExists
+	}
+	uid := base64.URLEncoding.EncodeToString([]byte(account.Username))
+	token, err := s.tokenGen.GenerateToken(emailChangeClaims(account, newEmail))
+	if err != nil {
+		return fmt.Errorf("generating email change token: %w", err)
+	}
+	if err := s.Email.SendEmailChangeEmail(account, newEmail, uid, token); err != nil {
+		return fmt.Errorf("sending email change email [%s]: %w", newEmail, err)
+	}
+	if account.Email != "" {
+		if err := s.Email.SendEmailChangeNotice(account, newEmail); err != nil {
+			return fmt.Errorf("sending email change notice [%s]: %w", account.Email, err)
+		}
+	}
+	return nil
+}
+
+// ConfirmEmailChange sets the confirmed email address to the account. Token is valid only until
+// the email address or password of the account is changed. Note that the change also invalidates
+// outstanding activation and password reset tokens (sent to the previous address).
+func (s *AccountsService) ConfirmEmailChange(uid, newEmail, token string) (domain.Account, error) {
+	username, err := base64.URLEncoding.DecodeString(uid)
+	if err != nil {
+		return domain.Account{}, ErrInvalidToken
+	}
+	account, err := s.Repository.GetByUsername(string(username))
+	if err != nil {
+		if errors.Is(err, domain.ErrAccountNotFound) {
+			return account, ErrInvalidToken
+		}
+		return account, fmt.Errorf("confirm email change %s: %w", username, err)
+	}
+	if err := s.tokenGen.CheckToken(token, emailChangeClaims(account, newEmail)); err != nil {
+		return account, ErrInvalidToken
+	}
+	account.Email = newEmail
+	if err := s.Repository.Update(account); err != nil {
+		return account, err
 	}
-	return s.Repository.Update(account)
+	return account, nil
 }
 
 func (s *AccountsService) GetActiveAccounts() ([]domain.Account, error) {
@@ -166,6 +282,11 @@ func (s *AccountsService) GetAllAccounts() ([]domain.Account, error) {
 	return s.Repository.GetAllAccounts()
 }
 
+// QueryAccounts returns page of accounts matching the query and total count of matching accounts
+func (s *AccountsService) QueryAccounts(query domain.AccountsQuery) ([]domain.Account, int, error) {
+	return s.Repository.QueryAccounts(query)
+}
+
 func (s *AccountsService) SupportEmails() bool {
 	return s.Email != nil
 }
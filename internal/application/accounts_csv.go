@@ -0,0 +1,55 @@
+package application
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+)
+
+// Columns of accounts in CSV format
+var AccountsCSVColumns = []string{"username", "email", "first_name", "last_name", "active", "superuser", "created_at", "confirmed_at", "last_login_at"}
+
+func formatCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// csvValue neutralizes values, which would be interpreted as formulas by spreadsheet applications
+func csvValue(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+	return value
+}
+
+// WriteAccountsCSV writes accounts in CSV format (with header)
+func WriteAccountsCSV(w io.Writer, accounts []domain.Account) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(AccountsCSVColumns); err != nil {
+		return err
+	}
+	for _, a := range accounts {
+		record := []string{
+			csvValue(a.Username),
+			csvValue(a.Email),
+			csvValue(a.FirstName),
+			csvValue(a.LastName),
+			strconv.FormatBool(a.Active),
+			strconv.FormatBool(a.Superuser),
+			formatCSVTime(a.Created),
+			formatCSVTime(a.Confirmed),
+			formatCSVTime(a.LastLogin),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
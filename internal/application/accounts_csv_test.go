@@ -0,0 +1,36 @@
+package application_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+)
+
+func TestWriteAccountsCSVFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	accounts := []domain.Account{
+		{Username: "jan", Email: "jan@example.com", FirstName: "=HYPERLINK(\"http://example.com\")", LastName: "+1"},
+		{Username: "eva", Email: "@eva", FirstName: "-2", LastName: "Novak-Smith"},
+	}
+	if err := application.WriteAccountsCSV(&buf, accounts); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"jan", "jan@example.com", "'=HYPERLINK(\"http://example.com\")", "'+1"},
+		{"eva", "'@eva", "'-2", "Novak-Smith"},
+	}
+	for i, row := range expected {
+		for j, value := range row {
+			if records[i+1][j] != value {
+				t.Errorf("row %d, column %d = %q, want %q", i, j, records[i+1][j], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,475 @@
+package application
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+)
+
+var ErrInvalidImport = errors.New("Invalid import data")
+
+// Fields of accounts supported by import. Password field contains password hash (bcrypt or
+// Django's pbkdf2_sha256), as exported by 'dumpusers' command.
+var AccountsImportFields = domain.Flags{"username", "email", "first_name", "last_name", "active", "superuser", "password", "created_at", "confirmed_at", "last_login_at", "profile"}
+
+// alternative names of fields used in JSON dumps of accounts
+var accountsImportAliases = map[string]string{
+	"is_active":    "active",
+	"is_superuser": "superuser",
+}
+
+// Import actions of records
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+	ImportSkip   = "skip"
+)
+
+// ImportMapping maps columns (or keys) of the input data to account fields, columns mapped
+// to an empty string are ignored. Columns without mapping are used when they match name
+// of an account field.
+type ImportMapping map[string]string
+
+// ParseImportMapping parses mapping from list of "column:field" items
+func ParseImportMapping(items []string) (ImportMapping, error) {
+	mapping := make(ImportMapping, len(items))
+	for _, item := range items {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		i := strings.LastIndex(item, ":")
+		if i == -1 {
+			return nil, fmt.Errorf("%w: invalid mapping '%s'", ErrInvalidImport, item)
+		}
+		column, field := strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+		if field != "" && !AccountsImportFields.Has(field) {
+			return nil, fmt.Errorf("%w: unknown field '%s'", ErrInvalidImport, field)
+		}
+		mapping[column] = field
+	}
+	return mapping, nil
+}
+
+// field returns account field of the input column
+func (m ImportMapping) field(column string) (string, bool) {
+	if field, ok := m[column]; ok {
+		return field, field != ""
+	}
+	if field, ok := accountsImportAliases[column]; ok {
+		return field, true
+	}
+	return column, AccountsImportFields.Has(column)
+}
+
+// AccountRecord contains values of account fields of a single imported record
+type AccountRecord struct {
+	// number of the record in the input data (starting from 1)
+	Row    int
+	Values map[string]interface{}
+}
+
+func (m ImportMapping) record(row int, data map[string]interface{}) AccountRecord {
+	rec := AccountRecord{Row: row, Values: make(map[string]interface{}, len(data))}
+	for column, value := range data {
+		if field, ok := m.field(column); ok {
+			rec.Values[field] = value
+		}
+	}
+	return rec
+}
+
+// ReadAccountsCSV reads accounts from CSV data with header
+func ReadAccountsCSV(r io.Reader, mapping ImportMapping) ([]AccountRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: missing header", ErrInvalidImport)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err)
+	}
+	if len(header) > 0 {
+		// byte order mark (e.g. files saved by spreadsheet applications)
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	for column := range mapping {
+		if !contains(header, column) {
+			return nil, fmt.Errorf("%w: column '%s' not found", ErrInvalidImport, column)
+		}
+	}
+	hasUsername := false
+	for _, column := range header {
+		if field, ok := mapping.field(column); ok && field == "username" {
+			hasUsername = true
+		}
+	}
+	if !hasUsername {
+		return nil, fmt.Errorf("%w: missing username column", ErrInvalidImport)
+	}
+	var records []AccountRecord
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err)
+		}
+		data := make(map[string]interface{}, len(row))
+		for i, value := range row {
+			data[header[i]] = value
+		}
+		records = append(records, mapping.record(len(records)+1, data))
+	}
+	return records, nil
+}
+
+// ReadAccountsJSON reads accounts from JSON array of objects
+func ReadAccountsJSON(r io.Reader, mapping ImportMapping) ([]AccountRecord, error) {
+	var data []map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err)
+	}
+	records := make([]AccountRecord, len(data))
+	for i, d := range data {
+		records[i] = mapping.record(i+1, d)
+	}
+	return records, nil
+}
+
+// ReadAccounts reads accounts in the given format (csv or json)
+func ReadAccounts(r io.Reader, format string, mapping ImportMapping) ([]AccountRecord, error) {
+	switch format {
+	case "csv":
+		return ReadAccountsCSV(r, mapping)
+	case "json":
+		return ReadAccountsJSON(r, mapping)
+	}
+	return nil, fmt.Errorf("%w: unsupported format '%s'", ErrInvalidImport, format)
+}
+
+// importedAccount holds validated values of the record, nil values are not set
+type importedAccount struct {
+	username  string
+	email     *string
+	firstName *string
+	lastName  *string
+	active    *bool
+	superuser *bool
+	password  *string
+	created   *time.Time
+	confirmed *time.Time
+	lastLogin *time.Time
+	profile   domain.Profile
+}
+
+// stringValue returns trimmed string value of the field, empty values are considered as not set
+func stringValue(rec AccountRecord, field string) (*string, error) {
+	var s string
+	switch v := rec.Values[field].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		s = strings.TrimSpace(v)
+	case float64, bool:
+		s = fmt.Sprint(v)
+	default:
+		return nil, fmt.Errorf("invalid value of %s", field)
+	}
+	if s == "" {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func boolValue(rec AccountRecord, field string) (*bool, error) {
+	if v, ok := rec.Values[field].(bool); ok {
+		return &v, nil
+	}
+	s, err := stringValue(rec, field)
+	if s == nil || err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(*s) {
+	case "true", "1", "yes", "y":
+		v := true
+		return &v, nil
+	case "false", "0", "no", "n":
+		v := false
+		return &v, nil
+	}
+	return nil, fmt.Errorf("invalid value of %s: '%s'", field, *s)
+}
+
+func timeValue(rec AccountRecord, field string) (*time.Time, error) {
+	s, err := stringValue(rec, field)
+	if s == nil || err != nil {
+		return nil, err
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, *s); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid value of %s: '%s'", field, *s)
+}
+
+func profileValue(rec AccountRecord) (domain.Profile, error) {
+	switch v := rec.Values["profile"].(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return v, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		var profile domain.Profile
+		if err := json.Unmarshal([]byte(v), &profile); err != nil {
+			return nil, errors.New("invalid value of profile: JSON object expected")
+		}
+		return profile, nil
+	}
+	return nil, errors.New("invalid value of profile: JSON object expected")
+}
+
+func validPasswordHash(hash string) bool {
+	return strings.HasPrefix(hash, "pbkdf2_sha256$") || strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// parseRecord converts values of the record, returns all found errors
+func parseRecord(rec AccountRecord) (importedAccount, []string) {
+	var a importedAccount
+	var errs []string
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	username, err := stringValue(rec, "username")
+	check(err)
+	if username == nil {
+		errs = append(errs, "missing username")
+	} else {
+		a.username = *username
+		check(domain.ValidateUsername(a.username))
+	}
+	a.email, err = stringValue(rec, "email")
+	check(err)
+	if a.email != nil {
+		email, err := domain.NormalizeEmail(*a.email)
+		check(err)
+		a.email = &email
+	}
+	a.firstName, err = stringValue(rec, "first_name")
+	check(err)
+	a.lastName, err = stringValue(rec, "last_name")
+	check(err)
+	a.active, err = boolValue(rec, "active")
+	check(err)
+	a.superuser, err = boolValue(rec, "superuser")
+	check(err)
+	a.password, err = stringValue(rec, "password")
+	check(err)
+	if a.password != nil && !validPasswordHash(*a.password) {
+		errs = append(errs, "unsupported password hash")
+	}
+	a.created, err = timeValue(rec, "created_at")
+	check(err)
+	a.confirmed, err = timeValue(rec, "confirmed_at")
+	check(err)
+	a.lastLogin, err = timeValue(rec, "last_login_at")
+	check(err)
+	a.profile, err = profileValue(rec)
+	check(err)
+	return a, errs
+}
+
+// apply sets imported values to the account
+func (a importedAccount) apply(account *domain.Account) {
+	if a.email != nil {
+		account.Email = *a.email
+	}
+	if a.firstName != nil {
+		account.FirstName = *a.firstName
+	}
+	if a.lastName != nil {
+		account.LastName = *a.lastName
+	}
+	if a.superuser != nil {
+		account.Superuser = *a.superuser
+	}
+	if a.password != nil && *a.password != string(account.Password) {
+		account.Password = []byte(*a.password)
+		now := time.Now()
+		account.PasswordChanged = &now
+	}
+	if a.confirmed != nil {
+		account.Confirmed = a.confirmed
+	}
+	if a.active != nil {
+		account.Active = *a.active
+		if account.Active && account.Confirmed == nil {
+			now := time.Now()
+			account.Confirmed = &now
+		}
+	}
+	if a.lastLogin != nil {
+		account.LastLogin = a.lastLogin
+	}
+	if a.profile != nil {
+		account.Profile = a.profile
+	}
+}
+
+type AccountsImportOptions struct {
+	// records are only validated, no changes are saved
+	DryRun bool
+	// send invitation (activation) emails to the new inactive accounts without password
+	SendInvitations bool
+}
+
+// AccountImportResult is a result of import of a single record
+type AccountImportResult struct {
+	Row      int      `json:"row"`
+	Username string   `json:"username"`
+	Action   string   `json:"action"`
+	Errors   []string `json:"errors,omitempty"`
+	Invited  bool     `json:"invited,omitempty"`
+}
+
+// AccountsImportReport summarizes import of accounts (or validation in dry-run mode)
+type AccountsImportReport struct {
+	DryRun  bool                  `json:"dry_run"`
+	Created int                   `json:"created"`
+	Updated int                   `json:"updated"`
+	Failed  int                   `json:"failed"`
+	Records []AccountImportResult `json:"records"`
+}
+
+// ImportAccounts creates new accounts and updates existing accounts (matched by username) with
+// imported values. Invalid records (e.g. with invalid username or with duplicate username
+// or email address) are skipped and reported. Records of saved accounts can still contain
+// errors of subsequent steps (profile update, invitation email).
+func (s *AccountsService) ImportAccounts(records []AccountRecord, opts AccountsImportOptions) (AccountsImportReport, error) {
+	report := AccountsImportReport{DryRun: opts.DryRun, Records: make([]AccountImportResult, len(records))}
+	if opts.SendInvitations && !s.SupportEmails() {
+		return report, errors.New("sending of emails is not supported")
+	}
+	imported := make([]importedAccount, len(records))
+	usernames := make(map[string]int)
+	emails := make(map[string]int)
+	for i, rec := range records {
+		a, errs := parseRecord(rec)
+		if a.username != "" {
+			if row, ok := usernames[a.username]; ok {
+				errs = append(errs, fmt.Sprintf("duplicate username (row %d)", row))
+			} else {
+				usernames[a.username] = rec.Row
+			}
+		}
+		if a.email != nil {
+			if row, ok := emails[*a.email]; ok {
+				errs = append(errs, fmt.Sprintf("duplicate email (row %d)", row))
+			} else {
+				emails[*a.email] = rec.Row
+			}
+		}
+		imported[i] = a
+		report.Records[i] = AccountImportResult{Row: rec.Row, Username: a.username, Errors: errs}
+	}
+
+	for i, a := range imported {
+		res := &report.Records[i]
+		if len(res.Errors) == 0 {
+			s.importAccount(a, res, opts)
+		}
+		switch res.Action {
+		case ImportCreate:
+			report.Created++
+		case ImportUpdate:
+			report.Updated++
+		default:
+			res.Action = ImportSkip
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+func (s *AccountsService) importAccount(a importedAccount, res *AccountImportResult, opts AccountsImportOptions) {
+	fail := func(msg string, err error) {
+		if err != nil {
+			msg = fmt.Sprintf("%s: %s", msg, err)
+		}
+		res.Errors = append(res.Errors, msg)
+	}
+	account, err := s.Repository.GetByUsername(a.username)
+	if err != nil && !errors.Is(err, domain.ErrAccountNotFound) {
+		fail("reading account", err)
+		return
+	}
+	exists := err == nil
+	if a.email != nil && *a.email != account.Email {
+		other, err := s.Repository.GetByEmail(*a.email)
+		if err == nil && other.Username != a.username {
+			fail(domain.ErrEmailExists.Error(), nil)
+			return
+		}
+		if err != nil && !errors.Is(err, domain.ErrAccountNotFound) {
+			fail("checking email address", err)
+			return
+		}
+	}
+	if exists {
+		if opts.DryRun {
+			res.Action = ImportUpdate
+			return
+		}
+		a.apply(&account)
+		if err := s.Repository.Update(account); err != nil {
+			fail("updating account", err)
+			return
+		}
+		res.Action = ImportUpdate
+		if a.profile != nil {
+			if err := s.Repository.UpdateProfile(account); err != nil {
+				fail("updating profile", err)
+			}
+		}
+		return
+	}
+
+	if opts.DryRun {
+		res.Action = ImportCreate
+		return
+	}
+	account, err = domain.NewAccount(a.username, "", "", "", "")
+	if err != nil {
+		fail("creating account", err)
+		return
+	}
+	if a.created != nil {
+		account.Created = a.created
+	}
+	a.apply(&account)
+	if err := s.Repository.Create(account); err != nil {
+		fail("creating account", err)
+		return
+	}
+	res.Action = ImportCreate
+	if opts.SendInvitations && !account.Active && account.Email != "" && len(account.Password) == 0 {
+		if err := s.SendActivationEmail(account, nil); err != nil {
+			fail("sending invitation email", err)
+			return
+		}
+		res.Invited = true
+	}
+}
@@ -0,0 +1,95 @@
+package application
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+type AuditConfig struct {
+	// events older than retention period are deleted (kept forever when zero)
+	Retention time.Duration
+	// interval of deleting of old events
+	Interval time.Duration
+}
+
+// AuditService records security events of accounts
+type AuditService struct {
+	log    *zap.SugaredLogger
+	repo   domain.AuditRepository
+	config AuditConfig
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewAuditService(log *zap.SugaredLogger, repo domain.AuditRepository, config AuditConfig) *AuditService {
+	return &AuditService{
+		log:    log,
+		repo:   repo,
+		config: config,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Record saves the event, failures are only logged, so they don't interrupt the audited action
+func (s *AuditService) Record(event domain.AuditEvent) {
+	if event.Created.IsZero() {
+		event.Created = time.Now().UTC()
+	}
+	if err := s.repo.Create(event); err != nil {
+		s.log.Errorw("saving audit event", "type", event.Type, "user", event.Username, zap.Error(err))
+	}
+}
+
+func (s *AuditService) Query(query domain.AuditQuery) ([]domain.AuditEvent, int, error) {
+	return s.repo.Query(query)
+}
+
+// UserEvents returns the most recent events of the user
+func (s *AuditService) UserEvents(username string, limit int) ([]domain.AuditEvent, error) {
+	events, _, err := s.repo.Query(domain.AuditQuery{Username: username, Limit: limit})
+	return events, err
+}
+
+// Cleanup deletes events older than the retention period
+func (s *AuditService) Cleanup(now time.Time) error {
+	if s.config.Retention <= 0 {
+		return nil
+	}
+	n, err := s.repo.DeleteBefore(now.Add(-s.config.Retention))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		s.log.Infow("deleted old audit events", "count", n)
+	}
+	return nil
+}
+
+// Start starts background deletion of old events
+func (s *AuditService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Cleanup(time.Now()); err != nil {
+				s.log.Errorw("deleting old audit events", zap.Error(err))
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *AuditService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
@@ -0,0 +1,380 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrCampaignNotEditable = errors.New("Campaign can be modified only as a draft")
+	ErrCampaignState       = errors.New("Operation not allowed in the current state of campaign")
+	ErrInvalidTemplate     = errors.New("Invalid email template")
+)
+
+// time for which recipients are claimed for sending by one instance
+const campaignClaimLock = 15 * time.Minute
+
+type CampaignsConfig struct {
+	// maximal number of emails sent per interval
+	BatchSize int
+	Interval  time.Duration
+}
+
+// CampaignsService manages email campaigns and sends them in throttled batches in the background
+type CampaignsService struct {
+	log      *zap.SugaredLogger
+	repo     domain.CampaignsRepository
+	accounts domain.AccountsRepository
+	projects ProjectService
+	email    EmailService
+	config   CampaignsConfig
+	// ResolveUser returns user of the account (with resolved memberships) for evaluation of project access
+	ResolveUser func(account domain.Account) domain.User
+
+	// serializes processing of campaigns and state changes within the instance, multiple
+	// instances are coordinated by the repository (StartCampaign, ClaimRecipients)
+	mu   sync.Mutex
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewCampaignsService(log *zap.SugaredLogger, repo domain.CampaignsRepository, accounts domain.AccountsRepository, projects ProjectService, email EmailService, config CampaignsConfig) *CampaignsService {
+	return &CampaignsService{
+		log:         log,
+		repo:        repo,
+		accounts:    accounts,
+		projects:    projects,
+		email:       email,
+		config:      config,
+		ResolveUser: domain.AccountToUser,
+		stop:        make(chan struct{}),
+	}
+}
+
+func (s *CampaignsService) validate(campaign domain.Campaign) error {
+	if err := campaign.Filter.Validate(); err != nil {
+		return err
+	}
+	if campaign.HtmlTemplate == "" && campaign.TextTemplate == "" {
+		return fmt.Errorf("%w: template not specified", ErrInvalidTemplate)
+	}
+	if _, _, err := s.email.ParseTemplates(campaign.HtmlTemplate, campaign.TextTemplate); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+	}
+	return nil
+}
+
+// Create creates a new draft campaign
+func (s *CampaignsService) Create(campaign domain.Campaign, author string) (domain.Campaign, error) {
+	if err := s.validate(campaign); err != nil {
+		return campaign, err
+	}
+	now := time.Now()
+	campaign.Status = domain.CampaignDraft
+	campaign.CreatedBy = author
+	campaign.Created = &now
+	campaign.SendAt = nil
+	return s.repo.Create(campaign)
+}
+
+// Update updates content and recipients filter of the draft campaign
+func (s *CampaignsService) Update(campaign domain.Campaign) (domain.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.repo.Get(campaign.ID)
+	if err != nil {
+		return campaign, err
+	}
+	if current.Status != domain.CampaignDraft {
+		return current, ErrCampaignNotEditable
+	}
+	if err := s.validate(campaign); err != nil {
+		return current, err
+	}
+	current.Subject = campaign.Subject
+	current.HtmlTemplate = campaign.HtmlTemplate
+	current.TextTemplate = campaign.TextTemplate
+	current.Style = campaign.Style
+	current.Filter = campaign.Filter
+	return current, s.repo.Update(current)
+}
+
+func (s *CampaignsService) Get(id int64) (domain.Campaign, error) {
+	return s.repo.Get(id)
+}
+
+func (s *CampaignsService) List() ([]domain.Campaign, error) {
+	return s.repo.List()
+}
+
+func (s *CampaignsService) GetRecipients(id int64, status string, limit, offset int) ([]domain.CampaignRecipient, error) {
+	if _, err := s.repo.Get(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRecipients(id, status, limit, offset)
+}
+
+// Delete deletes campaign, which is not being sent
+func (s *CampaignsService) Delete(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	campaign, err := s.repo.Get(id)
+	if err != nil {
+		return err
+	}
+	if campaign.Status == domain.CampaignSending {
+		return ErrCampaignState
+	}
+	return s.repo.Delete(id)
+}
+
+// Schedule schedules the draft campaign to be sent at the given time (immediately when nil)
+func (s *CampaignsService) Schedule(id int64, sendAt *time.Time) (domain.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	campaign, err := s.repo.Get(id)
+	if err != nil {
+		return campaign, err
+	}
+	if campaign.Status != domain.CampaignDraft {
+		return campaign, ErrCampaignState
+	}
+	if sendAt == nil {
+		now := time.Now()
+		sendAt = &now
+	}
+	campaign.Status = domain.CampaignScheduled
+	campaign.SendAt = sendAt
+	if err := s.repo.Update(campaign); err != nil {
+		return campaign, err
+	}
+	return campaign, nil
+}
+
+// Cancel returns scheduled campaign back to draft or stops sending of the campaign
+func (s *CampaignsService) Cancel(id int64) (domain.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	campaign, err := s.repo.Get(id)
+	if err != nil {
+		return campaign, err
+	}
+	switch campaign.Status {
+	case domain.CampaignScheduled:
+		campaign.Status = domain.CampaignDraft
+		campaign.SendAt = nil
+	case domain.CampaignSending:
+		now := time.Now()
+		campaign.Status = domain.CampaignCancelled
+		campaign.Finished = &now
+	default:
+		return campaign, ErrCampaignState
+	}
+	return campaign, s.repo.Update(campaign)
+}
+
+// hasProjectAccess checks whether the user has access to the project by its own authentication
+// settings (superusers are not included implicitly)
+func hasProjectAccess(user domain.User, projectName string, settings domain.ProjectSettings) bool {
+	namespace := strings.Split(projectName, "/")[0]
+	if user.IsNamespaceMember(namespace) || user.InList(settings.SettingsAuth.AdminUsers) {
+		return true
+	}
+	switch settings.Auth.Type {
+	case "public", "authenticated":
+		return true
+	case "users":
+		return user.InList(settings.Auth.Users)
+	}
+	return false
+}
+
+// Recipients returns recipients matching the filter (accounts without email are skipped)
+func (s *CampaignsService) Recipients(filter domain.RecipientsFilter) ([]domain.CampaignRecipient, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	var accounts []domain.Account
+	if len(filter.Users) > 0 {
+		for _, username := range filter.Users {
+			account, err := s.accounts.GetByUsername(username)
+			if err != nil {
+				if errors.Is(err, domain.ErrAccountNotFound) {
+					return nil, fmt.Errorf("%w: unknown user %s", domain.ErrInvalidFilter, username)
+				}
+				return nil, err
+			}
+			accounts = append(accounts, account)
+		}
+	} else {
+		var err error
+		if accounts, err = s.accounts.GetAllAccounts(); err != nil {
+			return nil, fmt.Errorf("querying accounts: %w", err)
+		}
+	}
+	var settings domain.ProjectSettings
+	if filter.Project != "" {
+		if _, err := s.projects.GetProjectInfo(filter.Project); err != nil {
+			if errors.Is(err, domain.ErrProjectNotExists) {
+				return nil, fmt.Errorf("%w: unknown project %s", domain.ErrInvalidFilter, filter.Project)
+			}
+			return nil, fmt.Errorf("reading project info: %w", err)
+		}
+		var err error
+		if settings, err = s.projects.GetSettings(filter.Project); err != nil {
+			return nil, fmt.Errorf("reading project settings: %w", err)
+		}
+	}
+	recipients := []domain.CampaignRecipient{}
+	for _, a := range accounts {
+		if a.Email == "" || (filter.Active && !a.Active) || !filter.MatchProfile(a.Profile) {
+			continue
+		}
+		if filter.Project != "" && !hasProjectAccess(s.ResolveUser(a), filter.Project, settings) {
+			continue
+		}
+		recipients = append(recipients, domain.CampaignRecipient{Username: a.Username, Email: a.Email, Status: domain.RecipientPending})
+	}
+	return recipients, nil
+}
+
+// start resolves recipients of the scheduled campaign and starts its sending. Returns false
+// when the campaign was started by another instance in the meantime.
+func (s *CampaignsService) start(campaign *domain.Campaign) (bool, error) {
+	recipients, err := s.Recipients(campaign.Filter)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	started, err := s.repo.StartCampaign(campaign.ID, now, recipients)
+	if err != nil {
+		return false, fmt.Errorf("saving recipients: %w", err)
+	}
+	if started {
+		campaign.Status = domain.CampaignSending
+		campaign.Started = &now
+		campaign.Stats.Total = len(recipients)
+		campaign.Stats.Pending = len(recipients)
+	}
+	return started, nil
+}
+
+// finish completes the campaign, unless some recipients are still pending (claimed by another instance)
+func (s *CampaignsService) finish(campaign *domain.Campaign) error {
+	_, err := s.repo.FinishCampaign(campaign.ID, time.Now())
+	return err
+}
+
+// sendBatch sends email to the next batch of pending recipients, returns number of sent emails
+func (s *CampaignsService) sendBatch(campaign *domain.Campaign, limit int) (int, error) {
+	recipients, err := s.repo.ClaimRecipients(campaign.ID, time.Now(), limit, campaignClaimLock)
+	if err != nil {
+		return 0, fmt.Errorf("claiming pending recipients: %w", err)
+	}
+	if len(recipients) == 0 {
+		return 0, s.finish(campaign)
+	}
+	htmlTemplate, textTemplate, err := s.email.ParseTemplates(campaign.HtmlTemplate, campaign.TextTemplate)
+	if err != nil {
+		return 0, err
+	}
+	data := map[string]interface{}{
+		"Style": htmltemplate.CSS(campaign.Style),
+	}
+	for _, r := range recipients {
+		account, err := s.accounts.GetByUsername(r.Username)
+		if err == nil {
+			// send to the address resolved when the campaign started
+			account.Email = r.Email
+			err = s.email.SendEmail(account, campaign.Subject, htmlTemplate, textTemplate, data)
+		}
+		if err == nil {
+			now := time.Now()
+			r.Sent = &now
+			r.Status = domain.RecipientSent
+		} else {
+			r.Status = domain.RecipientFailed
+			r.Error = err.Error()
+			s.log.Warnw("campaign email", "campaign", campaign.ID, "user", r.Username, zap.Error(err))
+		}
+		if err := s.repo.SetRecipientStatus(campaign.ID, r); err != nil {
+			return 0, fmt.Errorf("saving recipient status: %w", err)
+		}
+	}
+	if len(recipients) < limit {
+		return len(recipients), s.finish(campaign)
+	}
+	return len(recipients), nil
+}
+
+// Process starts due scheduled campaigns and sends the next batch of emails
+func (s *CampaignsService) Process(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	campaigns, err := s.repo.DueCampaigns(now)
+	if err != nil {
+		return fmt.Errorf("getting due campaigns: %w", err)
+	}
+	remaining := s.config.BatchSize
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		if campaign.Status == domain.CampaignScheduled {
+			started, err := s.start(campaign)
+			if err != nil {
+				s.log.Errorw("starting campaign", "campaign", campaign.ID, zap.Error(err))
+				// return back to drafts to not retry invalid campaign forever
+				campaign.Status = domain.CampaignDraft
+				campaign.SendAt = nil
+				if err := s.repo.Update(*campaign); err != nil {
+					return err
+				}
+				continue
+			}
+			if !started {
+				continue
+			}
+		}
+		if remaining <= 0 {
+			continue
+		}
+		n, err := s.sendBatch(campaign, remaining)
+		if err != nil {
+			s.log.Errorw("sending campaign", "campaign", campaign.ID, zap.Error(err))
+			continue
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// Start starts background processing of campaigns
+func (s *CampaignsService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Process(time.Now()); err != nil {
+				s.log.Errorw("campaigns processing", zap.Error(err))
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *CampaignsService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
@@ -0,0 +1,331 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvitationPermission = errors.New("Permission denied")
+	ErrUsernameRequired     = errors.New("Username is required for invitation of a new user")
+)
+
+type InvitationsConfig struct {
+	// validity of invitations
+	Expiration time.Duration
+}
+
+// InvitationRequest describes invitation of existing (matched by email address) or a new user
+type InvitationRequest struct {
+	Email string
+	// username, first and last name and profile are used only for a new account
+	Username  string
+	FirstName string
+	LastName  string
+	Profile   domain.Profile
+	Project   string
+	Grants    []domain.InvitationGrant
+}
+
+// InvitationsService handles invitations of users into projects
+type InvitationsService struct {
+	log      *zap.SugaredLogger
+	repo     domain.InvitationsRepository
+	accounts *AccountsService
+	projects ProjectService
+	config   InvitationsConfig
+	// ResolveUser returns user of the account (with resolved memberships) for checking permissions
+	// of the inviter
+	ResolveUser func(account domain.Account) domain.User
+}
+
+func NewInvitationsService(log *zap.SugaredLogger, repo domain.InvitationsRepository, accounts *AccountsService, projects ProjectService, config InvitationsConfig) *InvitationsService {
+	return &InvitationsService{
+		log:         log,
+		repo:        repo,
+		accounts:    accounts,
+		projects:    projects,
+		config:      config,
+		ResolveUser: domain.AccountToUser,
+	}
+}
+
+// checkGrants validates grants against the current project settings and permissions of the inviter.
+// Only owners of the project (or superusers) can grant administration of the project settings.
+func (s *InvitationsService) checkGrants(inviter domain.User, project string, grants []domain.InvitationGrant) error {
+	if len(grants) == 0 {
+		return fmt.Errorf("%w: no grant specified", domain.ErrInvalidGrant)
+	}
+	settings, err := s.projects.GetSettings(project)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", domain.ErrInvalidGrant, domain.ErrNoSettings)
+		}
+		return fmt.Errorf("reading project settings: %w", err)
+	}
+	for _, g := range grants {
+		if err := g.Validate(); err != nil {
+			return err
+		}
+		switch g.Type {
+		case domain.GrantProjectAccess:
+			if settings.Auth.Type != "users" {
+				return fmt.Errorf("%w: project is not shared with selected users", domain.ErrInvalidGrant)
+			}
+		case domain.GrantProjectRole:
+			if findRole(settings.Auth.Roles, g.Role) == nil {
+				return fmt.Errorf("%w: role '%s' not found", domain.ErrInvalidGrant, g.Role)
+			}
+		case domain.GrantProjectAdmin:
+			owner := strings.Split(project, "/")[0]
+			if !inviter.IsSuperuser && !inviter.CanManageNamespace(owner) {
+				return ErrInvitationPermission
+			}
+		}
+	}
+	return nil
+}
+
+func findRole(roles []domain.ProjectRole, name string) *domain.ProjectRole {
+	for i, r := range roles {
+		if r.Name == name && r.Auth == "users" {
+			return &roles[i]
+		}
+	}
+	return nil
+}
+
+// Invite creates invitation with grants into the project. New (inactive) account is created,
+// when there is no account with the given email address.
+func (s *InvitationsService) Invite(inviter domain.User, req InvitationRequest) (domain.Invitation, error) {
+	invitation := domain.Invitation{Project: req.Project, Grants: req.Grants, InvitedBy: inviter.Username}
+	email, err := domain.NormalizeEmail(req.Email)
+	if err != nil {
+		return invitation, err
+	}
+	if err := s.checkGrants(inviter, req.Project, req.Grants); err != nil {
+		return invitation, err
+	}
+	pInfo, err := s.projects.GetProjectInfo(req.Project)
+	if err != nil {
+		return invitation, fmt.Errorf("reading project info: %w", err)
+	}
+	account, err := s.accounts.Repository.GetByEmail(email)
+	if err != nil {
+		if !errors.Is(err, domain.ErrAccountNotFound) {
+			return invitation, fmt.Errorf("getting account: %w", err)
+		}
+		if req.Username == "" {
+			return invitation, ErrUsernameRequired
+		}
+		account, err = s.accounts.CreateAccount(req.Username, email, req.FirstName, req.LastName, "")
+		if err != nil {
+			return invitation, err
+		}
+		account.Profile = req.Profile
+		if err := s.accounts.Repository.Create(account); err != nil {
+			return invitation, err
+		}
+		invitation.NewAccount = true
+	}
+	now := time.Now()
+	invitation.Username = account.Username
+	invitation.Email = account.Email
+	invitation.Created = now
+	invitation.Expires = now.Add(s.config.Expiration)
+	invitation, err = s.repo.Create(invitation)
+	if err != nil {
+		return invitation, fmt.Errorf("saving invitation: %w", err)
+	}
+	title := pInfo.Title
+	if title == "" {
+		title = req.Project
+	}
+	inviterName := strings.TrimSpace(fmt.Sprintf("%s %s", inviter.FirstName, inviter.LastName))
+	if inviterName == "" {
+		inviterName = inviter.Username
+	}
+	data := map[string]interface{}{
+		"Project":      req.Project,
+		"ProjectTitle": title,
+		"InvitedBy":    inviterName,
+		"Expires":      invitation.Expires.Format("2006-01-02 15:04"),
+	}
+	if account.IsActive() {
+		err = s.accounts.Email.SendProjectInvitationEmail(account, invitation.ID, data)
+	} else {
+		// grants are applied on activation of the account
+		err = s.accounts.SendActivationEmail(account, data)
+	}
+	if err != nil {
+		return invitation, fmt.Errorf("sending invitation email [%s]: %w", account.Email, err)
+	}
+	return invitation, nil
+}
+
+func appendUser(list interface{}, username string) []interface{} {
+	users, _ := list.([]interface{})
+	for _, u := range users {
+		if u == username {
+			return users
+		}
+	}
+	return append(users, username)
+}
+
+func objectField(data map[string]interface{}, key string) map[string]interface{} {
+	obj, ok := data[key].(map[string]interface{})
+	if !ok {
+		obj = make(map[string]interface{})
+		data[key] = obj
+	}
+	return obj
+}
+
+// applyGrants adds the user into lists of users in project settings document. Roles removed
+// from the settings since the invitation was created are skipped.
+func applyGrants(settings map[string]interface{}, username string, grants []domain.InvitationGrant) {
+	auth := objectField(settings, "auth")
+	for _, g := range grants {
+		switch g.Type {
+		case domain.GrantProjectAccess:
+			auth["users"] = appendUser(auth["users"], username)
+		case domain.GrantProjectRole:
+			roles, _ := auth["roles"].([]interface{})
+			for _, r := range roles {
+				role, ok := r.(map[string]interface{})
+				if ok && role["name"] == g.Role && role["type"] == "users" {
+					role["users"] = appendUser(role["users"], username)
+				}
+			}
+		case domain.GrantProjectAdmin:
+			settingsAuth := objectField(settings, "settings_auth")
+			settingsAuth["admin_users"] = appendUser(settingsAuth["admin_users"], username)
+		}
+	}
+}
+
+// checkInviter checks that the inviter is still allowed to administer the project and to give
+// the invitation's grants
+func (s *InvitationsService) checkInviter(invitation domain.Invitation) error {
+	account, err := s.accounts.Repository.GetByUsername(invitation.InvitedBy)
+	if err != nil {
+		if errors.Is(err, domain.ErrAccountNotFound) {
+			return fmt.Errorf("%w: inviter's account doesn't exist", ErrInvitationPermission)
+		}
+		return fmt.Errorf("getting inviter's account: %w", err)
+	}
+	if !account.IsActive() {
+		return fmt.Errorf("%w: inviter's account is not active", ErrInvitationPermission)
+	}
+	inviter := s.ResolveUser(account)
+	owner := strings.Split(invitation.Project, "/")[0]
+	if inviter.IsSuperuser || inviter.CanManageNamespace(owner) {
+		return nil
+	}
+	settings, err := s.projects.GetSettings(invitation.Project)
+	if err != nil {
+		return fmt.Errorf("reading project settings: %w", err)
+	}
+	if !inviter.InList(settings.SettingsAuth.AdminUsers) {
+		return fmt.Errorf("%w: inviter is no longer administrator of the project", ErrInvitationPermission)
+	}
+	for _, g := range invitation.Grants {
+		if g.Type == domain.GrantProjectAdmin {
+			return fmt.Errorf("%w: inviter is no longer owner of the project", ErrInvitationPermission)
+		}
+	}
+	return nil
+}
+
+func (s *InvitationsService) accept(invitation domain.Invitation) (domain.Invitation, error) {
+	switch invitation.Status(time.Now()) {
+	case domain.InvitationExpired:
+		return invitation, domain.ErrInvitationExpired
+	case domain.InvitationAccepted, domain.InvitationRevoked:
+		return invitation, domain.ErrInvitationClosed
+	}
+	if err := s.checkInviter(invitation); err != nil {
+		return invitation, err
+	}
+	err := s.projects.PatchSettings(invitation.Project, func(settings map[string]interface{}) error {
+		applyGrants(settings, invitation.Username, invitation.Grants)
+		return nil
+	})
+	if err != nil {
+		return invitation, fmt.Errorf("applying invitation grants: %w", err)
+	}
+	now := time.Now()
+	invitation.Accepted = &now
+	if err := s.repo.Update(invitation); err != nil {
+		return invitation, fmt.Errorf("saving invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+// Accept applies grants of the user's pending invitation
+func (s *InvitationsService) Accept(username string, id int64) (domain.Invitation, error) {
+	invitation, err := s.repo.Get(id)
+	if err != nil {
+		return invitation, err
+	}
+	if invitation.Username != username {
+		return domain.Invitation{}, domain.ErrInvitationNotFound
+	}
+	return s.accept(invitation)
+}
+
+// AcceptPending applies grants of all pending invitations of the user (used on activation
+// of invited accounts)
+func (s *InvitationsService) AcceptPending(username string) error {
+	invitations, err := s.repo.UserInvitations(username)
+	if err != nil {
+		return fmt.Errorf("listing invitations: %w", err)
+	}
+	now := time.Now()
+	for _, i := range invitations {
+		if i.Status(now) != domain.InvitationPending {
+			continue
+		}
+		if _, err := s.accept(i); err != nil {
+			s.log.Errorw("accepting invitation", "id", i.ID, "user", username, "project", i.Project, zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// Revoke revokes pending invitation (grants are not applied), used also for declining
+// of invitations by invited users
+func (s *InvitationsService) Revoke(invitation domain.Invitation) (domain.Invitation, error) {
+	if invitation.Accepted != nil || invitation.Revoked != nil {
+		return invitation, domain.ErrInvitationClosed
+	}
+	now := time.Now()
+	invitation.Revoked = &now
+	if err := s.repo.Update(invitation); err != nil {
+		return invitation, fmt.Errorf("saving invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+func (s *InvitationsService) Get(id int64) (domain.Invitation, error) {
+	return s.repo.Get(id)
+}
+
+func (s *InvitationsService) ProjectInvitations(project string) ([]domain.Invitation, error) {
+	return s.repo.ProjectInvitations(project)
+}
+
+func (s *InvitationsService) UserInvitations(username string) ([]domain.Invitation, error) {
+	return s.repo.UserInvitations(username)
+}
+
+func (s *InvitationsService) AllInvitations() ([]domain.Invitation, error) {
+	return s.repo.All()
+}
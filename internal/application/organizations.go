@@ -0,0 +1,365 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+)
+
+var (
+	ErrOrganizationPermission  = errors.New("Permission denied")
+	ErrOrganizationNotEmpty    = errors.New("Organization has projects")
+	ErrLastOrganizationOwner   = errors.New("Organization must have at least one owner")
+	ErrInvalidOrganizationRole = errors.New("Invalid organization role")
+	ErrNotOrganizationMember   = errors.New("User is not member of the organization")
+)
+
+// OrganizationInfo is organization with the role of the user
+type OrganizationInfo struct {
+	domain.Organization
+	Role string `json:"role,omitempty"`
+}
+
+type OrganizationDetail struct {
+	OrganizationInfo
+	Members []domain.OrganizationMember `json:"members"`
+	Teams   []domain.Team               `json:"teams"`
+}
+
+type OrganizationsService struct {
+	Repository domain.OrganizationsRepository
+	accounts   domain.AccountsRepository
+	projects   ProjectService
+	// called with usernames of users whose memberships were changed
+	OnMembershipsChange func(usernames ...string)
+}
+
+func NewOrganizationsService(repo domain.OrganizationsRepository, accounts domain.AccountsRepository, projects ProjectService) *OrganizationsService {
+	return &OrganizationsService{
+		Repository: repo,
+		accounts:   accounts,
+		projects:   projects,
+	}
+}
+
+func (s *OrganizationsService) membershipsChanged(usernames ...string) {
+	if s.OnMembershipsChange != nil && len(usernames) > 0 {
+		s.OnMembershipsChange(usernames...)
+	}
+}
+
+// actorRole returns current role of the user in the organization (read from the repository, not from
+// possibly outdated memberships of the user) and list of all members
+func (s *OrganizationsService) actorRole(actor domain.User, org string) (string, []domain.OrganizationMember, error) {
+	members, err := s.Repository.GetMembers(org)
+	if err != nil {
+		return "", nil, fmt.Errorf("getting organization members: %w", err)
+	}
+	if len(members) == 0 {
+		// organization without members (e.g. after deletion of its owner's account) or non-existing one
+		if _, err := s.Repository.Get(org); err != nil {
+			return "", nil, err
+		}
+	}
+	member, _ := findMember(members, actor.Username)
+	return member.Role, members, nil
+}
+
+func canAdministrate(actor domain.User, role string) bool {
+	return actor.IsSuperuser || role == domain.OrgRoleOwner || role == domain.OrgRoleAdmin
+}
+
+func countOwners(members []domain.OrganizationMember) int {
+	count := 0
+	for _, m := range members {
+		if m.Role == domain.OrgRoleOwner {
+			count++
+		}
+	}
+	return count
+}
+
+func findMember(members []domain.OrganizationMember, username string) (domain.OrganizationMember, bool) {
+	for _, m := range members {
+		if m.Username == username {
+			return m, true
+		}
+	}
+	return domain.OrganizationMember{}, false
+}
+
+func membersUsernames(members []domain.OrganizationMember) []string {
+	usernames := make([]string, len(members))
+	for i, m := range members {
+		usernames[i] = m.Username
+	}
+	return usernames
+}
+
+// Create creates a new organization owned by the given user
+func (s *OrganizationsService) Create(name, title, owner string) (domain.Organization, error) {
+	org, err := domain.NewOrganization(name, title)
+	if err != nil {
+		return org, err
+	}
+	if _, err := s.accounts.GetByUsername(owner); err != nil {
+		return org, err
+	}
+	if err := s.Repository.Create(org, owner); err != nil {
+		return org, err
+	}
+	s.membershipsChanged(owner)
+	return org, nil
+}
+
+// UserOrganizations returns organizations of the user
+func (s *OrganizationsService) UserOrganizations(username string) ([]OrganizationInfo, error) {
+	memberships, err := s.Repository.GetUserMemberships(username)
+	if err != nil {
+		return nil, err
+	}
+	orgs := []OrganizationInfo{}
+	for name, role := range memberships.Organizations {
+		org, err := s.Repository.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, OrganizationInfo{Organization: org, Role: role})
+	}
+	sort.Slice(orgs, func(i, j int) bool {
+		return orgs[i].Name < orgs[j].Name
+	})
+	return orgs, nil
+}
+
+// AllOrganizations returns all organizations (without roles)
+func (s *OrganizationsService) AllOrganizations() ([]OrganizationInfo, error) {
+	list, err := s.Repository.List()
+	if err != nil {
+		return nil, err
+	}
+	orgs := make([]OrganizationInfo, len(list))
+	for i, org := range list {
+		orgs[i] = OrganizationInfo{Organization: org}
+	}
+	return orgs, nil
+}
+
+// Get returns organization with its members and teams, accessible to members and superusers
+func (s *OrganizationsService) Get(actor domain.User, name string) (OrganizationDetail, error) {
+	role, members, err := s.actorRole(actor, name)
+	if err != nil {
+		return OrganizationDetail{}, err
+	}
+	if role == "" && !actor.IsSuperuser {
+		return OrganizationDetail{}, ErrOrganizationPermission
+	}
+	org, err := s.Repository.Get(name)
+	if err != nil {
+		return OrganizationDetail{}, err
+	}
+	teams, err := s.Repository.GetTeams(name)
+	if err != nil {
+		return OrganizationDetail{}, fmt.Errorf("getting organization teams: %w", err)
+	}
+	return OrganizationDetail{
+		OrganizationInfo: OrganizationInfo{Organization: org, Role: role},
+		Members:          members,
+		Teams:            teams,
+	}, nil
+}
+
+// UpdateTitle changes title of the organization (by its owner or admin)
+func (s *OrganizationsService) UpdateTitle(actor domain.User, name, title string) (domain.Organization, error) {
+	role, _, err := s.actorRole(actor, name)
+	if err != nil {
+		return domain.Organization{}, err
+	}
+	if !canAdministrate(actor, role) {
+		return domain.Organization{}, ErrOrganizationPermission
+	}
+	org, err := s.Repository.Get(name)
+	if err != nil {
+		return org, err
+	}
+	org.Title = strings.TrimSpace(title)
+	return org, s.Repository.Update(org)
+}
+
+// Update updates organization including its limits (administration)
+func (s *OrganizationsService) Update(org domain.Organization) error {
+	org.Title = strings.TrimSpace(org.Title)
+	return s.Repository.Update(org)
+}
+
+// Delete deletes organization without projects (by its owner)
+func (s *OrganizationsService) Delete(actor domain.User, name string) error {
+	role, members, err := s.actorRole(actor, name)
+	if err != nil {
+		return err
+	}
+	if role != domain.OrgRoleOwner && !actor.IsSuperuser {
+		return ErrOrganizationPermission
+	}
+	projects, err := s.projects.GetUserProjects(name)
+	if err != nil {
+		return fmt.Errorf("getting organization projects: %w", err)
+	}
+	if len(projects) > 0 {
+		return ErrOrganizationNotEmpty
+	}
+	if err := s.Repository.Delete(name); err != nil {
+		return err
+	}
+	s.membershipsChanged(membersUsernames(members)...)
+	return nil
+}
+
+// SetMember adds a new member or changes role of existing member. Only owners can grant
+// or revoke owner role.
+func (s *OrganizationsService) SetMember(actor domain.User, org, username, role string) error {
+	if !domain.OrganizationRoles.Has(role) {
+		return ErrInvalidOrganizationRole
+	}
+	actorRole, members, err := s.actorRole(actor, org)
+	if err != nil {
+		return err
+	}
+	if !canAdministrate(actor, actorRole) {
+		return ErrOrganizationPermission
+	}
+	current, exists := findMember(members, username)
+	if (role == domain.OrgRoleOwner || current.Role == domain.OrgRoleOwner) && actorRole != domain.OrgRoleOwner && !actor.IsSuperuser {
+		return ErrOrganizationPermission
+	}
+	if current.Role == domain.OrgRoleOwner && role != domain.OrgRoleOwner && countOwners(members) == 1 {
+		return ErrLastOrganizationOwner
+	}
+	if !exists {
+		if _, err := s.accounts.GetByUsername(username); err != nil {
+			return err
+		}
+	}
+	member := domain.OrganizationMember{Organization: org, Username: username, Role: role}
+	if exists {
+		member.Created = current.Created
+	}
+	if err := s.Repository.SetMember(member); err != nil {
+		return err
+	}
+	s.membershipsChanged(username)
+	return nil
+}
+
+// RemoveMember removes the user from organization (and its teams). Members can also leave
+// the organization by themselves.
+func (s *OrganizationsService) RemoveMember(actor domain.User, org, username string) error {
+	actorRole, members, err := s.actorRole(actor, org)
+	if err != nil {
+		return err
+	}
+	member, exists := findMember(members, username)
+	if !exists {
+		return ErrNotOrganizationMember
+	}
+	if actor.Username != username {
+		if !canAdministrate(actor, actorRole) {
+			return ErrOrganizationPermission
+		}
+		if member.Role == domain.OrgRoleOwner && actorRole != domain.OrgRoleOwner && !actor.IsSuperuser {
+			return ErrOrganizationPermission
+		}
+	}
+	if member.Role == domain.OrgRoleOwner && countOwners(members) == 1 {
+		return ErrLastOrganizationOwner
+	}
+	if err := s.Repository.RemoveMember(org, username); err != nil {
+		return err
+	}
+	s.membershipsChanged(username)
+	return nil
+}
+
+// SaveTeam creates or updates the team, all team members must be members of the organization
+func (s *OrganizationsService) SaveTeam(actor domain.User, org, name string, teamMembers []string) (domain.Team, error) {
+	if !domain.ValidateTeamName(name) {
+		return domain.Team{}, fmt.Errorf("%w: '%s'", domain.ErrInvalidName, name)
+	}
+	actorRole, members, err := s.actorRole(actor, org)
+	if err != nil {
+		return domain.Team{}, err
+	}
+	if !canAdministrate(actor, actorRole) {
+		return domain.Team{}, ErrOrganizationPermission
+	}
+	team := domain.Team{Organization: org, Name: name, Members: []string{}}
+	for _, username := range teamMembers {
+		if _, ok := findMember(members, username); !ok {
+			return team, fmt.Errorf("%w: %s", ErrNotOrganizationMember, username)
+		}
+		if !domain.StringArray(team.Members).Has(username) {
+			team.Members = append(team.Members, username)
+		}
+	}
+	if err := s.Repository.SaveTeam(team); err != nil {
+		return team, err
+	}
+	s.membershipsChanged(membersUsernames(members)...)
+	return team, nil
+}
+
+func (s *OrganizationsService) DeleteTeam(actor domain.User, org, name string) error {
+	actorRole, members, err := s.actorRole(actor, org)
+	if err != nil {
+		return err
+	}
+	if !canAdministrate(actor, actorRole) {
+		return ErrOrganizationPermission
+	}
+	if err := s.Repository.DeleteTeam(org, name); err != nil {
+		return err
+	}
+	s.membershipsChanged(membersUsernames(members)...)
+	return nil
+}
+
+// Projects returns projects of the organization, accessible to members and superusers
+func (s *OrganizationsService) Projects(actor domain.User, org string) ([]domain.ProjectInfo, error) {
+	role, _, err := s.actorRole(actor, org)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" && !actor.IsSuperuser {
+		return nil, ErrOrganizationPermission
+	}
+	return s.projects.GetUserProjects(org)
+}
+
+// OrganizationsLimiter applies limits of organizations to projects in organization's namespace,
+// other namespaces are handled by the wrapped limiter
+type OrganizationsLimiter struct {
+	limiter AccountsLimiter
+	repo    domain.OrganizationsRepository
+}
+
+func NewOrganizationsLimiter(limiter AccountsLimiter, repo domain.OrganizationsRepository) *OrganizationsLimiter {
+	return &OrganizationsLimiter{limiter: limiter, repo: repo}
+}
+
+func (l *OrganizationsLimiter) GetAccountLimits(namespace string) (domain.AccountConfig, error) {
+	config, err := l.limiter.GetAccountLimits(namespace)
+	if err != nil {
+		return config, err
+	}
+	org, err := l.repo.Get(namespace)
+	if err != nil {
+		if errors.Is(err, domain.ErrOrganizationNotFound) {
+			return config, nil
+		}
+		return config, fmt.Errorf("getting organization limits: %w", err)
+	}
+	return org.ApplyLimits(config), nil
+}
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gisquick/gisquick-server/internal/domain"
 	"go.uber.org/zap"
@@ -21,10 +23,13 @@ var (
 type ProjectService interface {
 	Create(projectName string, meta json.RawMessage) (*domain.ProjectInfo, error)
 	Delete(projectName string) error
+	Transfer(projectName, namespace string) (string, error)
 	GetProjectInfo(projectName string) (domain.ProjectInfo, error)
 	GetUserProjects(username string) ([]domain.ProjectInfo, error)
-	AccessibleProjects(username string, skipErrors bool) ([]domain.ProjectInfo, error)
+	AccessibleProjects(user domain.User, skipErrors bool) ([]domain.ProjectInfo, error)
 	ProjectsNames(skipErrors bool) ([]string, error)
+	SetPublishSchedule(projectName string, schedule *domain.PublishSchedule) (domain.ProjectInfo, error)
+	ApplySchedules(now time.Time) error
 
 	// SaveFile(projectName, filename string, r io.Reader) (string, error)
 	SaveFile(projectName, dir, pattern string, r io.Reader, size int64) (domain.ProjectFile, error)
@@ -36,6 +41,14 @@ type ProjectService interface {
 
 	GetSettings(projectName string) (domain.ProjectSettings, error)
 	UpdateSettings(projectName string, data json.RawMessage) error
+	GetDraftSettings(projectName string) (domain.ProjectSettings, error)
+	SaveDraftSettings(projectName string, data json.RawMessage) error
+	DeleteDraftSettings(projectName string) error
+	PromoteDraftSettings(projectName string) error
+	RollbackSettings(projectName string) error
+	PatchSettings(projectName string, patch func(settings map[string]interface{}) error) error
+	ValidateProject(projectName string) (ValidationReport, error)
+	ValidateSettings(projectName string, settings domain.ProjectSettings) (ValidationReport, error)
 
 	GetThumbnailPath(projectName string) string
 	SaveThumbnail(projectName string, r io.Reader) error
@@ -43,7 +56,9 @@ type ProjectService interface {
 	UpdateFiles(projectName string, info domain.FilesChanges, next func() (string, io.ReadCloser, error)) ([]domain.ProjectFile, error)
 
 	GetLayersData(projectName string) (LayersData, error)
+	GetProjections(projectName string) (map[string]domain.Projection, error)
 	GetMapConfig(projectName string, user domain.User) (map[string]interface{}, error)
+	GetDraftMapConfig(projectName string, user domain.User) (map[string]interface{}, error)
 
 	GetScripts(projectName string) (domain.Scripts, error)
 	UpdateScripts(projectName string, scripts domain.Scripts) error
@@ -97,6 +112,25 @@ func (s *projectService) Delete(name string) error {
 	return s.repo.Delete(name)
 }
 
+// Transfer moves the project into another namespace (user or organization) and returns its new name.
+// When the namespace already contains project with the same name, name of the original owner is appended,
+// followed by the first free numeric suffix if needed.
+func (s *projectService) Transfer(name, namespace string) (string, error) {
+	owner, projectName, _ := strings.Cut(name, "/")
+	newName := namespace + "/" + projectName
+	if s.repo.CheckProjectExists(newName) {
+		base := fmt.Sprintf("%s/%s_%s", namespace, projectName, owner)
+		newName = base
+		for i := 2; s.repo.CheckProjectExists(newName); i++ {
+			newName = fmt.Sprintf("%s_%d", base, i)
+		}
+	}
+	if err := s.repo.Move(name, newName); err != nil {
+		return "", err
+	}
+	return newName, nil
+}
+
 func (s *projectService) ListProjectFiles(project string, checksum bool) ([]domain.ProjectFile, []domain.ProjectFile, error) {
 	return s.repo.ListProjectFiles(project, checksum)
 }
@@ -187,6 +221,30 @@ func (s *projectService) GetSettings(projectName string) (domain.ProjectSettings
 	return s.repo.GetSettings(projectName)
 }
 
+func (s *projectService) GetDraftSettings(projectName string) (domain.ProjectSettings, error) {
+	return s.repo.GetDraftSettings(projectName)
+}
+
+func (s *projectService) SaveDraftSettings(projectName string, data json.RawMessage) error {
+	return s.repo.SaveDraftSettings(projectName, data)
+}
+
+func (s *projectService) DeleteDraftSettings(projectName string) error {
+	return s.repo.DeleteDraftSettings(projectName)
+}
+
+func (s *projectService) PromoteDraftSettings(projectName string) error {
+	return s.repo.PromoteDraftSettings(projectName)
+}
+
+func (s *projectService) RollbackSettings(projectName string) error {
+	return s.repo.RollbackSettings(projectName)
+}
+
+func (s *projectService) PatchSettings(projectName string, patch func(settings map[string]interface{}) error) error {
+	return s.repo.PatchSettings(projectName, patch)
+}
+
 func (s *projectService) UpdateSettings(projectName string, data json.RawMessage) error {
 	return s.repo.UpdateSettings(projectName, data)
 }
@@ -351,6 +409,34 @@ func (s *projectService) GetLayersData(projectName string) (LayersData, error) {
 	return data, nil
 }
 
+// Returns proj4 definitions of project's projections (including overrides from the settings)
+func (s *projectService) GetProjections(projectName string) (map[string]domain.Projection, error) {
+	type ProjectionsMetadata struct {
+		Projections map[string]*domain.Projection `json:"projections"`
+	}
+	var meta ProjectionsMetadata
+	if err := s.repo.ParseQgisMetadata(projectName, &meta); err != nil {
+		return nil, fmt.Errorf("parsing qgis meta: %w", err)
+	}
+	projections := make(map[string]domain.Projection, len(meta.Projections))
+	for code, p := range meta.Projections {
+		if p != nil {
+			projections[code] = *p
+		}
+	}
+	settings, err := s.repo.GetSettings(projectName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for code, proj4 := range settings.Proj4 {
+		if p, ok := projections[code]; ok {
+			p.Proj4 = proj4
+			projections[code] = p
+		}
+	}
+	return projections, nil
+}
+
 type Layer struct {
 	Name             string                     `json:"name"`
 	Title            string                     `json:"title"`
@@ -541,14 +627,30 @@ func (s *projectService) GetProjectCustomizations(projectName string) (json.RawM
 }
 
 func (s *projectService) GetMapConfig(projectName string, user domain.User) (map[string]interface{}, error) {
-	var meta domain.QgisMeta
-	if err := s.repo.ParseQgisMetadata(projectName, &meta); err != nil {
-		return nil, fmt.Errorf("parsing qgis meta: %w", err)
-	}
 	settings, err := s.repo.GetSettings(projectName)
 	if err != nil {
 		return nil, err
 	}
+	return s.createMapConfig(projectName, user, settings)
+}
+
+// GetDraftMapConfig returns map config created from draft settings (or live settings when there is no draft)
+func (s *projectService) GetDraftMapConfig(projectName string, user domain.User) (map[string]interface{}, error) {
+	settings, err := s.repo.GetDraftSettings(projectName)
+	if errors.Is(err, domain.ErrNoDraftSettings) {
+		settings, err = s.repo.GetSettings(projectName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.createMapConfig(projectName, user, settings)
+}
+
+func (s *projectService) createMapConfig(projectName string, user domain.User, settings domain.ProjectSettings) (map[string]interface{}, error) {
+	var meta domain.QgisMeta
+	if err := s.repo.ParseQgisMetadata(projectName, &meta); err != nil {
+		return nil, fmt.Errorf("parsing qgis meta: %w", err)
+	}
 	layersTree, err := domain.CreateTree2(meta.LayersTree)
 	if err != nil {
 		return nil, err
@@ -855,7 +957,7 @@ func (s *projectService) ProjectsNames(skipErrors bool) ([]string, error) {
 	return s.repo.AllProjects(skipErrors)
 }
 
-func (s *projectService) AccessibleProjects(username string, skipErrors bool) ([]domain.ProjectInfo, error) {
+func (s *projectService) AccessibleProjects(user domain.User, skipErrors bool) ([]domain.ProjectInfo, error) {
 	projects := make([]domain.ProjectInfo, 0)
 	list, err := s.repo.AllProjects(skipErrors)
 	if err != nil {
@@ -868,6 +970,8 @@ func (s *projectService) AccessibleProjects(username string, skipErrors bool) ([
 			if !skipErrors {
 				return nil, err
 			}
+		} else if pi.Unpublished() {
+			continue
 		} else {
 			if pi.Authentication == "public" || pi.Authentication == "authenticated" {
 				projects = append(projects, pi)
@@ -879,7 +983,7 @@ func (s *projectService) AccessibleProjects(username string, skipErrors bool) ([
 						return nil, err
 					}
 				}
-				if domain.StringArray(settings.Auth.Users).Has(username) {
+				if user.InList(settings.Auth.Users) {
 					projects = append(projects, pi)
 				}
 			}
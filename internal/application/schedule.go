@@ -0,0 +1,181 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidSchedule = errors.New("invalid publish schedule")
+
+func validateSchedule(schedule *domain.PublishSchedule) error {
+	if schedule == nil {
+		return nil
+	}
+	action := schedule.UnpublishAction
+	if action != "" && action != domain.UnpublishActionHide && action != domain.UnpublishActionPrivate {
+		return fmt.Errorf("%w: unknown unpublish action '%s'", ErrInvalidSchedule, action)
+	}
+	if schedule.Publish != nil && schedule.Unpublish != nil && !schedule.Unpublish.After(*schedule.Publish) {
+		return fmt.Errorf("%w: unpublish time must be after publish time", ErrInvalidSchedule)
+	}
+	return nil
+}
+
+func (s *projectService) SetPublishSchedule(projectName string, schedule *domain.PublishSchedule) (domain.ProjectInfo, error) {
+	if err := validateSchedule(schedule); err != nil {
+		return domain.ProjectInfo{}, err
+	}
+	if schedule.IsEmpty() {
+		schedule = nil
+	} else {
+		if schedule.Publish != nil {
+			t := schedule.Publish.UTC()
+			schedule.Publish = &t
+		}
+		if schedule.Unpublish != nil {
+			t := schedule.Unpublish.UTC()
+			schedule.Unpublish = &t
+		}
+	}
+	now := time.Now()
+	return s.repo.UpdateProjectInfo(projectName, func(info *domain.ProjectInfo) error {
+		info.Schedule = schedule
+		if schedule.PendingPublish(now) {
+			if info.State == "published" || info.State == "unpublished" {
+				info.State = "scheduled"
+			}
+		} else if info.State == "scheduled" && (schedule == nil || schedule.Publish == nil) {
+			// publishing was canceled
+			info.State = "unpublished"
+		}
+		return nil
+	})
+}
+
+// applySchedule performs scheduled actions which are due. Returns true when the project was modified.
+// Schedule is checked again on the project info loaded under the config lock, as it can be changed
+// or removed concurrently. Unpublishing by switching to private authentication is removed from the
+// schedule only after the authentication is changed, so it's retried when the switch fails.
+func (s *projectService) applySchedule(projectName string, now time.Time) (bool, error) {
+	info, err := s.repo.GetProjectInfo(projectName)
+	if err != nil {
+		return false, err
+	}
+	if !info.Schedule.Due(now) {
+		return false, nil
+	}
+	changed := false
+	var privateUnpublish *time.Time
+	info, err = s.repo.UpdateProjectInfo(projectName, func(info *domain.ProjectInfo) error {
+		sch := info.Schedule
+		if !sch.Due(now) {
+			return nil
+		}
+		changed = true
+		if sch.Publish != nil && !now.Before(*sch.Publish) {
+			if info.State == "scheduled" || info.State == "unpublished" {
+				info.State = "published"
+			}
+			sch.Publish = nil
+		}
+		if sch.Unpublish != nil && !now.Before(*sch.Unpublish) {
+			if sch.UnpublishAction == domain.UnpublishActionPrivate {
+				privateUnpublish = sch.Unpublish
+			} else {
+				if info.State == "published" {
+					info.State = "unpublished"
+				}
+				sch.Unpublish = nil
+				sch.UnpublishAction = ""
+			}
+		}
+		if sch.IsEmpty() {
+			info.Schedule = nil
+		}
+		return nil
+	})
+	if err != nil || privateUnpublish == nil {
+		return changed, err
+	}
+	if info.Authentication != "private" {
+		if err := s.repo.SetAuthentication(projectName, "private"); err != nil {
+			return changed, fmt.Errorf("switching authentication to private: %w", err)
+		}
+	}
+	_, err = s.repo.UpdateProjectInfo(projectName, func(info *domain.ProjectInfo) error {
+		sch := info.Schedule
+		if sch == nil || sch.Unpublish == nil || !sch.Unpublish.Equal(*privateUnpublish) {
+			return nil
+		}
+		sch.Unpublish = nil
+		sch.UnpublishAction = ""
+		if sch.IsEmpty() {
+			info.Schedule = nil
+		}
+		return nil
+	})
+	return true, err
+}
+
+func (s *projectService) ApplySchedules(now time.Time) error {
+	projects, err := s.repo.AllProjects(true)
+	if err != nil {
+		return fmt.Errorf("listing projects: %w", err)
+	}
+	for _, projectName := range projects {
+		changed, err := s.applySchedule(projectName, now)
+		if err != nil {
+			s.log.Errorw("applying project schedule", "project", projectName, zap.Error(err))
+		} else if changed {
+			s.log.Infow("applied project schedule", "project", projectName)
+		}
+	}
+	return nil
+}
+
+// PublishScheduler periodically applies projects publish schedules
+type PublishScheduler struct {
+	log      *zap.SugaredLogger
+	projects ProjectService
+	interval time.Duration
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewPublishScheduler(log *zap.SugaredLogger, projects ProjectService, interval time.Duration) *PublishScheduler {
+	return &PublishScheduler{
+		log:      log,
+		projects: projects,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (s *PublishScheduler) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			if err := s.projects.ApplySchedules(time.Now()); err != nil {
+				s.log.Errorw("publish scheduler", zap.Error(err))
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *PublishScheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
@@ -0,0 +1,97 @@
+package application_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+// scheduleRepo holds info of a single project, onUpdate is called before the update to simulate
+// concurrent changes
+type scheduleRepo struct {
+	domain.ProjectsRepository
+	info           domain.ProjectInfo
+	onUpdate       func(info *domain.ProjectInfo)
+	authentication error
+}
+
+func (r *scheduleRepo) AllProjects(skipErrors bool) ([]string, error) {
+	return []string{r.info.Name}, nil
+}
+
+func (r *scheduleRepo) GetProjectInfo(name string) (domain.ProjectInfo, error) {
+	info := r.info
+	if info.Schedule != nil {
+		schedule := *info.Schedule
+		info.Schedule = &schedule
+	}
+	return info, nil
+}
+
+func (r *scheduleRepo) UpdateProjectInfo(name string, update func(info *domain.ProjectInfo) error) (domain.ProjectInfo, error) {
+	if r.onUpdate != nil {
+		r.onUpdate(&r.info)
+		r.onUpdate = nil
+	}
+	info, _ := r.GetProjectInfo(name)
+	if err := update(&info); err != nil {
+		return info, err
+	}
+	r.info = info
+	return info, nil
+}
+
+func (r *scheduleRepo) SetAuthentication(name, authType string) error {
+	if r.authentication != nil {
+		return r.authentication
+	}
+	r.info.Authentication = authType
+	return nil
+}
+
+func TestApplySchedulesRemovedSchedule(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	repo := &scheduleRepo{info: domain.ProjectInfo{
+		Name:     "jan/map",
+		State:    "published",
+		Schedule: &domain.PublishSchedule{Unpublish: &past, UnpublishAction: domain.UnpublishActionPrivate},
+	}}
+	// schedule is deleted between reading of the project info and its update
+	repo.onUpdate = func(info *domain.ProjectInfo) {
+		info.Schedule = nil
+	}
+	service := application.NewProjectsService(zap.NewNop().Sugar(), repo, nil)
+	if err := service.ApplySchedules(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if repo.info.Authentication != "" || repo.info.State != "published" {
+		t.Errorf("removed schedule was applied: %+v", repo.info)
+	}
+}
+
+func TestApplySchedulesPrivateUnpublish(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	repo := &scheduleRepo{
+		info: domain.ProjectInfo{
+			Name:     "jan/map",
+			State:    "published",
+			Schedule: &domain.PublishSchedule{Unpublish: &past, UnpublishAction: domain.UnpublishActionPrivate},
+		},
+		authentication: errors.New("write error"),
+	}
+	service := application.NewProjectsService(zap.NewNop().Sugar(), repo, nil)
+	service.ApplySchedules(time.Now())
+	if repo.info.Schedule == nil || repo.info.Schedule.Unpublish == nil {
+		t.Fatalf("schedule must be kept when authentication switch fails: %+v", repo.info)
+	}
+
+	repo.authentication = nil
+	service.ApplySchedules(time.Now())
+	if repo.info.Authentication != "private" || repo.info.Schedule != nil {
+		t.Errorf("project was not switched to private: %+v", repo.info)
+	}
+}
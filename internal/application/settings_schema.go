@@ -0,0 +1,15 @@
+package application
+
+import (
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/jsonschema"
+)
+
+// SettingsSchema returns JSON Schema of the current project settings format
+func SettingsSchema() jsonschema.Schema {
+	schema := jsonschema.Generate(domain.ProjectSettings{}, "", "Gisquick project settings")
+	if props, ok := schema["properties"].(map[string]jsonschema.Schema); ok {
+		props["version"] = jsonschema.Schema{"type": "integer", "const": domain.SettingsVersion}
+	}
+	return schema
+}
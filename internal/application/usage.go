@@ -0,0 +1,310 @@
+package application
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+// Fields, by which usage overview can be sorted
+var UsageSortFields = domain.Flags{"username", "projects", "storage", "storage_ratio"}
+
+type UsageConfig struct {
+	// interval of sampling of storage usage (sampling is disabled when zero)
+	Interval time.Duration
+	// samples older than retention period are deleted (kept forever when zero)
+	Retention time.Duration
+}
+
+// Usage is used amount of a resource and its limit (-1 when unlimited)
+type Usage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+// Ratio returns used portion of the limit (-1 when unlimited)
+func (u Usage) Ratio() float64 {
+	if u.Limit < 0 {
+		return -1
+	}
+	if u.Limit == 0 {
+		return 1
+	}
+	return float64(u.Used) / float64(u.Limit)
+}
+
+type ProjectUsage struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	// size of the project and project size limit
+	Size Usage `json:"size"`
+}
+
+type FileUsage struct {
+	Project string `json:"project"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Mtime   int64  `json:"mtime"`
+}
+
+// AccountUsage describes usage of the account (user or organization) against its limits
+type AccountUsage struct {
+	Username      string               `json:"username"`
+	ProjectsCount Usage                `json:"projects_count"`
+	Storage       Usage                `json:"storage"`
+	Projects      []ProjectUsage       `json:"projects"`
+	LargestFiles  []FileUsage          `json:"largest_files"`
+	History       []domain.UsageSample `json:"history"`
+}
+
+// AccountUsageSummary is an item of usage overview of all accounts
+type AccountUsageSummary struct {
+	Username      string  `json:"username"`
+	ProjectsCount Usage   `json:"projects_count"`
+	Storage       Usage   `json:"storage"`
+	StorageRatio  float64 `json:"storage_ratio"`
+}
+
+type UsageOptions struct {
+	// number of the largest files
+	LargestFiles int
+	// start of usage history
+	HistoryFrom time.Time
+}
+
+// UsageService reports storage usage of accounts and samples it periodically
+type UsageService struct {
+	log           *zap.SugaredLogger
+	repo          domain.UsageRepository
+	accounts      domain.AccountsRepository
+	organizations domain.OrganizationsRepository
+	projects      ProjectService
+	limiter       AccountsLimiter
+	config        UsageConfig
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewUsageService(log *zap.SugaredLogger, repo domain.UsageRepository, accounts domain.AccountsRepository, organizations domain.OrganizationsRepository, projects ProjectService, limiter AccountsLimiter, config UsageConfig) *UsageService {
+	return &UsageService{
+		log:           log,
+		repo:          repo,
+		accounts:      accounts,
+		organizations: organizations,
+		projects:      projects,
+		limiter:       limiter,
+		config:        config,
+		stop:          make(chan struct{}),
+	}
+}
+
+func (s *UsageService) summary(username string, projects []domain.ProjectInfo) (AccountUsageSummary, domain.AccountConfig, error) {
+	limits, err := s.limiter.GetAccountLimits(username)
+	if err != nil {
+		return AccountUsageSummary{}, limits, fmt.Errorf("getting account limits [%s]: %w", username, err)
+	}
+	var size int64
+	for _, p := range projects {
+		size += p.Size
+	}
+	summary := AccountUsageSummary{
+		Username:      username,
+		ProjectsCount: Usage{Used: int64(len(projects)), Limit: int64(limits.ProjectsCountLimit)},
+		Storage:       Usage{Used: size, Limit: int64(limits.StorageLimit)},
+	}
+	summary.StorageRatio = summary.Storage.Ratio()
+	return summary, limits, nil
+}
+
+// AccountUsage returns usage of the account with sizes of its projects, the largest files
+// and history of usage
+func (s *UsageService) AccountUsage(username string, opts UsageOptions) (AccountUsage, error) {
+	projects, err := s.projects.GetUserProjects(username)
+	if err != nil {
+		return AccountUsage{}, fmt.Errorf("listing projects: %w", err)
+	}
+	summary, limits, err := s.summary(username, projects)
+	if err != nil {
+		return AccountUsage{}, err
+	}
+	usage := AccountUsage{
+		Username:      username,
+		ProjectsCount: summary.ProjectsCount,
+		Storage:       summary.Storage,
+		Projects:      make([]ProjectUsage, len(projects)),
+		LargestFiles:  []FileUsage{},
+	}
+	for i, p := range projects {
+		usage.Projects[i] = ProjectUsage{Name: p.Name, Title: p.Title, Size: Usage{Used: p.Size, Limit: int64(limits.ProjectSizeLimit)}}
+	}
+	sort.SliceStable(usage.Projects, func(i, j int) bool {
+		return usage.Projects[i].Size.Used > usage.Projects[j].Size.Used
+	})
+
+	if opts.LargestFiles > 0 {
+		for _, p := range projects {
+			files, _, err := s.projects.ListProjectFiles(p.Name, false)
+			if err != nil {
+				return usage, fmt.Errorf("listing files of project %s: %w", p.Name, err)
+			}
+			for _, f := range files {
+				usage.LargestFiles = append(usage.LargestFiles, FileUsage{Project: p.Name, Path: f.Path, Size: f.Size, Mtime: f.Mtime})
+			}
+		}
+		sort.Slice(usage.LargestFiles, func(i, j int) bool {
+			return usage.LargestFiles[i].Size > usage.LargestFiles[j].Size
+		})
+		if len(usage.LargestFiles) > opts.LargestFiles {
+			usage.LargestFiles = usage.LargestFiles[:opts.LargestFiles]
+		}
+	}
+
+	usage.History, err = s.repo.Samples(username, opts.HistoryFrom)
+	if err != nil {
+		return usage, fmt.Errorf("getting usage history: %w", err)
+	}
+	return usage, nil
+}
+
+// namespacesProjects returns projects grouped by their owners, including all accounts
+// and organizations without any project
+func (s *UsageService) namespacesProjects() (map[string][]domain.ProjectInfo, error) {
+	accounts, err := s.accounts.GetAllAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("listing accounts: %w", err)
+	}
+	orgs, err := s.organizations.List()
+	if err != nil {
+		return nil, fmt.Errorf("listing organizations: %w", err)
+	}
+	names, err := s.projects.ProjectsNames(true)
+	if err != nil {
+		return nil, fmt.Errorf("listing projects: %w", err)
+	}
+	data := make(map[string][]domain.ProjectInfo, len(accounts)+len(orgs))
+	for _, a := range accounts {
+		data[a.Username] = []domain.ProjectInfo{}
+	}
+	for _, o := range orgs {
+		data[o.Name] = []domain.ProjectInfo{}
+	}
+	for _, name := range names {
+		info, err := s.projects.GetProjectInfo(name)
+		if err != nil {
+			s.log.Errorw("getting project info", "project", name, zap.Error(err))
+			continue
+		}
+		owner := strings.Split(name, "/")[0]
+		data[owner] = append(data[owner], info)
+	}
+	return data, nil
+}
+
+// Overview returns usage of all accounts and organizations, sorted by one of UsageSortFields
+func (s *UsageService) Overview(sortBy string, descending bool) ([]AccountUsageSummary, error) {
+	if sortBy == "" {
+		sortBy = "username"
+	}
+	if !UsageSortFields.Has(sortBy) {
+		return nil, fmt.Errorf("%w: unknown sort field '%s'", domain.ErrInvalidQuery, sortBy)
+	}
+	namespaces, err := s.namespacesProjects()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]AccountUsageSummary, 0, len(namespaces))
+	for username, projects := range namespaces {
+		summary, _, err := s.summary(username, projects)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, summary)
+	}
+	less := func(a, b AccountUsageSummary) bool {
+		switch sortBy {
+		case "projects":
+			return a.ProjectsCount.Used < b.ProjectsCount.Used
+		case "storage":
+			return a.Storage.Used < b.Storage.Used
+		case "storage_ratio":
+			return a.StorageRatio < b.StorageRatio
+		}
+		return a.Username < b.Username
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if less(list[i], list[j]) {
+			return !descending
+		}
+		if less(list[j], list[i]) {
+			return descending
+		}
+		// stable order of equal items
+		return list[i].Username < list[j].Username
+	})
+	return list, nil
+}
+
+// Sample records current usage of all accounts and organizations. Time of samples is rounded
+// down to the sampling interval, so samples taken by multiple instances (or after restart)
+// within the same interval replace each other.
+func (s *UsageService) Sample(now time.Time) error {
+	namespaces, err := s.namespacesProjects()
+	if err != nil {
+		return err
+	}
+	sampled := now.UTC()
+	if s.config.Interval > 0 {
+		sampled = sampled.Truncate(s.config.Interval)
+	}
+	samples := make([]domain.UsageSample, 0, len(namespaces))
+	for username, projects := range namespaces {
+		sample := domain.UsageSample{Username: username, Time: sampled, ProjectsCount: len(projects)}
+		for _, p := range projects {
+			sample.StorageSize += p.Size
+		}
+		samples = append(samples, sample)
+	}
+	if err := s.repo.SaveSamples(samples); err != nil {
+		return fmt.Errorf("saving usage samples: %w", err)
+	}
+	if s.config.Retention > 0 {
+		if _, err := s.repo.DeleteBefore(now.Add(-s.config.Retention)); err != nil {
+			return fmt.Errorf("deleting old usage samples: %w", err)
+		}
+	}
+	return nil
+}
+
+// Start starts periodic sampling of usage
+func (s *UsageService) Start() {
+	if s.config.Interval <= 0 {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Sample(time.Now()); err != nil {
+				s.log.Errorw("sampling storage usage", zap.Error(err))
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *UsageService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
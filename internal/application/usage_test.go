@@ -0,0 +1,100 @@
+package application_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+type usageAccounts struct {
+	domain.AccountsRepository
+}
+
+func (r usageAccounts) GetAllAccounts() ([]domain.Account, error) {
+	return []domain.Account{{Username: "jan"}, {Username: "eva"}}, nil
+}
+
+type usageOrganizations struct {
+	domain.OrganizationsRepository
+}
+
+func (r usageOrganizations) List() ([]domain.Organization, error) {
+	return []domain.Organization{{Name: "team"}}, nil
+}
+
+type usageProjects struct {
+	application.ProjectService
+}
+
+func (s usageProjects) ProjectsNames(skipErrors bool) ([]string, error) {
+	return []string{"jan/map", "jan/other"}, nil
+}
+
+func (s usageProjects) GetProjectInfo(name string) (domain.ProjectInfo, error) {
+	return domain.ProjectInfo{Name: name, Size: 100}, nil
+}
+
+type usageLimiter struct{}
+
+func (l usageLimiter) GetAccountLimits(username string) (domain.AccountConfig, error) {
+	return domain.AccountConfig{ProjectsCountLimit: -1, ProjectSizeLimit: -1, StorageLimit: -1}, nil
+}
+
+type usageSamples struct {
+	domain.UsageRepository
+	samples map[string]domain.UsageSample
+}
+
+func (r *usageSamples) SaveSamples(samples []domain.UsageSample) error {
+	for _, s := range samples {
+		r.samples[s.Username+s.Time.String()] = s
+	}
+	return nil
+}
+
+func newUsageTest() (*application.UsageService, *usageSamples) {
+	repo := &usageSamples{samples: make(map[string]domain.UsageSample)}
+	service := application.NewUsageService(zap.NewNop().Sugar(), repo, usageAccounts{}, usageOrganizations{}, usageProjects{}, usageLimiter{}, application.UsageConfig{
+		Interval: time.Hour,
+	})
+	return service, repo
+}
+
+func TestUsageOverviewAllAccounts(t *testing.T) {
+	service, _ := newUsageTest()
+	list, err := service.Overview("username", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int64{"eva": 0, "jan": 2, "team": 0}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %d accounts, got %+v", len(expected), list)
+	}
+	for _, item := range list {
+		if count, ok := expected[item.Username]; !ok || item.ProjectsCount.Used != count {
+			t.Errorf("unexpected usage: %+v", item)
+		}
+	}
+}
+
+func TestUsageSampleInterval(t *testing.T) {
+	service, repo := newUsageTest()
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	// e.g. two instances or restart within the same interval
+	for _, now := range []time.Time{start.Add(5 * time.Minute), start.Add(40 * time.Minute)} {
+		if err := service.Sample(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(repo.samples) != 3 {
+		t.Fatalf("expected one sample of each account, got %d", len(repo.samples))
+	}
+	for _, s := range repo.samples {
+		if !s.Time.Equal(start) {
+			t.Errorf("sample time %v is not rounded to the interval", s.Time)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+type ValidationIssue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"msg"`
+	Layer    string `json:"layer,omitempty"`
+}
+
+type ValidationReport struct {
+	Errors   []ValidationIssue `json:"errors"`
+	Warnings []ValidationIssue `json:"warnings"`
+}
+
+func (r *ValidationReport) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+func (r *ValidationReport) addError(code, layer, msg string, args ...interface{}) {
+	r.Errors = append(r.Errors, ValidationIssue{Severity: SeverityError, Code: code, Layer: layer, Message: fmt.Sprintf(msg, args...)})
+}
+
+func (r *ValidationReport) addWarning(code, layer, msg string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, ValidationIssue{Severity: SeverityWarning, Code: code, Layer: layer, Message: fmt.Sprintf(msg, args...)})
+}
+
+// providers with data stored in files
+var fileProviders = domain.Flags{"ogr", "gdal", "spatialite", "delimitedtext"}
+
+// layerSourceFile returns relative path to the data file of the layer (if any)
+func layerSourceFile(lmeta domain.LayerMeta) string {
+	if !fileProviders.Has(lmeta.Provider) {
+		return ""
+	}
+	path := lmeta.SourceParams.String("path")
+	if path == "" {
+		path = lmeta.SourceParams.String("file")
+	}
+	path = strings.TrimPrefix(path, "file://")
+	if i := strings.Index(path, "|"); i != -1 {
+		path = path[:i]
+	}
+	if i := strings.Index(path, "?"); i != -1 {
+		path = path[:i]
+	}
+	if path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "/vsi") || strings.Contains(path, "://") {
+		return ""
+	}
+	return filepath.Clean(path)
+}
+
+func collectGroupNames(nodes []domain.TreeNode, names map[string]bool) {
+	for _, n := range nodes {
+		if n.IsGroup() {
+			names[n.GroupName()] = true
+			if g, ok := n.(domain.GroupTreeNode); ok && g.WmsName != "" {
+				names[g.WmsName] = true
+			}
+			collectGroupNames(n.Children(), names)
+		}
+	}
+}
+
+func extentContains(outer, inner []float64) bool {
+	return inner[0] >= outer[0] && inner[1] >= outer[1] && inner[2] <= outer[2] && inner[3] <= outer[3]
+}
+
+func (s *projectService) ValidateProject(projectName string) (ValidationReport, error) {
+	settings, err := s.repo.GetSettings(projectName)
+	if err != nil {
+		return ValidationReport{}, fmt.Errorf("reading project settings: %w", err)
+	}
+	return s.ValidateSettings(projectName, settings)
+}
+
+// ValidateSettings cross-checks project settings against current QGIS project metadata
+func (s *projectService) ValidateSettings(projectName string, settings domain.ProjectSettings) (ValidationReport, error) {
+	report := ValidationReport{Errors: []ValidationIssue{}, Warnings: []ValidationIssue{}}
+	var meta domain.QgisMeta
+	if err := s.repo.ParseQgisMetadata(projectName, &meta); err != nil {
+		return report, fmt.Errorf("parsing qgis meta: %w", err)
+	}
+	tree, err := domain.CreateTree2(meta.LayersTree)
+	if err != nil {
+		report.addError("invalid_layers_tree", "", "Invalid layers tree structure in QGIS metadata")
+	}
+	groups := make(map[string]bool)
+	collectGroupNames(tree, groups)
+
+	layerExists := func(id string) bool {
+		_, ok := meta.Layers[id]
+		return ok
+	}
+
+	for id := range settings.Layers {
+		if !layerExists(id) {
+			report.addWarning("unknown_layer", id, "Settings of layer '%s' which no longer exists in the project", id)
+		}
+	}
+	for _, id := range settings.BaseLayers {
+		if !layerExists(id) && !groups[id] {
+			report.addError("missing_base_layer", id, "Base layer '%s' doesn't exist in the project", id)
+		}
+	}
+	topics := make(map[string]bool, len(settings.Topics))
+	for _, t := range settings.Topics {
+		topics[t.ID] = true
+		for _, id := range t.Layers {
+			if !layerExists(id) {
+				report.addWarning("unknown_topic_layer", id, "Topic '%s' references layer '%s' which no longer exists", t.Title, id)
+			}
+		}
+	}
+	for _, role := range settings.Auth.Roles {
+		for id := range role.Permissions.Layers {
+			if !layerExists(id) {
+				report.addWarning("unknown_role_layer", id, "Role '%s' references layer '%s' which no longer exists", role.Name, id)
+			}
+		}
+		for id := range role.Permissions.Attributes {
+			if !layerExists(id) {
+				report.addWarning("unknown_role_layer", id, "Role '%s' references attributes of layer '%s' which no longer exists", role.Name, id)
+			}
+		}
+		for _, topicID := range role.Permissions.Topics {
+			if !topics[topicID] {
+				report.addWarning("unknown_role_topic", "", "Role '%s' references topic '%s' which doesn't exist", role.Name, topicID)
+			}
+		}
+	}
+
+	if len(settings.Extent) == 4 && len(settings.InitialExtent) == 4 {
+		if !extentContains(settings.Extent, settings.InitialExtent) {
+			report.addError("initial_extent", "", "Initial extent is outside of the project extent")
+		}
+	} else if len(settings.Extent) != 0 && len(settings.Extent) != 4 {
+		report.addError("invalid_extent", "", "Invalid project extent")
+	}
+
+	formatters := make(map[string]bool, len(settings.Formatters))
+	for _, f := range settings.Formatters {
+		var formatter struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(f, &formatter); err == nil && formatter.Name != "" {
+			formatters[formatter.Name] = true
+		}
+	}
+	for id, lset := range settings.Layers {
+		for attrName, attr := range lset.Attributes {
+			if attr.Formatter != "" && !formatters[attr.Formatter] {
+				report.addError("unknown_formatter", id, "Attribute '%s' uses undefined formatter '%s'", attrName, attr.Formatter)
+			}
+		}
+	}
+
+	layerFiles := make(map[string][]string)
+	for id, lmeta := range meta.Layers {
+		if settings.Layers[id].Flags.Has("excluded") {
+			continue
+		}
+		if path := layerSourceFile(lmeta); path != "" {
+			layerFiles[path] = append(layerFiles[path], id)
+		}
+	}
+	if len(layerFiles) > 0 {
+		paths := make([]string, 0, len(layerFiles))
+		for p := range layerFiles {
+			paths = append(paths, p)
+		}
+		filesInfo, err := s.repo.GetFilesInfo(projectName, paths...)
+		if err != nil {
+			return report, fmt.Errorf("checking project files: %w", err)
+		}
+		for path, layers := range layerFiles {
+			if _, exists := filesInfo[path]; !exists {
+				for _, id := range layers {
+					report.addError("missing_file", id, "Data file '%s' of layer '%s' is missing in the project directory", path, meta.Layers[id].Title)
+				}
+			}
+		}
+	}
+	return report, nil
+}
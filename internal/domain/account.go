@@ -21,6 +21,9 @@ var (
 	ErrAccountExists   = errors.New("Account already exists")
 	ErrAccountActive   = errors.New("Account was already activated")
 	ErrAccountNotFound = errors.New("Account not found")
+	ErrEmailExists     = errors.New("Email address is already used by another account")
+	ErrInvalidEmail    = errors.New("Invalid email address")
+	ErrInvalidUsername = errors.New("Invalid username")
 )
 
 var isValidUsername = regexp.MustCompile(`^[0-9A-Za-z_\-\.]+$`).MatchString
@@ -29,6 +32,14 @@ func validateUsername(v string) bool {
 	return len(v) < 24 && isValidUsername(v)
 }
 
+// ValidateUsername checks length and allowed characters of the username
+func ValidateUsername(username string) error {
+	if !validateUsername(username) {
+		return fmt.Errorf("%w: '%s'", ErrInvalidUsername, username)
+	}
+	return nil
+}
+
 func validateEmail(email string) bool {
 	_, err := mail.ParseAddress(email)
 	return err == nil
@@ -68,6 +79,10 @@ type Account struct {
 	Confirmed *time.Time
 	LastLogin *time.Time
 	Profile   Profile
+	// time of the last password change
+	PasswordChanged *time.Time
+	// password must be changed periodically (see PasswordPolicy.MaxAge)
+	PasswordExpiry bool
 }
 
 func (a *Account) IsActive() bool {
@@ -90,6 +105,8 @@ func (a *Account) SetPassword(password string) error {
 		return err
 	}
 	a.Password = hashedPassword
+	now := time.Now()
+	a.PasswordChanged = &now
 	return nil
 }
 
@@ -111,6 +128,15 @@ func (a *Account) FullName() string {
 	return name
 }
 
+// profile field with preferred language of the user (e.g. for emails)
+const ProfileLanguage = "lang"
+
+// Language returns preferred language of the user (empty when not set)
+func (a *Account) Language() string {
+	lang, _ := a.Profile[ProfileLanguage].(string)
+	return lang
+}
+
 // type AccountOpts struct {
 // 	IsSuperuser bool
 // }
@@ -142,9 +168,21 @@ func NewAccount(username, email, firstName, lastName, password string) (Account,
 	return account, nil
 }
 
+// NormalizeEmail returns validated email address in lower case
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !validateEmail(email) {
+		return email, fmt.Errorf("%w: '%s'", ErrInvalidEmail, email)
+	}
+	return email, nil
+}
+
 // AccountsRepository repository interface
 type AccountsRepository interface {
+	// Create saves a new account, fails with ErrAccountExists or ErrEmailExists
 	Create(account Account) error
+	// Update saves account's fields, fails with ErrEmailExists when the email address is used
+	// by another account
 	Update(account Account) error
 	UpdateProfile(account Account) error
 	UpdateProfile2(username string, profile Profile) error
@@ -155,4 +193,6 @@ type AccountsRepository interface {
 	UsernameExists(username string) (bool, error)
 	GetAllAccounts() ([]Account, error)
 	GetActiveAccounts() ([]Account, error)
+	// QueryAccounts returns page of accounts matching the query and total count of matching accounts
+	QueryAccounts(query AccountsQuery) ([]Account, int, error)
 }
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrDeletionNotRequested = errors.New("Account deletion not requested")
+
+// AccountDeletion is user's request for deletion of own account. Account is deleted after
+// the request is confirmed (by link sent to user's email) and the grace period passes.
+type AccountDeletion struct {
+	Username string `json:"username"`
+	// organization, to which user's projects are transferred (deleted when empty)
+	TransferTo string     `json:"transfer_to"`
+	Requested  time.Time  `json:"requested_at"`
+	Confirmed  *time.Time `json:"confirmed_at"`
+	// time of deletion (set on confirmation)
+	DeleteAt *time.Time `json:"delete_at"`
+}
+
+// AccountDeletionsRepository repository interface
+type AccountDeletionsRepository interface {
+	// Save creates or replaces deletion request of the user
+	Save(deletion AccountDeletion) error
+	Get(username string) (AccountDeletion, error)
+	Delete(username string) error
+	// Due returns confirmed deletions, whose time of deletion passed
+	Due(now time.Time) ([]AccountDeletion, error)
+}
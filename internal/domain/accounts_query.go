@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidQuery = errors.New("Invalid query")
+
+// Fields, by which accounts can be sorted
+var AccountsSortFields = Flags{"username", "email", "first_name", "last_name", "created_at", "last_login_at"}
+
+// AccountsQuery filters, sorts and paginates listing of accounts. All specified conditions
+// must be satisfied, text conditions are case-insensitive substring matches.
+type AccountsQuery struct {
+	// matches username, first name, last name or email
+	Search string
+	// matches username, first name or last name (doesn't disclose email addresses)
+	Lookup   string
+	Username string
+	// matches first name, last name or full name
+	Name   string
+	Email  string
+	Active *bool
+	// last login in range [LastLoginFrom, LastLoginTo)
+	LastLoginFrom *time.Time
+	LastLoginTo   *time.Time
+	// selected accounts
+	Usernames []string
+
+	// one of AccountsSortFields (username by default)
+	OrderBy    string
+	Descending bool
+	// no limit when zero
+	Limit  int
+	Offset int
+}
+
+func (q AccountsQuery) Validate() error {
+	if q.OrderBy != "" && !AccountsSortFields.Has(q.OrderBy) {
+		return fmt.Errorf("%w: unknown sort field '%s'", ErrInvalidQuery, q.OrderBy)
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("%w: invalid pagination", ErrInvalidQuery)
+	}
+	return nil
+}
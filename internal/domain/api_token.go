@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrAPITokenNotFound = errors.New("API token not found")
+
+// API token scopes
+const (
+	// read-only access to maps of accessible projects
+	ScopeMapRead = "map:read"
+	// creating and uploading (publishing from QGIS) own (or administrated) projects
+	ScopeProjectUpload = "project:upload"
+	// changing settings, publish schedule and invitations of projects
+	ScopeProjectSettings = "project:settings"
+	// deleting of projects
+	ScopeProjectDelete = "project:delete"
+	// full access with permissions of the token owner
+	ScopeAdmin = "admin"
+)
+
+var APITokenScopes = Flags{ScopeMapRead, ScopeProjectUpload, ScopeProjectSettings, ScopeProjectDelete, ScopeAdmin}
+
+// APIToken is a named personal access token. Only hash of the token value is stored.
+type APIToken struct {
+	ID       int64      `json:"id"`
+	Username string     `json:"-"`
+	Name     string     `json:"name"`
+	Prefix   string     `json:"prefix"`
+	Scopes   Flags      `json:"scopes"`
+	Created  time.Time  `json:"created_at"`
+	Expires  *time.Time `json:"expires_at"`
+	LastUsed *time.Time `json:"last_used_at"`
+}
+
+func (t APIToken) IsExpired(now time.Time) bool {
+	return t.Expires != nil && !now.Before(*t.Expires)
+}
+
+// APITokensRepository repository interface
+type APITokensRepository interface {
+	Create(token APIToken, hash string) (APIToken, error)
+	GetByHash(hash string) (APIToken, error)
+	List(username string) ([]APIToken, error)
+	Delete(username string, id int64) error
+	UpdateLastUsed(id int64, t time.Time) error
+}
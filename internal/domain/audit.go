@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Types of audit events
+const (
+	AuditLogin                = "login"
+	AuditLoginFailed          = "login_failed"
+	AuditPasswordResetRequest = "password_reset_request"
+	AuditPasswordReset        = "password_reset"
+	AuditPasswordChange       = "password_change"
+	AuditAccountActivated     = "account_activated"
+	AuditSuperuserGranted     = "superuser_granted"
+	AuditSuperuserRevoked     = "superuser_revoked"
+	AuditAccountDeleted       = "account_deleted"
+)
+
+var AuditEventTypes = Flags{
+	AuditLogin,
+	AuditLoginFailed,
+	AuditPasswordResetRequest,
+	AuditPasswordReset,
+	AuditPasswordChange,
+	AuditAccountActivated,
+	AuditSuperuserGranted,
+	AuditSuperuserRevoked,
+	AuditAccountDeleted,
+}
+
+// AuditEvent is a record of security related event of the account
+type AuditEvent struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+	// account of the event (empty for failed logins with unknown login)
+	Username string `json:"username"`
+	// user who performed the action, when it was not the account's owner (e.g. administrator)
+	Actor     string                 `json:"actor,omitempty"`
+	IP        string                 `json:"ip"`
+	UserAgent string                 `json:"user_agent"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Created   time.Time              `json:"created_at"`
+}
+
+// AuditQuery filters and paginates audit events (the newest first)
+type AuditQuery struct {
+	Username string
+	Actor    string
+	Types    []string
+	IP       string
+	// events in range [From, To)
+	From *time.Time
+	To   *time.Time
+	// no limit when zero
+	Limit  int
+	Offset int
+}
+
+func (q AuditQuery) Validate() error {
+	for _, t := range q.Types {
+		if !AuditEventTypes.Has(t) {
+			return fmt.Errorf("%w: unknown event type '%s'", ErrInvalidQuery, t)
+		}
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("%w: invalid pagination", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// AuditRepository repository interface
+type AuditRepository interface {
+	Create(event AuditEvent) error
+	// Query returns page of events matching the query and total count of matching events
+	Query(query AuditQuery) ([]AuditEvent, int, error)
+	// DeleteBefore deletes events created before the given time, returns number of deleted events
+	DeleteBefore(t time.Time) (int64, error)
+}
@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrCampaignNotFound = errors.New("Campaign not found")
+	ErrInvalidFilter    = errors.New("Invalid recipients filter")
+)
+
+// Statuses of email campaigns
+const (
+	CampaignDraft     = "draft"
+	CampaignScheduled = "scheduled"
+	CampaignSending   = "sending"
+	CampaignCompleted = "completed"
+	CampaignCancelled = "cancelled"
+)
+
+// Statuses of campaign recipients
+const (
+	RecipientPending = "pending"
+	// email was handed over for delivery, i.e. sent by SMTP or only enqueued when the email
+	// outbox is enabled (delivery failures are then tracked in the outbox)
+	RecipientSent   = "sent"
+	RecipientFailed = "failed"
+)
+
+var RecipientStatuses = Flags{RecipientPending, RecipientSent, RecipientFailed}
+
+// RecipientsFilter selects recipients of email campaign, all specified conditions must be satisfied
+type RecipientsFilter struct {
+	// explicit list of usernames (all accounts when empty)
+	Users []string `json:"users,omitempty"`
+	// only active accounts
+	Active bool `json:"active"`
+	// users with access to the project
+	Project string `json:"project,omitempty"`
+	// users with the given value of profile field (or list of values containing it)
+	ProfileField string `json:"profile_field,omitempty"`
+	ProfileValue string `json:"profile_value,omitempty"`
+}
+
+func (f RecipientsFilter) Validate() error {
+	if len(f.Users) == 0 && !f.Active && f.Project == "" && f.ProfileField == "" {
+		return fmt.Errorf("%w: no condition specified", ErrInvalidFilter)
+	}
+	if f.ProfileValue != "" && f.ProfileField == "" {
+		return fmt.Errorf("%w: profile field not specified", ErrInvalidFilter)
+	}
+	return nil
+}
+
+// MatchProfile checks the profile condition of the filter
+func (f RecipientsFilter) MatchProfile(p Profile) bool {
+	if f.ProfileField == "" {
+		return true
+	}
+	switch v := p[f.ProfileField].(type) {
+	case nil:
+		return false
+	case []interface{}:
+		for _, item := range v {
+			if fmt.Sprint(item) == f.ProfileValue {
+				return true
+			}
+		}
+		return false
+	case []string:
+		return StringArray(v).Has(f.ProfileValue)
+	default:
+		return fmt.Sprint(v) == f.ProfileValue
+	}
+}
+
+type CampaignStats struct {
+	Total   int `json:"total"`
+	Pending int `json:"pending"`
+	Sent    int `json:"sent"`
+	Failed  int `json:"failed"`
+}
+
+// Campaign is bulk email sent to selected users in the background
+type Campaign struct {
+	ID           int64            `json:"id"`
+	Subject      string           `json:"subject"`
+	HtmlTemplate string           `json:"html_template"`
+	TextTemplate string           `json:"text_template"`
+	Style        string           `json:"style"`
+	Filter       RecipientsFilter `json:"filter"`
+	Status       string           `json:"status"`
+	// time of sending (when scheduled)
+	SendAt    *time.Time    `json:"send_at"`
+	CreatedBy string        `json:"created_by"`
+	Created   *time.Time    `json:"created_at"`
+	Started   *time.Time    `json:"started_at"`
+	Finished  *time.Time    `json:"finished_at"`
+	Stats     CampaignStats `json:"stats"`
+}
+
+type CampaignRecipient struct {
+	Username string     `json:"username"`
+	Email    string     `json:"email"`
+	Status   string     `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	Sent     *time.Time `json:"sent_at"` // time of handing over for delivery (see RecipientSent)
+}
+
+// CampaignsRepository repository interface
+type CampaignsRepository interface {
+	Create(campaign Campaign) (Campaign, error)
+	// Update saves campaign's content, filter and status
+	Update(campaign Campaign) error
+	Delete(id int64) error
+	// Get returns campaign including its stats
+	Get(id int64) (Campaign, error)
+	List() ([]Campaign, error)
+	// DueCampaigns returns campaigns being sent and scheduled campaigns, whose time of sending passed
+	DueCampaigns(now time.Time) ([]Campaign, error)
+	// StartCampaign atomically changes status of the scheduled campaign to sending and adds its recipients.
+	// Returns false when the campaign is not scheduled (e.g. it was already started by another instance).
+	StartCampaign(id int64, started time.Time, recipients []CampaignRecipient) (bool, error)
+	// FinishCampaign changes status of the campaign being sent to completed, when there are no pending
+	// recipients. Returns false when the campaign was not finished.
+	FinishCampaign(id int64, finished time.Time) (bool, error)
+
+	GetRecipients(id int64, status string, limit, offset int) ([]CampaignRecipient, error)
+	// ClaimRecipients returns pending recipients and locks them for sending until the lock expires,
+	// so they are not returned to other instances
+	ClaimRecipients(id int64, now time.Time, limit int, lock time.Duration) ([]CampaignRecipient, error)
+	SetRecipientStatus(id int64, recipient CampaignRecipient) error
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrGroupNotFound = errors.New("Group not found")
+	ErrGroupExists   = errors.New("Group already exists")
+)
+
+// prefix of user list entries referencing server-managed user groups, e.g. "group:editors"
+const GroupPrefix = "group:"
+
+var isValidGroupName = regexp.MustCompile(`^[0-9A-Za-z_\-\.]{1,50}$`).MatchString
+
+// Group is a named set of users managed by administrators
+type Group struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Created     *time.Time `json:"created_at"`
+	Members     []string   `json:"members"`
+}
+
+func NewGroup(name, description string) (Group, error) {
+	name = strings.TrimSpace(name)
+	if !isValidGroupName(name) {
+		return Group{}, fmt.Errorf("%w: '%s'", ErrInvalidName, name)
+	}
+	now := time.Now()
+	return Group{Name: name, Description: strings.TrimSpace(description), Created: &now, Members: []string{}}, nil
+}
+
+// GroupsRepository repository interface
+type GroupsRepository interface {
+	// Create saves a new group with its members (all or nothing)
+	Create(group Group) error
+	Update(group Group) error
+	Delete(name string) error
+	Get(name string) (Group, error)
+	List() ([]Group, error)
+	// SetMembers replaces members of the group
+	SetMembers(name string, usernames []string) error
+	GetUserGroups(username string) ([]string, error)
+}
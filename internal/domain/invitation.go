@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvitationNotFound = errors.New("Invitation not found")
+	ErrInvitationExpired  = errors.New("Invitation expired")
+	ErrInvitationClosed   = errors.New("Invitation was already accepted or revoked")
+	ErrInvalidGrant       = errors.New("Invalid invitation grant")
+)
+
+// Types of grants carried by invitations
+const (
+	// adds user into project's list of users (Auth.Users)
+	GrantProjectAccess = "project_access"
+	// adds user into the project role (role's users)
+	GrantProjectRole = "project_role"
+	// adds user into project's settings administrators (SettingsAuth.AdminUsers)
+	GrantProjectAdmin = "project_admin"
+)
+
+var GrantTypes = Flags{GrantProjectAccess, GrantProjectRole, GrantProjectAdmin}
+
+// Statuses of invitations
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+type InvitationGrant struct {
+	Type string `json:"type"`
+	// name of the project role (GrantProjectRole)
+	Role string `json:"role,omitempty"`
+}
+
+func (g InvitationGrant) Validate() error {
+	if !GrantTypes.Has(g.Type) {
+		return fmt.Errorf("%w: unknown type '%s'", ErrInvalidGrant, g.Type)
+	}
+	if g.Type == GrantProjectRole && g.Role == "" {
+		return fmt.Errorf("%w: role not specified", ErrInvalidGrant)
+	}
+	return nil
+}
+
+// Invitation of a new or existing user into the project. Grants are applied when the user
+// accepts the invitation (new users by activation of their account).
+type Invitation struct {
+	ID       int64             `json:"id"`
+	Username string            `json:"username"`
+	Email    string            `json:"email"`
+	Project  string            `json:"project"`
+	Grants   []InvitationGrant `json:"grants"`
+	// invitation created a new account
+	NewAccount bool       `json:"new_account"`
+	InvitedBy  string     `json:"invited_by"`
+	Created    time.Time  `json:"created_at"`
+	Expires    time.Time  `json:"expires_at"`
+	Accepted   *time.Time `json:"accepted_at"`
+	Revoked    *time.Time `json:"revoked_at"`
+}
+
+func (i Invitation) Status(now time.Time) string {
+	switch {
+	case i.Accepted != nil:
+		return InvitationAccepted
+	case i.Revoked != nil:
+		return InvitationRevoked
+	case now.After(i.Expires):
+		return InvitationExpired
+	}
+	return InvitationPending
+}
+
+// InvitationsRepository repository interface
+type InvitationsRepository interface {
+	Create(invitation Invitation) (Invitation, error)
+	Get(id int64) (Invitation, error)
+	// Update saves acceptance or revocation of the invitation
+	Update(invitation Invitation) error
+	// ProjectInvitations returns all invitations into the project (the newest first)
+	ProjectInvitations(project string) ([]Invitation, error)
+	// UserInvitations returns all invitations of the user (the newest first)
+	UserInvitations(username string) ([]Invitation, error)
+	// All returns all invitations (the newest first)
+	All() ([]Invitation, error)
+}
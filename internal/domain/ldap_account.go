@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// LDAPAccount is a local account managed by LDAP directory, with user's groups synchronized on login
+type LDAPAccount struct {
+	Username string
+	Groups   []string
+	Synced   time.Time
+}
+
+type LDAPAccountsRepository interface {
+	// Get returns ErrAccountNotFound when the account is not managed by LDAP
+	Get(username string) (LDAPAccount, error)
+	// Save creates or updates LDAP account
+	Save(account LDAPAccount) error
+}
@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrIdentityLinked   = errors.New("identity is already linked to another account")
+)
+
+// OIDCIdentity binds identity of OpenID Connect provider (issuer and subject claims)
+// to a local account
+type OIDCIdentity struct {
+	Issuer   string
+	Subject  string
+	Username string
+	Created  time.Time
+}
+
+// OIDCIdentityRepository repository interface
+type OIDCIdentityRepository interface {
+	Get(issuer, subject string) (OIDCIdentity, error)
+	// Create saves a new identity, returns ErrIdentityLinked when the identity is already linked
+	Create(identity OIDCIdentity) error
+}
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("Organization not found")
+	ErrOrganizationExists   = errors.New("Organization or user with this name already exists")
+	ErrTeamNotFound         = errors.New("Team not found")
+	ErrInvalidName          = errors.New("Invalid name")
+)
+
+// Roles of organization members
+const (
+	// full control, including management of owners and deletion of the organization
+	OrgRoleOwner = "owner"
+	// management of organization's projects, members and teams
+	OrgRoleAdmin = "admin"
+	// access to organization's projects
+	OrgRoleMember = "member"
+)
+
+var OrganizationRoles = Flags{OrgRoleOwner, OrgRoleAdmin, OrgRoleMember}
+
+// prefixes of user list entries referencing all members of organization (e.g. "org:acme")
+// or members of organization's team (e.g. "team:acme/editors")
+const (
+	OrganizationPrefix = "org:"
+	TeamPrefix         = "team:"
+)
+
+// Organization is a namespace of projects shared by its members. Projects of organization
+// are stored in the same way as projects of users, so names of organizations and users
+// must not collide.
+type Organization struct {
+	Name    string     `json:"name"`
+	Title   string     `json:"title"`
+	Created *time.Time `json:"created_at"`
+	// limits overriding default account limits (nil when not set)
+	ProjectsLimit    *int      `json:"projects_limit"`
+	ProjectSizeLimit *ByteSize `json:"project_size_limit"`
+	StorageLimit     *ByteSize `json:"storage_limit"`
+}
+
+// ApplyLimits overrides limits of the account config with organization's limits
+func (o Organization) ApplyLimits(config AccountConfig) AccountConfig {
+	if o.ProjectsLimit != nil {
+		config.ProjectsCountLimit = *o.ProjectsLimit
+	}
+	if o.ProjectSizeLimit != nil {
+		config.ProjectSizeLimit = *o.ProjectSizeLimit
+	}
+	if o.StorageLimit != nil {
+		config.StorageLimit = *o.StorageLimit
+	}
+	return config
+}
+
+type OrganizationMember struct {
+	Organization string     `json:"organization"`
+	Username     string     `json:"username"`
+	Role         string     `json:"role"`
+	Created      *time.Time `json:"created_at"`
+}
+
+type Team struct {
+	Organization string   `json:"organization"`
+	Name         string   `json:"name"`
+	Members      []string `json:"members"`
+}
+
+func NewOrganization(name, title string) (Organization, error) {
+	name = strings.TrimSpace(name)
+	if !validateUsername(name) {
+		return Organization{}, fmt.Errorf("%w: '%s'", ErrInvalidName, name)
+	}
+	now := time.Now()
+	return Organization{Name: name, Title: strings.TrimSpace(title), Created: &now}, nil
+}
+
+func ValidateTeamName(name string) bool {
+	return validateUsername(name)
+}
+
+// OrganizationsRepository repository interface
+type OrganizationsRepository interface {
+	// Create creates organization with the given owner, fails with ErrOrganizationExists also
+	// when user with the same name exists
+	Create(org Organization, owner string) error
+	Update(org Organization) error
+	Delete(name string) error
+	Get(name string) (Organization, error)
+	List() ([]Organization, error)
+
+	GetMembers(org string) ([]OrganizationMember, error)
+	SetMember(member OrganizationMember) error
+	RemoveMember(org, username string) error
+
+	GetTeams(org string) ([]Team, error)
+	SaveTeam(team Team) error
+	DeleteTeam(org, name string) error
+
+	GetUserMemberships(username string) (Memberships, error)
+}
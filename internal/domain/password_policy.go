@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrPasswordExpired = errors.New("Password expired")
+
+// Password character classes
+const (
+	CharClassLower  = "lower"
+	CharClassUpper  = "upper"
+	CharClassDigit  = "digit"
+	CharClassSymbol = "symbol"
+)
+
+var PasswordCharClasses = Flags{CharClassLower, CharClassUpper, CharClassDigit, CharClassSymbol}
+
+// PasswordBlocklist checks passwords against a list of common or breached passwords
+type PasswordBlocklist interface {
+	Contains(password string) (bool, error)
+}
+
+// PasswordViolation describes a single unsatisfied rule of password policy
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError is returned when password doesn't satisfy the password policy
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+type PasswordPolicy struct {
+	MinLength int
+	// required character classes
+	CharClasses Flags
+	// reject passwords containing username or email address
+	DisallowUserInfo bool
+	Blocklists       []PasswordBlocklist
+	// maximal age of passwords of accounts with enabled password expiry (0 - no expiry)
+	MaxAge time.Duration
+}
+
+func charClass(r rune) string {
+	switch {
+	case unicode.IsLower(r):
+		return CharClassLower
+	case unicode.IsUpper(r):
+		return CharClassUpper
+	case unicode.IsDigit(r):
+		return CharClassDigit
+	default:
+		return CharClassSymbol
+	}
+}
+
+var charClassNames = map[string]string{
+	CharClassLower:  "a lowercase letter",
+	CharClassUpper:  "an uppercase letter",
+	CharClassDigit:  "a digit",
+	CharClassSymbol: "a special character",
+}
+
+// Validate checks the password of the account. Returns *PasswordPolicyError with all
+// violated rules, or other error when the check couldn't be performed.
+func (p PasswordPolicy) Validate(password string, account Account) error {
+	var violations []PasswordViolation
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Code:    "too_short",
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.MinLength),
+		})
+	}
+	if len(p.CharClasses) > 0 {
+		classes := Flags{}
+		for _, r := range password {
+			if c := charClass(r); !classes.Has(c) {
+				classes = append(classes, c)
+			}
+		}
+		for _, c := range p.CharClasses {
+			if !classes.Has(c) {
+				violations = append(violations, PasswordViolation{
+					Code:    "missing_" + c,
+					Message: fmt.Sprintf("Password must contain %s", charClassNames[c]),
+				})
+			}
+		}
+	}
+	if p.DisallowUserInfo {
+		lower := strings.ToLower(password)
+		localPart, _, _ := strings.Cut(account.Email, "@")
+		for _, info := range []string{account.Username, localPart} {
+			if len(info) >= 3 && strings.Contains(lower, strings.ToLower(info)) {
+				violations = append(violations, PasswordViolation{
+					Code:    "user_info",
+					Message: "Password must not contain username or email address",
+				})
+				break
+			}
+		}
+	}
+	for _, list := range p.Blocklists {
+		found, err := list.Contains(password)
+		if err != nil {
+			return fmt.Errorf("checking password blocklist: %w", err)
+		}
+		if found {
+			violations = append(violations, PasswordViolation{
+				Code:    "breached",
+				Message: "Password is too common or was found in a data breach",
+			})
+			break
+		}
+	}
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// PasswordExpired checks whether the password of the account expired (only for accounts
+// with enabled password expiry)
+func (p PasswordPolicy) PasswordExpired(account Account, now time.Time) bool {
+	if p.MaxAge <= 0 || !account.PasswordExpiry || len(account.Password) == 0 {
+		return false
+	}
+	return account.PasswordChanged == nil || now.Sub(*account.PasswordChanged) > p.MaxAge
+}
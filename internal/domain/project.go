@@ -10,6 +10,9 @@ var (
 	ErrProjectNotExists     = errors.New("project does not exists")
 	ErrFileNotExists        = errors.New("project file does not exists")
 	ErrProjectAlreadyExists = errors.New("project already exists")
+	ErrNoDraftSettings      = errors.New("project has no draft settings")
+	ErrNoPreviousSettings   = errors.New("project has no previous settings")
+	ErrNoSettings           = errors.New("project is not configured")
 )
 
 // Old code, currently used in mapcache package
@@ -163,11 +166,7 @@ func checkUserRole(u User, role ProjectRole) bool {
 		return !u.IsAuthenticated
 	}
 	if role.Auth == "users" {
-		for _, username := range role.Users {
-			if u.Username == username {
-				return true
-			}
-		}
+		return u.InList(role.Users)
 	}
 	return false
 }
@@ -209,7 +208,11 @@ type ProjectsRepository interface {
 	AllProjects(skipErrors bool) ([]string, error)
 	UserProjects(user string) ([]string, error) // or should it require User object?
 	GetProjectInfo(name string) (ProjectInfo, error)
+	UpdateProjectInfo(name string, update func(info *ProjectInfo) error) (ProjectInfo, error)
+	SetAuthentication(name, authType string) error
 	Delete(name string) error
+	// Move renames the project (e.g. to another namespace)
+	Move(name, newName string) error
 	// SaveFile(projectName, filename string, r io.Reader) error
 	CreateFile(projectName, directory, pattern string, r io.Reader) (ProjectFile, error)
 	SaveFile(project string, finfo ProjectFile, path string) error
@@ -223,6 +226,14 @@ type ProjectsRepository interface {
 
 	GetSettings(projectName string) (ProjectSettings, error)
 	UpdateSettings(projectName string, data json.RawMessage) error
+	GetDraftSettings(projectName string) (ProjectSettings, error)
+	SaveDraftSettings(projectName string, data json.RawMessage) error
+	DeleteDraftSettings(projectName string) error
+	PromoteDraftSettings(projectName string) error
+	RollbackSettings(projectName string) error
+	// PatchSettings modifies settings document of the project (also its draft), fails with
+	// ErrNoSettings when the project is not configured yet
+	PatchSettings(projectName string, patch func(settings map[string]interface{}) error) error
 
 	GetThumbnailPath(projectName string) string
 	SaveThumbnail(projectName string, r io.Reader) error
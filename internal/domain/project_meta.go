@@ -19,10 +19,45 @@ type ProjectInfo struct {
 	Projection     string    `json:"projection"` // projection code
 	Mapcache       bool      `json:"mapcache"`
 	Authentication string    `json:"authentication"`
-	// empty, pending update, hidden
-	State     string `json:"state"`
-	Size      int64  `json:"size"` // size in bytes
-	Thumbnail bool   `json:"thumbnail"`
+	// empty, staged, published, scheduled (waiting for publish time), unpublished
+	State     string           `json:"state"`
+	Size      int64            `json:"size"` // size in bytes
+	Thumbnail bool             `json:"thumbnail"`
+	Schedule  *PublishSchedule `json:"schedule,omitempty"`
+}
+
+const (
+	UnpublishActionHide    = "unpublish"
+	UnpublishActionPrivate = "private"
+)
+
+type PublishSchedule struct {
+	Publish   *time.Time `json:"publish,omitempty"`
+	Unpublish *time.Time `json:"unpublish,omitempty"`
+	// action applied at unpublish time: "unpublish" (default) or "private" (switch authentication to private)
+	UnpublishAction string `json:"unpublish_action,omitempty"`
+}
+
+// Unpublished returns true when the project is waiting for scheduled publish time or was unpublished
+func (p ProjectInfo) Unpublished() bool {
+	return p.State == "scheduled" || p.State == "unpublished"
+}
+
+func (s *PublishSchedule) IsEmpty() bool {
+	return s == nil || (s.Publish == nil && s.Unpublish == nil)
+}
+
+// Due returns true when some scheduled action (publish or unpublish) should be performed
+func (s *PublishSchedule) Due(now time.Time) bool {
+	if s == nil {
+		return false
+	}
+	return (s.Publish != nil && !now.Before(*s.Publish)) || (s.Unpublish != nil && !now.Before(*s.Unpublish))
+}
+
+// PendingPublish returns true when publishing of the project is scheduled to the future
+func (s *PublishSchedule) PendingPublish(now time.Time) bool {
+	return s != nil && s.Publish != nil && s.Publish.After(now)
 }
 
 type LayerNode struct {
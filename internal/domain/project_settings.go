@@ -82,6 +82,7 @@ type Geocoding struct {
 }
 
 type ProjectSettings struct {
+	Version          int                      `json:"version"`
 	Auth             Authentication           `json:"auth"`
 	SettingsAuth     SettingsAuthentication   `json:"settings_auth"`
 	BaseLayers       []string                 `json:"base_layers"`
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SettingsVersion is the current version of project settings format
+const SettingsVersion = 1
+
+var (
+	ErrUnsupportedSettingsVersion = errors.New("unsupported settings version")
+	ErrInvalidSettingsDocument    = errors.New("settings document must be a JSON object")
+)
+
+// settingsMigrations[i] upgrades settings document from version i to version i+1
+var settingsMigrations = []func(map[string]interface{}) error{
+	migrateSettingsV1,
+}
+
+// Converts legacy (unversioned) settings format:
+//   - 'root_title' is replaced by 'title'
+//   - layers 'attr_table_fields' and 'info_panel_fields' are moved into 'fields_order'
+//   - plain array values of layers 'fields_order' and 'excluded_fields' are moved into 'global' field
+func migrateSettingsV1(data map[string]interface{}) error {
+	if rootTitle, ok := data["root_title"]; ok {
+		if title, _ := data["title"].(string); title == "" {
+			data["title"] = rootTitle
+		}
+		delete(data, "root_title")
+	}
+	layers, _ := data["layers"].(map[string]interface{})
+	for _, l := range layers {
+		lset, ok := l.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"fields_order", "excluded_fields"} {
+			if arr, ok := lset[key].([]interface{}); ok {
+				lset[key] = map[string]interface{}{"global": arr}
+			}
+		}
+		legacyFields := map[string]string{
+			"attr_table_fields": "table",
+			"info_panel_fields": "infopanel",
+		}
+		for legacyKey, key := range legacyFields {
+			fields, ok := lset[legacyKey].([]interface{})
+			delete(lset, legacyKey)
+			if !ok || len(fields) == 0 {
+				continue
+			}
+			order, ok := lset["fields_order"].(map[string]interface{})
+			if !ok {
+				order = make(map[string]interface{})
+				lset["fields_order"] = order
+			}
+			if _, exists := order[key]; !exists {
+				order[key] = fields
+			}
+		}
+	}
+	return nil
+}
+
+// MigrateSettings upgrades settings document to the current format version. Returns
+// original document when it's already up to date, and version of the original document.
+func MigrateSettings(data []byte) ([]byte, int, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, 0, ErrInvalidSettingsDocument
+	}
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, 0, err
+	}
+	if header.Version == SettingsVersion {
+		return data, header.Version, nil
+	}
+	if header.Version < 0 || header.Version > SettingsVersion {
+		return nil, header.Version, fmt.Errorf("%w: %d", ErrUnsupportedSettingsVersion, header.Version)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, header.Version, err
+	}
+	if doc == nil {
+		return nil, header.Version, ErrInvalidSettingsDocument
+	}
+	for v := header.Version; v < SettingsVersion; v++ {
+		if err := settingsMigrations[v](doc); err != nil {
+			return nil, header.Version, fmt.Errorf("migrating settings to version %d: %w", v+1, err)
+		}
+	}
+	doc["version"] = SettingsVersion
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, header.Version, err
+	}
+	return migrated, header.Version, nil
+}
+
+// UnmarshalJSON decodes settings document, older versions are upgraded to the current format
+func (s *ProjectSettings) UnmarshalJSON(data []byte) error {
+	migrated, _, err := MigrateSettings(data)
+	if err != nil {
+		return err
+	}
+	type settings ProjectSettings
+	return json.Unmarshal(migrated, (*settings)(s))
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		output  string
+		version int
+		err     error
+	}{
+		{
+			name:    "legacy root title",
+			input:   `{"root_title": "Legacy"}`,
+			output:  `{"title": "Legacy", "version": 1}`,
+			version: 0,
+		},
+		{
+			name:    "legacy root title with title",
+			input:   `{"root_title": "Legacy", "title": "Current"}`,
+			output:  `{"title": "Current", "version": 1}`,
+			version: 0,
+		},
+		{
+			name:    "legacy fields lists",
+			input:   `{"layers": {"l1": {"fields_order": ["a", "b"], "excluded_fields": ["c"], "attr_table_fields": ["b"], "info_panel_fields": []}}}`,
+			output:  `{"layers": {"l1": {"fields_order": {"global": ["a", "b"], "table": ["b"]}, "excluded_fields": {"global": ["c"]}}}, "version": 1}`,
+			version: 0,
+		},
+		{
+			name:    "legacy fields without order",
+			input:   `{"layers": {"l1": {"info_panel_fields": ["x"]}}}`,
+			output:  `{"layers": {"l1": {"fields_order": {"infopanel": ["x"]}}}, "version": 1}`,
+			version: 0,
+		},
+		{
+			name:    "current version",
+			input:   `{"title": "Current", "root_title": "kept", "version": 1}`,
+			output:  `{"title": "Current", "root_title": "kept", "version": 1}`,
+			version: 1,
+		},
+		{name: "future version", input: `{"version": 2}`, version: 2, err: ErrUnsupportedSettingsVersion},
+		{name: "negative version", input: `{"version": -1}`, version: -1, err: ErrUnsupportedSettingsVersion},
+		{name: "null document", input: `null`, err: ErrInvalidSettingsDocument},
+		{name: "array document", input: `[{"version": 1}]`, err: ErrInvalidSettingsDocument},
+		{name: "empty document", input: ``, err: ErrInvalidSettingsDocument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrated, version, err := MigrateSettings([]byte(tt.input))
+			if version != tt.version {
+				t.Errorf("version = %d, want %d", version, tt.version)
+			}
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected error %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(migrated, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.output), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %s, want %s", migrated, tt.output)
+			}
+
+			// migrated document is up to date and is not changed again
+			again, version, err := MigrateSettings(migrated)
+			if err != nil || version != SettingsVersion || string(again) != string(migrated) {
+				t.Errorf("second migration changed the document: %s (version %d, %v)", again, version, err)
+			}
+		})
+	}
+}
+
+func TestProjectSettingsUnmarshalNull(t *testing.T) {
+	var settings ProjectSettings
+	if err := json.Unmarshal([]byte(`null`), &settings); !errors.Is(err, ErrInvalidSettingsDocument) {
+		t.Errorf("expected ErrInvalidSettingsDocument, got %v", err)
+	}
+}
+
+func TestProjectSettingsUnmarshalLegacy(t *testing.T) {
+	var settings ProjectSettings
+	if err := json.Unmarshal([]byte(`{"root_title": "Legacy"}`), &settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.Title != "Legacy" || settings.Version != SettingsVersion {
+		t.Errorf("got title %q and version %d", settings.Title, settings.Version)
+	}
+}
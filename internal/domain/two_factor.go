@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrTwoFactorNotConfigured = errors.New("Two-factor authentication is not configured")
+
+// TwoFactorConfig holds user's TOTP secret. Authentication is required only when enabled
+// (enrollment was confirmed with valid code).
+type TwoFactorConfig struct {
+	Username string
+	Secret   string
+	Enabled  bool
+	// hashes of unused recovery codes
+	RecoveryCodes Flags
+	// last accepted TOTP time step, to prevent code reuse
+	LastStep int64
+	Created  time.Time
+}
+
+// TwoFactorRepository repository interface
+type TwoFactorRepository interface {
+	Get(username string) (TwoFactorConfig, error)
+	Save(cfg TwoFactorConfig) error
+	Delete(username string) error
+}
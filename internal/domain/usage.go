@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// UsageSample is periodically recorded storage usage of the account (user or organization)
+type UsageSample struct {
+	Username      string    `json:"-"`
+	Time          time.Time `json:"time"`
+	ProjectsCount int       `json:"projects_count"`
+	StorageSize   int64     `json:"storage_size"`
+}
+
+// UsageRepository repository interface
+type UsageRepository interface {
+	SaveSamples(samples []UsageSample) error
+	// Samples returns samples of the account recorded since the given time (the oldest first)
+	Samples(username string, from time.Time) ([]UsageSample, error)
+	// DeleteBefore deletes samples recorded before the given time, returns number of deleted samples
+	DeleteBefore(t time.Time) (int64, error)
+}
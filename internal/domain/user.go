@@ -1,14 +1,94 @@
 package domain
 
+import "strings"
+
+// prefix of user list entries referencing LDAP groups, e.g. "ldap:gis-editors"
+const LDAPGroupPrefix = "ldap:"
+
 type User struct {
-	Username        string  `json:"username"`
-	Email           string  `json:"email"`
-	FirstName       string  `json:"first_name"`
-	LastName        string  `json:"last_name"`
-	IsSuperuser     bool    `json:"is_superuser"`
-	IsAuthenticated bool    `json:"-"`
-	IsGuest         bool    `json:"is_guest"`
-	Profile         Profile `json:"profile,omitempty"`
+	Username        string   `json:"username"`
+	Email           string   `json:"email"`
+	FirstName       string   `json:"first_name"`
+	LastName        string   `json:"last_name"`
+	IsSuperuser     bool     `json:"is_superuser"`
+	IsAuthenticated bool     `json:"-"`
+	IsGuest         bool     `json:"is_guest"`
+	Profile         Profile  `json:"profile,omitempty"`
+	LDAPGroups      []string `json:"-"`
+	// organization name -> role
+	Organizations map[string]string `json:"organizations,omitempty"`
+	// teams in "organization/team" format
+	Teams []string `json:"teams,omitempty"`
+	// server-managed user groups
+	Groups []string `json:"groups,omitempty"`
+	// set when the user is authenticated with API token
+	TokenID     int64 `json:"-"`
+	TokenScopes Flags `json:"-"`
+}
+
+// Memberships of a single user in organizations, teams, user groups and LDAP groups
+type Memberships struct {
+	// organization name -> role
+	Organizations map[string]string
+	// teams in "organization/team" format
+	Teams []string
+	// server-managed user groups
+	Groups []string
+	// groups of LDAP account from the last login
+	LDAPGroups []string
+}
+
+// HasScope checks whether the request is authorized for the given API token scope. Users
+// authenticated without API token (session, basic auth) are not restricted.
+func (u User) HasScope(scope string) bool {
+	if u.TokenID == 0 {
+		return true
+	}
+	return u.TokenScopes.Has(ScopeAdmin) || u.TokenScopes.Has(scope)
+}
+
+// InList checks whether the user is referenced in the list of users, either by username
+// or by membership in referenced LDAP group, user group, organization or team
+func (u User) InList(users []string) bool {
+	for _, entry := range users {
+		if entry == u.Username {
+			return true
+		}
+		if strings.HasPrefix(entry, LDAPGroupPrefix) && contains(u.LDAPGroups, strings.TrimPrefix(entry, LDAPGroupPrefix)) {
+			return true
+		}
+		if strings.HasPrefix(entry, GroupPrefix) && contains(u.Groups, strings.TrimPrefix(entry, GroupPrefix)) {
+			return true
+		}
+		if strings.HasPrefix(entry, OrganizationPrefix) && u.OrganizationRole(strings.TrimPrefix(entry, OrganizationPrefix)) != "" {
+			return true
+		}
+		if strings.HasPrefix(entry, TeamPrefix) && contains(u.Teams, strings.TrimPrefix(entry, TeamPrefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// OrganizationRole returns user's role in the organization (empty string if not a member)
+func (u User) OrganizationRole(org string) string {
+	return u.Organizations[org]
+}
+
+// IsNamespaceMember checks whether the namespace (first part of project name) belongs to the user
+// or to organization the user is member of
+func (u User) IsNamespaceMember(namespace string) bool {
+	return u.IsAuthenticated && (namespace == u.Username || u.OrganizationRole(namespace) != "")
+}
+
+// CanManageNamespace checks whether the user can create and manage projects in the namespace,
+// i.e. it is user's own namespace or the user is owner or admin of the organization
+func (u User) CanManageNamespace(namespace string) bool {
+	if !u.IsAuthenticated {
+		return false
+	}
+	role := u.OrganizationRole(namespace)
+	return namespace == u.Username || role == OrgRoleOwner || role == OrgRoleAdmin
 }
 
 func AccountToUser(account Account) User {
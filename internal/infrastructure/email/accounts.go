@@ -5,6 +5,7 @@ import (
 	"fmt"
 	htmltemplate "html/template"
 	"net/url"
+	"strconv"
 	texttemplate "text/template"
 
 	"github.com/gisquick/gisquick-server/internal/domain"
@@ -13,43 +14,32 @@ import (
 )
 
 type AccountsEmailSender struct {
-	client               EmailService
-	sender               string
-	siteURL              string
-	activationSubject    string
-	passwordResetSubject string
-	templates            map[string]EmailTemplate
+	client    EmailService
+	sender    string
+	siteURL   string
+	templates *Templates
 }
 
-type EmailTemplate struct {
-	HTML *htmltemplate.Template
-	Text *texttemplate.Template
-}
-
-func parseEmailTemplate(name string) EmailTemplate {
-	funcs := map[string]any{
-		"query_escape": url.QueryEscape,
+func NewAccountsEmailSender(client EmailService, sender, siteURL string, templates *Templates) *AccountsEmailSender {
+	return &AccountsEmailSender{
+		client:    client,
+		sender:    sender,
+		siteURL:   siteURL,
+		templates: templates,
 	}
-	htmlFuncs := htmltemplate.FuncMap(funcs)
-	textFuncs := texttemplate.FuncMap(funcs)
-	html := htmltemplate.Must(htmltemplate.New("email").Funcs(htmlFuncs).ParseFiles("./templates/email_base.html", fmt.Sprintf("%s.html", name)))
-	text := texttemplate.Must(texttemplate.New("email").Funcs(textFuncs).ParseFiles("./templates/email_base.txt", fmt.Sprintf("%s.txt", name)))
-	return EmailTemplate{HTML: html, Text: text}
 }
 
-func NewAccountsEmailSender(client EmailService, sender, siteURL, activationSubject, passwordResetSubject string) *AccountsEmailSender {
-	templates := make(map[string]EmailTemplate, 3)
-	templates["activation_email"] = parseEmailTemplate("./templates/activation_email")
-	templates["invitation_email"] = parseEmailTemplate("./templates/invitation_email")
-	templates["password_reset_email"] = parseEmailTemplate("./templates/reset_password_email")
-	return &AccountsEmailSender{
-		client:               client,
-		sender:               sender,
-		siteURL:              siteURL,
-		activationSubject:    activationSubject,
-		passwordResetSubject: passwordResetSubject,
-		templates:            templates,
+// sendTemplateEmail sends email composed from the template in the preferred language of the account
+func (s *AccountsEmailSender) sendTemplateEmail(account domain.Account, name string, data map[string]interface{}) error {
+	template, err := s.templates.Get(name, account.Language())
+	if err != nil {
+		return fmt.Errorf("loading email template: %w", err)
+	}
+	email, err := s.composeEmail(account, template.Subject, template.HTML, template.Text, data)
+	if err != nil {
+		return err
 	}
+	return s.client.SendEmail(email)
 }
 
 func (s *AccountsEmailSender) SendActivationEmail(account domain.Account, uid, token string, data map[string]interface{}) error {
@@ -60,34 +50,14 @@ func (s *AccountsEmailSender) SendActivationEmail(account domain.Account, uid, t
 	params.Set("token", token)
 	activationUrl.RawQuery = params.Encode()
 	data = maps.NewMap(data)
-	data["User"] = &account
-	data["SiteURL"] = s.siteURL
 	data["ActivationLink"] = activationUrl.String()
 	data["uid"] = uid
 	data["token"] = token
-	template := "activation_email"
+	template := ActivationTemplate
 	if len(account.Password) == 0 {
-		template = "invitation_email"
-	}
-	var htmlMsg, textMsg bytes.Buffer
-	if err := s.templates[template].HTML.ExecuteTemplate(&htmlMsg, "email", data); err != nil {
-		return err
+		template = InvitationTemplate
 	}
-	if err := s.templates[template].Text.ExecuteTemplate(&textMsg, "email", data); err != nil {
-		return err
-	}
-	email := mail.NewMSG()
-	email.SetFrom(s.sender)
-	email.AddTo(account.Email)
-	email.SetSubject(s.activationSubject)
-	email.SetBody(mail.TextPlain, textMsg.String())
-	email.AddAlternative(mail.TextHTML, htmlMsg.String())
-	// email.SetBody(mail.TextHTML, htmlMsg.String())
-	// email.AddAlternative(mail.TextPlain, textMsg.String())
-	if email.Error != nil {
-		return email.Error
-	}
-	return s.client.SendEmail(email)
+	return s.sendTemplateEmail(account, template, data)
 }
 
 func (s *AccountsEmailSender) SendPasswordResetEmail(account domain.Account, uid, token string) error {
@@ -98,30 +68,117 @@ func (s *AccountsEmailSender) SendPasswordResetEmail(account domain.Account, uid
 	params.Set("token", token)
 	activationUrl.RawQuery = params.Encode()
 	data := map[string]interface{}{
-		"User":            &account,
-		"SiteURL":         s.siteURL,
 		"SetPasswordLink": activationUrl.String(),
 	}
-	var htmlMsg, textMsg bytes.Buffer
-	if err := s.templates["password_reset_email"].HTML.ExecuteTemplate(&htmlMsg, "email", data); err != nil {
-		return err
+	return s.sendTemplateEmail(account, PasswordResetTemplate, data)
+}
+
+// SendAccountDeletionEmail sends link for confirmation of the account deletion
+func (s *AccountsEmailSender) SendAccountDeletionEmail(account domain.Account, uid, token string, data map[string]interface{}) error {
+	confirmationUrl, _ := url.Parse(s.siteURL)
+	confirmationUrl.Path = "/accounts/delete/"
+	params := confirmationUrl.Query()
+	params.Set("uid", uid)
+	params.Set("token", token)
+	confirmationUrl.RawQuery = params.Encode()
+	data = maps.NewMap(data)
+	data["ConfirmationLink"] = confirmationUrl.String()
+	return s.sendTemplateEmail(account, AccountDeletionTemplate, data)
+}
+
+// SendAccountDeletionPostponed notifies the user that the confirmed deletion of the account
+// was postponed, because projects cannot be transferred to the requested organization
+func (s *AccountsEmailSender) SendAccountDeletionPostponed(account domain.Account, data map[string]interface{}) error {
+	return s.sendTemplateEmail(account, AccountDeletionPostponed, data)
+}
+
+// SendEmailChangeEmail sends link for confirmation of the email change to the new address
+func (s *AccountsEmailSender) SendEmailChangeEmail(account domain.Account, newEmail, uid, token string) error {
+	confirmationUrl, _ := url.Parse(s.siteURL)
+	confirmationUrl.Path = "/accounts/change-email/"
+	params := confirmationUrl.Query()
+	params.Set("uid", uid)
+	params.Set("email", newEmail)
+	params.Set("token", token)
+	confirmationUrl.RawQuery = params.Encode()
+	data := map[string]interface{}{
+		"ConfirmationLink": confirmationUrl.String(),
+		"OldEmail":         account.Email,
+		"NewEmail":         newEmail,
 	}
-	if err := s.templates["password_reset_email"].Text.ExecuteTemplate(&textMsg, "email", data); err != nil {
-		return err
+	recipient := account
+	recipient.Email = newEmail
+	return s.sendTemplateEmail(recipient, EmailChangeTemplate, data)
+}
+
+// SendEmailChangeNotice notifies the current address about the requested email change
+func (s *AccountsEmailSender) SendEmailChangeNotice(account domain.Account, newEmail string) error {
+	data := map[string]interface{}{
+		"NewEmail": newEmail,
 	}
+	return s.sendTemplateEmail(account, EmailChangeNotice, data)
+}
+
+// SendProjectInvitationEmail notifies existing user about invitation into the project
+func (s *AccountsEmailSender) SendProjectInvitationEmail(account domain.Account, invitationID int64, data map[string]interface{}) error {
+	invitationUrl, _ := url.Parse(s.siteURL)
+	invitationUrl.Path = "/accounts/invitations/"
+	params := invitationUrl.Query()
+	params.Set("id", strconv.FormatInt(invitationID, 10))
+	invitationUrl.RawQuery = params.Encode()
+	data = maps.NewMap(data)
+	data["InvitationLink"] = invitationUrl.String()
+	return s.sendTemplateEmail(account, ProjectInvitation, data)
+}
+
+func (s *AccountsEmailSender) composeEmail(account domain.Account, subject string, htmlTemplate *htmltemplate.Template, textTemplate *texttemplate.Template, data map[string]interface{}) (*mail.Email, error) {
+	templateData := maps.NewMap(data)
+	templateData["User"] = &account
+	templateData["SiteURL"] = s.siteURL
 	email := mail.NewMSG()
 	email.SetFrom(s.sender)
 	email.AddTo(account.Email)
-	email.SetSubject(s.passwordResetSubject)
-	email.SetBody(mail.TextPlain, textMsg.String())
-	email.AddAlternative(mail.TextHTML, htmlMsg.String())
+	email.SetSubject(subject)
 
-	if email.Error != nil {
-		return email.Error
+	var htmlMsg, textMsg bytes.Buffer
+	if textTemplate != nil {
+		if err := textTemplate.Execute(&textMsg, templateData); err != nil {
+			return email, fmt.Errorf("building text tempalte: %w", err)
+		}
+		email.SetBody(mail.TextPlain, textMsg.String())
+	}
+	if htmlTemplate != nil {
+		// if err := htmlTemplate.ExecuteTemplate(&htmlMsg, "email", templateData); err != nil {
+		if err := htmlTemplate.Execute(&htmlMsg, templateData); err != nil {
+			return email, fmt.Errorf("building html tempalte: %w", err)
+		}
+		if textTemplate == nil {
+			email.SetBody(mail.TextHTML, htmlMsg.String())
+		} else {
+			email.AddAlternative(mail.TextHTML, htmlMsg.String())
+		}
+	}
+	return email, email.Error
+}
+
+// SendEmail sends email composed from the templates to a single account
+func (s *AccountsEmailSender) SendEmail(account domain.Account, subject string, htmlTemplate *htmltemplate.Template, textTemplate *texttemplate.Template, data map[string]interface{}) error {
+	if account.Email == "" {
+		return fmt.Errorf("account %s does not have email address", account.Username)
+	}
+	email, err := s.composeEmail(account, subject, htmlTemplate, textTemplate, data)
+	if err != nil {
+		return err
 	}
 	return s.client.SendEmail(email)
 }
 
+// ParseTemplates parses custom email templates, templates starting with {{template "email" .}}
+// extend the base email template
+func (s *AccountsEmailSender) ParseTemplates(html, text string) (*htmltemplate.Template, *texttemplate.Template, error) {
+	return s.templates.ParseTemplates(html, text, "")
+}
+
 func (s *AccountsEmailSender) SendBulkEmail(accounts []domain.Account, subject string, htmlTemplate *htmltemplate.Template, textTemplate *texttemplate.Template, data map[string]interface{}) error {
 	validAccounts := make([]domain.Account, 0, len(accounts))
 	for _, a := range accounts {
@@ -136,34 +193,7 @@ func (s *AccountsEmailSender) SendBulkEmail(accounts []domain.Account, subject s
 		}
 		account := validAccounts[index]
 		index += 1
-
-		templateData := maps.NewMap(data)
-		templateData["User"] = &account
-		templateData["SiteURL"] = s.siteURL
-		email := mail.NewMSG()
-		email.SetFrom(s.sender)
-		email.AddTo(account.Email)
-		email.SetSubject(subject)
-
-		var htmlMsg, textMsg bytes.Buffer
-		if textTemplate != nil {
-			if err := textTemplate.Execute(&textMsg, templateData); err != nil {
-				return email, fmt.Errorf("building text tempalte: %w", err)
-			}
-			email.SetBody(mail.TextPlain, textMsg.String())
-		}
-		if htmlTemplate != nil {
-			// if err := htmlTemplate.ExecuteTemplate(&htmlMsg, "email", templateData); err != nil {
-			if err := htmlTemplate.Execute(&htmlMsg, templateData); err != nil {
-				return email, fmt.Errorf("building html tempalte: %w", err)
-			}
-			if textTemplate == nil {
-				email.SetBody(mail.TextHTML, htmlMsg.String())
-			} else {
-				email.AddAlternative(mail.TextHTML, htmlMsg.String())
-			}
-		}
-		return email, email.Error
+		return s.composeEmail(account, subject, htmlTemplate, textTemplate, data)
 	}
 
 	/*
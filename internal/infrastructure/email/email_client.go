@@ -27,7 +27,7 @@ type SmtpEmailService struct {
 	Password   string
 }
 
-func (s *SmtpEmailService) SendEmail(email *mail.Email) error {
+func (s *SmtpEmailService) connect(keepAlive bool) (*mail.SMTPClient, error) {
 	smtp := mail.NewSMTPClient()
 	smtp.Host = s.Host
 	smtp.Port = s.Port
@@ -43,7 +43,7 @@ func (s *SmtpEmailService) SendEmail(email *mail.Email) error {
 			InsecureSkipVerify: true,
 		}
 	}
-	smtp.KeepAlive = false
+	smtp.KeepAlive = keepAlive
 	// Timeout for connect to SMTP Server
 	smtp.ConnectTimeout = 10 * time.Second
 	// Timeout for send the data and wait respond
@@ -51,7 +51,15 @@ func (s *SmtpEmailService) SendEmail(email *mail.Email) error {
 
 	client, err := smtp.Connect()
 	if err != nil {
-		return fmt.Errorf("smtp connect: %w", err)
+		return nil, fmt.Errorf("smtp connect: %w", err)
+	}
+	return client, nil
+}
+
+func (s *SmtpEmailService) SendEmail(email *mail.Email) error {
+	client, err := s.connect(false)
+	if err != nil {
+		return err
 	}
 	defer client.Close()
 	err = email.Send(client)
@@ -62,30 +70,9 @@ func (s *SmtpEmailService) SendEmail(email *mail.Email) error {
 }
 
 func (s *SmtpEmailService) SendMultiple(next func() (*mail.Email, error)) error {
-	smtp := mail.NewSMTPClient()
-	smtp.Host = s.Host
-	smtp.Port = s.Port
-	smtp.Username = s.Username
-	smtp.Password = s.Password
-	smtp.Encryption = s.Encryption
-	if s.Encryption == mail.EncryptionTLS || s.Encryption == mail.EncryptionSSLTLS || s.Encryption == mail.EncryptionSTARTTLS {
-		smtp.TLSConfig = &tls.Config{
-			ServerName: s.Host,
-		}
-	} else {
-		smtp.TLSConfig = &tls.Config{
-			InsecureSkipVerify: true,
-		}
-	}
-	smtp.KeepAlive = true
-	// Timeout for connect to SMTP Server
-	smtp.ConnectTimeout = 10 * time.Second
-	// Timeout for send the data and wait respond
-	smtp.SendTimeout = 10 * time.Second
-
-	client, err := smtp.Connect()
+	client, err := s.connect(true)
 	if err != nil {
-		return fmt.Errorf("smtp connect: %w", err)
+		return err
 	}
 	defer client.Close()
 	email, err := next()
@@ -109,3 +96,23 @@ func (s *SmtpEmailService) SendMultiple(next func() (*mail.Email, error)) error
 	}
 	return nil
 }
+
+// SendMessages sends already composed messages over a single connection, returns errors
+// of individual messages (nil for successfully sent messages)
+func (s *SmtpEmailService) SendMessages(messages []RawMessage) []error {
+	errs := make([]error, len(messages))
+	client, err := s.connect(len(messages) > 1)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	defer client.Close()
+	for i, m := range messages {
+		if err := mail.SendMessage(m.From, m.Recipients, m.Message, client); err != nil {
+			errs[i] = fmt.Errorf("smtp send: %w", err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,339 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	netmail "net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+	"go.uber.org/zap"
+)
+
+var ErrMessageNotFound = errors.New("Message not found")
+
+// Statuses of outbox messages
+const (
+	StatusPending = "pending"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	// all delivery attempts failed
+	StatusFailed = "failed"
+)
+
+var OutboxStatuses = []string{StatusPending, StatusSending, StatusSent, StatusFailed}
+
+// RawMessage is RFC822 formatted email message with its envelope
+type RawMessage struct {
+	From       string
+	Recipients []string
+	Message    string
+}
+
+// MessageSender sends already composed email messages
+type MessageSender interface {
+	SendMessages(messages []RawMessage) []error
+}
+
+type OutboxMessage struct {
+	ID          int64      `json:"id"`
+	Sender      string     `json:"sender"`
+	Recipients  []string   `json:"recipients"`
+	Subject     string     `json:"subject"`
+	Message     string     `json:"message,omitempty"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	Created     time.Time  `json:"created_at"`
+	NextAttempt time.Time  `json:"next_attempt_at"`
+	Sent        *time.Time `json:"sent_at"`
+}
+
+// OutboxStore is a persistent queue of outgoing emails
+type OutboxStore interface {
+	Enqueue(messages ...OutboxMessage) error
+	// Claim returns up to limit messages ready to be sent, marks them as being sent until
+	// the lock expires and increments their attempts counter
+	Claim(now time.Time, limit int, lock time.Duration) ([]OutboxMessage, error)
+	MarkSent(id int64, sent time.Time) error
+	// MarkFailed records failed delivery attempt, message stays pending (with the next attempt
+	// scheduled) or it is marked as failed
+	MarkFailed(id int64, status, lastError string, nextAttempt time.Time) error
+	Get(id int64) (OutboxMessage, error)
+	// List returns messages (without content) with the given status (all when empty), newest first,
+	// and total count of such messages
+	List(status string, limit, offset int) ([]OutboxMessage, int, error)
+	// Retry schedules message for immediate delivery with reset attempts counter
+	Retry(id int64, now time.Time) error
+	RetryFailed(now time.Time) (int, error)
+	DeleteSent(before time.Time) (int, error)
+}
+
+type OutboxConfig struct {
+	// number of delivery attempts before message is marked as failed
+	MaxAttempts int
+	// delay after the first failed attempt, doubled after each next failure
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// interval of checking queue for messages to retry
+	PollInterval time.Duration
+	// how long are sent messages kept in the queue (0 - forever)
+	Retention time.Duration
+}
+
+// Outbox is EmailService storing emails into persistent queue, from which they are delivered
+// by background worker with retries
+type Outbox struct {
+	log    *zap.SugaredLogger
+	store  OutboxStore
+	sender MessageSender
+	config OutboxConfig
+
+	notify chan struct{}
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// number of messages sent in one batch (over one connection)
+const outboxBatchSize = 50
+
+// time after which messages claimed by a worker (which might have crashed) can be claimed again
+const outboxLockTimeout = 5 * time.Minute
+
+func NewOutbox(log *zap.SugaredLogger, store OutboxStore, sender MessageSender, config OutboxConfig) *Outbox {
+	return &Outbox{
+		log:    log,
+		store:  store,
+		sender: sender,
+		config: config,
+		notify: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+}
+
+func parseSubject(message string) string {
+	msg, err := netmail.ReadMessage(strings.NewReader(message))
+	if err != nil {
+		return ""
+	}
+	subject := msg.Header.Get("Subject")
+	dec := new(mime.WordDecoder)
+	if decoded, err := dec.DecodeHeader(subject); err == nil {
+		return decoded
+	}
+	return subject
+}
+
+func newOutboxMessage(email *mail.Email) (OutboxMessage, error) {
+	if email.Error != nil {
+		return OutboxMessage{}, email.Error
+	}
+	if len(email.GetRecipients()) == 0 {
+		return OutboxMessage{}, errors.New("no recipient specified")
+	}
+	msg := email.GetMessage()
+	return OutboxMessage{
+		Sender:     email.GetFrom(),
+		Recipients: email.GetRecipients(),
+		Subject:    parseSubject(msg),
+		Message:    msg,
+		Status:     StatusPending,
+	}, nil
+}
+
+func (o *Outbox) wakeUp() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+// SendEmail enqueues the email for delivery
+func (o *Outbox) SendEmail(email *mail.Email) error {
+	msg, err := newOutboxMessage(email)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	msg.Created = now
+	msg.NextAttempt = now
+	if err := o.store.Enqueue(msg); err != nil {
+		return fmt.Errorf("enqueuing email: %w", err)
+	}
+	o.wakeUp()
+	return nil
+}
+
+// SendMultiple enqueues all generated emails, emails which couldn't be composed are
+// reported in BulkEmailError
+func (o *Outbox) SendMultiple(next func() (*mail.Email, error)) error {
+	var messages []OutboxMessage
+	var errs []EmailError
+	now := time.Now()
+	email, err := next()
+	for err != EndOfQue {
+		if err == nil {
+			var msg OutboxMessage
+			if msg, err = newOutboxMessage(email); err == nil {
+				msg.Created = now
+				msg.NextAttempt = now
+				messages = append(messages, msg)
+			}
+		}
+		if err != nil {
+			if email != nil {
+				errs = append(errs, newEmailError(email, err))
+			} else {
+				errs = append(errs, EmailError{Err: err})
+			}
+		}
+		email, err = next()
+	}
+	if len(messages) > 0 {
+		if err := o.store.Enqueue(messages...); err != nil {
+			return fmt.Errorf("enqueuing emails: %w", err)
+		}
+		o.wakeUp()
+	}
+	if len(errs) > 0 {
+		return &BulkEmailError{Errors: errs}
+	}
+	return nil
+}
+
+// retryDelay returns delay before the next attempt after the given number of failed attempts
+func (o *Outbox) retryDelay(attempts int) time.Duration {
+	delay := o.config.RetryDelay
+	for i := 1; i < attempts && delay < o.config.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if o.config.MaxRetryDelay > 0 && delay > o.config.MaxRetryDelay {
+		delay = o.config.MaxRetryDelay
+	}
+	return delay
+}
+
+// processBatch sends one batch of messages ready to be sent, returns number of processed messages
+func (o *Outbox) processBatch() (int, error) {
+	messages, err := o.store.Claim(time.Now(), outboxBatchSize, outboxLockTimeout)
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+	raw := make([]RawMessage, len(messages))
+	for i, m := range messages {
+		raw[i] = RawMessage{From: m.Sender, Recipients: m.Recipients, Message: m.Message}
+	}
+	errs := o.sender.SendMessages(raw)
+	now := time.Now()
+	for i, m := range messages {
+		if errs[i] == nil {
+			if err := o.store.MarkSent(m.ID, now); err != nil {
+				o.log.Errorw("email outbox: marking message as sent", "id", m.ID, zap.Error(err))
+			}
+			continue
+		}
+		status := StatusPending
+		if m.Attempts >= o.config.MaxAttempts {
+			status = StatusFailed
+			o.log.Errorw("email outbox: delivery failed", "id", m.ID, "recipients", m.Recipients, "attempts", m.Attempts, zap.Error(errs[i]))
+		} else {
+			o.log.Warnw("email outbox: delivery attempt failed", "id", m.ID, "recipients", m.Recipients, "attempts", m.Attempts, zap.Error(errs[i]))
+		}
+		if err := o.store.MarkFailed(m.ID, status, errs[i].Error(), now.Add(o.retryDelay(m.Attempts))); err != nil {
+			o.log.Errorw("email outbox: marking failed message", "id", m.ID, zap.Error(err))
+		}
+	}
+	return len(messages), nil
+}
+
+// Process sends all messages ready to be sent
+func (o *Outbox) Process() {
+	for {
+		n, err := o.processBatch()
+		if err != nil {
+			o.log.Errorw("email outbox: claiming messages", zap.Error(err))
+			return
+		}
+		if n < outboxBatchSize {
+			return
+		}
+		select {
+		case <-o.stop:
+			return
+		default:
+		}
+	}
+}
+
+func (o *Outbox) cleanup() {
+	if o.config.Retention <= 0 {
+		return
+	}
+	n, err := o.store.DeleteSent(time.Now().Add(-o.config.Retention))
+	if err != nil {
+		o.log.Errorw("email outbox: deleting sent messages", zap.Error(err))
+	} else if n > 0 {
+		o.log.Infow("email outbox: deleted sent messages", "count", n)
+	}
+}
+
+// Start starts background worker delivering queued messages
+func (o *Outbox) Start() {
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		ticker := time.NewTicker(o.config.PollInterval)
+		defer ticker.Stop()
+		lastCleanup := time.Time{}
+		for {
+			o.Process()
+			if time.Since(lastCleanup) > time.Hour {
+				o.cleanup()
+				lastCleanup = time.Now()
+			}
+			select {
+			case <-o.stop:
+				return
+			case <-ticker.C:
+			case <-o.notify:
+			}
+		}
+	}()
+}
+
+func (o *Outbox) Stop() {
+	close(o.stop)
+	o.wg.Wait()
+}
+
+func (o *Outbox) Get(id int64) (OutboxMessage, error) {
+	return o.store.Get(id)
+}
+
+func (o *Outbox) List(status string, limit, offset int) ([]OutboxMessage, int, error) {
+	return o.store.List(status, limit, offset)
+}
+
+// Resend schedules the message (also already sent one) for immediate delivery
+func (o *Outbox) Resend(id int64) error {
+	if err := o.store.Retry(id, time.Now()); err != nil {
+		return err
+	}
+	o.wakeUp()
+	return nil
+}
+
+// ResendFailed schedules all failed messages for immediate delivery
+func (o *Outbox) ResendFailed() (int, error) {
+	n, err := o.store.RetryFailed(time.Now())
+	if err != nil {
+		return n, err
+	}
+	if n > 0 {
+		o.wakeUp()
+	}
+	return n, nil
+}
@@ -0,0 +1,471 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("Template not found")
+	ErrOverrideNotFound = errors.New("Template override not found")
+	ErrInvalidLanguage  = errors.New("Invalid language code")
+	ErrInvalidTemplate  = errors.New("Invalid email template")
+)
+
+// Names of email templates used for accounts management
+const (
+	ActivationTemplate      = "activation_email"
+	InvitationTemplate      = "invitation_email"
+	PasswordResetTemplate   = "password_reset_email"
+	AccountDeletionTemplate = "account_deletion_email"
+	AccountDeletionPostponed = "account_deletion_postponed"
+	EmailChangeTemplate     = "email_change_email"
+	EmailChangeNotice       = "email_change_notice"
+	ProjectInvitation       = "project_invitation_email"
+)
+
+var TemplateNames = []string{ActivationTemplate, InvitationTemplate, PasswordResetTemplate, AccountDeletionTemplate, AccountDeletionPostponed, EmailChangeTemplate, EmailChangeNotice, ProjectInvitation}
+
+// template files (without extension)
+var templateFiles = map[string]string{
+	ActivationTemplate:      "activation_email",
+	InvitationTemplate:      "invitation_email",
+	PasswordResetTemplate:   "reset_password_email",
+	AccountDeletionTemplate: "account_deletion_email",
+	AccountDeletionPostponed: "account_deletion_postponed",
+	EmailChangeTemplate:     "email_change_email",
+	EmailChangeNotice:       "email_change_notice",
+	ProjectInvitation:       "project_invitation_email",
+}
+
+var languageRegex = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{1,8})*$`)
+
+// NormalizeLanguage returns lower case language code with '-' separator (e.g. "cs-cz"),
+// or empty string for invalid code
+func NormalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+	if !languageRegex.MatchString(lang) {
+		return ""
+	}
+	return lang
+}
+
+// languageChain returns languages searched for templates of the given language, from the most
+// specific one to the default templates ("")
+func languageChain(lang string) []string {
+	var chain []string
+	for lang = NormalizeLanguage(lang); lang != ""; {
+		chain = append(chain, lang)
+		i := strings.LastIndex(lang, "-")
+		if i == -1 {
+			break
+		}
+		lang = lang[:i]
+	}
+	return append(chain, "")
+}
+
+// EmailTemplate is parsed email template with its subject
+type EmailTemplate struct {
+	Subject string
+	HTML    *htmltemplate.Template
+	Text    *texttemplate.Template
+}
+
+// TemplateOverride replaces parts of the email template (in the given language) defined in files,
+// empty fields are not overridden
+type TemplateOverride struct {
+	Name string `json:"name"`
+	// empty for default templates
+	Language  string    `json:"lang"`
+	Subject   string    `json:"subject"`
+	HTML      string    `json:"html"`
+	Text      string    `json:"text"`
+	Updated   time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by"`
+}
+
+// TemplateOverridesStore stores email templates managed by administrators
+type TemplateOverridesStore interface {
+	List() ([]TemplateOverride, error)
+	Save(override TemplateOverride) error
+	// Delete deletes override, returns ErrOverrideNotFound when it doesn't exist
+	Delete(name, lang string) error
+}
+
+// TemplateSource is the effective source of email template in the given language
+type TemplateSource struct {
+	Name     string `json:"name"`
+	Language string `json:"lang"`
+	Subject  string `json:"subject"`
+	HTML     string `json:"html"`
+	Text     string `json:"text"`
+	// override of the template in the given language
+	Override *TemplateOverride `json:"override,omitempty"`
+}
+
+type TemplatesConfig struct {
+	// directory with default templates, templates in other languages are in its subdirectories
+	// named by language code (e.g. "templates/cs/activation_email.html")
+	Dir string
+	// language of the default templates
+	DefaultLanguage string
+	// subjects used when not defined in 'subjects.json' file of the templates directory
+	Subjects map[string]string
+}
+
+// Templates provides email templates in multiple languages. Each part of the template (subject, html
+// and text content, base template) is searched from the requested language to more generic ones
+// (e.g. "cs-cz", "cs") and the default templates, overrides take precedence over files.
+type Templates struct {
+	config TemplatesConfig
+	store  TemplateOverridesStore
+	cache  *ttlcache.Cache[string, EmailTemplate]
+}
+
+// NewTemplates creates templates provider and checks that the default template files are valid.
+// Store of template overrides is optional.
+func NewTemplates(config TemplatesConfig, store TemplateOverridesStore) (*Templates, error) {
+	t := &Templates{
+		config: config,
+		// short expiration, so changes of overrides made by other server instances are applied soon
+		cache: ttlcache.New(
+			ttlcache.WithTTL[string, EmailTemplate](time.Minute),
+			ttlcache.WithDisableTouchOnHit[string, EmailTemplate](),
+		),
+	}
+	for _, name := range TemplateNames {
+		if _, err := t.Get(name, ""); err != nil {
+			return nil, fmt.Errorf("loading email template %s: %w", name, err)
+		}
+	}
+	t.store = store
+	t.cache.DeleteAll()
+	return t, nil
+}
+
+func (t *Templates) readFile(lang, file string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(t.config.Dir, lang, file))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (t *Templates) readSubjects(lang string) (map[string]string, error) {
+	content, err := t.readFile(lang, "subjects.json")
+	if err != nil || content == "" {
+		return nil, err
+	}
+	var subjects map[string]string
+	if err := json.Unmarshal([]byte(content), &subjects); err != nil {
+		return nil, fmt.Errorf("parsing subjects of language '%s': %w", lang, err)
+	}
+	return subjects, nil
+}
+
+func overrideKey(name, lang string) string {
+	return name + ":" + lang
+}
+
+func (t *Templates) overrides() (map[string]TemplateOverride, error) {
+	overrides := make(map[string]TemplateOverride)
+	if t.store == nil {
+		return overrides, nil
+	}
+	list, err := t.store.List()
+	if err != nil {
+		return nil, fmt.Errorf("listing template overrides: %w", err)
+	}
+	for _, o := range list {
+		overrides[overrideKey(o.Name, o.Language)] = o
+	}
+	return overrides, nil
+}
+
+// source resolves sources of the template and its base templates
+func (t *Templates) source(name, lang string) (src TemplateSource, htmlBase, textBase string, err error) {
+	file, ok := templateFiles[name]
+	if !ok {
+		return src, "", "", ErrTemplateNotFound
+	}
+	overrides, err := t.overrides()
+	if err != nil {
+		return src, "", "", err
+	}
+	src = TemplateSource{Name: name, Language: NormalizeLanguage(lang)}
+	for _, l := range languageChain(lang) {
+		o, hasOverride := overrides[overrideKey(name, l)]
+		if hasOverride && l == src.Language {
+			src.Override = &o
+		}
+		if src.Subject == "" {
+			if hasOverride && o.Subject != "" {
+				src.Subject = o.Subject
+			} else {
+				subjects, err := t.readSubjects(l)
+				if err != nil {
+					return src, "", "", err
+				}
+				src.Subject = subjects[name]
+			}
+		}
+		if src.HTML == "" {
+			if hasOverride && o.HTML != "" {
+				src.HTML = o.HTML
+			} else if src.HTML, err = t.readFile(l, file+".html"); err != nil {
+				return src, "", "", err
+			}
+		}
+		if src.Text == "" {
+			if hasOverride && o.Text != "" {
+				src.Text = o.Text
+			} else if src.Text, err = t.readFile(l, file+".txt"); err != nil {
+				return src, "", "", err
+			}
+		}
+		if htmlBase == "" {
+			if htmlBase, err = t.readFile(l, "email_base.html"); err != nil {
+				return src, "", "", err
+			}
+		}
+		if textBase == "" {
+			if textBase, err = t.readFile(l, "email_base.txt"); err != nil {
+				return src, "", "", err
+			}
+		}
+	}
+	if src.Subject == "" {
+		src.Subject = t.config.Subjects[name]
+	}
+	if src.HTML == "" && src.Text == "" {
+		return src, "", "", fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return src, htmlBase, textBase, nil
+}
+
+// Source returns the effective source of the template in the given language
+func (t *Templates) Source(name, lang string) (TemplateSource, error) {
+	src, _, _, err := t.source(name, lang)
+	return src, err
+}
+
+// Get returns parsed template in the given language (or the most similar available language)
+func (t *Templates) Get(name, lang string) (EmailTemplate, error) {
+	key := overrideKey(name, NormalizeLanguage(lang))
+	if item := t.cache.Get(key); item != nil {
+		return item.Value(), nil
+	}
+	src, htmlBase, textBase, err := t.source(name, lang)
+	if err != nil {
+		return EmailTemplate{}, err
+	}
+	html, text, err := parseTemplates(src.HTML, src.Text, htmlBase, textBase)
+	if err != nil {
+		return EmailTemplate{}, err
+	}
+	tmpl := EmailTemplate{Subject: src.Subject, HTML: html, Text: text}
+	t.cache.Set(key, tmpl, ttlcache.DefaultTTL)
+	return tmpl, nil
+}
+
+// ParseTemplates parses custom email templates with the base templates of the given language
+func (t *Templates) ParseTemplates(html, text, lang string) (*htmltemplate.Template, *texttemplate.Template, error) {
+	var htmlBase, textBase string
+	for _, l := range languageChain(lang) {
+		var err error
+		if htmlBase == "" {
+			if htmlBase, err = t.readFile(l, "email_base.html"); err != nil {
+				return nil, nil, err
+			}
+		}
+		if textBase == "" {
+			if textBase, err = t.readFile(l, "email_base.txt"); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return parseTemplates(html, text, htmlBase, textBase)
+}
+
+// Languages returns sorted list of languages with templates (including the default language)
+func (t *Templates) Languages() ([]string, error) {
+	languages := make(map[string]struct{})
+	if lang := NormalizeLanguage(t.config.DefaultLanguage); lang != "" {
+		languages[lang] = struct{}{}
+	}
+	entries, err := os.ReadDir(t.config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading templates directory: %w", err)
+	}
+	for _, e := range entries {
+		if lang := NormalizeLanguage(e.Name()); e.IsDir() && lang == e.Name() {
+			languages[lang] = struct{}{}
+		}
+	}
+	overrides, err := t.overrides()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		if o.Language != "" {
+			languages[o.Language] = struct{}{}
+		}
+	}
+	list := make([]string, 0, len(languages))
+	for lang := range languages {
+		list = append(list, lang)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// parseAcceptLanguage returns language tags from Accept-Language header value ordered by preference
+func parseAcceptLanguage(header string) []string {
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if f, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil {
+				q = f
+			}
+		}
+		if lang = NormalizeLanguage(lang); lang != "" && q > 0 {
+			tags = append(tags, tag{lang, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	langs := make([]string, len(tags))
+	for i, t := range tags {
+		langs[i] = t.lang
+	}
+	return langs
+}
+
+// MatchLanguage returns the available language best matching to the value of Accept-Language
+// header, or empty string when there is no such language
+func (t *Templates) MatchLanguage(acceptLanguage string) (string, error) {
+	languages, err := t.Languages()
+	if err != nil {
+		return "", err
+	}
+	available := make(map[string]struct{}, len(languages))
+	for _, l := range languages {
+		available[l] = struct{}{}
+	}
+	for _, lang := range parseAcceptLanguage(acceptLanguage) {
+		for _, l := range languageChain(lang) {
+			if _, ok := available[l]; ok && l != "" {
+				return l, nil
+			}
+		}
+		// more specific variant of the language (e.g. "en-us" for "en")
+		for _, l := range languages {
+			if strings.HasPrefix(l, lang+"-") {
+				return l, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// SaveOverride validates and saves override of the template
+func (t *Templates) SaveOverride(override TemplateOverride) (TemplateOverride, error) {
+	if t.store == nil {
+		return override, errors.New("template overrides are not supported")
+	}
+	if _, ok := templateFiles[override.Name]; !ok {
+		return override, ErrTemplateNotFound
+	}
+	if override.Language != "" {
+		lang := NormalizeLanguage(override.Language)
+		if lang == "" {
+			return override, ErrInvalidLanguage
+		}
+		override.Language = lang
+	}
+	if _, _, err := t.ParseTemplates(override.HTML, override.Text, override.Language); err != nil {
+		return override, fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+	}
+	override.Updated = time.Now()
+	if err := t.store.Save(override); err != nil {
+		return override, err
+	}
+	t.cache.DeleteAll()
+	return override, nil
+}
+
+// DeleteOverride deletes override of the template, so it is loaded from files again
+func (t *Templates) DeleteOverride(name, lang string) error {
+	if t.store == nil {
+		return ErrOverrideNotFound
+	}
+	if err := t.store.Delete(name, NormalizeLanguage(lang)); err != nil {
+		return err
+	}
+	t.cache.DeleteAll()
+	return nil
+}
+
+// ListOverrides returns all template overrides
+func (t *Templates) ListOverrides() ([]TemplateOverride, error) {
+	if t.store == nil {
+		return []TemplateOverride{}, nil
+	}
+	return t.store.List()
+}
+
+var templateFuncs = map[string]any{
+	"query_escape": url.QueryEscape,
+}
+
+// extendsBase checks whether template extends the base email template
+func extendsBase(content string) bool {
+	return strings.HasPrefix(strings.TrimSpace(content), `{{template "email" .}}`)
+}
+
+func parseTemplates(html, text, htmlBase, textBase string) (*htmltemplate.Template, *texttemplate.Template, error) {
+	var htmlTemplate *htmltemplate.Template
+	var textTemplate *texttemplate.Template
+	if text != "" {
+		textTemplate = texttemplate.New("text_email").Funcs(texttemplate.FuncMap(templateFuncs))
+		if extendsBase(text) {
+			if _, err := textTemplate.New("email_base").Parse(textBase); err != nil {
+				return nil, nil, fmt.Errorf("parsing base text template: %w", err)
+			}
+		}
+		if _, err := textTemplate.Parse(text); err != nil {
+			return nil, nil, fmt.Errorf("parsing text template: %w", err)
+		}
+	}
+	if html != "" {
+		htmlTemplate = htmltemplate.New("html_email").Funcs(htmltemplate.FuncMap(templateFuncs))
+		if extendsBase(html) {
+			if _, err := htmlTemplate.New("email_base").Parse(htmlBase); err != nil {
+				return nil, nil, fmt.Errorf("parsing base html template: %w", err)
+			}
+		}
+		if _, err := htmlTemplate.Parse(html); err != nil {
+			return nil, nil, fmt.Errorf("parsing html template: %w", err)
+		}
+	}
+	return htmlTemplate, textTemplate, nil
+}
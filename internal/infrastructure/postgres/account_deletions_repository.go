@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type AccountDeletionsRepository struct {
+	db *sqlx.DB
+}
+
+func NewAccountDeletionsRepository(db *sqlx.DB) *AccountDeletionsRepository {
+	return &AccountDeletionsRepository{db}
+}
+
+func (r *AccountDeletionsRepository) Save(d domain.AccountDeletion) error {
+	row := AccountDeletion(d)
+	_, err := r.db.NamedExec(
+		`INSERT INTO account_deletions (username, transfer_to, requested_at, confirmed_at, delete_at)
+		VALUES (:username, :transfer_to, :requested_at, :confirmed_at, :delete_at)
+		ON CONFLICT (username) DO UPDATE SET
+			transfer_to = EXCLUDED.transfer_to,
+			requested_at = EXCLUDED.requested_at,
+			confirmed_at = EXCLUDED.confirmed_at,
+			delete_at = EXCLUDED.delete_at`,
+		&row,
+	)
+	return err
+}
+
+func (r *AccountDeletionsRepository) Get(username string) (domain.AccountDeletion, error) {
+	var row AccountDeletion
+	if err := r.db.Get(&row, "SELECT * FROM account_deletions WHERE username = $1", username); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.AccountDeletion{}, domain.ErrDeletionNotRequested
+		}
+		return domain.AccountDeletion{}, err
+	}
+	return domain.AccountDeletion(row), nil
+}
+
+func (r *AccountDeletionsRepository) Delete(username string) error {
+	res, err := r.db.Exec("DELETE FROM account_deletions WHERE username = $1", username)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return domain.ErrDeletionNotRequested
+	}
+	return nil
+}
+
+func (r *AccountDeletionsRepository) Due(now time.Time) ([]domain.AccountDeletion, error) {
+	var rows []AccountDeletion
+	err := r.db.Select(&rows,
+		"SELECT * FROM account_deletions WHERE delete_at IS NOT NULL AND delete_at <= $1 ORDER BY delete_at",
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	deletions := make([]domain.AccountDeletion, len(rows))
+	for i, d := range rows {
+		deletions[i] = domain.AccountDeletion(d)
+	}
+	return deletions, nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/gisquick/gisquick-server/internal/domain"
 	"github.com/jackc/pgconn"
@@ -18,23 +19,42 @@ func NewAccountsRepository(db *sqlx.DB) *AccountsRepository {
 	return &AccountsRepository{db}
 }
 
+// unique index of (non-empty) email addresses
+const usersEmailIndex = "users_email_unique_idx"
+
+// uniqueViolationError maps unique violation of users table to domain error
+func uniqueViolationError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { // UniqueViolation
+		if pgErr.ConstraintName == usersEmailIndex {
+			return domain.ErrEmailExists
+		}
+		return domain.ErrAccountExists
+	}
+	return err
+}
+
 func (r *AccountsRepository) Create(account domain.Account) error {
+	// users share namespace of projects with organizations
+	var orgExists bool
+	if err := r.db.Get(&orgExists, "SELECT exists (SELECT 1 FROM organizations WHERE name = $1)", account.Username); err != nil {
+		return err
+	}
+	if orgExists {
+		return domain.ErrAccountExists
+	}
 	dbUser := toUser(account)
 	_, err := r.db.NamedExec(
-		`INSERT INTO users (username, email, password, first_name, last_name, is_superuser, is_active, created_at, confirmed_at, last_login_at, profile)
-		VALUES (:username, :email, :password, :first_name, :last_name, :is_superuser, :is_active, :created_at, :confirmed_at, :last_login_at, :profile)`,
+		`INSERT INTO users (username, email, password, first_name, last_name, is_superuser, is_active, created_at, confirmed_at, last_login_at, profile, password_changed_at, password_expiry)
+		VALUES (:username, :email, :password, :first_name, :last_name, :is_superuser, :is_active, :created_at, :confirmed_at, :last_login_at, :profile, :password_changed_at, :password_expiry)`,
 		&dbUser,
 	)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // UniqueViolation
-			return domain.ErrAccountExists
-		}
 		// for 'postgres' driver
 		// if err, ok := err.(*pq.Error); ok {
 		// 	log.Println("PG ERROR Code #2:", err.Code)
 		// }
-		return err
+		return uniqueViolationError(err)
 	}
 	return nil
 }
@@ -63,7 +83,7 @@ func (r *AccountsRepository) GetByUsername(username string) (domain.Account, err
 
 func (r *AccountsRepository) GetByEmail(email string) (domain.Account, error) {
 	var dbUsers []User
-	err := r.db.Select(&dbUsers, `SELECT * FROM users WHERE email LIKE $1`, email)
+	err := r.db.Select(&dbUsers, `SELECT * FROM users WHERE lower(email) = lower($1)`, email)
 	if err != nil {
 		return domain.Account{}, err
 	}
@@ -91,12 +111,17 @@ func (r *AccountsRepository) Update(account domain.Account) error {
 			"is_active" = :is_active,
 			"created_at" = :created_at,
 			"confirmed_at" = :confirmed_at,
-			"last_login_at" = :last_login_at
+			"last_login_at" = :last_login_at,
+			"password_changed_at" = :password_changed_at,
+			"password_expiry" = :password_expiry
 	WHERE
 			username = :username
 	`
 	_, err := r.db.NamedExec(q, user)
-	return err
+	if err != nil {
+		return uniqueViolationError(err)
+	}
+	return nil
 }
 
 func (r *AccountsRepository) UpdateProfile(account domain.Account) error {
@@ -115,7 +140,7 @@ func (r *AccountsRepository) UpdateProfile2(username string, profile domain.Prof
 
 func (r *AccountsRepository) EmailExists(email string) (bool, error) {
 	var exists bool
-	err := r.db.QueryRow("SELECT exists (SELECT 1 FROM users WHERE email ILIKE $1)", email).Scan(&exists)
+	err := r.db.QueryRow("SELECT exists (SELECT 1 FROM users WHERE lower(email) = lower($1))", email).Scan(&exists)
 	if err != nil && err != sql.ErrNoRows {
 		return false, err
 	}
@@ -124,7 +149,10 @@ func (r *AccountsRepository) EmailExists(email string) (bool, error) {
 
 func (r *AccountsRepository) UsernameExists(username string) (bool, error) {
 	var exists bool
-	err := r.db.QueryRow("SELECT exists (SELECT 1 FROM users WHERE username = $1)", username).Scan(&exists)
+	err := r.db.QueryRow(
+		"SELECT exists (SELECT 1 FROM users WHERE username = $1) OR exists (SELECT 1 FROM organizations WHERE name = $1)",
+		username,
+	).Scan(&exists)
 	if err != nil && err != sql.ErrNoRows {
 		return false, err
 	}
@@ -185,6 +213,9 @@ func toAccount(user User) domain.Account {
 		Confirmed: user.Confirmed,
 		LastLogin: user.LastLogin,
 		Profile:   domain.Profile(user.Profile),
+
+		PasswordChanged: user.PasswordChanged,
+		PasswordExpiry:  user.PasswordExpiry,
 	}
 }
 
@@ -201,5 +232,100 @@ func toUser(a domain.Account) User {
 		Confirmed:   a.Confirmed,
 		LastLogin:   a.LastLogin,
 		Profile:     UserProfile(a.Profile),
+
+		PasswordChanged: a.PasswordChanged,
+		PasswordExpiry:  a.PasswordExpiry,
+	}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// containsPattern returns LIKE pattern matching values containing the text
+func containsPattern(text string) string {
+	return "%" + likeEscaper.Replace(text) + "%"
+}
+
+func accountsQueryConditions(q domain.AccountsQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if q.Search != "" {
+		p := arg(containsPattern(q.Search))
+		conditions = append(conditions, fmt.Sprintf(
+			"(username ILIKE %[1]s OR email ILIKE %[1]s OR first_name ILIKE %[1]s OR last_name ILIKE %[1]s OR concat_ws(' ', first_name, last_name) ILIKE %[1]s)", p,
+		))
+	}
+	if q.Lookup != "" {
+		p := arg(containsPattern(q.Lookup))
+		conditions = append(conditions, fmt.Sprintf(
+			"(username ILIKE %[1]s OR first_name ILIKE %[1]s OR last_name ILIKE %[1]s OR concat_ws(' ', first_name, last_name) ILIKE %[1]s)", p,
+		))
+	}
+	if q.Username != "" {
+		conditions = append(conditions, "username ILIKE "+arg(containsPattern(q.Username)))
+	}
+	if q.Name != "" {
+		p := arg(containsPattern(q.Name))
+		conditions = append(conditions, fmt.Sprintf(
+			"(first_name ILIKE %[1]s OR last_name ILIKE %[1]s OR concat_ws(' ', first_name, last_name) ILIKE %[1]s)", p,
+		))
+	}
+	if q.Email != "" {
+		conditions = append(conditions, "email ILIKE "+arg(containsPattern(q.Email)))
+	}
+	if q.Active != nil {
+		conditions = append(conditions, "is_active = "+arg(*q.Active))
+	}
+	if q.LastLoginFrom != nil {
+		conditions = append(conditions, "last_login_at >= "+arg(*q.LastLoginFrom))
+	}
+	if q.LastLoginTo != nil {
+		conditions = append(conditions, "last_login_at < "+arg(*q.LastLoginTo))
+	}
+	if q.Usernames != nil {
+		conditions = append(conditions, "username = ANY("+arg(q.Usernames)+")")
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *AccountsRepository) QueryAccounts(q domain.AccountsQuery) ([]domain.Account, int, error) {
+	if err := q.Validate(); err != nil {
+		return nil, 0, err
+	}
+	where, args := accountsQueryConditions(q)
+	var total int
+	if err := r.db.Get(&total, "SELECT count(*) FROM users"+where, args...); err != nil {
+		return nil, 0, err
+	}
+	orderBy := q.OrderBy
+	if orderBy == "" {
+		orderBy = "username"
+	}
+	direction := "ASC"
+	if q.Descending {
+		direction = "DESC"
+	}
+	// sort field is validated, username makes the order stable
+	query := fmt.Sprintf("SELECT * FROM users%s ORDER BY %s %s NULLS LAST, username", where, orderBy, direction)
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+	if q.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", q.Offset)
+	}
+	var dbUsers []User
+	if err := r.db.Select(&dbUsers, query, args...); err != nil {
+		return nil, 0, err
+	}
+	accounts := make([]domain.Account, len(dbUsers))
+	for index, user := range dbUsers {
+		accounts[index] = toAccount(user)
 	}
+	return accounts, total, nil
 }
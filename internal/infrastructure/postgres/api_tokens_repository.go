@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type APITokensRepository struct {
+	db *sqlx.DB
+}
+
+func NewAPITokensRepository(db *sqlx.DB) *APITokensRepository {
+	return &APITokensRepository{db}
+}
+
+func (r *APITokensRepository) Create(token domain.APIToken, hash string) (domain.APIToken, error) {
+	dbToken := toDBToken(token)
+	dbToken.Hash = hash
+	rows, err := r.db.NamedQuery(
+		`INSERT INTO api_tokens (username, name, token_hash, token_prefix, scopes, created_at, expires_at)
+		VALUES (:username, :name, :token_hash, :token_prefix, :scopes, :created_at, :expires_at) RETURNING id`,
+		&dbToken,
+	)
+	if err != nil {
+		return token, err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		if err := rows.Scan(&token.ID); err != nil {
+			return token, err
+		}
+	}
+	return token, rows.Err()
+}
+
+func (r *APITokensRepository) GetByHash(hash string) (domain.APIToken, error) {
+	var token APIToken
+	err := r.db.Get(&token, "SELECT * FROM api_tokens WHERE token_hash=$1", hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.APIToken{}, domain.ErrAPITokenNotFound
+		}
+		return domain.APIToken{}, err
+	}
+	return toAPIToken(token), nil
+}
+
+func (r *APITokensRepository) List(username string) ([]domain.APIToken, error) {
+	var dbTokens []APIToken
+	err := r.db.Select(&dbTokens, "SELECT * FROM api_tokens WHERE username=$1 ORDER BY created_at", username)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]domain.APIToken, len(dbTokens))
+	for i, t := range dbTokens {
+		tokens[i] = toAPIToken(t)
+	}
+	return tokens, nil
+}
+
+func (r *APITokensRepository) Delete(username string, id int64) error {
+	res, err := r.db.Exec("DELETE FROM api_tokens WHERE username=$1 AND id=$2", username, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return domain.ErrAPITokenNotFound
+	}
+	return nil
+}
+
+func (r *APITokensRepository) UpdateLastUsed(id int64, t time.Time) error {
+	_, err := r.db.Exec("UPDATE api_tokens SET last_used_at=$1 WHERE id=$2", t, id)
+	return err
+}
+
+func toAPIToken(t APIToken) domain.APIToken {
+	return domain.APIToken{
+		ID:       t.ID,
+		Username: t.Username,
+		Name:     t.Name,
+		Prefix:   t.Prefix,
+		Scopes:   domain.Flags(t.Scopes),
+		Created:  t.Created,
+		Expires:  t.Expires,
+		LastUsed: t.LastUsed,
+	}
+}
+
+func toDBToken(t domain.APIToken) APIToken {
+	return APIToken{
+		ID:       t.ID,
+		Username: t.Username,
+		Name:     t.Name,
+		Prefix:   t.Prefix,
+		Scopes:   pq.StringArray(t.Scopes),
+		Created:  t.Created,
+		Expires:  t.Expires,
+		LastUsed: t.LastUsed,
+	}
+}
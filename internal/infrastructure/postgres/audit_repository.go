@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuditRepository struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) *AuditRepository {
+	return &AuditRepository{db}
+}
+
+func (e AuditEvent) toDomain() (domain.AuditEvent, error) {
+	event := domain.AuditEvent{
+		ID:        e.ID,
+		Type:      e.Type,
+		Username:  e.Username,
+		Actor:     e.Actor,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		Created:   e.Created,
+	}
+	if len(e.Details) > 0 {
+		if err := json.Unmarshal(e.Details, &event.Details); err != nil {
+			return event, fmt.Errorf("parsing audit event details: %w", err)
+		}
+	}
+	return event, nil
+}
+
+func (r *AuditRepository) Create(event domain.AuditEvent) error {
+	var details interface{}
+	if len(event.Details) > 0 {
+		data, err := json.Marshal(event.Details)
+		if err != nil {
+			return err
+		}
+		details = string(data)
+	}
+	_, err := r.db.Exec(
+		`INSERT INTO audit_events (type, username, actor, ip, user_agent, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		event.Type, event.Username, event.Actor, event.IP, event.UserAgent, details, event.Created,
+	)
+	return err
+}
+
+func auditQueryConditions(q domain.AuditQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if q.Username != "" {
+		conditions = append(conditions, "username = "+arg(q.Username))
+	}
+	if q.Actor != "" {
+		conditions = append(conditions, "actor = "+arg(q.Actor))
+	}
+	if len(q.Types) > 0 {
+		conditions = append(conditions, "type = ANY("+arg(q.Types)+")")
+	}
+	if q.IP != "" {
+		conditions = append(conditions, "ip = "+arg(q.IP))
+	}
+	if q.From != nil {
+		conditions = append(conditions, "created_at >= "+arg(*q.From))
+	}
+	if q.To != nil {
+		conditions = append(conditions, "created_at < "+arg(*q.To))
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *AuditRepository) Query(q domain.AuditQuery) ([]domain.AuditEvent, int, error) {
+	if err := q.Validate(); err != nil {
+		return nil, 0, err
+	}
+	where, args := auditQueryConditions(q)
+	var total int
+	if err := r.db.Get(&total, "SELECT count(*) FROM audit_events"+where, args...); err != nil {
+		return nil, 0, err
+	}
+	query := "SELECT * FROM audit_events" + where + " ORDER BY created_at DESC, id DESC"
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+	if q.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", q.Offset)
+	}
+	var rows []AuditEvent
+	if err := r.db.Select(&rows, query, args...); err != nil {
+		return nil, 0, err
+	}
+	events := make([]domain.AuditEvent, len(rows))
+	for i, row := range rows {
+		var err error
+		if events[i], err = row.toDomain(); err != nil {
+			return nil, 0, err
+		}
+	}
+	return events, total, nil
+}
+
+func (r *AuditRepository) DeleteBefore(t time.Time) (int64, error) {
+	res, err := r.db.Exec("DELETE FROM audit_events WHERE created_at < $1", t)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
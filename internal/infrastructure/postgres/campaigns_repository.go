@@ -0,0 +1,245 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type CampaignsRepository struct {
+	db *sqlx.DB
+}
+
+func NewCampaignsRepository(db *sqlx.DB) *CampaignsRepository {
+	return &CampaignsRepository{db}
+}
+
+const selectCampaigns = `
+	SELECT c.*,
+		count(r.username) AS total,
+		count(r.username) FILTER (WHERE r.status = 'pending') AS pending,
+		count(r.username) FILTER (WHERE r.status = 'sent') AS sent,
+		count(r.username) FILTER (WHERE r.status = 'failed') AS failed
+	FROM email_campaigns c
+	LEFT JOIN email_campaign_recipients r ON r.campaign_id = c.id
+`
+
+func (c EmailCampaign) toDomain() (domain.Campaign, error) {
+	campaign := domain.Campaign{
+		ID:           c.ID,
+		Subject:      c.Subject,
+		HtmlTemplate: c.HtmlTemplate,
+		TextTemplate: c.TextTemplate,
+		Style:        c.Style,
+		Status:       c.Status,
+		SendAt:       c.SendAt,
+		CreatedBy:    c.CreatedBy,
+		Created:      c.Created,
+		Started:      c.Started,
+		Finished:     c.Finished,
+		Stats: domain.CampaignStats{
+			Total:   c.Total,
+			Pending: c.Pending,
+			Sent:    c.Sent,
+			Failed:  c.Failed,
+		},
+	}
+	if err := json.Unmarshal(c.Filter, &campaign.Filter); err != nil {
+		return campaign, fmt.Errorf("parsing campaign filter: %w", err)
+	}
+	return campaign, nil
+}
+
+func toCampaigns(rows []EmailCampaign) ([]domain.Campaign, error) {
+	campaigns := make([]domain.Campaign, len(rows))
+	for i, c := range rows {
+		var err error
+		if campaigns[i], err = c.toDomain(); err != nil {
+			return nil, err
+		}
+	}
+	return campaigns, nil
+}
+
+func (r *CampaignsRepository) Create(campaign domain.Campaign) (domain.Campaign, error) {
+	filter, err := json.Marshal(campaign.Filter)
+	if err != nil {
+		return campaign, err
+	}
+	err = r.db.Get(&campaign.ID,
+		`INSERT INTO email_campaigns (subject, html_template, text_template, style, filter, status, send_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		campaign.Subject, campaign.HtmlTemplate, campaign.TextTemplate, campaign.Style, string(filter),
+		campaign.Status, campaign.SendAt, campaign.CreatedBy, campaign.Created,
+	)
+	return campaign, err
+}
+
+func (r *CampaignsRepository) Update(campaign domain.Campaign) error {
+	filter, err := json.Marshal(campaign.Filter)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.Exec(
+		`UPDATE email_campaigns SET subject=$2, html_template=$3, text_template=$4, style=$5, filter=$6,
+			status=$7, send_at=$8, started_at=$9, finished_at=$10
+		WHERE id=$1`,
+		campaign.ID, campaign.Subject, campaign.HtmlTemplate, campaign.TextTemplate, campaign.Style, string(filter),
+		campaign.Status, campaign.SendAt, campaign.Started, campaign.Finished,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrCampaignNotFound
+	}
+	return nil
+}
+
+func (r *CampaignsRepository) Delete(id int64) error {
+	res, err := r.db.Exec("DELETE FROM email_campaigns WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrCampaignNotFound
+	}
+	return nil
+}
+
+func (r *CampaignsRepository) Get(id int64) (domain.Campaign, error) {
+	var c EmailCampaign
+	if err := r.db.Get(&c, selectCampaigns+" WHERE c.id=$1 GROUP BY c.id", id); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Campaign{}, domain.ErrCampaignNotFound
+		}
+		return domain.Campaign{}, err
+	}
+	return c.toDomain()
+}
+
+func (r *CampaignsRepository) List() ([]domain.Campaign, error) {
+	var rows []EmailCampaign
+	if err := r.db.Select(&rows, selectCampaigns+" GROUP BY c.id ORDER BY c.id DESC"); err != nil {
+		return nil, err
+	}
+	return toCampaigns(rows)
+}
+
+func (r *CampaignsRepository) DueCampaigns(now time.Time) ([]domain.Campaign, error) {
+	var rows []EmailCampaign
+	err := r.db.Select(&rows,
+		selectCampaigns+` WHERE c.status = 'sending' OR (c.status = 'scheduled' AND c.send_at <= $1)
+		GROUP BY c.id ORDER BY c.id`,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return toCampaigns(rows)
+}
+
+func (r *CampaignsRepository) StartCampaign(id int64, started time.Time, recipients []domain.CampaignRecipient) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(
+		"UPDATE email_campaigns SET status='sending', started_at=$2 WHERE id=$1 AND status='scheduled'",
+		id, started,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := insertRecipients(tx, id, recipients); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (r *CampaignsRepository) FinishCampaign(id int64, finished time.Time) (bool, error) {
+	res, err := r.db.Exec(
+		`UPDATE email_campaigns SET status='completed', finished_at=$2
+		WHERE id=$1 AND status='sending' AND NOT EXISTS (
+			SELECT 1 FROM email_campaign_recipients WHERE campaign_id=$1 AND status='pending'
+		)`,
+		id, finished,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func insertRecipients(tx *sqlx.Tx, id int64, recipients []domain.CampaignRecipient) error {
+	for _, rc := range recipients {
+		_, err := tx.Exec(
+			`INSERT INTO email_campaign_recipients (campaign_id, username, email, status)
+			VALUES ($1, $2, $3, 'pending') ON CONFLICT DO NOTHING`,
+			id, rc.Username, rc.Email,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toRecipients(rows []EmailCampaignRecipient) []domain.CampaignRecipient {
+	recipients := make([]domain.CampaignRecipient, len(rows))
+	for i, rc := range rows {
+		recipients[i] = domain.CampaignRecipient(rc)
+	}
+	return recipients
+}
+
+func (r *CampaignsRepository) GetRecipients(id int64, status string, limit, offset int) ([]domain.CampaignRecipient, error) {
+	var rows []EmailCampaignRecipient
+	err := r.db.Select(&rows,
+		`SELECT username, email, status, error, sent_at FROM email_campaign_recipients
+		WHERE campaign_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY username LIMIT $3 OFFSET $4`,
+		id, status, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return toRecipients(rows), nil
+}
+
+func (r *CampaignsRepository) ClaimRecipients(id int64, now time.Time, limit int, lock time.Duration) ([]domain.CampaignRecipient, error) {
+	var rows []EmailCampaignRecipient
+	err := r.db.Select(&rows,
+		`UPDATE email_campaign_recipients SET claimed_until = $3
+		WHERE campaign_id = $1 AND username IN (
+			SELECT username FROM email_campaign_recipients
+			WHERE campaign_id = $1 AND status = 'pending' AND (claimed_until IS NULL OR claimed_until <= $2)
+			ORDER BY username
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING username, email, status, error, sent_at`,
+		id, now, now.Add(lock), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return toRecipients(rows), nil
+}
+
+func (r *CampaignsRepository) SetRecipientStatus(id int64, recipient domain.CampaignRecipient) error {
+	_, err := r.db.Exec(
+		"UPDATE email_campaign_recipients SET status=$3, error=$4, sent_at=$5 WHERE campaign_id=$1 AND username=$2",
+		id, recipient.Username, recipient.Status, recipient.Error, recipient.Sent,
+	)
+	return err
+}
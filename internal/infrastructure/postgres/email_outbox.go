@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/infrastructure/email"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// EmailOutbox stores queue of outgoing emails in Postgres database
+type EmailOutbox struct {
+	db *sqlx.DB
+}
+
+func NewEmailOutbox(db *sqlx.DB) *EmailOutbox {
+	return &EmailOutbox{db}
+}
+
+func (m EmailOutboxMessage) toDomain() email.OutboxMessage {
+	return email.OutboxMessage{
+		ID:          m.ID,
+		Sender:      m.Sender,
+		Recipients:  []string(m.Recipients),
+		Subject:     m.Subject,
+		Message:     m.Message,
+		Status:      m.Status,
+		Attempts:    m.Attempts,
+		LastError:   m.LastError,
+		Created:     m.Created,
+		NextAttempt: m.NextAttempt,
+		Sent:        m.Sent,
+	}
+}
+
+func toOutboxMessages(rows []EmailOutboxMessage) []email.OutboxMessage {
+	messages := make([]email.OutboxMessage, len(rows))
+	for i, m := range rows {
+		messages[i] = m.toDomain()
+	}
+	return messages
+}
+
+func (s *EmailOutbox) Enqueue(messages ...email.OutboxMessage) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, m := range messages {
+		_, err := tx.Exec(
+			`INSERT INTO email_outbox (sender, recipients, subject, message, status, created_at, next_attempt_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			m.Sender, pq.StringArray(m.Recipients), m.Subject, m.Message, email.StatusPending, m.Created, m.NextAttempt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *EmailOutbox) Claim(now time.Time, limit int, lock time.Duration) ([]email.OutboxMessage, error) {
+	var rows []EmailOutboxMessage
+	err := s.db.Select(&rows,
+		`UPDATE email_outbox SET status = 'sending', attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status IN ('pending', 'sending') AND next_attempt_at <= $1
+			ORDER BY next_attempt_at, id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		now, now.Add(lock), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return toOutboxMessages(rows), nil
+}
+
+func (s *EmailOutbox) MarkSent(id int64, sent time.Time) error {
+	_, err := s.db.Exec(
+		"UPDATE email_outbox SET status = 'sent', sent_at = $2, last_error = '' WHERE id = $1",
+		id, sent,
+	)
+	return err
+}
+
+func (s *EmailOutbox) MarkFailed(id int64, status, lastError string, nextAttempt time.Time) error {
+	_, err := s.db.Exec(
+		"UPDATE email_outbox SET status = $2, last_error = $3, next_attempt_at = $4 WHERE id = $1",
+		id, status, lastError, nextAttempt,
+	)
+	return err
+}
+
+func (s *EmailOutbox) Get(id int64) (email.OutboxMessage, error) {
+	var m EmailOutboxMessage
+	if err := s.db.Get(&m, "SELECT * FROM email_outbox WHERE id = $1", id); err != nil {
+		if err == sql.ErrNoRows {
+			return email.OutboxMessage{}, email.ErrMessageNotFound
+		}
+		return email.OutboxMessage{}, err
+	}
+	return m.toDomain(), nil
+}
+
+func (s *EmailOutbox) List(status string, limit, offset int) ([]email.OutboxMessage, int, error) {
+	var total int
+	if err := s.db.Get(&total, "SELECT count(*) FROM email_outbox WHERE $1 = '' OR status = $1", status); err != nil {
+		return nil, 0, err
+	}
+	var rows []EmailOutboxMessage
+	err := s.db.Select(&rows,
+		`SELECT id, sender, recipients, subject, '' AS message, status, attempts, last_error, created_at, next_attempt_at, sent_at
+		FROM email_outbox WHERE $1 = '' OR status = $1
+		ORDER BY id DESC LIMIT $2 OFFSET $3`,
+		status, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return toOutboxMessages(rows), total, nil
+}
+
+func (s *EmailOutbox) Retry(id int64, now time.Time) error {
+	res, err := s.db.Exec(
+		`UPDATE email_outbox SET status = 'pending', attempts = 0, next_attempt_at = $2, sent_at = NULL
+		WHERE id = $1 AND status <> 'sending'`,
+		id, now,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if _, err := s.Get(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *EmailOutbox) RetryFailed(now time.Time) (int, error) {
+	res, err := s.db.Exec(
+		"UPDATE email_outbox SET status = 'pending', attempts = 0, next_attempt_at = $1 WHERE status = 'failed'",
+		now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *EmailOutbox) DeleteSent(before time.Time) (int, error) {
+	res, err := s.db.Exec("DELETE FROM email_outbox WHERE status = 'sent' AND sent_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"github.com/gisquick/gisquick-server/internal/infrastructure/email"
+	"github.com/jmoiron/sqlx"
+)
+
+// EmailTemplates stores overrides of email templates in Postgres database
+type EmailTemplates struct {
+	db *sqlx.DB
+}
+
+func NewEmailTemplates(db *sqlx.DB) *EmailTemplates {
+	return &EmailTemplates{db}
+}
+
+func (s *EmailTemplates) List() ([]email.TemplateOverride, error) {
+	var rows []EmailTemplateOverride
+	if err := s.db.Select(&rows, "SELECT * FROM email_templates ORDER BY name, lang"); err != nil {
+		return nil, err
+	}
+	overrides := make([]email.TemplateOverride, len(rows))
+	for i, r := range rows {
+		overrides[i] = email.TemplateOverride(r)
+	}
+	return overrides, nil
+}
+
+func (s *EmailTemplates) Save(o email.TemplateOverride) error {
+	row := EmailTemplateOverride(o)
+	_, err := s.db.NamedExec(
+		`INSERT INTO email_templates (name, lang, subject, html, text, updated_at, updated_by)
+		VALUES (:name, :lang, :subject, :html, :text, :updated_at, :updated_by)
+		ON CONFLICT (name, lang) DO UPDATE SET
+			subject = EXCLUDED.subject,
+			html = EXCLUDED.html,
+			text = EXCLUDED.text,
+			updated_at = EXCLUDED.updated_at,
+			updated_by = EXCLUDED.updated_by`,
+		&row,
+	)
+	return err
+}
+
+func (s *EmailTemplates) Delete(name, lang string) error {
+	res, err := s.db.Exec("DELETE FROM email_templates WHERE name = $1 AND lang = $2", name, lang)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return email.ErrOverrideNotFound
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jackc/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+type GroupsRepository struct {
+	db *sqlx.DB
+}
+
+func NewGroupsRepository(db *sqlx.DB) *GroupsRepository {
+	return &GroupsRepository{db}
+}
+
+const selectGroups = `
+	SELECT g.name, g.description, g.created_at,
+		array_remove(array_agg(m.username ORDER BY m.username), NULL) AS members
+	FROM user_groups g
+	LEFT JOIN user_group_members m ON m.group_name = g.name
+`
+
+func (g UserGroup) toDomain() domain.Group {
+	created := g.Created
+	members := []string(g.Members)
+	if members == nil {
+		members = []string{}
+	}
+	return domain.Group{
+		Name:        g.Name,
+		Description: g.Description,
+		Created:     &created,
+		Members:     members,
+	}
+}
+
+func insertGroupMembers(tx *sqlx.Tx, name string, usernames []string) error {
+	for _, username := range usernames {
+		_, err := tx.Exec(
+			"INSERT INTO user_group_members (group_name, username) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			name, username,
+		)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" { // ForeignKeyViolation
+				return domain.ErrAccountNotFound
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Create saves a new group together with its members
+func (r *GroupsRepository) Create(group domain.Group) error {
+	g := UserGroup{Name: group.Name, Description: group.Description}
+	if group.Created != nil {
+		g.Created = *group.Created
+	}
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.NamedExec(
+		"INSERT INTO user_groups (name, description, created_at) VALUES (:name, :description, :created_at)",
+		g,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // UniqueViolation
+			return domain.ErrGroupExists
+		}
+		return err
+	}
+	if err := insertGroupMembers(tx, group.Name, group.Members); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *GroupsRepository) Update(group domain.Group) error {
+	res, err := r.db.Exec("UPDATE user_groups SET description=$2 WHERE name=$1", group.Name, group.Description)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrGroupNotFound
+	}
+	return nil
+}
+
+func (r *GroupsRepository) Delete(name string) error {
+	res, err := r.db.Exec("DELETE FROM user_groups WHERE name=$1", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrGroupNotFound
+	}
+	return nil
+}
+
+func (r *GroupsRepository) Get(name string) (domain.Group, error) {
+	var g UserGroup
+	if err := r.db.Get(&g, selectGroups+" WHERE g.name=$1 GROUP BY g.name", name); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Group{}, domain.ErrGroupNotFound
+		}
+		return domain.Group{}, err
+	}
+	return g.toDomain(), nil
+}
+
+func (r *GroupsRepository) List() ([]domain.Group, error) {
+	var groups []UserGroup
+	if err := r.db.Select(&groups, selectGroups+" GROUP BY g.name ORDER BY g.name"); err != nil {
+		return nil, err
+	}
+	list := make([]domain.Group, len(groups))
+	for i, g := range groups {
+		list[i] = g.toDomain()
+	}
+	return list, nil
+}
+
+func (r *GroupsRepository) SetMembers(name string, usernames []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.Get(&exists, "SELECT exists (SELECT 1 FROM user_groups WHERE name = $1)", name); err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrGroupNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM user_group_members WHERE group_name=$1", name); err != nil {
+		return err
+	}
+	if err := insertGroupMembers(tx, name, usernames); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *GroupsRepository) GetUserGroups(username string) ([]string, error) {
+	groups := []string{}
+	err := r.db.Select(&groups, "SELECT group_name FROM user_group_members WHERE username=$1 ORDER BY group_name", username)
+	return groups, err
+}
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type InvitationsRepository struct {
+	db *sqlx.DB
+}
+
+func NewInvitationsRepository(db *sqlx.DB) *InvitationsRepository {
+	return &InvitationsRepository{db}
+}
+
+func (i Invitation) toDomain() (domain.Invitation, error) {
+	invitation := domain.Invitation{
+		ID:         i.ID,
+		Username:   i.Username,
+		Email:      i.Email,
+		Project:    i.Project,
+		NewAccount: i.NewAccount,
+		InvitedBy:  i.InvitedBy,
+		Created:    i.Created,
+		Expires:    i.Expires,
+		Accepted:   i.Accepted,
+		Revoked:    i.Revoked,
+	}
+	if err := json.Unmarshal(i.Grants, &invitation.Grants); err != nil {
+		return invitation, fmt.Errorf("parsing invitation grants: %w", err)
+	}
+	return invitation, nil
+}
+
+func (r *InvitationsRepository) list(q string, args ...interface{}) ([]domain.Invitation, error) {
+	var rows []Invitation
+	if err := r.db.Select(&rows, q, args...); err != nil {
+		return nil, err
+	}
+	invitations := make([]domain.Invitation, len(rows))
+	for i, row := range rows {
+		var err error
+		if invitations[i], err = row.toDomain(); err != nil {
+			return nil, err
+		}
+	}
+	return invitations, nil
+}
+
+func (r *InvitationsRepository) Create(invitation domain.Invitation) (domain.Invitation, error) {
+	grants, err := json.Marshal(invitation.Grants)
+	if err != nil {
+		return invitation, err
+	}
+	err = r.db.Get(&invitation.ID,
+		`INSERT INTO invitations (username, email, project, grants, new_account, invited_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		invitation.Username, invitation.Email, invitation.Project, string(grants),
+		invitation.NewAccount, invitation.InvitedBy, invitation.Created, invitation.Expires,
+	)
+	return invitation, err
+}
+
+func (r *InvitationsRepository) Get(id int64) (domain.Invitation, error) {
+	var row Invitation
+	if err := r.db.Get(&row, "SELECT * FROM invitations WHERE id = $1", id); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Invitation{}, domain.ErrInvitationNotFound
+		}
+		return domain.Invitation{}, err
+	}
+	return row.toDomain()
+}
+
+func (r *InvitationsRepository) Update(invitation domain.Invitation) error {
+	res, err := r.db.Exec(
+		"UPDATE invitations SET accepted_at = $2, revoked_at = $3 WHERE id = $1",
+		invitation.ID, invitation.Accepted, invitation.Revoked,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return domain.ErrInvitationNotFound
+	}
+	return nil
+}
+
+func (r *InvitationsRepository) ProjectInvitations(project string) ([]domain.Invitation, error) {
+	return r.list("SELECT * FROM invitations WHERE project = $1 ORDER BY created_at DESC", project)
+}
+
+func (r *InvitationsRepository) UserInvitations(username string) ([]domain.Invitation, error) {
+	return r.list("SELECT * FROM invitations WHERE username = $1 ORDER BY created_at DESC", username)
+}
+
+func (r *InvitationsRepository) All() ([]domain.Invitation, error) {
+	return r.list("SELECT * FROM invitations ORDER BY created_at DESC")
+}
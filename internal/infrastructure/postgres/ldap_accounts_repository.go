@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type LDAPAccountsRepository struct {
+	db *sqlx.DB
+}
+
+func NewLDAPAccountsRepository(db *sqlx.DB) *LDAPAccountsRepository {
+	return &LDAPAccountsRepository{db}
+}
+
+func (r *LDAPAccountsRepository) Get(username string) (domain.LDAPAccount, error) {
+	var row LDAPAccount
+	err := r.db.Get(&row, "SELECT * FROM ldap_accounts WHERE username = $1", username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.LDAPAccount{}, domain.ErrAccountNotFound
+		}
+		return domain.LDAPAccount{}, err
+	}
+	return domain.LDAPAccount{Username: row.Username, Groups: []string(row.Groups), Synced: row.Synced}, nil
+}
+
+func (r *LDAPAccountsRepository) Save(account domain.LDAPAccount) error {
+	groups := account.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	_, err := r.db.Exec(
+		`INSERT INTO ldap_accounts (username, groups, synced_at) VALUES ($1, $2, $3)
+		ON CONFLICT (username) DO UPDATE SET groups = EXCLUDED.groups, synced_at = EXCLUDED.synced_at`,
+		account.Username, pq.StringArray(groups), account.Synced,
+	)
+	return err
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type UserProfile map[string]any
@@ -40,4 +42,156 @@ type User struct {
 	Confirmed   *time.Time  `db:"confirmed_at"`
 	LastLogin   *time.Time  `db:"last_login_at"`
 	Profile     UserProfile `db:"profile"`
+
+	PasswordChanged *time.Time `db:"password_changed_at"`
+	PasswordExpiry  bool       `db:"password_expiry"`
+}
+
+type APIToken struct {
+	ID       int64          `db:"id"`
+	Username string         `db:"username"`
+	Name     string         `db:"name"`
+	Hash     string         `db:"token_hash"`
+	Prefix   string         `db:"token_prefix"`
+	Scopes   pq.StringArray `db:"scopes"`
+	Created  time.Time      `db:"created_at"`
+	Expires  *time.Time     `db:"expires_at"`
+	LastUsed *time.Time     `db:"last_used_at"`
+}
+
+type TwoFactor struct {
+	Username      string         `db:"username"`
+	Secret        string         `db:"secret"`
+	Enabled       bool           `db:"enabled"`
+	RecoveryCodes pq.StringArray `db:"recovery_codes"`
+	LastStep      int64          `db:"last_step"`
+	Created       time.Time      `db:"created_at"`
+}
+
+type Organization struct {
+	Name             string    `db:"name"`
+	Title            string    `db:"title"`
+	ProjectsLimit    *int      `db:"projects_limit"`
+	ProjectSizeLimit *int64    `db:"project_size_limit"`
+	StorageLimit     *int64    `db:"storage_limit"`
+	Created          time.Time `db:"created_at"`
+}
+
+type OrganizationMember struct {
+	Organization string    `db:"organization"`
+	Username     string    `db:"username"`
+	Role         string    `db:"role"`
+	Created      time.Time `db:"created_at"`
+}
+
+type UserGroup struct {
+	Name        string         `db:"name"`
+	Description string         `db:"description"`
+	Created     time.Time      `db:"created_at"`
+	Members     pq.StringArray `db:"members"`
+}
+
+type EmailOutboxMessage struct {
+	ID          int64          `db:"id"`
+	Sender      string         `db:"sender"`
+	Recipients  pq.StringArray `db:"recipients"`
+	Subject     string         `db:"subject"`
+	Message     string         `db:"message"`
+	Status      string         `db:"status"`
+	Attempts    int            `db:"attempts"`
+	LastError   string         `db:"last_error"`
+	Created     time.Time      `db:"created_at"`
+	NextAttempt time.Time      `db:"next_attempt_at"`
+	Sent        *time.Time     `db:"sent_at"`
+}
+
+type EmailCampaign struct {
+	ID           int64      `db:"id"`
+	Subject      string     `db:"subject"`
+	HtmlTemplate string     `db:"html_template"`
+	TextTemplate string     `db:"text_template"`
+	Style        string     `db:"style"`
+	Filter       []byte     `db:"filter"`
+	Status       string     `db:"status"`
+	SendAt       *time.Time `db:"send_at"`
+	CreatedBy    string     `db:"created_by"`
+	Created      *time.Time `db:"created_at"`
+	Started      *time.Time `db:"started_at"`
+	Finished     *time.Time `db:"finished_at"`
+
+	Total   int `db:"total"`
+	Pending int `db:"pending"`
+	Sent    int `db:"sent"`
+	Failed  int `db:"failed"`
+}
+
+type EmailCampaignRecipient struct {
+	Username string     `db:"username"`
+	Email    string     `db:"email"`
+	Status   string     `db:"status"`
+	Error    string     `db:"error"`
+	Sent     *time.Time `db:"sent_at"`
+}
+
+type EmailTemplateOverride struct {
+	Name      string    `db:"name"`
+	Language  string    `db:"lang"`
+	Subject   string    `db:"subject"`
+	HTML      string    `db:"html"`
+	Text      string    `db:"text"`
+	Updated   time.Time `db:"updated_at"`
+	UpdatedBy string    `db:"updated_by"`
+}
+
+type AccountDeletion struct {
+	Username   string     `db:"username"`
+	TransferTo string     `db:"transfer_to"`
+	Requested  time.Time  `db:"requested_at"`
+	Confirmed  *time.Time `db:"confirmed_at"`
+	DeleteAt   *time.Time `db:"delete_at"`
+}
+
+type Invitation struct {
+	ID         int64      `db:"id"`
+	Username   string     `db:"username"`
+	Email      string     `db:"email"`
+	Project    string     `db:"project"`
+	Grants     []byte     `db:"grants"`
+	NewAccount bool       `db:"new_account"`
+	InvitedBy  string     `db:"invited_by"`
+	Created    time.Time  `db:"created_at"`
+	Expires    time.Time  `db:"expires_at"`
+	Accepted   *time.Time `db:"accepted_at"`
+	Revoked    *time.Time `db:"revoked_at"`
+}
+
+type AuditEvent struct {
+	ID        int64     `db:"id"`
+	Type      string    `db:"type"`
+	Username  string    `db:"username"`
+	Actor     string    `db:"actor"`
+	IP        string    `db:"ip"`
+	UserAgent string    `db:"user_agent"`
+	Details   []byte    `db:"details"`
+	Created   time.Time `db:"created_at"`
+}
+
+type UsageSample struct {
+	Username      string    `db:"username"`
+	Sampled       time.Time `db:"sampled_at"`
+	ProjectsCount int       `db:"projects_count"`
+	StorageSize   int64     `db:"storage_size"`
+}
+
+type OIDCIdentity struct {
+	Issuer   string    `db:"issuer"`
+	Subject  string    `db:"subject"`
+	Username string    `db:"username"`
+	Created  time.Time `db:"created_at"`
+}
+
+type LDAPAccount struct {
+	Username string         `db:"username"`
+	Groups   pq.StringArray `db:"groups"`
+	Synced   time.Time      `db:"synced_at"`
 }
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/infrastructure/project"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// NotificationStore stores notifications in Postgres database
+type NotificationStore struct {
+	log *zap.SugaredLogger
+	db  *sqlx.DB
+}
+
+func NewNotificationStore(log *zap.SugaredLogger, db *sqlx.DB) *NotificationStore {
+	return &NotificationStore{log: log, db: db}
+}
+
+func (s *NotificationStore) SaveNotification(ctx context.Context, notification project.Notification) error {
+	var expires *time.Time
+	if !notification.Expiration.IsZero() {
+		if notification.Expiration.Before(time.Now()) {
+			return project.ErrInvalidDuration
+		}
+		expires = &notification.Expiration
+	}
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO notifications (id, data, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at`,
+		notification.ID, string(data), expires,
+	)
+	return err
+}
+
+func (s *NotificationStore) DeleteNotification(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM notifications WHERE id=$1", id)
+	return err
+}
+
+func (s *NotificationStore) GetNotifications() ([]project.Notification, error) {
+	if _, err := s.db.Exec("DELETE FROM notifications WHERE expires_at <= now()"); err != nil {
+		s.log.Warnw("deleting expired notifications", zap.Error(err))
+	}
+	var rows []struct {
+		ID   string `db:"id"`
+		Data []byte `db:"data"`
+	}
+	err := s.db.Select(&rows, "SELECT id, data FROM notifications WHERE expires_at IS NULL OR expires_at > now() ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	notifications := make([]project.Notification, 0, len(rows))
+	for _, r := range rows {
+		var n project.Notification
+		if err := json.Unmarshal(r.Data, &n); err != nil {
+			s.log.Warnw("GetNotifications", zap.Error(err))
+			continue
+		}
+		n.ID = r.ID
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+func (s *NotificationStore) GetMapProjectNotifications(projectName string, user domain.User) ([]project.Notification, error) {
+	allNotifications, err := s.GetNotifications()
+	if err != nil {
+		return nil, err
+	}
+	return project.FilterMapProjectNotifications(allNotifications, projectName, user), nil
+}
+
+func (s *NotificationStore) GetSettingsNotifications(user domain.User) ([]project.Notification, error) {
+	allNotifications, err := s.GetNotifications()
+	if err != nil {
+		return nil, err
+	}
+	return project.FilterSettingsNotifications(allNotifications, user), nil
+}
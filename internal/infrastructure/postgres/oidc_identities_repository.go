@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jackc/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+type OIDCIdentitiesRepository struct {
+	db *sqlx.DB
+}
+
+func NewOIDCIdentitiesRepository(db *sqlx.DB) *OIDCIdentitiesRepository {
+	return &OIDCIdentitiesRepository{db}
+}
+
+func (r *OIDCIdentitiesRepository) Get(issuer, subject string) (domain.OIDCIdentity, error) {
+	var row OIDCIdentity
+	err := r.db.Get(&row, "SELECT * FROM oidc_identities WHERE issuer = $1 AND subject = $2", issuer, subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.OIDCIdentity{}, domain.ErrIdentityNotFound
+		}
+		return domain.OIDCIdentity{}, err
+	}
+	return domain.OIDCIdentity{
+		Issuer:   row.Issuer,
+		Subject:  row.Subject,
+		Username: row.Username,
+		Created:  row.Created,
+	}, nil
+}
+
+func (r *OIDCIdentitiesRepository) Create(identity domain.OIDCIdentity) error {
+	_, err := r.db.Exec(
+		"INSERT INTO oidc_identities (issuer, subject, username, created_at) VALUES ($1, $2, $3, $4)",
+		identity.Issuer, identity.Subject, identity.Username, identity.Created,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { // UniqueViolation
+		return domain.ErrIdentityLinked
+	}
+	return err
+}
@@ -0,0 +1,289 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jackc/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type OrganizationsRepository struct {
+	db *sqlx.DB
+}
+
+func NewOrganizationsRepository(db *sqlx.DB) *OrganizationsRepository {
+	return &OrganizationsRepository{db}
+}
+
+func toOrganization(org domain.Organization) Organization {
+	o := Organization{
+		Name:          org.Name,
+		Title:         org.Title,
+		ProjectsLimit: org.ProjectsLimit,
+	}
+	if org.Created != nil {
+		o.Created = *org.Created
+	}
+	if org.ProjectSizeLimit != nil {
+		v := int64(*org.ProjectSizeLimit)
+		o.ProjectSizeLimit = &v
+	}
+	if org.StorageLimit != nil {
+		v := int64(*org.StorageLimit)
+		o.StorageLimit = &v
+	}
+	return o
+}
+
+func (o Organization) toDomain() domain.Organization {
+	created := o.Created
+	org := domain.Organization{
+		Name:          o.Name,
+		Title:         o.Title,
+		Created:       &created,
+		ProjectsLimit: o.ProjectsLimit,
+	}
+	if o.ProjectSizeLimit != nil {
+		v := domain.ByteSize(*o.ProjectSizeLimit)
+		org.ProjectSizeLimit = &v
+	}
+	if o.StorageLimit != nil {
+		v := domain.ByteSize(*o.StorageLimit)
+		org.StorageLimit = &v
+	}
+	return org
+}
+
+func (r *OrganizationsRepository) Create(org domain.Organization, owner string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// organizations share namespace of projects with users
+	var exists bool
+	if err := tx.Get(&exists, "SELECT exists (SELECT 1 FROM users WHERE username = $1)", org.Name); err != nil {
+		return err
+	}
+	if exists {
+		return domain.ErrOrganizationExists
+	}
+	_, err = tx.NamedExec(
+		`INSERT INTO organizations (name, title, projects_limit, project_size_limit, storage_limit, created_at)
+		VALUES (:name, :title, :projects_limit, :project_size_limit, :storage_limit, :created_at)`,
+		toOrganization(org),
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // UniqueViolation
+			return domain.ErrOrganizationExists
+		}
+		return err
+	}
+	if owner != "" {
+		_, err = tx.Exec(
+			"INSERT INTO organization_members (organization, username, role, created_at) VALUES ($1, $2, $3, $4)",
+			org.Name, owner, domain.OrgRoleOwner, time.Now(),
+		)
+		if err != nil {
+			return fmt.Errorf("adding organization owner: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *OrganizationsRepository) Update(org domain.Organization) error {
+	res, err := r.db.NamedExec(
+		`UPDATE organizations SET title=:title, projects_limit=:projects_limit,
+		project_size_limit=:project_size_limit, storage_limit=:storage_limit WHERE name=:name`,
+		toOrganization(org),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrOrganizationNotFound
+	}
+	return nil
+}
+
+func (r *OrganizationsRepository) Delete(name string) error {
+	res, err := r.db.Exec("DELETE FROM organizations WHERE name=$1", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrOrganizationNotFound
+	}
+	return nil
+}
+
+func (r *OrganizationsRepository) Get(name string) (domain.Organization, error) {
+	var o Organization
+	if err := r.db.Get(&o, "SELECT * FROM organizations WHERE name=$1", name); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Organization{}, domain.ErrOrganizationNotFound
+		}
+		return domain.Organization{}, err
+	}
+	return o.toDomain(), nil
+}
+
+func (r *OrganizationsRepository) List() ([]domain.Organization, error) {
+	var orgs []Organization
+	if err := r.db.Select(&orgs, "SELECT * FROM organizations ORDER BY name"); err != nil {
+		return nil, err
+	}
+	list := make([]domain.Organization, len(orgs))
+	for i, o := range orgs {
+		list[i] = o.toDomain()
+	}
+	return list, nil
+}
+
+func (r *OrganizationsRepository) GetMembers(org string) ([]domain.OrganizationMember, error) {
+	var members []OrganizationMember
+	err := r.db.Select(&members, "SELECT * FROM organization_members WHERE organization=$1 ORDER BY username", org)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]domain.OrganizationMember, len(members))
+	for i, m := range members {
+		created := m.Created
+		list[i] = domain.OrganizationMember{
+			Organization: m.Organization,
+			Username:     m.Username,
+			Role:         m.Role,
+			Created:      &created,
+		}
+	}
+	return list, nil
+}
+
+func (r *OrganizationsRepository) SetMember(member domain.OrganizationMember) error {
+	m := OrganizationMember{
+		Organization: member.Organization,
+		Username:     member.Username,
+		Role:         member.Role,
+		Created:      time.Now(),
+	}
+	if member.Created != nil {
+		m.Created = *member.Created
+	}
+	const q = `
+	INSERT INTO organization_members (organization, username, role, created_at)
+	VALUES (:organization, :username, :role, :created_at)
+	ON CONFLICT (organization, username) DO UPDATE SET "role" = EXCLUDED.role
+	`
+	if _, err := r.db.NamedExec(q, m); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // ForeignKeyViolation
+			if pgErr.ConstraintName == "organization_members_username_fkey" {
+				return domain.ErrAccountNotFound
+			}
+			return domain.ErrOrganizationNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *OrganizationsRepository) RemoveMember(org, username string) error {
+	_, err := r.db.Exec("DELETE FROM organization_members WHERE organization=$1 AND username=$2", org, username)
+	return err
+}
+
+func (r *OrganizationsRepository) GetTeams(org string) ([]domain.Team, error) {
+	var rows []struct {
+		Name    string         `db:"name"`
+		Members pq.StringArray `db:"members"`
+	}
+	const q = `
+	SELECT t.name, array_remove(array_agg(m.username ORDER BY m.username), NULL) AS members
+	FROM organization_teams t
+	LEFT JOIN organization_team_members m ON m.organization = t.organization AND m.team = t.name
+	WHERE t.organization=$1
+	GROUP BY t.name
+	ORDER BY t.name
+	`
+	if err := r.db.Select(&rows, q, org); err != nil {
+		return nil, err
+	}
+	teams := make([]domain.Team, len(rows))
+	for i, row := range rows {
+		members := []string(row.Members)
+		if members == nil {
+			members = []string{}
+		}
+		teams[i] = domain.Team{Organization: org, Name: row.Name, Members: members}
+	}
+	return teams, nil
+}
+
+// SaveTeam creates or updates the team and replaces its members
+func (r *OrganizationsRepository) SaveTeam(team domain.Team) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO organization_teams (organization, name) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		team.Organization, team.Name,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM organization_team_members WHERE organization=$1 AND team=$2", team.Organization, team.Name)
+	if err != nil {
+		return err
+	}
+	for _, username := range team.Members {
+		_, err = tx.Exec(
+			"INSERT INTO organization_team_members (organization, team, username) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			team.Organization, team.Name, username,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *OrganizationsRepository) DeleteTeam(org, name string) error {
+	res, err := r.db.Exec("DELETE FROM organization_teams WHERE organization=$1 AND name=$2", org, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrTeamNotFound
+	}
+	return nil
+}
+
+func (r *OrganizationsRepository) GetUserMemberships(username string) (domain.Memberships, error) {
+	memberships := domain.Memberships{Organizations: make(map[string]string), Teams: []string{}}
+	var members []OrganizationMember
+	if err := r.db.Select(&members, "SELECT * FROM organization_members WHERE username=$1", username); err != nil {
+		return memberships, err
+	}
+	for _, m := range members {
+		memberships.Organizations[m.Organization] = m.Role
+	}
+	if len(members) == 0 {
+		return memberships, nil
+	}
+	err := r.db.Select(
+		&memberships.Teams,
+		"SELECT organization || '/' || team FROM organization_team_members WHERE username=$1 ORDER BY 1",
+		username,
+	)
+	return memberships, err
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type TwoFactorRepository struct {
+	db *sqlx.DB
+}
+
+func NewTwoFactorRepository(db *sqlx.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db}
+}
+
+func (r *TwoFactorRepository) Get(username string) (domain.TwoFactorConfig, error) {
+	var tf TwoFactor
+	if err := r.db.Get(&tf, "SELECT * FROM two_factor WHERE username=$1", username); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.TwoFactorConfig{}, domain.ErrTwoFactorNotConfigured
+		}
+		return domain.TwoFactorConfig{}, err
+	}
+	return domain.TwoFactorConfig{
+		Username:      tf.Username,
+		Secret:        tf.Secret,
+		Enabled:       tf.Enabled,
+		RecoveryCodes: domain.Flags(tf.RecoveryCodes),
+		LastStep:      tf.LastStep,
+		Created:       tf.Created,
+	}, nil
+}
+
+func (r *TwoFactorRepository) Save(cfg domain.TwoFactorConfig) error {
+	codes := pq.StringArray(cfg.RecoveryCodes)
+	if codes == nil {
+		codes = pq.StringArray{}
+	}
+	tf := TwoFactor{
+		Username:      cfg.Username,
+		Secret:        cfg.Secret,
+		Enabled:       cfg.Enabled,
+		RecoveryCodes: codes,
+		LastStep:      cfg.LastStep,
+		Created:       cfg.Created,
+	}
+	const q = `
+	INSERT INTO two_factor (username, secret, enabled, recovery_codes, last_step, created_at)
+	VALUES (:username, :secret, :enabled, :recovery_codes, :last_step, :created_at)
+	ON CONFLICT (username) DO UPDATE SET
+			"secret" = EXCLUDED.secret,
+			"enabled" = EXCLUDED.enabled,
+			"recovery_codes" = EXCLUDED.recovery_codes,
+			"last_step" = EXCLUDED.last_step,
+			"created_at" = EXCLUDED.created_at
+	`
+	_, err := r.db.NamedExec(q, tf)
+	return err
+}
+
+func (r *TwoFactorRepository) Delete(username string) error {
+	_, err := r.db.Exec("DELETE FROM two_factor WHERE username=$1", username)
+	return err
+}
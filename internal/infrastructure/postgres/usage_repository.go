@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type UsageRepository struct {
+	db *sqlx.DB
+}
+
+func NewUsageRepository(db *sqlx.DB) *UsageRepository {
+	return &UsageRepository{db}
+}
+
+func (r *UsageRepository) SaveSamples(samples []domain.UsageSample) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, s := range samples {
+		_, err := tx.Exec(
+			`INSERT INTO usage_samples (username, sampled_at, projects_count, storage_size) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (username, sampled_at) DO UPDATE SET
+				projects_count = EXCLUDED.projects_count,
+				storage_size = EXCLUDED.storage_size`,
+			s.Username, s.Time, s.ProjectsCount, s.StorageSize,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *UsageRepository) Samples(username string, from time.Time) ([]domain.UsageSample, error) {
+	var rows []UsageSample
+	err := r.db.Select(&rows,
+		"SELECT * FROM usage_samples WHERE username = $1 AND sampled_at >= $2 ORDER BY sampled_at",
+		username, from,
+	)
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]domain.UsageSample, len(rows))
+	for i, row := range rows {
+		samples[i] = domain.UsageSample{
+			Username:      row.Username,
+			Time:          row.Sampled,
+			ProjectsCount: row.ProjectsCount,
+			StorageSize:   row.StorageSize,
+		}
+	}
+	return samples, nil
+}
+
+func (r *UsageRepository) DeleteBefore(t time.Time) (int64, error) {
+	res, err := r.db.Exec("DELETE FROM usage_samples WHERE sampled_at < $1", t)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -1,6 +1,7 @@
 package project
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
 	"encoding/json"
@@ -90,6 +91,8 @@ type DiskStorage struct {
 	configCache       *cache.DataCache[string, json.RawMessage]
 	projectInfoReader JsonFilesReader[domain.ProjectInfo]
 	settingsReader    JsonFilesReader[domain.ProjectSettings]
+	// per-project locks of read-modify-write updates of project's config files
+	configLocks sync.Map
 }
 
 type Info struct {
@@ -216,6 +219,10 @@ func NewDiskStorage(log *zap.SugaredLogger, projectsRoot string) *DiskStorage {
 		project := i.Key()
 		index := i.Value()
 		log.Infow("ttlcache.OnEviction.indexCache", "project", project)
+		if er == ttlcache.EvictionReasonDeleted && !ds.CheckProjectExists(project) {
+			// project was deleted or moved
+			return
+		}
 		if err := saveJsonFile(filepath.Join(projectsRoot, project, ".gisquick", "filesmap.json"), index.Index); err != nil {
 			log.Errorw("saving files index", "project", project, zap.Error(err))
 		}
@@ -343,6 +350,67 @@ func (s *DiskStorage) GetProjectInfo(name string) (domain.ProjectInfo, error) {
 	return pInfo, nil
 }
 
+// lockConfig locks project's config files (project info and settings) for an update
+func (s *DiskStorage) lockConfig(projectName string) func() {
+	mu, _ := s.configLocks.LoadOrStore(projectName, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+func (s *DiskStorage) UpdateProjectInfo(name string, update func(info *domain.ProjectInfo) error) (domain.ProjectInfo, error) {
+	unlock := s.lockConfig(name)
+	defer unlock()
+	return s.updateProjectInfo(name, update)
+}
+
+// updateProjectInfo updates project info file, caller must hold the config lock
+func (s *DiskStorage) updateProjectInfo(name string, update func(info *domain.ProjectInfo) error) (domain.ProjectInfo, error) {
+	info, err := s.GetProjectInfo(name)
+	if err != nil {
+		return info, err
+	}
+	if info.Schedule != nil {
+		// don't modify cached value
+		schedule := *info.Schedule
+		info.Schedule = &schedule
+	}
+	if err := update(&info); err != nil {
+		return info, err
+	}
+	if err := s.saveConfigFile(name, "project.json", info); err != nil {
+		return info, fmt.Errorf("updating project file: %w", err)
+	}
+	return info, nil
+}
+
+// SetAuthentication changes authentication type in both project's settings and info files
+func (s *DiskStorage) SetAuthentication(name, authType string) error {
+	unlock := s.lockConfig(name)
+	defer unlock()
+	content, err := os.ReadFile(s.GetSettingsPath(name))
+	if err != nil {
+		return fmt.Errorf("reading settings file: %w", err)
+	}
+	var settings map[string]interface{}
+	if err := json.Unmarshal(content, &settings); err != nil {
+		return fmt.Errorf("parsing settings file: %w", err)
+	}
+	auth, ok := settings["auth"].(map[string]interface{})
+	if !ok {
+		auth = make(map[string]interface{})
+		settings["auth"] = auth
+	}
+	auth["type"] = authType
+	if err := s.saveConfigFile(name, "settings.json", settings); err != nil {
+		return fmt.Errorf("saving settings file: %w", err)
+	}
+	_, err = s.updateProjectInfo(name, func(info *domain.ProjectInfo) error {
+		info.Authentication = authType
+		return nil
+	})
+	return err
+}
+
 // func (s *DiskStorage) saveFileIndex(project string, index *FilesIndex) {
 // 	if err := saveJsonFile(filepath.Join(s.ProjectsRoot, project, ".gisquick", "filesmap.json"), index); err != nil {
 // 		return nil, fmt.Errorf("saving files index: %w", err)
@@ -493,14 +561,13 @@ func (s *DiskStorage) ListProjectFiles(project string, checksum bool) ([]domain.
 		}
 	}
 	if indexUpdated {
-		projectInfo, err := s.GetProjectInfo(project)
+		_, err := s.UpdateProjectInfo(project, func(info *domain.ProjectInfo) error {
+			info.Size = index.TotalSize()
+			return nil
+		})
 		if err != nil {
 			s.log.Errorw("updating project size", "project", project, zap.Error(err))
 		}
-		projectInfo.Size = index.TotalSize()
-		if err := s.saveConfigFile(project, "project.json", projectInfo); err != nil {
-			s.log.Errorw("updating project size", "project", project, zap.Error(err))
-		}
 	}
 	tempFiles := make([]domain.ProjectFile, len(temporaryFiles))
 	i = 0
@@ -574,6 +641,34 @@ func (s *DiskStorage) Delete(name string) error {
 	return nil
 }
 
+func (s *DiskStorage) Move(name, newName string) error {
+	if !s.CheckProjectExists(name) {
+		return domain.ErrProjectNotExists
+	}
+	dest := filepath.Join(s.ProjectsRoot, newName)
+	if fileExists(dest) {
+		return domain.ErrProjectAlreadyExists
+	}
+	// save the current files index (if loaded) before the project is moved
+	if item := s.indexCache.Get(name, ttlcache.WithLoader[string, *FilesIndex](nil)); item != nil {
+		index := item.Value()
+		index.RLock()
+		err := saveJsonFile(filepath.Join(s.ProjectsRoot, name, ".gisquick", "filesmap.json"), index.Index)
+		index.RUnlock()
+		if err != nil {
+			return fmt.Errorf("saving files index: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0775); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(s.ProjectsRoot, name), dest); err != nil {
+		return err
+	}
+	s.indexCache.Delete(name)
+	return nil
+}
+
 func saveToFile(src io.Reader, filename string) (err error) {
 	err = os.MkdirAll(filepath.Dir(filename), 0775)
 	if err != nil {
@@ -699,13 +794,12 @@ func (s *DiskStorage) CreateFile(projectName, directory, pattern string, r io.Re
 		return
 	}
 	index.Set(finfo.Path, domain.FileInfo{Hash: finfo.Hash, Size: finfo.Size, Mtime: finfo.Mtime})
-	pInfo, err := s.GetProjectInfo(projectName)
-	if err != nil {
-		s.log.Errorw("getting project info", zap.Error(err))
-	}
-	pInfo.Size += finfo.Size
-	if err := s.saveConfigFile(projectName, "project.json", pInfo); err != nil {
-		s.log.Errorw("updating project file", zap.Error(err))
+	_, uerr := s.UpdateProjectInfo(projectName, func(info *domain.ProjectInfo) error {
+		info.Size += finfo.Size
+		return nil
+	})
+	if uerr != nil {
+		s.log.Errorw("updating project file", zap.Error(uerr))
 	}
 	return
 }
@@ -724,12 +818,11 @@ func (s *DiskStorage) SaveFile(project string, finfo domain.ProjectFile, path st
 		return nil
 	}
 	index.Set(path, domain.FileInfo{Hash: finfo.Hash, Size: finfo.Size, Mtime: finfo.Mtime})
-	pInfo, err := s.GetProjectInfo(project)
+	_, err = s.UpdateProjectInfo(project, func(info *domain.ProjectInfo) error {
+		info.Size += finfo.Size
+		return nil
+	})
 	if err != nil {
-		s.log.Errorw("getting project info", zap.Error(err))
-	}
-	pInfo.Size += finfo.Size
-	if err := s.saveConfigFile(project, "project.json", pInfo); err != nil {
 		s.log.Errorw("updating project file", zap.Error(err))
 	}
 	return nil
@@ -748,19 +841,18 @@ func (s *DiskStorage) GetThumbnailPath(projectName string) string {
 }
 
 func (s *DiskStorage) SaveThumbnail(projectName string, r io.Reader) error {
-	project, err := s.GetProjectInfo(projectName)
-	if err != nil {
-		return err
+	if !s.CheckProjectExists(projectName) {
+		return domain.ErrProjectNotExists
 	}
 	if err := saveToFile(r, s.GetThumbnailPath(projectName)); err != nil {
 		return fmt.Errorf("saving thumbnail file: %w", err)
 	}
-	project.Thumbnail = true
-	project.LastUpdate = time.Now().UTC()
-	if err := s.saveConfigFile(projectName, "project.json", project); err != nil {
-		return fmt.Errorf("updating project file: %w", err)
-	}
-	return nil
+	_, err := s.UpdateProjectInfo(projectName, func(info *domain.ProjectInfo) error {
+		info.Thumbnail = true
+		info.LastUpdate = time.Now().UTC()
+		return nil
+	})
+	return err
 }
 
 // func (s *DiskStorage) filesIndex1(projectName string) ([]domain.ProjectFile, error) {
@@ -840,8 +932,7 @@ func indexProjectFilesList(index *FilesIndex) []domain.ProjectFile {
 }
 
 func (s *DiskStorage) UpdateFiles(projectName string, info domain.FilesChanges, next domain.FilesReader) ([]domain.ProjectFile, error) {
-	project, err := s.GetProjectInfo(projectName)
-	if err != nil {
+	if _, err := s.GetProjectInfo(projectName); err != nil {
 		return nil, err
 	}
 	index, err := s.filesIndex(projectName)
@@ -935,13 +1026,16 @@ func (s *DiskStorage) UpdateFiles(projectName string, info domain.FilesChanges,
 		return nil, fmt.Errorf("saving files index: %w", err)
 	}
 	size := index.TotalSize()
-	project.Size = size
-	if project.State == "empty" && size > 0 {
-		project.State = "staged"
-		project.LastUpdate = time.Now().UTC()
-	}
-	if err := s.saveConfigFile(projectName, "project.json", project); err != nil {
-		return nil, fmt.Errorf("updating project file: %w", err)
+	_, err = s.UpdateProjectInfo(projectName, func(project *domain.ProjectInfo) error {
+		project.Size = size
+		if project.State == "empty" && size > 0 {
+			project.State = "staged"
+			project.LastUpdate = time.Now().UTC()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return indexProjectFilesList(index), nil
 }
@@ -954,25 +1048,45 @@ type SettingsInfo struct {
 }
 
 func (s *DiskStorage) UpdateSettings(projectName string, data json.RawMessage) error {
-	project, err := s.GetProjectInfo(projectName)
+	unlock := s.lockConfig(projectName)
+	defer unlock()
+	if !s.CheckProjectExists(projectName) {
+		return domain.ErrProjectNotExists
+	}
+	data, _, err := domain.MigrateSettings(data)
 	if err != nil {
-		return err
+		return fmt.Errorf("migrating settings: %w", err)
 	}
 	var sInfo SettingsInfo
 	if err := json.Unmarshal(data, &sInfo); err != nil {
 		return fmt.Errorf("extracting authentication settings: %w", err)
 	}
+	// keep current settings for rollback
+	current, err := os.ReadFile(s.GetSettingsPath(projectName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading settings file: %w", err)
+	}
 	if err := s.saveConfigFile(projectName, "settings.json", data); err != nil {
 		return fmt.Errorf("saving settings file: %w", err)
 	}
-	project.State = "published"
-	project.LastUpdate = time.Now().UTC()
-	project.Authentication = sInfo.Auth.Type
-	project.Title = sInfo.Title
-	if err := s.saveConfigFile(projectName, "project.json", project); err != nil {
-		return fmt.Errorf("updating project file: %w", err)
+	if current != nil {
+		if err := s.saveConfigFile(projectName, "settings.prev.json", json.RawMessage(current)); err != nil {
+			return fmt.Errorf("saving previous settings file: %w", err)
+		}
 	}
-	return nil
+	_, err = s.updateProjectInfo(projectName, func(project *domain.ProjectInfo) error {
+		now := time.Now().UTC()
+		if project.Schedule.PendingPublish(now) {
+			project.State = "scheduled"
+		} else {
+			project.State = "published"
+		}
+		project.LastUpdate = now
+		project.Authentication = sInfo.Auth.Type
+		project.Title = sInfo.Title
+		return nil
+	})
+	return err
 }
 
 func (s *DiskStorage) GetSettings(projectName string) (domain.ProjectSettings, error) {
@@ -984,6 +1098,141 @@ func (s *DiskStorage) GetSettings(projectName string) (domain.ProjectSettings, e
 	return data, nil
 }
 
+func (s *DiskStorage) draftSettingsPath(projectName string) string {
+	return filepath.Join(s.ProjectsRoot, projectName, ".gisquick", "settings.draft.json")
+}
+
+func (s *DiskStorage) previousSettingsPath(projectName string) string {
+	return filepath.Join(s.ProjectsRoot, projectName, ".gisquick", "settings.prev.json")
+}
+
+func (s *DiskStorage) GetDraftSettings(projectName string) (domain.ProjectSettings, error) {
+	var settings domain.ProjectSettings
+	content, err := os.ReadFile(s.draftSettingsPath(projectName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return settings, domain.ErrNoDraftSettings
+		}
+		return settings, err
+	}
+	err = json.Unmarshal(content, &settings)
+	return settings, err
+}
+
+func (s *DiskStorage) SaveDraftSettings(projectName string, data json.RawMessage) error {
+	unlock := s.lockConfig(projectName)
+	defer unlock()
+	if !s.CheckProjectExists(projectName) {
+		return domain.ErrProjectNotExists
+	}
+	data, _, err := domain.MigrateSettings(data)
+	if err != nil {
+		return fmt.Errorf("migrating settings: %w", err)
+	}
+	if err := s.saveConfigFile(projectName, "settings.draft.json", data); err != nil {
+		return fmt.Errorf("saving draft settings file: %w", err)
+	}
+	return nil
+}
+
+func (s *DiskStorage) DeleteDraftSettings(projectName string) error {
+	err := os.Remove(s.draftSettingsPath(projectName))
+	if errors.Is(err, os.ErrNotExist) {
+		return domain.ErrNoDraftSettings
+	}
+	return err
+}
+
+func patchSettingsFile(path string, patch func(settings map[string]interface{}) error) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content, _, err = domain.MigrateSettings(content)
+	if err != nil {
+		return fmt.Errorf("migrating settings: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var settings map[string]interface{}
+	if err := decoder.Decode(&settings); err != nil {
+		return fmt.Errorf("parsing settings: %w", err)
+	}
+	if err := patch(settings); err != nil {
+		return err
+	}
+	return saveJsonFile(path, settings)
+}
+
+// PatchSettings modifies live settings and also the draft settings (if exists), without
+// changing publication state of the project
+func (s *DiskStorage) PatchSettings(projectName string, patch func(settings map[string]interface{}) error) error {
+	unlock := s.lockConfig(projectName)
+	defer unlock()
+	if !s.CheckProjectExists(projectName) {
+		return domain.ErrProjectNotExists
+	}
+	if err := patchSettingsFile(s.GetSettingsPath(projectName), patch); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return domain.ErrNoSettings
+		}
+		return fmt.Errorf("updating settings file: %w", err)
+	}
+	if err := patchSettingsFile(s.draftSettingsPath(projectName), patch); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("updating draft settings file: %w", err)
+	}
+	return nil
+}
+
+// PromoteDraftSettings replaces live settings with the draft
+func (s *DiskStorage) PromoteDraftSettings(projectName string) error {
+	draft, err := os.ReadFile(s.draftSettingsPath(projectName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return domain.ErrNoDraftSettings
+		}
+		return fmt.Errorf("reading draft settings file: %w", err)
+	}
+	if err := s.UpdateSettings(projectName, draft); err != nil {
+		return err
+	}
+	return os.Remove(s.draftSettingsPath(projectName))
+}
+
+// RollbackSettings restores previous live settings (rollback can be reverted by another rollback)
+func (s *DiskStorage) RollbackSettings(projectName string) error {
+	previous, err := os.ReadFile(s.previousSettingsPath(projectName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return domain.ErrNoPreviousSettings
+		}
+		return fmt.Errorf("reading previous settings file: %w", err)
+	}
+	return s.UpdateSettings(projectName, previous)
+}
+
+// MigrateSettingsFile upgrades project's settings file to the current format version.
+// Returns original version of the settings and whether the file was (or would be in dry run mode) modified.
+func (s *DiskStorage) MigrateSettingsFile(projectName string, dryRun bool) (int, bool, error) {
+	content, err := os.ReadFile(s.GetSettingsPath(projectName))
+	if err != nil {
+		return 0, false, err
+	}
+	migrated, version, err := domain.MigrateSettings(content)
+	if err != nil {
+		return version, false, err
+	}
+	if version == domain.SettingsVersion {
+		return version, false, nil
+	}
+	if !dryRun {
+		if err := s.saveConfigFile(projectName, "settings.json", json.RawMessage(migrated)); err != nil {
+			return version, false, fmt.Errorf("saving settings file: %w", err)
+		}
+	}
+	return version, true, nil
+}
+
 func (s *DiskStorage) ParseQgisMetadata(projectName string, data interface{}) error {
 	content, err := os.ReadFile(s.GetQgisMetaPath(projectName))
 	if err != nil {
@@ -996,9 +1245,10 @@ func (s *DiskStorage) ParseQgisMetadata(projectName string, data interface{}) er
 }
 
 func (s *DiskStorage) UpdateMeta(projectName string, meta json.RawMessage) error {
-	pInfo, err := s.GetProjectInfo(projectName)
-	if err != nil {
-		return err
+	unlock := s.lockConfig(projectName)
+	defer unlock()
+	if !s.CheckProjectExists(projectName) {
+		return domain.ErrProjectNotExists
 	}
 	var i Info
 	if err := json.Unmarshal(meta, &i); err != nil {
@@ -1010,11 +1260,14 @@ func (s *DiskStorage) UpdateMeta(projectName string, meta json.RawMessage) error
 		return fmt.Errorf("creating qgis meta file: %w", err)
 	}
 
-	pInfo.QgisFile = i.File
-	pInfo.Projection = i.Projection
-	pInfo.Title = i.Title
-	pInfo.LastUpdate = time.Now().UTC()
-	return s.saveConfigFile(projectName, "project.json", pInfo)
+	_, err := s.updateProjectInfo(projectName, func(pInfo *domain.ProjectInfo) error {
+		pInfo.QgisFile = i.File
+		pInfo.Projection = i.Projection
+		pInfo.Title = i.Title
+		pInfo.LastUpdate = time.Now().UTC()
+		return nil
+	})
+	return err
 }
 
 func (s *DiskStorage) GetScripts(projectName string) (domain.Scripts, error) {
@@ -23,6 +23,46 @@ type Notification struct {
 	Message    string    `json:"msg"`
 }
 
+// NotificationStore stores notifications created by admins, expired notifications are not returned
+type NotificationStore interface {
+	SaveNotification(ctx context.Context, notification Notification) error
+	DeleteNotification(ctx context.Context, id string) error
+	GetNotifications() ([]Notification, error)
+	GetMapProjectNotifications(projectName string, user domain.User) ([]Notification, error)
+	GetSettingsNotifications(user domain.User) ([]Notification, error)
+}
+
+// FilterMapProjectNotifications returns notifications for the map application of the project
+func FilterMapProjectNotifications(allNotifications []Notification, projectName string, user domain.User) []Notification {
+	notifications := []Notification{}
+	for _, n := range allNotifications {
+		if n.App != "map" ||
+			(n.Users == "authenticated" && !user.IsAuthenticated) ||
+			(n.Users == "not_authenticated" && user.IsAuthenticated) ||
+			(n.Users == "owner" && !user.CanManageNamespace(strings.Split(projectName, "/")[0])) {
+			continue
+		}
+		for _, p := range strings.Split(n.Projects, ",") {
+			if strings.HasPrefix(projectName, p) {
+				notifications = append(notifications, n)
+				break
+			}
+		}
+	}
+	return notifications
+}
+
+// FilterSettingsNotifications returns notifications for the settings application
+func FilterSettingsNotifications(allNotifications []Notification, user domain.User) []Notification {
+	notifications := []Notification{}
+	for _, n := range allNotifications {
+		if n.App == "settings" {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications
+}
+
 type RedisNotificationStore struct {
 	log *zap.SugaredLogger
 	rdb *redis.Client
@@ -96,22 +136,7 @@ func (s *RedisNotificationStore) GetMapProjectNotifications(projectName string,
 	if err != nil {
 		return nil, err
 	}
-	notifications := []Notification{}
-	for _, n := range allNotifications {
-		if n.App != "map" ||
-			(n.Users == "authenticated" && !user.IsAuthenticated) ||
-			(n.Users == "not_authenticated" && user.IsAuthenticated) ||
-			(n.Users == "owner" && !strings.HasPrefix(projectName, user.Username+"/")) {
-			continue
-		}
-		for _, p := range strings.Split(n.Projects, ",") {
-			if strings.HasPrefix(projectName, p) {
-				notifications = append(notifications, n)
-				break
-			}
-		}
-	}
-	return notifications, nil
+	return FilterMapProjectNotifications(allNotifications, projectName, user), nil
 }
 
 func (s *RedisNotificationStore) GetSettingsNotifications(user domain.User) ([]Notification, error) {
@@ -119,11 +144,5 @@ func (s *RedisNotificationStore) GetSettingsNotifications(user domain.User) ([]N
 	if err != nil {
 		return nil, err
 	}
-	notifications := []Notification{}
-	for _, n := range allNotifications {
-		if n.App == "settings" {
-			notifications = append(notifications, n)
-		}
-	}
-	return notifications, nil
+	return FilterSettingsNotifications(allNotifications, user), nil
 }
@@ -0,0 +1,124 @@
+package project
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+// FileNotificationStore stores notifications in a single JSON file
+type FileNotificationStore struct {
+	log  *zap.SugaredLogger
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotificationStore(log *zap.SugaredLogger, path string) *FileNotificationStore {
+	return &FileNotificationStore{log: log, path: path}
+}
+
+func (s *FileNotificationStore) load() (map[string]Notification, error) {
+	notifications := make(map[string]Notification)
+	content, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return notifications, nil
+		}
+		return nil, fmt.Errorf("reading notifications file: %w", err)
+	}
+	if err := json.Unmarshal(content, &notifications); err != nil {
+		return nil, fmt.Errorf("parsing notifications file: %w", err)
+	}
+	now := time.Now()
+	for id, n := range notifications {
+		if !n.Expiration.IsZero() && !n.Expiration.After(now) {
+			delete(notifications, id)
+		}
+	}
+	return notifications, nil
+}
+
+func (s *FileNotificationStore) save(notifications map[string]Notification) error {
+	content, err := json.Marshal(notifications)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0775); err != nil {
+		return err
+	}
+	tmpPath := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, content, 0664); err != nil {
+		return fmt.Errorf("writing notifications file: %w", err)
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+func (s *FileNotificationStore) SaveNotification(ctx context.Context, notification Notification) error {
+	if !notification.Expiration.IsZero() && notification.Expiration.Before(time.Now()) {
+		return ErrInvalidDuration
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notifications, err := s.load()
+	if err != nil {
+		return err
+	}
+	notifications[notification.ID] = notification
+	return s.save(notifications)
+}
+
+func (s *FileNotificationStore) DeleteNotification(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notifications, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(notifications, id)
+	return s.save(notifications)
+}
+
+func (s *FileNotificationStore) GetNotifications() ([]Notification, error) {
+	s.mu.Lock()
+	stored, err := s.load()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	notifications := make([]Notification, 0, len(stored))
+	for id, n := range stored {
+		n.ID = id
+		notifications = append(notifications, n)
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].ID < notifications[j].ID
+	})
+	return notifications, nil
+}
+
+func (s *FileNotificationStore) GetMapProjectNotifications(projectName string, user domain.User) ([]Notification, error) {
+	allNotifications, err := s.GetNotifications()
+	if err != nil {
+		return nil, err
+	}
+	return FilterMapProjectNotifications(allNotifications, projectName, user), nil
+}
+
+func (s *FileNotificationStore) GetSettingsNotifications(user domain.User) ([]Notification, error) {
+	allNotifications, err := s.GetNotifications()
+	if err != nil {
+		return nil, err
+	}
+	return FilterSettingsNotifications(allNotifications, user), nil
+}
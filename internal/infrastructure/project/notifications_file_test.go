@@ -0,0 +1,16 @@
+package project_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gisquick/gisquick-server/internal/infrastructure/project"
+	"github.com/gisquick/gisquick-server/internal/infrastructure/project/notificationtest"
+	"go.uber.org/zap"
+)
+
+func TestFileNotificationStore(t *testing.T) {
+	notificationtest.RunNotificationStoreTests(t, func(t *testing.T) project.NotificationStore {
+		return project.NewFileNotificationStore(zap.NewNop().Sugar(), filepath.Join(t.TempDir(), "notifications.json"))
+	})
+}
@@ -0,0 +1,137 @@
+// Package notificationtest provides conformance tests shared by all project.NotificationStore implementations
+package notificationtest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/infrastructure/project"
+)
+
+// RunNotificationStoreTests runs conformance tests against empty notification stores created by newStore
+func RunNotificationStoreTests(t *testing.T, newStore func(t *testing.T) project.NotificationStore) {
+	t.Run("SaveDelete", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		n := project.Notification{
+			ID:         "n1",
+			Title:      "Maintenance",
+			App:        "settings",
+			Users:      "all",
+			Expiration: time.Now().Add(time.Hour).Truncate(time.Second),
+			Message:    "Server will be restarted",
+		}
+		if err := s.SaveNotification(ctx, n); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		if err := s.SaveNotification(ctx, project.Notification{ID: "n2", App: "map", Projects: "user1/"}); err != nil {
+			t.Fatalf("save without expiration: %v", err)
+		}
+		notifications := getNotifications(t, s)
+		if len(notifications) != 2 {
+			t.Fatalf("expected 2 notifications, got %d", len(notifications))
+		}
+		got := notifications[0]
+		if got.ID != n.ID || got.Title != n.Title || got.Message != n.Message || !got.Expiration.Equal(n.Expiration) {
+			t.Fatalf("expected %+v, got %+v", n, got)
+		}
+
+		n.Title = "Updated"
+		if err := s.SaveNotification(ctx, n); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		notifications = getNotifications(t, s)
+		if len(notifications) != 2 || notifications[0].Title != "Updated" {
+			t.Fatalf("expected updated notification, got %+v", notifications)
+		}
+
+		if err := s.DeleteNotification(ctx, "n1"); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if err := s.DeleteNotification(ctx, "missing"); err != nil {
+			t.Fatalf("delete missing: %v", err)
+		}
+		notifications = getNotifications(t, s)
+		if len(notifications) != 1 || notifications[0].ID != "n2" {
+			t.Fatalf("expected only n2, got %+v", notifications)
+		}
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		err := s.SaveNotification(ctx, project.Notification{ID: "past", Expiration: time.Now().Add(-time.Minute)})
+		if !errors.Is(err, project.ErrInvalidDuration) {
+			t.Fatalf("save expired: expected ErrInvalidDuration, got %v", err)
+		}
+		if err := s.SaveNotification(ctx, project.Notification{ID: "short", Expiration: time.Now().Add(time.Second)}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		time.Sleep(1100 * time.Millisecond)
+		if notifications := getNotifications(t, s); len(notifications) != 0 {
+			t.Fatalf("expected no notifications, got %+v", notifications)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		for _, n := range []project.Notification{
+			{ID: "all", App: "map", Users: "all", Projects: "user1/,user2/"},
+			{ID: "auth", App: "map", Users: "authenticated", Projects: "user1/"},
+			{ID: "owner", App: "map", Users: "owner", Projects: "user1/"},
+			{ID: "settings", App: "settings", Users: "all"},
+		} {
+			if err := s.SaveNotification(ctx, n); err != nil {
+				t.Fatalf("save: %v", err)
+			}
+		}
+		owner := domain.User{Username: "user1", IsAuthenticated: true}
+		anonymous := domain.User{IsGuest: true}
+
+		check := func(name string, notifications []project.Notification, err error, expected ...string) {
+			t.Helper()
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			ids := make([]string, len(notifications))
+			for i, n := range notifications {
+				ids[i] = n.ID
+			}
+			sort.Strings(ids)
+			if len(ids) != len(expected) {
+				t.Fatalf("%s: expected %v, got %v", name, expected, ids)
+			}
+			for i := range ids {
+				if ids[i] != expected[i] {
+					t.Fatalf("%s: expected %v, got %v", name, expected, ids)
+				}
+			}
+		}
+		n, err := s.GetMapProjectNotifications("user1/project", owner)
+		check("owner", n, err, "all", "auth", "owner")
+		n, err = s.GetMapProjectNotifications("user1/project", anonymous)
+		check("anonymous", n, err, "all")
+		n, err = s.GetMapProjectNotifications("user2/project", owner)
+		check("other project", n, err, "all")
+		n, err = s.GetSettingsNotifications(owner)
+		check("settings", n, err, "settings")
+	})
+}
+
+// getNotifications returns all notifications sorted by ID
+func getNotifications(t *testing.T, s project.NotificationStore) []project.Notification {
+	t.Helper()
+	notifications, err := s.GetNotifications()
+	if err != nil {
+		t.Fatalf("get notifications: %v", err)
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].ID < notifications[j].ID
+	})
+	return notifications
+}
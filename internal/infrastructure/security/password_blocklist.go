@@ -0,0 +1,141 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SHA-1 hashes of the most common passwords (sorted, one per line)
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+func passwordHash(password string) string {
+	h := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(h[:]))
+}
+
+// parseHashLine returns upper case hash from line in format "HASH" or "HASH:COUNT"
+func parseHashLine(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(hash)
+}
+
+// PasswordHashSet is in-memory list of SHA-1 hashes of passwords
+type PasswordHashSet map[string]struct{}
+
+func readPasswordHashes(r io.Reader) (PasswordHashSet, error) {
+	set := make(PasswordHashSet)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if hash := parseHashLine(scanner.Text()); hash != "" {
+			set[hash] = struct{}{}
+		}
+	}
+	return set, scanner.Err()
+}
+
+// NewCommonPasswordsList returns bundled list of common passwords
+func NewCommonPasswordsList() PasswordHashSet {
+	set, _ := readPasswordHashes(strings.NewReader(commonPasswords))
+	return set
+}
+
+func (s PasswordHashSet) Contains(password string) (bool, error) {
+	_, ok := s[passwordHash(password)]
+	return ok, nil
+}
+
+// PasswordHashFile is a list of SHA-1 hashes of passwords stored in a file sorted by hash,
+// e.g. list of breached passwords downloaded from haveibeenpwned.com ("HASH:COUNT" lines).
+// Lookups are done by binary search directly in the file, so it can be very large.
+type PasswordHashFile struct {
+	file *os.File
+	size int64
+}
+
+// maximal length of line in the file
+const maxHashLineLength = 256
+
+func OpenPasswordHashFile(path string) (*PasswordHashFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &PasswordHashFile{file: f, size: info.Size()}, nil
+}
+
+func (f *PasswordHashFile) Close() error {
+	return f.file.Close()
+}
+
+// lineAt returns the first line starting at or after the given offset and its position
+// (-1 when there is no such line)
+func (f *PasswordHashFile) lineAt(offset int64) (int64, []byte, error) {
+	buf := make([]byte, 2*maxHashLineLength)
+	start := offset
+	if offset > 0 {
+		// read also the previous byte to find out whether the offset is at the line start
+		start = offset - 1
+	}
+	n, err := f.file.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return -1, nil, err
+	}
+	buf = buf[:n]
+	if offset > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i == -1 {
+			return -1, nil, nil
+		}
+		buf = buf[i+1:]
+		start += int64(i + 1)
+	}
+	if len(buf) == 0 {
+		return -1, nil, nil
+	}
+	if i := bytes.IndexByte(buf, '\n'); i != -1 {
+		buf = buf[:i+1]
+	} else if start+int64(len(buf)) < f.size {
+		return -1, nil, fmt.Errorf("line at offset %d is too long", start)
+	}
+	return start, buf, nil
+}
+
+func (f *PasswordHashFile) Contains(password string) (bool, error) {
+	hash := passwordHash(password)
+	lo, hi := int64(0), f.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := f.lineAt(mid)
+		if err != nil {
+			return false, err
+		}
+		if start == -1 || start >= hi {
+			hi = mid
+			continue
+		}
+		key := parseHashLine(string(line))
+		switch {
+		case key == hash:
+			return true, nil
+		case key < hash:
+			lo = start + int64(len(line))
+		default:
+			hi = mid
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,132 @@
+// Package jsonschema generates JSON Schema documents from Go types using
+// reflection and encoding/json struct tags.
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+type Schema = map[string]interface{}
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+type generator struct {
+	defs map[string]Schema
+}
+
+// Generate creates JSON Schema of the given value's type. Named struct types are placed
+// into '$defs' section and referenced, so recursive types are supported.
+func Generate(v interface{}, id, title string) Schema {
+	g := &generator{defs: make(map[string]Schema)}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	schema := Schema{
+		"$schema": Draft,
+	}
+	if id != "" {
+		schema["$id"] = id
+	}
+	if title != "" {
+		schema["title"] = title
+	}
+	var root Schema
+	if t.Kind() == reflect.Struct {
+		root = g.structSchema(t)
+	} else {
+		root = g.typeSchema(t)
+	}
+	for k, v := range root {
+		schema[k] = v
+	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return schema
+}
+
+func (g *generator) typeSchema(t reflect.Type) Schema {
+	if t == rawMessageType {
+		return Schema{}
+	}
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeSchema(t.Elem())
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.Name()
+		if _, exists := g.defs[name]; !exists {
+			g.defs[name] = nil // placeholder for recursive types
+			g.defs[name] = g.structSchema(t)
+		}
+		return Schema{"$ref": "#/$defs/" + name}
+	}
+	return Schema{}
+}
+
+func (g *generator) structSchema(t reflect.Type) Schema {
+	properties := make(map[string]Schema)
+	g.collectFields(t, properties)
+	return Schema{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func (g *generator) collectFields(t reflect.Type, properties map[string]Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.collectFields(ft, properties)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.typeSchema(f.Type)
+	}
+}
@@ -0,0 +1,85 @@
+package proj
+
+import "math"
+
+type Ellipsoid struct {
+	A  float64 // semi-major axis
+	B  float64 // semi-minor axis
+	Es float64 // eccentricity squared
+	E  float64 // eccentricity
+}
+
+func newEllipsoid(a, b float64) Ellipsoid {
+	es := (a*a - b*b) / (a * a)
+	return Ellipsoid{A: a, B: b, Es: es, E: math.Sqrt(es)}
+}
+
+func ellipsoidFromRf(a, rf float64) Ellipsoid {
+	return newEllipsoid(a, a*(1-1/rf))
+}
+
+func (e Ellipsoid) IsSphere() bool {
+	return e.Es == 0
+}
+
+func (e Ellipsoid) equals(o Ellipsoid) bool {
+	return math.Abs(e.A-o.A) < 1e-6 && math.Abs(e.Es-o.Es) < 1e-12
+}
+
+var ellipsoids = map[string]Ellipsoid{
+	"WGS84":    ellipsoidFromRf(6378137, 298.257223563),
+	"GRS80":    ellipsoidFromRf(6378137, 298.257222101),
+	"WGS72":    ellipsoidFromRf(6378135, 298.26),
+	"GRS67":    ellipsoidFromRf(6378160, 298.247167427),
+	"bessel":   ellipsoidFromRf(6377397.155, 299.1528128),
+	"intl":     ellipsoidFromRf(6378388, 297),
+	"krass":    ellipsoidFromRf(6378245, 298.3),
+	"clrk66":   newEllipsoid(6378206.4, 6356583.8),
+	"clrk80":   ellipsoidFromRf(6378249.145, 293.4663),
+	"airy":     newEllipsoid(6377563.396, 6356256.910),
+	"mod_airy": newEllipsoid(6377340.189, 6356034.446),
+	"sphere":   newEllipsoid(6370997, 6370997),
+}
+
+type datumDef struct {
+	ellps   string
+	towgs84 []float64
+}
+
+var datums = map[string]datumDef{
+	"WGS84":         {"WGS84", []float64{0, 0, 0}},
+	"NAD83":         {"GRS80", []float64{0, 0, 0}},
+	"NAD27":         {"clrk66", []float64{-8, 160, 176}},
+	"potsdam":       {"bessel", []float64{598.1, 73.7, 418.2, 0.202, 0.045, -2.455, 6.7}},
+	"hermannskogel": {"bessel", []float64{577.326, 90.129, 463.919, 5.137, 1.474, 5.297, 2.4232}},
+	"carthage":      {"clrk80", []float64{-263.0, 6.0, 431.0}},
+	"ire65":         {"mod_airy", []float64{482.530, -130.596, 564.557, -1.042, -0.214, -0.631, 8.15}},
+	"OSGB36":        {"airy", []float64{446.448, -125.157, 542.060, 0.1502, 0.2470, 0.8421, -20.4894}},
+}
+
+var primeMeridians = map[string]float64{
+	"greenwich": 0,
+	"lisbon":    -9.131906111111,
+	"paris":     2.337229166667,
+	"bogota":    -74.080916666667,
+	"madrid":    -3.687938888889,
+	"rome":      12.452333333333,
+	"bern":      7.439583333333,
+	"jakarta":   106.807719444444,
+	"ferro":     -17.666666666667,
+	"brussels":  4.367975,
+	"stockholm": 18.058277777778,
+	"athens":    23.7163375,
+	"oslo":      10.722916666667,
+}
+
+var units = map[string]float64{
+	"m":     1,
+	"km":    1000,
+	"cm":    0.01,
+	"mm":    0.001,
+	"ft":    0.3048,
+	"us-ft": 1200.0 / 3937.0,
+	"yd":    0.9144,
+	"mi":    1609.344,
+}
@@ -0,0 +1,106 @@
+package proj
+
+import (
+	"fmt"
+)
+
+// TransformGeoJSON transforms coordinates of GeoJSON object (geometry, feature or
+// feature collection) decoded into generic map structure. Data are modified in place.
+func (t *Transformer) TransformGeoJSON(data map[string]interface{}) error {
+	objType, _ := data["type"].(string)
+	switch objType {
+	case "FeatureCollection":
+		features, ok := data["features"].([]interface{})
+		if !ok {
+			return fmt.Errorf("%w: missing features", ErrInvalidGeometry)
+		}
+		for _, f := range features {
+			feature, ok := f.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%w: invalid feature", ErrInvalidGeometry)
+			}
+			if err := t.TransformGeoJSON(feature); err != nil {
+				return err
+			}
+		}
+	case "Feature":
+		if geom, ok := data["geometry"].(map[string]interface{}); ok {
+			if err := t.TransformGeoJSON(geom); err != nil {
+				return err
+			}
+		}
+	case "GeometryCollection":
+		geometries, ok := data["geometries"].([]interface{})
+		if !ok {
+			return fmt.Errorf("%w: missing geometries", ErrInvalidGeometry)
+		}
+		for _, g := range geometries {
+			geom, ok := g.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%w: invalid geometry", ErrInvalidGeometry)
+			}
+			if err := t.TransformGeoJSON(geom); err != nil {
+				return err
+			}
+		}
+	case "Point", "MultiPoint", "LineString", "MultiLineString", "Polygon", "MultiPolygon":
+		coords, ok := data["coordinates"]
+		if !ok {
+			return fmt.Errorf("%w: missing coordinates", ErrInvalidGeometry)
+		}
+		if err := t.transformCoordinates(coords); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unknown type '%s'", ErrInvalidGeometry, objType)
+	}
+	if bbox, ok := data["bbox"].([]interface{}); ok && len(bbox) == 4 {
+		values := make([]float64, 4)
+		for i, v := range bbox {
+			f, ok := v.(float64)
+			if !ok {
+				return fmt.Errorf("%w: invalid bbox", ErrInvalidGeometry)
+			}
+			values[i] = f
+		}
+		transformed, err := t.TransformBounds(values)
+		if err != nil {
+			return err
+		}
+		data["bbox"] = transformed
+	}
+	return nil
+}
+
+// transformCoordinates walks nested coordinates arrays
+func (t *Transformer) transformCoordinates(coords interface{}) error {
+	list, ok := coords.([]interface{})
+	if !ok {
+		return fmt.Errorf("%w: invalid coordinates", ErrInvalidGeometry)
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	if _, isNumber := list[0].(float64); isNumber {
+		if len(list) < 2 {
+			return fmt.Errorf("%w: invalid position", ErrInvalidGeometry)
+		}
+		x, okx := list[0].(float64)
+		y, oky := list[1].(float64)
+		if !okx || !oky {
+			return fmt.Errorf("%w: invalid position", ErrInvalidGeometry)
+		}
+		tx, ty, err := t.Transform(x, y)
+		if err != nil {
+			return err
+		}
+		list[0], list[1] = tx, ty
+		return nil
+	}
+	for _, c := range list {
+		if err := t.transformCoordinates(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
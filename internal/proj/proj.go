@@ -0,0 +1,252 @@
+// Package proj implements coordinate transformations between coordinate reference
+// systems defined by proj4 strings. Only the most common projections are supported
+// (longlat, merc, tmerc, utm and krovak) together with 3/7 parameter datum shifts.
+package proj
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnsupportedProjection = errors.New("unsupported projection")
+	ErrInvalidDefinition     = errors.New("invalid proj4 definition")
+	ErrOutOfRange            = errors.New("coordinates out of projection range")
+)
+
+const (
+	deg2rad = math.Pi / 180
+	rad2deg = 180 / math.Pi
+)
+
+// WellKnown contains definitions of coordinate systems available for every project
+var WellKnown = map[string]string{
+	"EPSG:4326":   "+proj=longlat +datum=WGS84 +no_defs",
+	"EPSG:3857":   "+proj=merc +a=6378137 +b=6378137 +lat_ts=0 +lon_0=0 +x_0=0 +y_0=0 +k=1 +units=m +nadgrids=@null +wktext +no_defs",
+	"EPSG:900913": "+proj=merc +a=6378137 +b=6378137 +lat_ts=0 +lon_0=0 +x_0=0 +y_0=0 +k=1 +units=m +nadgrids=@null +wktext +no_defs",
+}
+
+type params map[string]string
+
+func parseParams(def string) params {
+	p := make(params)
+	for _, token := range strings.Fields(def) {
+		token = strings.TrimPrefix(token, "+")
+		kv := strings.SplitN(token, "=", 2)
+		if len(kv) == 2 {
+			p[kv[0]] = kv[1]
+		} else {
+			p[kv[0]] = ""
+		}
+	}
+	return p
+}
+
+func (p params) has(key string) bool {
+	_, ok := p[key]
+	return ok
+}
+
+func (p params) float(key string, def float64) float64 {
+	v, ok := p[key]
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+// angle returns parameter value in radians
+func (p params) angle(key string, def float64) float64 {
+	if !p.has(key) {
+		return def
+	}
+	return p.float(key, 0) * deg2rad
+}
+
+// CRS is a parsed coordinate reference system
+type CRS struct {
+	Definition string
+	proj       projection
+	ellps      Ellipsoid
+	towgs84    []float64
+	lon0       float64
+	pm         float64
+	x0         float64
+	y0         float64
+	toMeter    float64
+	geographic bool
+}
+
+func Parse(def string) (*CRS, error) {
+	p := parseParams(def)
+	projName, ok := p["proj"]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing proj parameter", ErrInvalidDefinition)
+	}
+	crs := &CRS{
+		Definition: def,
+		lon0:       p.angle("lon_0", 0),
+		x0:         p.float("x_0", 0),
+		y0:         p.float("y_0", 0),
+		toMeter:    1,
+	}
+	if err := crs.setupDatum(p); err != nil {
+		return nil, err
+	}
+	if u, ok := p["units"]; ok {
+		factor, known := units[u]
+		if !known {
+			return nil, fmt.Errorf("%w: unknown units '%s'", ErrInvalidDefinition, u)
+		}
+		crs.toMeter = factor
+	}
+	if p.has("to_meter") {
+		crs.toMeter = p.float("to_meter", 1)
+	}
+	if pm, ok := p["pm"]; ok {
+		if v, known := primeMeridians[pm]; known {
+			crs.pm = v * deg2rad
+		} else {
+			crs.pm = p.angle("pm", 0)
+		}
+	}
+
+	var err error
+	switch projName {
+	case "longlat", "latlong", "lonlat", "latlon":
+		crs.geographic = true
+	case "merc", "webmerc":
+		crs.proj, err = newMercator(crs.ellps, p)
+	case "tmerc", "etmerc":
+		crs.proj, err = newTransverseMercator(crs.ellps, p)
+	case "utm":
+		zone, zerr := strconv.Atoi(p["zone"])
+		if zerr != nil || zone < 1 || zone > 60 {
+			return nil, fmt.Errorf("%w: invalid utm zone", ErrInvalidDefinition)
+		}
+		crs.lon0 = (float64(zone-1)*6 - 180 + 3) * deg2rad
+		crs.x0 = 500000
+		crs.y0 = 0
+		if p.has("south") {
+			crs.y0 = 10000000
+		}
+		p["k_0"] = "0.9996"
+		delete(p, "lat_0")
+		crs.proj, err = newTransverseMercator(crs.ellps, p)
+	case "krovak":
+		if !p.has("lon_0") {
+			crs.lon0 = 0.4334234309119251
+		}
+		crs.proj, err = newKrovak(crs.ellps, p)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedProjection, projName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return crs, nil
+}
+
+func (c *CRS) setupDatum(p params) error {
+	ellpsName := ""
+	if d, ok := p["datum"]; ok {
+		def, known := datums[d]
+		if !known {
+			return fmt.Errorf("%w: unknown datum '%s'", ErrInvalidDefinition, d)
+		}
+		ellpsName = def.ellps
+		c.towgs84 = def.towgs84
+	}
+	if e, ok := p["ellps"]; ok {
+		ellpsName = e
+	}
+	switch {
+	case p.has("a") && p.has("b"):
+		c.ellps = newEllipsoid(p.float("a", 0), p.float("b", 0))
+	case p.has("a") && p.has("rf"):
+		c.ellps = ellipsoidFromRf(p.float("a", 0), p.float("rf", 0))
+	case p.has("R"):
+		r := p.float("R", 0)
+		c.ellps = newEllipsoid(r, r)
+	case ellpsName != "":
+		el, known := ellipsoids[ellpsName]
+		if !known {
+			return fmt.Errorf("%w: unknown ellipsoid '%s'", ErrInvalidDefinition, ellpsName)
+		}
+		c.ellps = el
+	default:
+		c.ellps = ellipsoids["WGS84"]
+		ellpsName = "WGS84"
+	}
+	if c.ellps.A <= 0 {
+		return fmt.Errorf("%w: invalid ellipsoid", ErrInvalidDefinition)
+	}
+	if v, ok := p["towgs84"]; ok {
+		parts := strings.Split(v, ",")
+		if len(parts) != 3 && len(parts) != 7 {
+			return fmt.Errorf("%w: invalid towgs84 parameter", ErrInvalidDefinition)
+		}
+		c.towgs84 = make([]float64, len(parts))
+		for i, s := range parts {
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return fmt.Errorf("%w: invalid towgs84 parameter", ErrInvalidDefinition)
+			}
+			c.towgs84[i] = f
+		}
+	}
+	// ellipsoids which are practically identical with WGS84 datum
+	if c.towgs84 == nil && !p.has("nadgrids") && (ellpsName == "WGS84" || ellpsName == "GRS80") {
+		c.towgs84 = []float64{0, 0, 0}
+	}
+	return nil
+}
+
+func (c *CRS) IsGeographic() bool {
+	return c.geographic
+}
+
+// toGeodetic converts coordinates to geodetic longitude/latitude (radians)
+func (c *CRS) toGeodetic(x, y float64) (float64, float64, error) {
+	if c.geographic {
+		return x*deg2rad + c.pm, y * deg2rad, nil
+	}
+	x = (x*c.toMeter - c.x0)
+	y = (y*c.toMeter - c.y0)
+	lam, phi, err := c.proj.inverse(x, y)
+	if err != nil {
+		return 0, 0, err
+	}
+	return adjlon(lam + c.lon0 + c.pm), phi, nil
+}
+
+// fromGeodetic converts geodetic longitude/latitude (radians) to the CRS coordinates
+func (c *CRS) fromGeodetic(lam, phi float64) (float64, float64, error) {
+	if c.geographic {
+		return (lam - c.pm) * rad2deg, phi * rad2deg, nil
+	}
+	x, y, err := c.proj.forward(adjlon(lam-c.lon0-c.pm), phi)
+	if err != nil {
+		return 0, 0, err
+	}
+	return (x + c.x0) / c.toMeter, (y + c.y0) / c.toMeter, nil
+}
+
+// adjlon normalizes longitude into <-pi, pi> range
+func adjlon(lon float64) float64 {
+	if math.Abs(lon) <= math.Pi {
+		return lon
+	}
+	lon = math.Mod(lon+math.Pi, 2*math.Pi)
+	if lon < 0 {
+		lon += 2 * math.Pi
+	}
+	return lon - math.Pi
+}
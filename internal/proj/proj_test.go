@@ -0,0 +1,143 @@
+package proj
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+const (
+	wgs84Def  = "+proj=longlat +datum=WGS84 +no_defs"
+	utm33nDef = "+proj=utm +zone=33 +datum=WGS84 +units=m +no_defs"
+	krovakDef = "+proj=krovak +lat_0=49.5 +lon_0=24.83333333333333 +alpha=30.28813972222222 +k=0.9999 +x_0=0 +y_0=0 +ellps=bessel +units=m +no_defs"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		def        string
+		err        error
+		geographic bool
+		toMeter    float64
+		x0         float64
+		y0         float64
+	}{
+		{name: "longlat", def: wgs84Def, geographic: true, toMeter: 1},
+		{name: "latlong alias", def: "+proj=latlong +ellps=GRS80", geographic: true, toMeter: 1},
+		{name: "web mercator", def: WellKnown["EPSG:3857"], toMeter: 1},
+		{name: "utm north", def: utm33nDef, toMeter: 1, x0: 500000},
+		{name: "utm south", def: "+proj=utm +zone=33 +south +datum=WGS84", toMeter: 1, x0: 500000, y0: 10000000},
+		{name: "tmerc with offsets", def: "+proj=tmerc +lat_0=0 +lon_0=9 +k=0.9996 +x_0=1500000 +y_0=-100 +ellps=intl", toMeter: 1, x0: 1500000, y0: -100},
+		{name: "us feet", def: "+proj=tmerc +lon_0=-81 +ellps=GRS80 +units=us-ft", toMeter: 0.304800609601219},
+		{name: "to_meter", def: "+proj=merc +ellps=WGS84 +to_meter=1000", toMeter: 1000},
+		{name: "krovak", def: krovakDef, toMeter: 1},
+		{name: "missing proj", def: "+ellps=WGS84 +no_defs", err: ErrInvalidDefinition},
+		{name: "empty", def: "", err: ErrInvalidDefinition},
+		{name: "unsupported projection", def: "+proj=lcc +lat_1=49 +lat_2=44", err: ErrUnsupportedProjection},
+		{name: "unknown datum", def: "+proj=longlat +datum=foo", err: ErrInvalidDefinition},
+		{name: "unknown ellipsoid", def: "+proj=longlat +ellps=foo", err: ErrInvalidDefinition},
+		{name: "unknown units", def: "+proj=merc +units=parsec", err: ErrInvalidDefinition},
+		{name: "invalid utm zone", def: "+proj=utm +zone=61", err: ErrInvalidDefinition},
+		{name: "missing utm zone", def: "+proj=utm +datum=WGS84", err: ErrInvalidDefinition},
+		{name: "invalid towgs84 count", def: "+proj=longlat +ellps=bessel +towgs84=1,2", err: ErrInvalidDefinition},
+		{name: "invalid towgs84 value", def: "+proj=longlat +ellps=bessel +towgs84=1,x,3", err: ErrInvalidDefinition},
+		{name: "invalid ellipsoid axis", def: "+proj=longlat +a=0 +b=0", err: ErrInvalidDefinition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crs, err := Parse(tt.def)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected error %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if crs.Definition != tt.def {
+				t.Errorf("definition = %q, want %q", crs.Definition, tt.def)
+			}
+			if crs.IsGeographic() != tt.geographic {
+				t.Errorf("geographic = %v, want %v", crs.IsGeographic(), tt.geographic)
+			}
+			if math.Abs(crs.toMeter-tt.toMeter) > 1e-12 {
+				t.Errorf("to_meter = %v, want %v", crs.toMeter, tt.toMeter)
+			}
+			if crs.x0 != tt.x0 || crs.y0 != tt.y0 {
+				t.Errorf("false origin = (%v, %v), want (%v, %v)", crs.x0, crs.y0, tt.x0, tt.y0)
+			}
+		})
+	}
+}
+
+func TestParseDatum(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     string
+		towgs84 []float64
+	}{
+		{name: "WGS84 datum", def: wgs84Def, towgs84: []float64{0, 0, 0}},
+		{name: "GRS80 ellipsoid", def: "+proj=longlat +ellps=GRS80", towgs84: []float64{0, 0, 0}},
+		{name: "explicit 7 params", def: "+proj=longlat +ellps=bessel +towgs84=570.8,85.7,462.8,4.998,1.587,5.261,3.56", towgs84: []float64{570.8, 85.7, 462.8, 4.998, 1.587, 5.261, 3.56}},
+		{name: "unknown datum shift", def: "+proj=longlat +ellps=bessel", towgs84: nil},
+		{name: "grid shift", def: WellKnown["EPSG:3857"], towgs84: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crs, err := Parse(tt.def)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (crs.towgs84 == nil) != (tt.towgs84 == nil) || !sameParams(crs.towgs84, tt.towgs84) {
+				t.Errorf("towgs84 = %v, want %v", crs.towgs84, tt.towgs84)
+			}
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		dst  string
+		in   [2]float64
+		out  [2]float64
+		tol  float64
+	}{
+		{name: "wgs84 to web mercator origin", src: wgs84Def, dst: WellKnown["EPSG:3857"], in: [2]float64{0, 0}, out: [2]float64{0, 0}, tol: 1e-6},
+		{name: "wgs84 to web mercator", src: wgs84Def, dst: WellKnown["EPSG:3857"], in: [2]float64{10, 50}, out: [2]float64{1113194.9079327357, 6446275.841017158}, tol: 1e-3},
+		{name: "wgs84 to web mercator near antimeridian", src: wgs84Def, dst: WellKnown["EPSG:3857"], in: [2]float64{179, 0}, out: [2]float64{19926188.851995967, 0}, tol: 1e-3},
+		{name: "wgs84 to utm central meridian", src: wgs84Def, dst: utm33nDef, in: [2]float64{15, 0}, out: [2]float64{500000, 0}, tol: 1e-3},
+		{name: "wgs84 to utm", src: wgs84Def, dst: utm33nDef, in: [2]float64{15, 50}, out: [2]float64{500000, 5538630.70}, tol: 0.1},
+		// example from EPSG Guidance Note 7-2
+		{name: "bessel to krovak", src: "+proj=longlat +ellps=bessel +no_defs", dst: krovakDef, in: [2]float64{16.849771944444444, 50.20901166666667}, out: [2]float64{-568991.00, -1050538.64}, tol: 0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := Parse(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dst, err := Parse(tt.dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			x, y, err := NewTransformer(src, dst).Transform(tt.in[0], tt.in[1])
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if math.Abs(x-tt.out[0]) > tt.tol || math.Abs(y-tt.out[1]) > tt.tol {
+				t.Errorf("got (%.4f, %.4f), want (%.4f, %.4f)", x, y, tt.out[0], tt.out[1])
+			}
+			// inverse transformation
+			bx, by, err := NewTransformer(dst, src).Transform(x, y)
+			if err != nil {
+				t.Fatalf("unexpected error of inverse transformation: %v", err)
+			}
+			if math.Abs(bx-tt.in[0]) > 1e-7 || math.Abs(by-tt.in[1]) > 1e-7 {
+				t.Errorf("inverse got (%.9f, %.9f), want (%.9f, %.9f)", bx, by, tt.in[0], tt.in[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package proj
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrNotConverged = errors.New("inverse projection did not converge")
+
+const halfPi = math.Pi / 2
+
+// projection implementations work with radians relative to the central meridian
+// and return/accept plain meters (without false easting/northing)
+type projection interface {
+	forward(lam, phi float64) (float64, float64, error)
+	inverse(x, y float64) (float64, float64, error)
+}
+
+/* Mercator */
+
+type mercator struct {
+	el Ellipsoid
+	k0 float64
+}
+
+func newMercator(el Ellipsoid, p params) (projection, error) {
+	k0 := p.float("k_0", p.float("k", 1))
+	if p.has("lat_ts") {
+		latTs := p.angle("lat_ts", 0)
+		sin := math.Sin(latTs)
+		k0 = math.Cos(latTs) / math.Sqrt(1-el.Es*sin*sin)
+	}
+	return &mercator{el: el, k0: k0}, nil
+}
+
+func (m *mercator) forward(lam, phi float64) (float64, float64, error) {
+	if math.Abs(math.Abs(phi)-halfPi) < 1e-10 {
+		return 0, 0, ErrOutOfRange
+	}
+	a := m.el.A * m.k0
+	x := a * lam
+	if m.el.IsSphere() {
+		return x, a * math.Log(math.Tan(math.Pi/4+phi/2)), nil
+	}
+	e := m.el.E
+	sin := math.Sin(phi)
+	y := a * math.Log(math.Tan(math.Pi/4+phi/2)*math.Pow((1-e*sin)/(1+e*sin), e/2))
+	return x, y, nil
+}
+
+func (m *mercator) inverse(x, y float64) (float64, float64, error) {
+	a := m.el.A * m.k0
+	lam := x / a
+	if m.el.IsSphere() {
+		return lam, halfPi - 2*math.Atan(math.Exp(-y/a)), nil
+	}
+	phi, err := phi2(math.Exp(-y/a), m.el.E)
+	return lam, phi, err
+}
+
+// phi2 computes latitude from the isometric latitude parameter ts
+func phi2(ts, e float64) (float64, error) {
+	phi := halfPi - 2*math.Atan(ts)
+	for i := 0; i < 15; i++ {
+		con := e * math.Sin(phi)
+		dphi := halfPi - 2*math.Atan(ts*math.Pow((1-con)/(1+con), e/2)) - phi
+		phi += dphi
+		if math.Abs(dphi) < 1e-12 {
+			return phi, nil
+		}
+	}
+	return phi, ErrNotConverged
+}
+
+/* Transverse Mercator (series expansion, Snyder 1987) */
+
+type transverseMercator struct {
+	el  Ellipsoid
+	k0  float64
+	ep2 float64
+	ml0 float64
+}
+
+func newTransverseMercator(el Ellipsoid, p params) (projection, error) {
+	tm := &transverseMercator{
+		el: el,
+		k0: p.float("k_0", p.float("k", 1)),
+	}
+	tm.ep2 = el.Es / (1 - el.Es)
+	tm.ml0 = tm.mlfn(p.angle("lat_0", 0))
+	return tm, nil
+}
+
+func (t *transverseMercator) mlfn(phi float64) float64 {
+	es := t.el.Es
+	es2 := es * es
+	es3 := es2 * es
+	return t.el.A * ((1-es/4-3*es2/64-5*es3/256)*phi -
+		(3*es/8+3*es2/32+45*es3/1024)*math.Sin(2*phi) +
+		(15*es2/256+45*es3/1024)*math.Sin(4*phi) -
+		(35*es3/3072)*math.Sin(6*phi))
+}
+
+func (t *transverseMercator) forward(lam, phi float64) (float64, float64, error) {
+	if math.Abs(lam) > halfPi {
+		return 0, 0, ErrOutOfRange
+	}
+	sin, cos := math.Sincos(phi)
+	n := t.el.A / math.Sqrt(1-t.el.Es*sin*sin)
+	tan := 0.0
+	if math.Abs(cos) > 1e-12 {
+		tan = sin / cos
+	}
+	T := tan * tan
+	C := t.ep2 * cos * cos
+	A := lam * cos
+	A2 := A * A
+	M := t.mlfn(phi)
+
+	x := t.k0 * n * (A + (1-T+C)*A*A2/6 + (5-18*T+T*T+72*C-58*t.ep2)*A*A2*A2/120)
+	y := t.k0 * (M - t.ml0 + n*tan*(A2/2+(5-T+9*C+4*C*C)*A2*A2/24+(61-58*T+T*T+600*C-330*t.ep2)*A2*A2*A2/720))
+	return x, y, nil
+}
+
+func (t *transverseMercator) inverse(x, y float64) (float64, float64, error) {
+	es := t.el.Es
+	m := t.ml0 + y/t.k0
+	mu := m / (t.el.A * (1 - es/4 - 3*es*es/64 - 5*es*es*es/256))
+	sq := math.Sqrt(1 - es)
+	e1 := (1 - sq) / (1 + sq)
+	e12 := e1 * e1
+	phi1 := mu + (3*e1/2-27*e1*e12/32)*math.Sin(2*mu) +
+		(21*e12/16-55*e12*e12/32)*math.Sin(4*mu) +
+		(151*e1*e12/96)*math.Sin(6*mu) +
+		(1097*e12*e12/512)*math.Sin(8*mu)
+
+	if math.Abs(phi1) >= halfPi {
+		return 0, math.Copysign(halfPi, y), nil
+	}
+	sin, cos := math.Sincos(phi1)
+	tan := sin / cos
+	C1 := t.ep2 * cos * cos
+	T1 := tan * tan
+	con := 1 - es*sin*sin
+	N1 := t.el.A / math.Sqrt(con)
+	R1 := t.el.A * (1 - es) / (con * math.Sqrt(con))
+	D := x / (N1 * t.k0)
+	D2 := D * D
+
+	phi := phi1 - (N1*tan/R1)*(D2/2-
+		(5+3*T1+10*C1-4*C1*C1-9*t.ep2)*D2*D2/24+
+		(61+90*T1+298*C1+45*T1*T1-252*t.ep2-3*C1*C1)*D2*D2*D2/720)
+	lam := (D - (1+2*T1+C1)*D*D2/6 +
+		(5-2*C1+28*T1-3*C1*C1+8*t.ep2+24*T1*T1)*D*D2*D2/120) / cos
+	return lam, phi, nil
+}
+
+/* Krovak (oblique conformal conic, EPSG method 9819) */
+
+const (
+	krovakUQ = 1.04216856380474 // 59°42'42.69689" - colatitude of the cone axis
+	krovakS0 = 1.37008346281555 // 78°30' - latitude of pseudo standard parallel
+)
+
+type krovak struct {
+	el    Ellipsoid
+	alpha float64
+	k     float64
+	n     float64
+	rho0  float64
+	ad    float64
+	czech float64
+}
+
+func newKrovak(el Ellipsoid, p params) (projection, error) {
+	phi0 := p.angle("lat_0", 0.863937979737193)
+	k0 := p.float("k_0", p.float("k", 0.9999))
+	e := el.E
+	sinPhi0 := math.Sin(phi0)
+	cosPhi0 := math.Cos(phi0)
+
+	kr := &krovak{el: el, czech: -1}
+	if p.has("czech") {
+		kr.czech = 1
+	}
+	kr.alpha = math.Sqrt(1 + (el.Es*math.Pow(cosPhi0, 4))/(1-el.Es))
+	u0 := math.Asin(sinPhi0 / kr.alpha)
+	g := math.Pow((1+e*sinPhi0)/(1-e*sinPhi0), kr.alpha*e/2)
+	kr.k = math.Tan(u0/2+math.Pi/4) / math.Pow(math.Tan(phi0/2+math.Pi/4), kr.alpha) * g
+	n0 := math.Sqrt(1-el.Es) / (1 - el.Es*sinPhi0*sinPhi0)
+	kr.n = math.Sin(krovakS0)
+	kr.rho0 = k0 * n0 / math.Tan(krovakS0)
+	kr.ad = halfPi - krovakUQ
+	return kr, nil
+}
+
+func (kr *krovak) forward(lam, phi float64) (float64, float64, error) {
+	e := kr.el.E
+	sin := math.Sin(phi)
+	gfi := math.Pow((1+e*sin)/(1-e*sin), kr.alpha*e/2)
+	u := 2 * (math.Atan(kr.k*math.Pow(math.Tan(phi/2+math.Pi/4), kr.alpha)/gfi) - math.Pi/4)
+	deltav := -lam * kr.alpha
+	s := math.Asin(math.Cos(kr.ad)*math.Sin(u) + math.Sin(kr.ad)*math.Cos(u)*math.Cos(deltav))
+	cosS := math.Cos(s)
+	if cosS < 1e-12 {
+		return 0, 0, nil
+	}
+	d := math.Asin(math.Cos(u) * math.Sin(deltav) / cosS)
+	eps := kr.n * d
+	rho := kr.rho0 * math.Pow(math.Tan(krovakS0/2+math.Pi/4), kr.n) / math.Pow(math.Tan(s/2+math.Pi/4), kr.n)
+	x := rho * math.Sin(eps) * kr.czech
+	y := rho * math.Cos(eps) * kr.czech
+	return x * kr.el.A, y * kr.el.A, nil
+}
+
+func (kr *krovak) inverse(x, y float64) (float64, float64, error) {
+	x = x / kr.el.A * kr.czech
+	y = y / kr.el.A * kr.czech
+	e := kr.el.E
+	rho := math.Hypot(x, y)
+	eps := math.Atan2(x, y)
+	d := eps / math.Sin(krovakS0)
+	var s float64
+	if rho == 0 {
+		s = halfPi
+	} else {
+		s = 2 * (math.Atan(math.Pow(kr.rho0/rho, 1/kr.n)*math.Tan(krovakS0/2+math.Pi/4)) - math.Pi/4)
+	}
+	u := math.Asin(math.Cos(kr.ad)*math.Sin(s) - math.Sin(kr.ad)*math.Cos(s)*math.Cos(d))
+	deltav := math.Asin(math.Cos(s) * math.Sin(d) / math.Cos(u))
+	lam := -deltav / kr.alpha
+
+	fi1 := u
+	for i := 0; i < 100; i++ {
+		sin := math.Sin(fi1)
+		phi := 2 * (math.Atan(math.Pow(kr.k, -1/kr.alpha)*math.Pow(math.Tan(u/2+math.Pi/4), 1/kr.alpha)*math.Pow((1+e*sin)/(1-e*sin), e/2)) - math.Pi/4)
+		if math.Abs(fi1-phi) < 1e-15 {
+			return lam, phi, nil
+		}
+		fi1 = phi
+	}
+	return lam, fi1, ErrNotConverged
+}
@@ -0,0 +1,168 @@
+package proj
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrInvalidGeometry = errors.New("invalid geometry")
+
+type Transformer struct {
+	src        *CRS
+	dst        *CRS
+	datumShift bool
+}
+
+func NewTransformer(src, dst *CRS) *Transformer {
+	t := &Transformer{src: src, dst: dst}
+	// datum shift is performed only when both datums are known (legacy proj4 behavior)
+	if src.towgs84 != nil && dst.towgs84 != nil {
+		t.datumShift = !src.ellps.equals(dst.ellps) || !sameParams(src.towgs84, dst.towgs84)
+	}
+	return t
+}
+
+func sameParams(a, b []float64) bool {
+	for i := 0; i < 7; i++ {
+		if paramAt(a, i) != paramAt(b, i) {
+			return false
+		}
+	}
+	return true
+}
+
+func paramAt(p []float64, i int) float64 {
+	if i < len(p) {
+		return p[i]
+	}
+	return 0
+}
+
+// Transform converts single coordinate pair. Geographic coordinates are
+// expected in degrees in longitude, latitude order.
+func (t *Transformer) Transform(x, y float64) (float64, float64, error) {
+	lam, phi, err := t.src.toGeodetic(x, y)
+	if err != nil {
+		return 0, 0, err
+	}
+	if t.datumShift {
+		lam, phi = t.shiftDatum(lam, phi)
+	}
+	rx, ry, err := t.dst.fromGeodetic(lam, phi)
+	if err != nil {
+		return 0, 0, err
+	}
+	if math.IsNaN(rx) || math.IsNaN(ry) || math.IsInf(rx, 0) || math.IsInf(ry, 0) {
+		return 0, 0, ErrOutOfRange
+	}
+	return rx, ry, nil
+}
+
+// TransformPoints converts list of [x, y] (or [x, y, z]) coordinates in place
+func (t *Transformer) TransformPoints(points [][]float64) error {
+	for i, p := range points {
+		if len(p) < 2 {
+			return fmt.Errorf("%w: point must have at least 2 coordinates", ErrInvalidGeometry)
+		}
+		x, y, err := t.Transform(p[0], p[1])
+		if err != nil {
+			return fmt.Errorf("transforming point [%d]: %w", i, err)
+		}
+		p[0], p[1] = x, y
+	}
+	return nil
+}
+
+// TransformBounds converts [minx, miny, maxx, maxy] extent. Edges are densified,
+// so the result covers the whole transformed area.
+func (t *Transformer) TransformBounds(bbox []float64) ([]float64, error) {
+	if len(bbox) != 4 {
+		return nil, fmt.Errorf("%w: bbox must have 4 values", ErrInvalidGeometry)
+	}
+	const steps = 20
+	minx, miny := math.Inf(1), math.Inf(1)
+	maxx, maxy := math.Inf(-1), math.Inf(-1)
+	dx := (bbox[2] - bbox[0]) / steps
+	dy := (bbox[3] - bbox[1]) / steps
+	add := func(x, y float64) error {
+		tx, ty, err := t.Transform(x, y)
+		if err != nil {
+			return err
+		}
+		minx = math.Min(minx, tx)
+		miny = math.Min(miny, ty)
+		maxx = math.Max(maxx, tx)
+		maxy = math.Max(maxy, ty)
+		return nil
+	}
+	for i := 0; i <= steps; i++ {
+		x := bbox[0] + float64(i)*dx
+		y := bbox[1] + float64(i)*dy
+		for _, p := range [][2]float64{{x, bbox[1]}, {x, bbox[3]}, {bbox[0], y}, {bbox[2], y}} {
+			if err := add(p[0], p[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return []float64{minx, miny, maxx, maxy}, nil
+}
+
+func (t *Transformer) shiftDatum(lam, phi float64) (float64, float64) {
+	x, y, z := geodeticToGeocentric(t.src.ellps, lam, phi)
+	x, y, z = helmertToWGS84(t.src.towgs84, x, y, z)
+	x, y, z = helmertFromWGS84(t.dst.towgs84, x, y, z)
+	return geocentricToGeodetic(t.dst.ellps, x, y, z)
+}
+
+func geodeticToGeocentric(el Ellipsoid, lam, phi float64) (float64, float64, float64) {
+	sinPhi, cosPhi := math.Sincos(phi)
+	n := el.A / math.Sqrt(1-el.Es*sinPhi*sinPhi)
+	return n * cosPhi * math.Cos(lam), n * cosPhi * math.Sin(lam), n * (1 - el.Es) * sinPhi
+}
+
+func geocentricToGeodetic(el Ellipsoid, x, y, z float64) (float64, float64) {
+	p := math.Hypot(x, y)
+	lam := math.Atan2(y, x)
+	if p < 1e-12 {
+		return lam, math.Copysign(halfPi, z)
+	}
+	phi := math.Atan2(z, p*(1-el.Es))
+	for i := 0; i < 10; i++ {
+		sin := math.Sin(phi)
+		n := el.A / math.Sqrt(1-el.Es*sin*sin)
+		next := math.Atan2(z+el.Es*n*sin, p)
+		if math.Abs(next-phi) < 1e-14 {
+			return lam, next
+		}
+		phi = next
+	}
+	return lam, phi
+}
+
+const sec2rad = math.Pi / (180 * 3600)
+
+func helmertParams(p []float64) (dx, dy, dz, rx, ry, rz, m float64) {
+	dx, dy, dz = paramAt(p, 0), paramAt(p, 1), paramAt(p, 2)
+	rx, ry, rz = paramAt(p, 3)*sec2rad, paramAt(p, 4)*sec2rad, paramAt(p, 5)*sec2rad
+	m = 1 + paramAt(p, 6)*1e-6
+	return
+}
+
+// position vector convention (same as proj4 towgs84 parameter)
+func helmertToWGS84(p []float64, x, y, z float64) (float64, float64, float64) {
+	dx, dy, dz, rx, ry, rz, m := helmertParams(p)
+	return m*(x-rz*y+ry*z) + dx,
+		m*(rz*x+y-rx*z) + dy,
+		m*(-ry*x+rx*y+z) + dz
+}
+
+func helmertFromWGS84(p []float64, x, y, z float64) (float64, float64, float64) {
+	dx, dy, dz, rx, ry, rz, m := helmertParams(p)
+	x = (x - dx) / m
+	y = (y - dy) / m
+	z = (z - dz) / m
+	return x + rz*y - ry*z,
+		-rz*x + y + rx*z,
+		ry*x - rx*y + z
+}
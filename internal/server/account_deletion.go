@@ -0,0 +1,196 @@
+package server
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// AddAccountDeletion registers self-service deletion of accounts
+func (s *Server) AddAccountDeletion(service *application.AccountDeletionService) {
+	s.accountDeletion = service
+	e := s.echo
+	LoginRequired := s.middlewares.LoginRequired
+	e.GET("/api/account/deletion", s.handleGetAccountDeletion, LoginRequired)
+	e.POST("/api/account/deletion", s.handleRequestAccountDeletion(), LoginRequired)
+	e.DELETE("/api/account/deletion", s.handleCancelAccountDeletion, LoginRequired)
+	e.POST("/api/accounts/delete/confirm", s.handleConfirmAccountDeletion())
+}
+
+func accountDeletionError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrDeletionNotRequested):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, application.ErrInvalidToken):
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid confirmation link")
+	case errors.Is(err, application.ErrEmailNotSet),
+		errors.Is(err, application.ErrInvalidTransferTarget):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, application.ErrLastOrganizationOwner):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func (s *Server) handleGetAccountDeletion(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	deletion, err := s.accountDeletion.Get(user.Username)
+	if err != nil {
+		return accountDeletionError(err, "getting account deletion")
+	}
+	return c.JSON(http.StatusOK, deletion)
+}
+
+func (s *Server) handleRequestAccountDeletion() func(echo.Context) error {
+	type DeletionForm struct {
+		// required for accounts with password
+		Password string `json:"password"`
+		// organization receiving projects (projects are deleted when empty)
+		TransferTo string `json:"transfer_to"`
+	}
+	return func(c echo.Context) error {
+		form := new(DeletionForm)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		if user.TokenID != 0 {
+			return echo.NewHTTPError(http.StatusForbidden, "Account cannot be deleted using API token")
+		}
+		account, err := s.accountsService.Repository.GetByUsername(user.Username)
+		if err != nil {
+			return fmt.Errorf("getting account: %w", err)
+		}
+		if len(account.Password) > 0 && !account.CheckPassword(form.Password) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Password doesn't match")
+		}
+		deletion, err := s.accountDeletion.Request(account, form.TransferTo)
+		if err != nil {
+			return accountDeletionError(err, "requesting account deletion")
+		}
+		return c.JSON(http.StatusOK, deletion)
+	}
+}
+
+func (s *Server) handleConfirmAccountDeletion() func(echo.Context) error {
+	type ConfirmForm struct {
+		Uid   string `json:"uid" validate:"required"`
+		Token string `json:"token" validate:"required"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		form := new(ConfirmForm)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		deletion, err := s.accountDeletion.Confirm(form.Uid, form.Token)
+		if err != nil {
+			return accountDeletionError(err, "confirming account deletion")
+		}
+		return c.JSON(http.StatusOK, deletion)
+	}
+}
+
+func (s *Server) handleCancelAccountDeletion(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	if err := s.accountDeletion.Cancel(user.Username); err != nil {
+		return accountDeletionError(err, "cancelling account deletion")
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func writeJSONEntry(w *zip.Writer, name string, data interface{}) error {
+	f, err := w.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// handleExportAccountData returns zip archive with all data stored about the user
+func (s *Server) handleExportAccountData(c echo.Context) error {
+	type Memberships struct {
+		Organizations map[string]string `json:"organizations"`
+		Teams         []string          `json:"teams"`
+		Groups        []string          `json:"groups"`
+	}
+	type Entry struct {
+		name string
+		data interface{}
+	}
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	account, err := s.accountsService.Repository.GetByUsername(user.Username)
+	if err != nil {
+		return fmt.Errorf("getting account: %w", err)
+	}
+	projects, err := s.projects.GetUserProjects(user.Username)
+	if err != nil {
+		return fmt.Errorf("listing projects: %w", err)
+	}
+	tokens, err := s.auth.ListAPITokens(user.Username)
+	if err != nil {
+		return fmt.Errorf("listing api tokens: %w", err)
+	}
+	sessions, err := s.auth.ListSessions(c, user.Username)
+	if err != nil {
+		return fmt.Errorf("listing sessions: %w", err)
+	}
+	memberUser := s.auth.AccountUser(account)
+	legacyProfile, err := s.getUserProfile(domain.AccountToUser(account))
+	if err != nil {
+		return err
+	}
+	entries := []Entry{
+		{"account.json", toAccountInfo(account)},
+		{"projects.json", projects},
+		{"memberships.json", Memberships{memberUser.Organizations, memberUser.Teams, memberUser.Groups}},
+		{"api_tokens.json", tokens},
+		{"sessions.json", sessions},
+	}
+	if legacyProfile != nil {
+		entries = append(entries, Entry{"profile.json", legacyProfile})
+	}
+	if s.audit != nil {
+		events, err := s.audit.UserEvents(user.Username, 0)
+		if err != nil {
+			return fmt.Errorf("listing security events: %w", err)
+		}
+		entries = append(entries, Entry{"security_events.json", events})
+	}
+	filename := fmt.Sprintf("%s-%s.zip", user.Username, time.Now().Format("20060102"))
+	c.Response().Header().Set("Content-Type", "application/zip")
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	writer := zip.NewWriter(c.Response())
+	defer writer.Close()
+	for _, entry := range entries {
+		if err := writeJSONEntry(writer, entry.name, entry.data); err != nil {
+			return fmt.Errorf("writing data export: %w", err)
+		}
+	}
+	return nil
+}
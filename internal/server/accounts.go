@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/gisquick/gisquick-server/internal/application"
 	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/server/auth"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -22,6 +24,8 @@ func (s *Server) handleSignUp() func(echo.Context) error {
 		FirstName       string         `json:"first_name" form:"first_name"`
 		LastName        string         `json:"last_name" form:"last_name"`
 		Profile         map[string]any `json:"profile"`
+		// preferred language, Accept-Language header is used when not set
+		Language string `json:"lang" form:"lang"`
 	}
 	var validate = validator.New()
 
@@ -36,11 +40,22 @@ func (s *Server) handleSignUp() func(echo.Context) error {
 		if form.Password != form.PasswordConfirm {
 			return echo.NewHTTPError(http.StatusBadRequest, "Password doesn't match")
 		}
-		_, err := s.accountsService.NewAccount(form.Username, form.Email, form.FirstName, form.LastName, form.Password)
+		acceptLanguage := form.Language
+		if acceptLanguage == "" {
+			acceptLanguage = c.Request().Header.Get("Accept-Language")
+		}
+		_, err := s.accountsService.NewAccount(form.Username, form.Email, form.FirstName, form.LastName, form.Password, s.languageProfile(acceptLanguage))
 		if err != nil {
 			if errors.Is(err, domain.ErrAccountExists) {
 				return echo.NewHTTPError(http.StatusBadRequest, "Account already exists")
 			}
+			if errors.Is(err, domain.ErrEmailExists) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			var policyErr *domain.PasswordPolicyError
+			if errors.As(err, &policyErr) {
+				return err
+			}
 			s.log.Errorw("creating a new account", zap.Error(err))
 			return err
 		}
@@ -48,6 +63,23 @@ func (s *Server) handleSignUp() func(echo.Context) error {
 	}
 }
 
+// languageProfile returns profile of a new account with the available language of emails best
+// matching to the given Accept-Language value (nil when there is no such language)
+func (s *Server) languageProfile(acceptLanguage string) domain.Profile {
+	if s.emailTemplates == nil || acceptLanguage == "" {
+		return nil
+	}
+	lang, err := s.emailTemplates.MatchLanguage(acceptLanguage)
+	if err != nil {
+		s.log.Errorw("resolving language of a new account", zap.Error(err))
+		return nil
+	}
+	if lang == "" {
+		return nil
+	}
+	return domain.Profile{domain.ProfileLanguage: lang}
+}
+
 func (s *Server) handleInvitation() func(echo.Context) error {
 	type InvitationForm struct {
 		Username   string                 `json:"username" form:"username" validate:"required"`
@@ -55,6 +87,7 @@ func (s *Server) handleInvitation() func(echo.Context) error {
 		FirstName  string                 `json:"first_name" form:"first_name"`
 		LastName   string                 `json:"last_name" form:"last_name"`
 		Parameters map[string]interface{} `json:"params"`
+		Language   string                 `json:"lang" form:"lang"`
 	}
 	var validate = validator.New()
 
@@ -66,11 +99,14 @@ func (s *Server) handleInvitation() func(echo.Context) error {
 		if err := validate.Struct(form); err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
-		_, err := s.accountsService.NewAccount(form.Username, form.Email, form.FirstName, form.LastName, "")
+		_, err := s.accountsService.NewAccount(form.Username, form.Email, form.FirstName, form.LastName, "", s.languageProfile(form.Language))
 		if err != nil {
 			if errors.Is(err, domain.ErrAccountExists) {
 				return echo.NewHTTPError(http.StatusBadRequest, "Account already exists")
 			}
+			if errors.Is(err, domain.ErrEmailExists) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
 			s.log.Errorw("creating a new account", zap.Error(err))
 			return err
 		}
@@ -98,6 +134,9 @@ func (s *Server) handleActivateAccount() func(echo.Context) error {
 			s.log.Errorw("activating account", "uid", uid, zap.Error(err))
 			return echo.NewHTTPError(http.StatusInternalServerError, "Activation error")
 		}
+		if username, err := base64.URLEncoding.DecodeString(uid); err == nil {
+			s.auth.RecordEvent(c, domain.AuditAccountActivated, string(username), nil)
+		}
 		return c.NoContent(http.StatusOK)
 	}
 }
@@ -143,6 +182,9 @@ func (s *Server) handlePasswordReset() func(echo.Context) error {
 		if err := validate.Struct(form); err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
+		if err := s.auth.CheckPasswordResetLimit(c, form.Email); err != nil {
+			return err
+		}
 		if err := s.accountsService.RequestPasswordReset(form.Email); err != nil {
 			if errors.Is(err, domain.ErrAccountNotFound) {
 				return echo.NewHTTPError(http.StatusBadRequest, "Account with given email doesn't exist")
@@ -151,6 +193,9 @@ func (s *Server) handlePasswordReset() func(echo.Context) error {
 			}
 			return err
 		}
+		if account, err := s.accountsService.Repository.GetByEmail(form.Email); err == nil {
+			s.auth.RecordEvent(c, domain.AuditPasswordResetRequest, account.Username, nil)
+		}
 		return c.NoContent(http.StatusOK)
 	}
 }
@@ -177,13 +222,27 @@ func (s *Server) handleNewPassword() func(echo.Context) error {
 		if form.Password != form.PasswordConfirm {
 			return echo.NewHTTPError(http.StatusBadRequest, "Passwords doesn't match")
 		}
+		username, uidErr := base64.URLEncoding.DecodeString(form.UID)
+		// state before the reset, initial password of invited users activates their account
+		account, accountErr := s.accountsService.Repository.GetByUsername(string(username))
 		err := s.accountsService.SetNewPassword(form.UID, form.Token, form.Password)
 		if err != nil {
 			if errors.Is(err, application.ErrInvalidToken) {
 				return echo.NewHTTPError(http.StatusBadRequest, "Invalid link")
 			}
+			return err
 		}
-		return err
+		// uid is already verified by successful password reset
+		if uidErr == nil {
+			if _, err := s.auth.RevokeUserSessions(c.Request().Context(), string(username), ""); err != nil {
+				s.log.Errorw("revoking sessions after password reset", "user", string(username), zap.Error(err))
+			}
+			s.auth.RecordEvent(c, domain.AuditPasswordReset, string(username), nil)
+			if accountErr == nil && !account.Active {
+				s.auth.RecordEvent(c, domain.AuditAccountActivated, string(username), nil)
+			}
+		}
+		return nil
 	}
 }
 
@@ -222,10 +281,172 @@ func (s *Server) handleChangePassword() func(echo.Context) error {
 		if !account.CheckPassword(form.OldPassword) {
 			return echo.NewHTTPError(http.StatusBadRequest, "Old password doesn't match")
 		}
-		if err := account.SetPassword(form.NewPassword); err != nil {
+		if err := s.accountsService.SetPassword(&account, form.NewPassword); err != nil {
+			return err
+		}
+		if err := s.accountsService.Repository.Update(account); err != nil {
 			return err
 		}
-		return s.accountsService.Repository.Update(account)
+		s.auth.RecordEvent(c, domain.AuditPasswordChange, account.Username, nil)
+		// keep only the current session
+		if _, err := s.auth.RevokeUserSessions(c.Request().Context(), account.Username, sessionInfo.ID); err != nil {
+			s.log.Errorw("revoking sessions after password change", "user", account.Username, zap.Error(err))
+		}
+		return nil
+	}
+}
+
+// handleChangeExpiredPassword changes expired password of the user, who can't login with it.
+// Users with enabled 2FA receive TwoFactorChallenge first and send the request again with
+// the token and verification code.
+func (s *Server) handleChangeExpiredPassword() func(echo.Context) error {
+	type ChangePasswordForm struct {
+		Username           string `json:"username" form:"username" validate:"required"`
+		OldPassword        string `json:"old_password" form:"old_password" validate:"required"`
+		NewPassword        string `json:"new_password1" form:"new_password1" validate:"required"`
+		NewPasswordConfirm string `json:"new_password2" form:"new_password2" validate:"required"`
+		Token              string `json:"token" form:"token"`
+		Code               string `json:"code" form:"code"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		form := new(ChangePasswordForm)
+		if err := c.Bind(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if form.NewPassword != form.NewPasswordConfirm {
+			return echo.NewHTTPError(http.StatusBadRequest, "New passwords doesn't match")
+		}
+		account, err := s.auth.AuthenticateRequest(c, form.Username, form.OldPassword)
+		if err != nil {
+			var limitErr *auth.LimitError
+			if errors.As(err, &limitErr) {
+				return err
+			}
+			return echo.NewHTTPError(http.StatusUnauthorized, "Please provide valid credentials")
+		}
+		if len(account.Password) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Password of the account is managed externally")
+		}
+		if !s.auth.PasswordExpired(account) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Password is not expired")
+		}
+		if form.NewPassword == form.OldPassword {
+			return echo.NewHTTPError(http.StatusBadRequest, "New password must be different from the old one")
+		}
+		enabled2FA, err := s.auth.TwoFactorEnabled(account.Username)
+		if err != nil {
+			return fmt.Errorf("checking 2fa of user [%s]: %w", account.Username, err)
+		}
+		if enabled2FA {
+			if form.Token == "" {
+				token, err := s.auth.StartTwoFactorPasswordChange(c.Request().Context(), account.Username)
+				if err != nil {
+					return err
+				}
+				return c.JSON(http.StatusAccepted, TwoFactorChallenge{Required: true, Token: token})
+			}
+			verified, err := s.auth.FinishTwoFactorPasswordChange(c, form.Token, form.Code)
+			if err != nil {
+				if errors.Is(err, auth.ErrInvalidCode) || errors.Is(err, auth.ErrInvalidTwoFactorLogin) || errors.Is(err, auth.ErrUserNotFound) {
+					return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+				}
+				return fmt.Errorf("2fa verification: %w", err)
+			}
+			if verified.Username != account.Username {
+				return echo.NewHTTPError(http.StatusUnauthorized, auth.ErrInvalidTwoFactorLogin.Error())
+			}
+		}
+		if err := s.accountsService.SetPassword(&account, form.NewPassword); err != nil {
+			return err
+		}
+		if err := s.accountsService.Repository.Update(account); err != nil {
+			return err
+		}
+		s.auth.RecordEvent(c, domain.AuditPasswordChange, account.Username, map[string]interface{}{"expired": true})
+		if _, err := s.auth.RevokeUserSessions(c.Request().Context(), account.Username, ""); err != nil {
+			s.log.Errorw("revoking sessions after password change", "user", account.Username, zap.Error(err))
+		}
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+func emailChangeError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidEmail):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrEmailExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, application.ErrInvalidToken):
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid confirmation link")
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// handleRequestEmailChange sends confirmation link to the new email address, the address
+// is changed after the confirmation (see handleConfirmEmailChange)
+func (s *Server) handleRequestEmailChange() func(echo.Context) error {
+	type EmailChangeForm struct {
+		Email string `json:"email" form:"email" validate:"required,email"`
+		// required for accounts with password
+		Password string `json:"password" form:"password"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		if !s.accountsService.SupportEmails() {
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "Email service not supported")
+		}
+		form := new(EmailChangeForm)
+		if err := c.Bind(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		if user.TokenID != 0 {
+			return echo.NewHTTPError(http.StatusForbidden, "Email address cannot be changed using API token")
+		}
+		account, err := s.accountsService.Repository.GetByUsername(user.Username)
+		if err != nil {
+			return fmt.Errorf("getting account: %w", err)
+		}
+		if len(account.Password) > 0 && !account.CheckPassword(form.Password) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Password doesn't match")
+		}
+		if err := s.accountsService.RequestEmailChange(account, form.Email); err != nil {
+			return emailChangeError(err, "requesting email change")
+		}
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+func (s *Server) handleConfirmEmailChange() func(echo.Context) error {
+	type ConfirmForm struct {
+		Uid   string `json:"uid" validate:"required"`
+		Email string `json:"email" validate:"required"`
+		Token string `json:"token" validate:"required"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		form := new(ConfirmForm)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		account, err := s.accountsService.ConfirmEmailChange(form.Uid, form.Email, form.Token)
+		if err != nil {
+			return emailChangeError(err, "confirming email change")
+		}
+		return c.JSON(http.StatusOK, toAccountInfo(account))
 	}
 }
 
@@ -6,13 +6,17 @@ import (
 	"errors"
 	"fmt"
 	htmltemplate "html/template"
+	"io"
 	"net/http"
+	"path/filepath"
 	"strings"
 	texttemplate "text/template"
 	"time"
 
+	"github.com/gisquick/gisquick-server/internal/application"
 	"github.com/gisquick/gisquick-server/internal/domain"
 	"github.com/gisquick/gisquick-server/internal/infrastructure/email"
+	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 )
@@ -28,6 +32,9 @@ type Account struct {
 	Confirmed *time.Time     `json:"confirmed_at"`
 	LastLogin *time.Time     `json:"last_login_at"`
 	Profile   map[string]any `json:"profile,omitempty"`
+
+	PasswordChanged *time.Time `json:"password_changed_at"`
+	PasswordExpiry  bool       `json:"password_expiry"`
 }
 
 func toAccountInfo(a domain.Account) Account {
@@ -42,6 +49,9 @@ func toAccountInfo(a domain.Account) Account {
 		Confirmed: a.Confirmed,
 		LastLogin: a.LastLogin,
 		Profile:   a.Profile,
+
+		PasswordChanged: a.PasswordChanged,
+		PasswordExpiry:  a.PasswordExpiry,
 	}
 }
 
@@ -49,16 +59,266 @@ func (s *Server) handleAdminConfig(c echo.Context) error {
 	return c.File("/etc/gisquick/admin.json")
 }
 
-func (s *Server) handleGetAllUsers(c echo.Context) error {
-	accounts, err := s.accountsService.GetAllAccounts()
+// parseTimeParam parses time in RFC3339 format or date (YYYY-MM-DD), endOfDay moves
+// dates to the end of the day (start of the next day)
+func parseTimeParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// parseAccountsQuery parses filter and sorting parameters of accounts listing
+func parseAccountsQuery(c echo.Context) (domain.AccountsQuery, error) {
+	type Params struct {
+		Search        string `query:"q"`
+		Username      string `query:"username"`
+		Name          string `query:"name"`
+		Email         string `query:"email"`
+		Active        *bool  `query:"active"`
+		LastLoginFrom string `query:"last_login_from"`
+		LastLoginTo   string `query:"last_login_to"`
+		// sort field, prefixed with '-' for descending order
+		Sort string `query:"sort"`
+	}
+	params := new(Params)
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, params); err != nil {
+		return domain.AccountsQuery{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid query parameters")
+	}
+	query := domain.AccountsQuery{
+		Search:   strings.TrimSpace(params.Search),
+		Username: strings.TrimSpace(params.Username),
+		Name:     strings.TrimSpace(params.Name),
+		Email:    strings.TrimSpace(params.Email),
+		Active:   params.Active,
+	}
+	var err error
+	if query.LastLoginFrom, err = parseTimeParam(params.LastLoginFrom, false); err != nil {
+		return query, echo.NewHTTPError(http.StatusBadRequest, "Invalid last_login_from parameter")
+	}
+	if query.LastLoginTo, err = parseTimeParam(params.LastLoginTo, true); err != nil {
+		return query, echo.NewHTTPError(http.StatusBadRequest, "Invalid last_login_to parameter")
+	}
+	if strings.HasPrefix(params.Sort, "-") {
+		query.Descending = true
+	}
+	query.OrderBy = strings.TrimPrefix(params.Sort, "-")
+	if err := query.Validate(); err != nil {
+		return query, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return query, nil
+}
+
+func (s *Server) handleGetAllUsers() func(echo.Context) error {
+	type Pagination struct {
+		Limit  int `query:"limit"`
+		Offset int `query:"offset"`
+	}
+	type Page struct {
+		Total  int       `json:"total"`
+		Limit  int       `json:"limit"`
+		Offset int       `json:"offset"`
+		Users  []Account `json:"users"`
+	}
+	return func(c echo.Context) error {
+		query, err := parseAccountsQuery(c)
+		if err != nil {
+			return err
+		}
+		pagination := Pagination{Limit: 50}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &pagination); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid query parameters")
+		}
+		if pagination.Limit <= 0 || pagination.Limit > 1000 || pagination.Offset < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid pagination parameters")
+		}
+		query.Limit = pagination.Limit
+		query.Offset = pagination.Offset
+		accounts, total, err := s.accountsService.QueryAccounts(query)
+		if err != nil {
+			return fmt.Errorf("listing accounts: %w", err)
+		}
+		data := make([]Account, len(accounts))
+		for i, a := range accounts {
+			data[i] = toAccountInfo(a)
+		}
+		return c.JSON(http.StatusOK, Page{Total: total, Limit: query.Limit, Offset: query.Offset, Users: data})
+	}
+}
+
+// handleExportUsers exports accounts matching the query parameters (or selected by 'users'
+// parameter) in CSV format
+func (s *Server) handleExportUsers(c echo.Context) error {
+	query, err := parseAccountsQuery(c)
 	if err != nil {
 		return err
 	}
-	data := []Account{}
-	for _, a := range accounts {
-		data = append(data, toAccountInfo(a))
+	if users, ok := c.QueryParams()["users"]; ok {
+		query.Usernames = users
+	}
+	accounts, _, err := s.accountsService.QueryAccounts(query)
+	if err != nil {
+		return fmt.Errorf("listing accounts: %w", err)
+	}
+	filename := fmt.Sprintf("users-%s.csv", time.Now().Format("20060102"))
+	c.Response().Header().Set("Content-Type", "text/csv; charset=utf-8")
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Response().WriteHeader(http.StatusOK)
+	return application.WriteAccountsCSV(c.Response(), accounts)
+}
+
+// handleUsersBulkAction applies the action on selected accounts, the action is skipped for
+// the current user
+func (s *Server) handleUsersBulkAction() func(echo.Context) error {
+	type BulkForm struct {
+		Action string   `json:"action" validate:"required,oneof=activate deactivate delete"`
+		Users  []string `json:"users" validate:"required,min=1,max=1000"`
+	}
+	type Result struct {
+		Updated []string          `json:"updated"`
+		Failed  map[string]string `json:"failed"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		form := new(BulkForm)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		ctx := c.Request().Context()
+		result := Result{Updated: []string{}, Failed: make(map[string]string)}
+		for _, username := range form.Users {
+			if username == user.Username {
+				result.Failed[username] = "Action cannot be applied on own account"
+				continue
+			}
+			account, err := s.accountsService.Repository.GetByUsername(username)
+			if err != nil {
+				if !errors.Is(err, domain.ErrAccountNotFound) {
+					s.log.Errorw("bulk action", "action", form.Action, "user", username, zap.Error(err))
+				}
+				result.Failed[username] = "Account not found"
+				continue
+			}
+			event := ""
+			switch form.Action {
+			case "activate":
+				if !account.IsActive() {
+					event = domain.AuditAccountActivated
+					if account.Confirmed == nil {
+						now := time.Now()
+						account.Confirmed = &now
+					}
+					account.Active = true
+					err = s.accountsService.Repository.Update(account)
+				}
+			case "deactivate":
+				if account.Active {
+					account.Active = false
+					err = s.accountsService.Repository.Update(account)
+				}
+			case "delete":
+				event = domain.AuditAccountDeleted
+				err = s.accountsService.Repository.Delete(username)
+			}
+			if err != nil {
+				s.log.Errorw("bulk action", "action", form.Action, "user", username, zap.Error(err))
+				result.Failed[username] = "Failed to update account"
+				continue
+			}
+			if event != "" {
+				s.auth.RecordEvent(c, event, username, nil)
+			}
+			if form.Action != "activate" {
+				if _, err := s.auth.RevokeUserSessions(ctx, username, ""); err != nil {
+					s.log.Errorw("revoking sessions", "user", username, zap.Error(err))
+				}
+			}
+			result.Updated = append(result.Updated, username)
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+// handleImportUsers imports accounts from CSV or JSON file (uploaded as 'file' field of multipart
+// form or sent in the request body). With dry_run parameter, only the validation report is returned.
+func (s *Server) handleImportUsers() func(echo.Context) error {
+	type Params struct {
+		Format  string   `query:"format"`
+		Mapping []string `query:"map"`
+		DryRun  bool     `query:"dry_run"`
+		Invite  bool     `query:"invite"`
+	}
+	return func(c echo.Context) error {
+		params := new(Params)
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, params); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid query parameters")
+		}
+		if params.Invite && !s.accountsService.SupportEmails() {
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "Email service not supported")
+		}
+		mapping, err := application.ParseImportMapping(params.Mapping)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, 10*MB)
+		format := params.Format
+		var src io.Reader = req.Body
+		if strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+			file, err := c.FormFile("file")
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Missing file")
+			}
+			f, err := file.Open()
+			if err != nil {
+				return fmt.Errorf("reading upload file: %w", err)
+			}
+			defer f.Close()
+			src = f
+			if format == "" {
+				format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), ".")
+			}
+		} else if format == "" {
+			format = "csv"
+			if strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+				format = "json"
+			}
+		}
+		records, err := application.ReadAccounts(src, format, mapping)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		opts := application.AccountsImportOptions{DryRun: params.DryRun, SendInvitations: params.Invite}
+		report, err := s.accountsService.ImportAccounts(records, opts)
+		if err != nil {
+			return fmt.Errorf("importing accounts: %w", err)
+		}
+		return c.JSON(http.StatusOK, report)
+	}
+}
+
+func superuserEvent(superuser bool) string {
+	if superuser {
+		return domain.AuditSuperuserGranted
 	}
-	return c.JSON(http.StatusOK, data)
+	return domain.AuditSuperuserRevoked
 }
 
 func (s *Server) handleGetUser(c echo.Context) error {
@@ -78,6 +338,8 @@ func (s *Server) handleUpdateUser() func(echo.Context) error {
 		Superuser bool           `json:"superuser"`
 		Active    bool           `json:"active"`
 		Profile   map[string]any `json:"profile"`
+		// optional, unchanged when not set
+		PasswordExpiry *bool `json:"password_expiry"`
 	}
 	return func(c echo.Context) error {
 		username := c.Param("user")
@@ -89,14 +351,41 @@ func (s *Server) handleUpdateUser() func(echo.Context) error {
 		if err != nil {
 			return err
 		}
-		account.Email = form.Email
+		if form.Email != account.Email {
+			// administrators can change email address without confirmation
+			email := ""
+			if form.Email != "" {
+				if email, err = domain.NormalizeEmail(form.Email); err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+				}
+			}
+			account.Email = email
+		}
+		wasActive, wasSuperuser := account.Active, account.Superuser
 		account.FirstName = form.FirstName
 		account.LastName = form.LastName
 		account.Active = form.Active
 		account.Superuser = form.Superuser
+		if form.PasswordExpiry != nil {
+			account.PasswordExpiry = *form.PasswordExpiry
+		}
 		if err := s.accountsService.Repository.Update(account); err != nil {
+			if errors.Is(err, domain.ErrEmailExists) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
 			return fmt.Errorf("updating account [%s]: %w", username, err)
 		}
+		if account.Active && !wasActive {
+			s.auth.RecordEvent(c, domain.AuditAccountActivated, username, nil)
+		}
+		if account.Superuser != wasSuperuser {
+			s.auth.RecordEvent(c, superuserEvent(account.Superuser), username, nil)
+		}
+		if !account.Active {
+			if _, err := s.auth.RevokeUserSessions(c.Request().Context(), username, ""); err != nil {
+				s.log.Errorw("revoking sessions of deactivated account", "user", username, zap.Error(err))
+			}
+		}
 		return c.JSON(http.StatusOK, toAccountInfo(account))
 	}
 }
@@ -131,6 +420,8 @@ func (s *Server) handleCreateUser() func(echo.Context) error {
 		Extra     map[string]any `json:"extra"`
 		Profile   map[string]any `json:"profile"`
 		SendEmail bool           `json:"send_email"`
+
+		PasswordExpiry bool `json:"password_expiry"`
 	}
 	return func(c echo.Context) error {
 		form := new(UserFields)
@@ -140,7 +431,7 @@ func (s *Server) handleCreateUser() func(echo.Context) error {
 		if form.SendEmail && !s.accountsService.SupportEmails() {
 			return echo.NewHTTPError(http.StatusPreconditionFailed, "Email service not supported")
 		}
-		account, err := domain.NewAccount(
+		account, err := s.accountsService.CreateAccount(
 			form.Username,
 			form.Email,
 			form.FirstName,
@@ -152,10 +443,17 @@ func (s *Server) handleCreateUser() func(echo.Context) error {
 		}
 		account.Active = form.Active
 		account.Superuser = form.Superuser
+		account.PasswordExpiry = form.PasswordExpiry
 		if err := s.accountsService.Repository.Create(account); err != nil {
+			if errors.Is(err, domain.ErrAccountExists) || errors.Is(err, domain.ErrEmailExists) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
 			s.log.Errorw("creating account", "username", form.Username, zap.Error(err))
 			return fmt.Errorf("failed to create user account")
 		}
+		if account.Superuser {
+			s.auth.RecordEvent(c, domain.AuditSuperuserGranted, account.Username, nil)
+		}
 		if len(form.Profile) > 0 {
 			account.Profile = form.Profile
 			if err := s.accountsService.Repository.UpdateProfile(account); err != nil {
@@ -174,9 +472,40 @@ func (s *Server) handleCreateUser() func(echo.Context) error {
 	}
 }
 
+func (s *Server) handleGetUserLoginLock(c echo.Context) error {
+	account, err := s.accountsService.Repository.GetByUsername(c.Param("user"))
+	if err != nil {
+		return err
+	}
+	status, err := s.auth.LoginLockStatus(c.Request().Context(), account)
+	if err != nil {
+		return fmt.Errorf("getting login lock status [%s]: %w", account.Username, err)
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) handleUnlockUserLogin(c echo.Context) error {
+	account, err := s.accountsService.Repository.GetByUsername(c.Param("user"))
+	if err != nil {
+		return err
+	}
+	if err := s.auth.UnlockLogin(c.Request().Context(), account); err != nil {
+		return fmt.Errorf("unlocking login [%s]: %w", account.Username, err)
+	}
+	s.log.Infow("security: login unlocked by admin", "username", account.Username)
+	return c.NoContent(http.StatusOK)
+}
+
 func (s *Server) handleDeleteUser(c echo.Context) error {
 	username := c.Param("user")
-	return s.accountsService.Repository.Delete(username)
+	if err := s.accountsService.Repository.Delete(username); err != nil {
+		return err
+	}
+	s.auth.RecordEvent(c, domain.AuditAccountDeleted, username, nil)
+	if _, err := s.auth.RevokeUserSessions(c.Request().Context(), username, ""); err != nil {
+		s.log.Errorw("revoking sessions of deleted account", "user", username, zap.Error(err))
+	}
+	return nil
 }
 
 func (s *Server) handleGetEmailPreview() func(echo.Context) error {
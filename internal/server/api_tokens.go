@@ -0,0 +1,86 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/server/auth"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *Server) handleGetAPITokens(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	tokens, err := s.auth.ListAPITokens(user.Username)
+	if err != nil {
+		return fmt.Errorf("listing api tokens [%s]: %w", user.Username, err)
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (s *Server) handleCreateAPIToken() func(echo.Context) error {
+	type TokenForm struct {
+		Name    string     `json:"name" validate:"required,max=100"`
+		Scopes  []string   `json:"scopes" validate:"required,min=1"`
+		Expires *time.Time `json:"expires_at"`
+	}
+	type Payload struct {
+		domain.APIToken
+		Token string `json:"token"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		form := new(TokenForm)
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if form.Expires != nil && !form.Expires.After(time.Now()) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Expiration time must be in the future")
+		}
+		token, value, err := s.auth.CreateAPIToken(user.Username, form.Name, form.Scopes, form.Expires)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidScopes) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			if errors.Is(err, auth.ErrAPITokensDisabled) {
+				return echo.NewHTTPError(http.StatusNotImplemented, "API tokens are not enabled")
+			}
+			return fmt.Errorf("creating api token [%s]: %w", user.Username, err)
+		}
+		return c.JSON(http.StatusCreated, Payload{APIToken: token, Token: value})
+	}
+}
+
+func (s *Server) handleDeleteAPIToken(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid token id")
+	}
+	if err := s.auth.RevokeAPIToken(user.Username, id); err != nil {
+		if errors.Is(err, domain.ErrAPITokenNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Token not found")
+		}
+		return fmt.Errorf("revoking api token [%s]: %w", user.Username, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gisquick/gisquick-server/internal/domain"
@@ -28,6 +29,7 @@ type AppConfig struct {
 type AppData struct {
 	AppConfig
 	PasswordResetUrl string `json:"reset_password_url,omitempty"`
+	OIDCLoginUrl     string `json:"oidc_login_url,omitempty"`
 }
 
 type UserInfo struct {
@@ -84,6 +86,9 @@ func (s *Server) handleAppInit() func(echo.Context) error {
 		if s.accountsService.SupportEmails() {
 			app.PasswordResetUrl = "/api/accounts/password_reset"
 		}
+		if s.oidc != nil {
+			app.OIDCLoginUrl = "/api/auth/oidc/login"
+		}
 		if user.IsAuthenticated {
 			account, err := s.accountsService.Repository.GetByUsername(user.Username)
 			if err != nil {
@@ -120,21 +125,61 @@ func (s *Server) handleGetSessionUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, SessionData{user})
 }
 
-func (s *Server) handleGetUsers(c echo.Context) error {
-	accounts, err := s.accountsService.GetAllAccounts()
-	if err != nil {
-		return err
+// handleGetUsers looks up active users by username, name or email (exact match), returns only
+// minimal information. Listing of all users without search text is allowed only for superusers.
+func (s *Server) handleGetUsers() func(echo.Context) error {
+	type Params struct {
+		Search string `query:"q"`
+		Limit  int    `query:"limit"`
 	}
-	res := []UserInfo{}
-	for _, u := range accounts {
-		res = append(res, UserInfo{
-			Username:  u.Username,
-			FirstName: u.FirstName,
-			LastName:  u.LastName,
-			FullName:  u.FullName(),
-			Active:    u.Active,
-			Profile:   u.Profile,
-		})
+	const minSearchLength = 2
+	return func(c echo.Context) error {
+		params := Params{Limit: 20}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &params); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid query parameters")
+		}
+		if params.Limit <= 0 || params.Limit > 100 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit parameter")
+		}
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		search := strings.TrimSpace(params.Search)
+		if len(search) < minSearchLength && !user.IsSuperuser {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Search text must have at least %d characters", minSearchLength))
+		}
+		active := true
+		query := domain.AccountsQuery{Active: &active, Limit: params.Limit}
+		if search != "" {
+			// email addresses are not searchable by substring, so they can't be enumerated
+			if strings.Contains(search, "@") {
+				if account, err := s.accountsService.Repository.GetByEmail(search); err == nil {
+					query.Usernames = []string{account.Username}
+				} else if errors.Is(err, domain.ErrAccountNotFound) {
+					return c.JSON(http.StatusOK, []UserInfo{})
+				} else {
+					return fmt.Errorf("getting account by email: %w", err)
+				}
+			} else {
+				query.Lookup = search
+			}
+		}
+		accounts, _, err := s.accountsService.QueryAccounts(query)
+		if err != nil {
+			return fmt.Errorf("looking up users: %w", err)
+		}
+		res := make([]UserInfo, len(accounts))
+		for i, u := range accounts {
+			// profiles are not exposed to other users
+			res[i] = UserInfo{
+				Username:  u.Username,
+				FirstName: u.FirstName,
+				LastName:  u.LastName,
+				FullName:  u.FullName(),
+				Active:    u.Active,
+			}
+		}
+		return c.JSON(http.StatusOK, res)
 	}
-	return c.JSON(http.StatusOK, res)
 }
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// AddAudit registers listing of security audit events
+func (s *Server) AddAudit(service *application.AuditService) {
+	s.audit = service
+	e := s.echo
+	LoginRequired := s.middlewares.LoginRequired
+	SuperuserRequired := s.middlewares.SuperuserRequired
+	e.GET("/api/account/security_events", s.handleGetAccountEvents, LoginRequired)
+	e.GET("/api/admin/audit", s.handleGetAuditEvents(), SuperuserRequired)
+}
+
+// handleGetAccountEvents returns recent security events of the current user
+func (s *Server) handleGetAccountEvents(c echo.Context) error {
+	type Params struct {
+		Limit int `query:"limit"`
+	}
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	params := Params{Limit: 50}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &params); err != nil || params.Limit <= 0 || params.Limit > 200 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit parameter")
+	}
+	events, err := s.audit.UserEvents(user.Username, params.Limit)
+	if err != nil {
+		return fmt.Errorf("listing security events: %w", err)
+	}
+	return c.JSON(http.StatusOK, events)
+}
+
+func (s *Server) handleGetAuditEvents() func(echo.Context) error {
+	type Params struct {
+		Username string   `query:"username"`
+		Actor    string   `query:"actor"`
+		Types    []string `query:"type"`
+		IP       string   `query:"ip"`
+		From     string   `query:"from"`
+		To       string   `query:"to"`
+		Limit    int      `query:"limit"`
+		Offset   int      `query:"offset"`
+	}
+	type Page struct {
+		Total  int                 `json:"total"`
+		Limit  int                 `json:"limit"`
+		Offset int                 `json:"offset"`
+		Events []domain.AuditEvent `json:"events"`
+	}
+	return func(c echo.Context) error {
+		params := Params{Limit: 100}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &params); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid query parameters")
+		}
+		if params.Limit <= 0 || params.Limit > 1000 || params.Offset < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid pagination parameters")
+		}
+		query := domain.AuditQuery{
+			Username: strings.TrimSpace(params.Username),
+			Actor:    strings.TrimSpace(params.Actor),
+			Types:    params.Types,
+			IP:       strings.TrimSpace(params.IP),
+			Limit:    params.Limit,
+			Offset:   params.Offset,
+		}
+		var err error
+		if query.From, err = parseTimeParam(params.From, false); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid from parameter")
+		}
+		if query.To, err = parseTimeParam(params.To, true); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid to parameter")
+		}
+		events, total, err := s.audit.Query(query)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidQuery) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			return fmt.Errorf("listing audit events: %w", err)
+		}
+		return c.JSON(http.StatusOK, Page{Total: total, Limit: query.Limit, Offset: query.Offset, Events: events})
+	}
+}
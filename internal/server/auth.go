@@ -1,9 +1,12 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/server/auth"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -23,23 +26,55 @@ func (s *Server) handleLogin() func(echo.Context) error {
 		if err := validate.Struct(form); err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
-		account, err := s.auth.Authenticate(form.Username, form.Password)
+		account, err := s.auth.AuthenticateRequest(c, form.Username, form.Password)
 		if err != nil {
+			var limitErr *auth.LimitError
+			if errors.As(err, &limitErr) {
+				return err
+			}
 			return echo.NewHTTPError(http.StatusUnauthorized, "Please provide valid credentials")
 		}
-		if err := s.auth.LoginUser(c, account); err != nil {
+		if s.auth.PasswordExpired(account) {
+			return c.JSON(http.StatusForbidden, echo.Map{"message": "Password expired", "password_expired": true})
+		}
+		token, err := s.startTwoFactorLogin(c, account)
+		if err != nil {
 			return err
 		}
-		user := domain.AccountToUser(account)
-		if user.Profile == nil {
-			profile, err := s.getUserProfile(user)
-			if err != nil {
-				s.log.Warnw("handleLogin", "user", user.Username, zap.Error(err))
-			}
-			user.Profile = profile
+		if token != "" {
+			return c.JSON(http.StatusAccepted, TwoFactorChallenge{Required: true, Token: token})
+		}
+		return s.completeLogin(c, account)
+	}
+}
+
+// startTwoFactorLogin returns token of the second step of login for users with enabled 2FA,
+// or empty string when 2FA is not enabled and the user can be logged in directly
+func (s *Server) startTwoFactorLogin(c echo.Context, account domain.Account) (string, error) {
+	enabled2FA, err := s.auth.TwoFactorEnabled(account.Username)
+	if err != nil {
+		return "", fmt.Errorf("checking 2fa of user [%s]: %w", account.Username, err)
+	}
+	if !enabled2FA {
+		return "", nil
+	}
+	return s.auth.StartTwoFactorLogin(c.Request().Context(), account.Username)
+}
+
+// completeLogin creates session of authenticated user and responds with user's data
+func (s *Server) completeLogin(c echo.Context, account domain.Account) error {
+	if err := s.auth.LoginUser(c, account); err != nil {
+		return err
+	}
+	user := domain.AccountToUser(account)
+	if user.Profile == nil {
+		profile, err := s.getUserProfile(user)
+		if err != nil {
+			s.log.Warnw("handleLogin", "user", user.Username, zap.Error(err))
 		}
-		return c.JSON(http.StatusOK, user)
+		user.Profile = profile
 	}
+	return c.JSON(http.StatusOK, user)
 }
 
 func (s *Server) handleLogout(c echo.Context) error {
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	bearer         = "bearer"
+	apiTokenPrefix = "gq_"
+	tokenCacheTTL  = 45 * time.Second
+)
+
+var (
+	ErrInvalidAPIToken   = errors.New("Invalid API token")
+	ErrInvalidScopes     = errors.New("Invalid API token scopes")
+	ErrAPITokensDisabled = errors.New("API tokens are not enabled")
+)
+
+func hashAPIToken(value string) string {
+	h := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(h[:])
+}
+
+// BearerToken returns value of the bearer token from Authorization header
+func BearerToken(c echo.Context) (string, bool) {
+	auth := c.Request().Header.Get("Authorization")
+	prefixLen := len(bearer)
+	if len(auth) > prefixLen+1 && strings.EqualFold(auth[:prefixLen], bearer) && auth[prefixLen] == ' ' {
+		return strings.TrimSpace(auth[prefixLen+1:]), true
+	}
+	return "", false
+}
+
+// SetAPITokensRepository enables authentication with personal API tokens
+func (s *AuthService) SetAPITokensRepository(tokens domain.APITokensRepository) {
+	s.tokens = tokens
+}
+
+func (s *AuthService) APITokensEnabled() bool {
+	return s.tokens != nil
+}
+
+// CreateAPIToken creates a new token and returns it along with its value, which is not stored
+// and cannot be obtained later
+func (s *AuthService) CreateAPIToken(username, name string, scopes []string, expires *time.Time) (domain.APIToken, string, error) {
+	if s.tokens == nil {
+		return domain.APIToken{}, "", ErrAPITokensDisabled
+	}
+	if len(scopes) == 0 {
+		return domain.APIToken{}, "", ErrInvalidScopes
+	}
+	for _, scope := range scopes {
+		if !domain.APITokenScopes.Has(scope) {
+			return domain.APIToken{}, "", fmt.Errorf("%w: unknown scope '%s'", ErrInvalidScopes, scope)
+		}
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return domain.APIToken{}, "", err
+	}
+	value := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	if expires != nil {
+		t := expires.UTC()
+		expires = &t
+	}
+	token := domain.APIToken{
+		Username: username,
+		Name:     strings.TrimSpace(name),
+		Prefix:   value[:len(apiTokenPrefix)+6],
+		Scopes:   domain.Flags(scopes).Clone(),
+		Created:  time.Now().UTC(),
+		Expires:  expires,
+	}
+	token, err := s.tokens.Create(token, hashAPIToken(value))
+	if err != nil {
+		return token, "", fmt.Errorf("saving api token: %w", err)
+	}
+	return token, value, nil
+}
+
+func (s *AuthService) ListAPITokens(username string) ([]domain.APIToken, error) {
+	if s.tokens == nil {
+		return []domain.APIToken{}, nil
+	}
+	return s.tokens.List(username)
+}
+
+func (s *AuthService) RevokeAPIToken(username string, id int64) error {
+	if s.tokens == nil {
+		return domain.ErrAPITokenNotFound
+	}
+	if err := s.tokens.Delete(username, id); err != nil {
+		return err
+	}
+	// drop cached authentications of the revoked token
+	for _, item := range s.tokenCache.Items() {
+		if item.Value().TokenID == id {
+			s.tokenCache.Delete(item.Key())
+		}
+	}
+	return nil
+}
+
+// authenticateAPIToken returns user (with token scopes) authenticated by the given token value
+func (s *AuthService) authenticateAPIToken(value string) (domain.User, error) {
+	if s.tokens == nil {
+		return AnonymousUser, ErrInvalidAPIToken
+	}
+	hash := hashAPIToken(value)
+	if item := s.tokenCache.Get(hash); item != nil {
+		return item.Value(), nil
+	}
+	token, err := s.tokens.GetByHash(hash)
+	if err != nil {
+		if errors.Is(err, domain.ErrAPITokenNotFound) {
+			return AnonymousUser, ErrInvalidAPIToken
+		}
+		return AnonymousUser, fmt.Errorf("getting api token: %w", err)
+	}
+	now := time.Now().UTC()
+	if token.IsExpired(now) {
+		return AnonymousUser, ErrInvalidAPIToken
+	}
+	account, err := s.accounts.GetByUsername(token.Username)
+	if err != nil {
+		if errors.Is(err, domain.ErrAccountNotFound) {
+			return AnonymousUser, ErrInvalidAPIToken
+		}
+		return AnonymousUser, err
+	}
+	if !account.Active {
+		return AnonymousUser, ErrInvalidAPIToken
+	}
+	// last usage is updated only on cache miss, which is precise enough
+	if err := s.tokens.UpdateLastUsed(token.ID, now); err != nil {
+		s.logger.Warnw("updating api token last usage", "id", token.ID, zap.Error(err))
+	}
+	user := domain.AccountToUser(account)
+	user.TokenID = token.ID
+	user.TokenScopes = token.Scopes
+	ttl := ttlcache.DefaultTTL
+	if token.Expires != nil && token.Expires.Sub(now) < tokenCacheTTL {
+		ttl = token.Expires.Sub(now)
+	}
+	s.tokenCache.Set(hash, user, ttl)
+	return user, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// AuditRecorder records security events (e.g. application.AuditService)
+type AuditRecorder interface {
+	Record(event domain.AuditEvent)
+}
+
+// SetAuditRecorder enables recording of security events
+func (s *AuthService) SetAuditRecorder(recorder AuditRecorder) {
+	s.audit = recorder
+}
+
+func (s *AuthService) recordEvent(c echo.Context, event domain.AuditEvent) {
+	if s.audit == nil {
+		return
+	}
+	event.IP = c.RealIP()
+	event.UserAgent = c.Request().UserAgent()
+	s.audit.Record(event)
+}
+
+// RecordEvent records security event of the account with client's IP address and user agent.
+// Logged in user is recorded as the actor, when the event concerns another account.
+func (s *AuthService) RecordEvent(c echo.Context, eventType, username string, details map[string]interface{}) {
+	event := domain.AuditEvent{Type: eventType, Username: username, Details: details}
+	if user, ok := c.Get("user").(domain.User); ok && !user.IsGuest && user.Username != username {
+		event.Actor = user.Username
+	}
+	s.recordEvent(c, event)
+}
+
+// loginHash returns shortened hash of the login, which allows to correlate failed attempts
+// without storing the login itself (it can be a password typed into the wrong field)
+func loginHash(login string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(login)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// recordFailedLogin records failed login. Login is resolved to username of existing account
+// only after a wrong password, unknown logins and attempts rejected by the limiter are recorded
+// just with hash of the login.
+func (s *AuthService) recordFailedLogin(c echo.Context, login string, err error) {
+	if s.audit == nil {
+		return
+	}
+	reason := "invalid_credentials"
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		reason = "locked"
+	}
+	username := ""
+	if errors.Is(err, ErrInvalidPassword) {
+		var account domain.Account
+		var lerr error
+		if strings.Contains(login, "@") {
+			account, lerr = s.accounts.GetByEmail(login)
+		} else {
+			account, lerr = s.accounts.GetByUsername(login)
+		}
+		if lerr == nil {
+			username = account.Username
+		}
+	}
+	details := map[string]interface{}{"reason": reason}
+	if username == "" {
+		details["login_hash"] = loginHash(login)
+	}
+	s.recordEvent(c, domain.AuditEvent{Type: domain.AuditLoginFailed, Username: username, Details: details})
+}
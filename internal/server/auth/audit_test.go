@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+)
+
+type auditEvents struct {
+	events []domain.AuditEvent
+}
+
+func (r *auditEvents) Record(event domain.AuditEvent) {
+	r.events = append(r.events, event)
+}
+
+func TestRecordFailedLogin(t *testing.T) {
+	s, _ := newSessionsTest()
+	recorder := &auditEvents{}
+	s.SetAuditRecorder(recorder)
+	c := sessionContext("")
+
+	s.recordFailedLogin(c, "jan", ErrInvalidPassword)
+	s.recordFailedLogin(c, "secret-password", ErrUserNotFound)
+	s.recordFailedLogin(c, "jan", &LimitError{Locked: true})
+
+	expected := []struct {
+		username string
+		reason   string
+		hash     bool
+	}{
+		{"jan", "invalid_credentials", false},
+		{"", "invalid_credentials", true},
+		{"", "locked", true},
+	}
+	if len(recorder.events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(recorder.events))
+	}
+	for i, e := range expected {
+		event := recorder.events[i]
+		_, hasHash := event.Details["login_hash"]
+		if event.Username != e.username || event.Details["reason"] != e.reason || hasHash != e.hash {
+			t.Errorf("event %d: unexpected %+v", i, event)
+		}
+		if _, ok := event.Details["login"]; ok {
+			t.Errorf("event %d: login must not be recorded", i)
+		}
+	}
+}
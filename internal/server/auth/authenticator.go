@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+)
+
+// Authenticator verifies user's credentials and returns corresponding local account
+type Authenticator interface {
+	Authenticate(login, password string) (domain.Account, error)
+}
+
+// LocalAuthenticator verifies credentials against passwords stored in accounts repository
+type LocalAuthenticator struct {
+	accounts domain.AccountsRepository
+}
+
+func NewLocalAuthenticator(accounts domain.AccountsRepository) *LocalAuthenticator {
+	return &LocalAuthenticator{accounts: accounts}
+}
+
+func (a *LocalAuthenticator) Authenticate(login, password string) (domain.Account, error) {
+	var account domain.Account
+	var err error
+	if strings.Contains(login, "@") {
+		account, err = a.accounts.GetByEmail(login)
+	} else {
+		account, err = a.accounts.GetByUsername(login)
+	}
+	if err != nil {
+		if errors.Is(err, domain.ErrAccountNotFound) {
+			return domain.Account{}, ErrUserNotFound
+		}
+		return domain.Account{}, err
+	}
+	if !account.Active {
+		return domain.Account{}, ErrUserNotFound
+	}
+	if !account.CheckPassword(password) {
+		return domain.Account{}, ErrInvalidPassword
+	}
+	return account, nil
+}
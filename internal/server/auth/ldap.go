@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/go-ldap/ldap/v3"
+	"go.uber.org/zap"
+)
+
+const ldapAuthSource = "ldap"
+
+type LDAPConfig struct {
+	URL                string // ldap://host:389 or ldaps://host:636
+	StartTLS           bool
+	InsecureSkipVerify bool
+	// service account used for searching (anonymous bind when empty)
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// user search filter, '%s' is replaced by escaped login, e.g. (&(objectClass=person)(uid=%s))
+	UserFilter    string
+	UsernameAttr  string
+	EmailAttr     string
+	FirstNameAttr string
+	LastNameAttr  string
+	GroupBaseDN   string
+	// group search filter, '%s' is replaced by escaped user's DN, e.g. (member=%s)
+	GroupFilter    string
+	GroupNameAttr  string
+	SuperuserGroup string
+	// allows to log in existing local accounts (not created from LDAP) with LDAP credentials
+	LinkExisting bool
+}
+
+// LDAPAuthenticator verifies credentials by binding to LDAP server and creates or
+// updates local accounts on successful login
+type LDAPAuthenticator struct {
+	log          *zap.SugaredLogger
+	cfg          LDAPConfig
+	accounts     domain.AccountsRepository
+	ldapAccounts domain.LDAPAccountsRepository
+}
+
+func NewLDAPAuthenticator(log *zap.SugaredLogger, cfg LDAPConfig, accounts domain.AccountsRepository, ldapAccounts domain.LDAPAccountsRepository) *LDAPAuthenticator {
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(uid=%s)"
+	}
+	if cfg.UsernameAttr == "" {
+		cfg.UsernameAttr = "uid"
+	}
+	if cfg.GroupNameAttr == "" {
+		cfg.GroupNameAttr = "cn"
+	}
+	if cfg.GroupBaseDN == "" {
+		cfg.GroupBaseDN = cfg.BaseDN
+	}
+	return &LDAPAuthenticator{log: log, cfg: cfg, accounts: accounts, ldapAccounts: ldapAccounts}
+}
+
+func (a *LDAPAuthenticator) connect() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: a.cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(a.cfg.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("ldap connect: %w", err)
+	}
+	conn.SetTimeout(10 * time.Second)
+	if a.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap start tls: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (a *LDAPAuthenticator) serviceBind(conn *ldap.Conn) error {
+	if a.cfg.BindDN == "" {
+		return conn.UnauthenticatedBind("")
+	}
+	return conn.Bind(a.cfg.BindDN, a.cfg.BindPassword)
+}
+
+func (a *LDAPAuthenticator) userGroups(conn *ldap.Conn, userDN string) ([]string, error) {
+	if a.cfg.GroupFilter == "" {
+		return nil, nil
+	}
+	req := ldap.NewSearchRequest(
+		a.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf(a.cfg.GroupFilter, ldap.EscapeFilter(userDN)),
+		[]string{a.cfg.GroupNameAttr},
+		nil,
+	)
+	res, err := conn.Search(req)
+	if err != nil {
+		return nil, fmt.Errorf("ldap groups search: %w", err)
+	}
+	groups := make([]string, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		if name := e.GetAttributeValue(a.cfg.GroupNameAttr); name != "" {
+			groups = append(groups, name)
+		}
+	}
+	return groups, nil
+}
+
+func (a *LDAPAuthenticator) Authenticate(login, password string) (domain.Account, error) {
+	if password == "" {
+		// prevent unauthenticated bind
+		return domain.Account{}, ErrInvalidPassword
+	}
+	conn, err := a.connect()
+	if err != nil {
+		return domain.Account{}, err
+	}
+	defer conn.Close()
+
+	if err := a.serviceBind(conn); err != nil {
+		return domain.Account{}, fmt.Errorf("ldap service bind: %w", err)
+	}
+	attributes := []string{"dn", a.cfg.UsernameAttr}
+	for _, attr := range []string{a.cfg.EmailAttr, a.cfg.FirstNameAttr, a.cfg.LastNameAttr} {
+		if attr != "" {
+			attributes = append(attributes, attr)
+		}
+	}
+	req := ldap.NewSearchRequest(
+		a.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(a.cfg.UserFilter, ldap.EscapeFilter(login)),
+		attributes,
+		nil,
+	)
+	res, err := conn.Search(req)
+	if err != nil {
+		return domain.Account{}, fmt.Errorf("ldap user search: %w", err)
+	}
+	if len(res.Entries) != 1 {
+		return domain.Account{}, ErrUserNotFound
+	}
+	entry := res.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return domain.Account{}, ErrInvalidPassword
+		}
+		return domain.Account{}, fmt.Errorf("ldap user bind: %w", err)
+	}
+	// search groups with service account privileges
+	if err := a.serviceBind(conn); err != nil {
+		return domain.Account{}, fmt.Errorf("ldap service bind: %w", err)
+	}
+	groups, err := a.userGroups(conn, entry.DN)
+	if err != nil {
+		return domain.Account{}, err
+	}
+	return a.syncAccount(entry, groups)
+}
+
+// syncAccount creates or updates local account with data from LDAP entry. Groups are stored
+// separately from the account's profile, which is editable by the user.
+func (a *LDAPAuthenticator) syncAccount(entry *ldap.Entry, groups []string) (domain.Account, error) {
+	username := entry.GetAttributeValue(a.cfg.UsernameAttr)
+	email := ""
+	if a.cfg.EmailAttr != "" {
+		email = strings.ToLower(entry.GetAttributeValue(a.cfg.EmailAttr))
+	}
+	firstName := ""
+	if a.cfg.FirstNameAttr != "" {
+		firstName = entry.GetAttributeValue(a.cfg.FirstNameAttr)
+	}
+	lastName := ""
+	if a.cfg.LastNameAttr != "" {
+		lastName = entry.GetAttributeValue(a.cfg.LastNameAttr)
+	}
+
+	account, err := a.accounts.GetByUsername(username)
+	isNew := errors.Is(err, domain.ErrAccountNotFound)
+	if err != nil && !isNew {
+		return account, err
+	}
+	if isNew {
+		account, err = domain.NewAccount(username, email, firstName, lastName, "")
+		if err != nil {
+			return account, fmt.Errorf("creating account from ldap entry: %w", err)
+		}
+		now := time.Now()
+		account.Active = true
+		account.Confirmed = &now
+		account.Profile = domain.Profile{"auth_source": ldapAuthSource}
+	} else {
+		if _, err := a.ldapAccounts.Get(account.Username); err != nil {
+			if !errors.Is(err, domain.ErrAccountNotFound) {
+				return domain.Account{}, err
+			}
+			if !a.cfg.LinkExisting {
+				a.log.Warnw("ldap: local account with the same username already exists", "username", username)
+				return domain.Account{}, ErrUserNotFound
+			}
+		}
+		if !account.Active {
+			return domain.Account{}, ErrUserNotFound
+		}
+		if email != "" {
+			account.Email = email
+		}
+		if firstName != "" {
+			account.FirstName = firstName
+		}
+		if lastName != "" {
+			account.LastName = lastName
+		}
+		if account.Profile == nil {
+			account.Profile = make(domain.Profile)
+		}
+		account.Profile["auth_source"] = ldapAuthSource
+		// groups were formerly stored in the profile
+		delete(account.Profile, "ldap_groups")
+	}
+	if a.cfg.SuperuserGroup != "" {
+		account.Superuser = domain.StringArray(groups).Has(a.cfg.SuperuserGroup)
+	}
+
+	if isNew {
+		if err := a.accounts.Create(account); err != nil {
+			return account, fmt.Errorf("creating account: %w", err)
+		}
+		a.log.Infow("ldap: created account", "username", username)
+	} else {
+		if err := a.accounts.Update(account); err != nil {
+			return account, fmt.Errorf("updating account: %w", err)
+		}
+		if err := a.accounts.UpdateProfile(account); err != nil {
+			return account, fmt.Errorf("updating account profile: %w", err)
+		}
+	}
+	ldapAccount := domain.LDAPAccount{Username: account.Username, Groups: groups, Synced: time.Now()}
+	if err := a.ldapAccounts.Save(ldapAccount); err != nil {
+		return account, fmt.Errorf("saving ldap groups: %w", err)
+	}
+	return account, nil
+}
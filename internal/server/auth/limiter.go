@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Limited actions
+const (
+	ActionLogin         = "login"
+	ActionPasswordReset = "password_reset"
+	ActionInvitation    = "invitation"
+	ActionTwoFactor     = "2fa"
+)
+
+// LimitRule configures limits of attempts of a single action
+type LimitRule struct {
+	// number of failed attempts for one subject (e.g. username) within Window, which leads to lockout
+	MaxAttempts int
+	// number of failed attempts from one IP address within Window
+	MaxIPAttempts int
+	Window        time.Duration
+	Lockout       time.Duration
+	// delay after the first failed attempt, doubled with each following one (up to MaxDelay)
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func (r LimitRule) delay(failures int64) time.Duration {
+	if r.BaseDelay <= 0 || failures < 1 {
+		return 0
+	}
+	d := r.BaseDelay
+	for i := int64(1); i < failures && (r.MaxDelay <= 0 || d < r.MaxDelay); i++ {
+		d *= 2
+	}
+	if r.MaxDelay > 0 && d > r.MaxDelay {
+		d = r.MaxDelay
+	}
+	return d
+}
+
+// LimitError is returned when the attempt is not allowed
+type LimitError struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("temporarily locked (retry after %s)", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many attempts (retry after %s)", e.RetryAfter.Round(time.Second))
+}
+
+// LockStatus describes limiting state of the subject
+type LockStatus struct {
+	Locked   bool       `json:"locked"`
+	Until    *time.Time `json:"locked_until,omitempty"`
+	Failures int64      `json:"failures"`
+}
+
+// AttemptsLimiter limits failed attempts of actions per subject and per IP address
+type AttemptsLimiter interface {
+	Check(ctx context.Context, action, subject, ip string) error
+	Failure(ctx context.Context, action, subject, ip string) (LockStatus, error)
+	Success(ctx context.Context, action, subject string) error
+	Status(ctx context.Context, action, subject string) (LockStatus, error)
+	Unlock(ctx context.Context, action, subject string) error
+}
+
+type RedisAttemptsLimiter struct {
+	rdb   *redis.Client
+	rules map[string]LimitRule
+}
+
+func NewRedisAttemptsLimiter(rdb *redis.Client, rules map[string]LimitRule) *RedisAttemptsLimiter {
+	return &RedisAttemptsLimiter{rdb: rdb, rules: rules}
+}
+
+func limiterKey(kind, action, subject string) string {
+	return fmt.Sprintf("limit:%s:%s:%s", kind, action, strings.ToLower(subject))
+}
+
+func (l *RedisAttemptsLimiter) Check(ctx context.Context, action, subject, ip string) error {
+	rule, ok := l.rules[action]
+	if !ok {
+		return nil
+	}
+	pipe := l.rdb.Pipeline()
+	lock := pipe.PTTL(ctx, limiterKey("lock", action, subject))
+	next := pipe.PTTL(ctx, limiterKey("next", action, subject))
+	ipFailures := pipe.Get(ctx, limiterKey("fail", action, "ip:"+ip))
+	ipTTL := pipe.PTTL(ctx, limiterKey("fail", action, "ip:"+ip))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return fmt.Errorf("redis check limits: %w", err)
+	}
+	if ttl := lock.Val(); ttl > 0 {
+		return &LimitError{Locked: true, RetryAfter: ttl}
+	}
+	if ttl := next.Val(); ttl > 0 {
+		return &LimitError{RetryAfter: ttl}
+	}
+	if rule.MaxIPAttempts > 0 {
+		if n, _ := ipFailures.Int64(); n >= int64(rule.MaxIPAttempts) {
+			return &LimitError{RetryAfter: ipTTL.Val()}
+		}
+	}
+	return nil
+}
+
+func (l *RedisAttemptsLimiter) incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	n, err := l.rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		if err := l.rdb.Expire(ctx, key, window).Err(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Failure records failed attempt and returns the resulting state
+func (l *RedisAttemptsLimiter) Failure(ctx context.Context, action, subject, ip string) (LockStatus, error) {
+	rule, ok := l.rules[action]
+	if !ok {
+		return LockStatus{}, nil
+	}
+	failKey := limiterKey("fail", action, subject)
+	n, err := l.incr(ctx, failKey, rule.Window)
+	if err != nil {
+		return LockStatus{}, fmt.Errorf("redis record failure: %w", err)
+	}
+	if ip != "" {
+		if _, err := l.incr(ctx, limiterKey("fail", action, "ip:"+ip), rule.Window); err != nil {
+			return LockStatus{}, fmt.Errorf("redis record failure: %w", err)
+		}
+	}
+	status := LockStatus{Failures: n}
+	if rule.MaxAttempts > 0 && n >= int64(rule.MaxAttempts) && rule.Lockout > 0 {
+		pipe := l.rdb.TxPipeline()
+		pipe.Set(ctx, limiterKey("lock", action, subject), n, rule.Lockout)
+		pipe.Del(ctx, failKey, limiterKey("next", action, subject))
+		if _, err := pipe.Exec(ctx); err != nil {
+			return status, fmt.Errorf("redis lock: %w", err)
+		}
+		until := time.Now().Add(rule.Lockout)
+		status.Locked = true
+		status.Until = &until
+		return status, nil
+	}
+	if d := rule.delay(n); d > 0 {
+		if err := l.rdb.Set(ctx, limiterKey("next", action, subject), n, d).Err(); err != nil {
+			return status, fmt.Errorf("redis set delay: %w", err)
+		}
+	}
+	return status, nil
+}
+
+func (l *RedisAttemptsLimiter) Success(ctx context.Context, action, subject string) error {
+	return l.rdb.Del(ctx, limiterKey("fail", action, subject), limiterKey("next", action, subject)).Err()
+}
+
+func (l *RedisAttemptsLimiter) Status(ctx context.Context, action, subject string) (LockStatus, error) {
+	pipe := l.rdb.Pipeline()
+	lock := pipe.PTTL(ctx, limiterKey("lock", action, subject))
+	failures := pipe.Get(ctx, limiterKey("fail", action, subject))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return LockStatus{}, fmt.Errorf("redis get limits status: %w", err)
+	}
+	status := LockStatus{}
+	status.Failures, _ = failures.Int64()
+	if ttl := lock.Val(); ttl > 0 {
+		until := time.Now().Add(ttl)
+		status.Locked = true
+		status.Until = &until
+	}
+	return status, nil
+}
+
+func (l *RedisAttemptsLimiter) Unlock(ctx context.Context, action, subject string) error {
+	return l.rdb.Del(ctx,
+		limiterKey("lock", action, subject),
+		limiterKey("fail", action, subject),
+		limiterKey("next", action, subject),
+	).Err()
+}
+
+// SetAttemptsLimiter enables protection against brute-force attacks
+func (s *AuthService) SetAttemptsLimiter(limiter AttemptsLimiter) {
+	s.attempts = limiter
+}
+
+// loginSubject returns subject of login attempts limiting, i.e. username of the account when
+// the login is email address of existing account, so both logins share the same limit
+func (s *AuthService) loginSubject(login string) string {
+	login = strings.TrimSpace(login)
+	if strings.Contains(login, "@") {
+		account, err := s.accounts.GetByEmail(strings.ToLower(login))
+		if err == nil {
+			return account.Username
+		}
+		if !errors.Is(err, domain.ErrAccountNotFound) {
+			s.logger.Errorw("resolving login", zap.Error(err))
+		}
+	}
+	return strings.ToLower(login)
+}
+
+// AuthenticateRequest verifies credentials like Authenticate, but with limited number
+// of failed attempts per account (login) and per client's IP address
+func (s *AuthService) AuthenticateRequest(c echo.Context, login, password string) (domain.Account, error) {
+	if s.attempts == nil {
+		account, err := s.Authenticate(login, password)
+		if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidPassword) {
+			s.recordFailedLogin(c, login, err)
+		}
+		return account, err
+	}
+	ctx := c.Request().Context()
+	ip := c.RealIP()
+	subject := s.loginSubject(login)
+	if err := s.attempts.Check(ctx, ActionLogin, subject, ip); err != nil {
+		s.logger.Warnw("security: login attempt rejected", "login", login, "ip", ip, "reason", err.Error())
+		s.recordFailedLogin(c, login, err)
+		return domain.Account{}, err
+	}
+	account, err := s.Authenticate(login, password)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidPassword) {
+			status, lerr := s.attempts.Failure(ctx, ActionLogin, subject, ip)
+			if lerr != nil {
+				s.logger.Errorw("recording failed login", zap.Error(lerr))
+			}
+			s.logger.Warnw("security: failed login", "login", login, "ip", ip, "user_agent", c.Request().UserAgent(), "failures", status.Failures)
+			if status.Locked {
+				s.logger.Warnw("security: login locked", "login", login, "ip", ip, "until", status.Until)
+			}
+			s.recordFailedLogin(c, login, err)
+		}
+		return account, err
+	}
+	// with enabled 2FA, failed logins are reset after verification of the second factor
+	if enabled, err := s.TwoFactorEnabled(account.Username); err != nil {
+		s.logger.Errorw("checking 2fa status", zap.Error(err))
+	} else if !enabled {
+		if err := s.attempts.Success(ctx, ActionLogin, account.Username); err != nil {
+			s.logger.Errorw("resetting failed logins", zap.Error(err))
+		}
+	}
+	return account, nil
+}
+
+// CheckPasswordResetLimit counts password reset request and returns LimitError when
+// the limit for the email or client's IP address was reached
+func (s *AuthService) CheckPasswordResetLimit(c echo.Context, email string) error {
+	if s.attempts == nil {
+		return nil
+	}
+	ctx := c.Request().Context()
+	ip := c.RealIP()
+	if err := s.attempts.Check(ctx, ActionPasswordReset, email, ip); err != nil {
+		s.logger.Warnw("security: password reset rejected", "email", email, "ip", ip, "reason", err.Error())
+		return err
+	}
+	if _, err := s.attempts.Failure(ctx, ActionPasswordReset, email, ip); err != nil {
+		s.logger.Errorw("recording password reset request", zap.Error(err))
+	}
+	return nil
+}
+
+// CheckInvitationLimit counts invitation sent by the user and returns LimitError when
+// the limit for the user was reached
+func (s *AuthService) CheckInvitationLimit(c echo.Context, username string) error {
+	if s.attempts == nil {
+		return nil
+	}
+	ctx := c.Request().Context()
+	if err := s.attempts.Check(ctx, ActionInvitation, username, c.RealIP()); err != nil {
+		s.logger.Warnw("security: invitation rejected", "user", username, "reason", err.Error())
+		var limitErr *LimitError
+		if errors.As(err, &limitErr) {
+			// only sending of invitations is limited, not the account
+			limitErr.Locked = false
+		}
+		return err
+	}
+	if _, err := s.attempts.Failure(ctx, ActionInvitation, username, ""); err != nil {
+		s.logger.Errorw("recording invitation", zap.Error(err))
+	}
+	return nil
+}
+
+// LoginLockStatus returns login limiting state of the account (logins by email address
+// are counted under the username)
+func (s *AuthService) LoginLockStatus(ctx context.Context, account domain.Account) (LockStatus, error) {
+	if s.attempts == nil {
+		return LockStatus{}, nil
+	}
+	return s.attempts.Status(ctx, ActionLogin, account.Username)
+}
+
+// UnlockLogin removes lockout and failed attempts of the account
+func (s *AuthService) UnlockLogin(ctx context.Context, account domain.Account) error {
+	if s.attempts == nil {
+		return nil
+	}
+	return s.attempts.Unlock(ctx, ActionLogin, account.Username)
+}
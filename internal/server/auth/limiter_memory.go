@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// number of counters, after which expired ones are purged when adding a new one
+const maxMemoryCounters = 10000
+
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+// MemoryAttemptsLimiter is an in-process AttemptsLimiter for deployments without Redis
+type MemoryAttemptsLimiter struct {
+	rules    map[string]LimitRule
+	mu       sync.Mutex
+	counters map[string]memoryCounter
+}
+
+func NewMemoryAttemptsLimiter(rules map[string]LimitRule) *MemoryAttemptsLimiter {
+	return &MemoryAttemptsLimiter{rules: rules, counters: make(map[string]memoryCounter)}
+}
+
+// get returns counter and its remaining time to live (must be called with locked mutex)
+func (l *MemoryAttemptsLimiter) get(key string, now time.Time) (int64, time.Duration) {
+	c, ok := l.counters[key]
+	if !ok {
+		return 0, 0
+	}
+	if !now.Before(c.expires) {
+		delete(l.counters, key)
+		return 0, 0
+	}
+	return c.value, c.expires.Sub(now)
+}
+
+func (l *MemoryAttemptsLimiter) incr(key string, now time.Time, window time.Duration) int64 {
+	c, ok := l.counters[key]
+	if !ok || !now.Before(c.expires) {
+		if len(l.counters) >= maxMemoryCounters {
+			l.purge(now)
+		}
+		c = memoryCounter{expires: now.Add(window)}
+	}
+	c.value++
+	l.counters[key] = c
+	return c.value
+}
+
+// purge removes expired counters (must be called with locked mutex)
+func (l *MemoryAttemptsLimiter) purge(now time.Time) {
+	for key, c := range l.counters {
+		if !now.Before(c.expires) {
+			delete(l.counters, key)
+		}
+	}
+}
+
+func (l *MemoryAttemptsLimiter) Check(ctx context.Context, action, subject, ip string) error {
+	rule, ok := l.rules[action]
+	if !ok {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if _, ttl := l.get(limiterKey("lock", action, subject), now); ttl > 0 {
+		return &LimitError{Locked: true, RetryAfter: ttl}
+	}
+	if _, ttl := l.get(limiterKey("next", action, subject), now); ttl > 0 {
+		return &LimitError{RetryAfter: ttl}
+	}
+	if rule.MaxIPAttempts > 0 {
+		if n, ttl := l.get(limiterKey("fail", action, "ip:"+ip), now); n >= int64(rule.MaxIPAttempts) {
+			return &LimitError{RetryAfter: ttl}
+		}
+	}
+	return nil
+}
+
+func (l *MemoryAttemptsLimiter) Failure(ctx context.Context, action, subject, ip string) (LockStatus, error) {
+	rule, ok := l.rules[action]
+	if !ok {
+		return LockStatus{}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	failKey := limiterKey("fail", action, subject)
+	n := l.incr(failKey, now, rule.Window)
+	if ip != "" {
+		l.incr(limiterKey("fail", action, "ip:"+ip), now, rule.Window)
+	}
+	status := LockStatus{Failures: n}
+	if rule.MaxAttempts > 0 && n >= int64(rule.MaxAttempts) && rule.Lockout > 0 {
+		until := now.Add(rule.Lockout)
+		l.counters[limiterKey("lock", action, subject)] = memoryCounter{value: n, expires: until}
+		delete(l.counters, failKey)
+		delete(l.counters, limiterKey("next", action, subject))
+		status.Locked = true
+		status.Until = &until
+		return status, nil
+	}
+	if d := rule.delay(n); d > 0 {
+		l.counters[limiterKey("next", action, subject)] = memoryCounter{value: n, expires: now.Add(d)}
+	}
+	return status, nil
+}
+
+func (l *MemoryAttemptsLimiter) Success(ctx context.Context, action, subject string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.counters, limiterKey("fail", action, subject))
+	delete(l.counters, limiterKey("next", action, subject))
+	return nil
+}
+
+func (l *MemoryAttemptsLimiter) Status(ctx context.Context, action, subject string) (LockStatus, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	status := LockStatus{}
+	status.Failures, _ = l.get(limiterKey("fail", action, subject), now)
+	if _, ttl := l.get(limiterKey("lock", action, subject), now); ttl > 0 {
+		until := now.Add(ttl)
+		status.Locked = true
+		status.Until = &until
+	}
+	return status, nil
+}
+
+func (l *MemoryAttemptsLimiter) Unlock(ctx context.Context, action, subject string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.counters, limiterKey("lock", action, subject))
+	delete(l.counters, limiterKey("fail", action, subject))
+	delete(l.counters, limiterKey("next", action, subject))
+	return nil
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+func TestLoginLimitSharedByUsernameAndEmail(t *testing.T) {
+	accounts := &accountsRepo{accounts: map[string]domain.Account{
+		"jan": {Username: "jan", Email: "jan@example.com", Active: true},
+	}}
+	s := NewAuthService(zap.NewNop().Sugar(), time.Hour, accounts, NewMemoryStore())
+	s.SetAttemptsLimiter(NewMemoryAttemptsLimiter(map[string]LimitRule{
+		ActionLogin: {MaxAttempts: 2, Window: time.Hour, Lockout: time.Hour},
+	}))
+	for _, login := range []string{"jan", "jan@example.com"} {
+		if _, err := s.AuthenticateRequest(sessionContext(""), login, "wrong"); !errors.Is(err, ErrInvalidPassword) {
+			t.Fatalf("login %q: expected ErrInvalidPassword, got %v", login, err)
+		}
+	}
+	var limitErr *LimitError
+	if _, err := s.AuthenticateRequest(sessionContext(""), "jan", "wrong"); !errors.As(err, &limitErr) || !limitErr.Locked {
+		t.Errorf("expected locked account, got %v", err)
+	}
+}
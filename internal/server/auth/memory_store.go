@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryItem struct {
+	value   string
+	expires time.Time
+}
+
+func (i memoryItem) expired(now time.Time) bool {
+	return !i.expires.IsZero() && !now.Before(i.expires)
+}
+
+type memoryIndex struct {
+	members map[string]struct{}
+	expires time.Time
+}
+
+func (i *memoryIndex) expired(now time.Time) bool {
+	return !i.expires.IsZero() && !now.Before(i.expires)
+}
+
+// MemoryStore is an in-process SessionStore for single instance deployments without Redis.
+// Sessions are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	items   map[string]memoryItem
+	indexes map[string]*memoryIndex
+	stop    chan struct{}
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items:   make(map[string]memoryItem),
+		indexes: make(map[string]*memoryIndex),
+	}
+}
+
+func expiresAt(now time.Time, expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return now.Add(expiration)
+}
+
+func (s *MemoryStore) Set(ctx context.Context, sessionID, data string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[sessionID] = memoryItem{value: data, expires: expiresAt(time.Now(), expiration)}
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, sessionID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[sessionID]
+	if !ok {
+		return "", ErrInvalidSession
+	}
+	if item.expired(time.Now()) {
+		delete(s.items, sessionID)
+		return "", ErrInvalidSession
+	}
+	return item.value, nil
+}
+
+func (s *MemoryStore) Del(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, sessionID)
+	return nil
+}
+
+func (s *MemoryStore) AddToIndex(ctx context.Context, index, sessionID string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.indexes[index]
+	if !ok || idx.expired(time.Now()) {
+		idx = &memoryIndex{members: make(map[string]struct{})}
+		s.indexes[index] = idx
+	}
+	idx.members[sessionID] = struct{}{}
+	idx.expires = expiresAt(time.Now(), expiration)
+	return nil
+}
+
+func (s *MemoryStore) RemoveFromIndex(ctx context.Context, index string, sessionIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idx, ok := s.indexes[index]; ok {
+		for _, id := range sessionIDs {
+			delete(idx.members, id)
+		}
+		if len(idx.members) == 0 {
+			delete(s.indexes, index)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) IndexMembers(ctx context.Context, index string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.indexes[index]
+	if !ok {
+		return []string{}, nil
+	}
+	if idx.expired(time.Now()) {
+		delete(s.indexes, index)
+		return []string{}, nil
+	}
+	members := make([]string, 0, len(idx.members))
+	for id := range idx.members {
+		members = append(members, id)
+	}
+	return members, nil
+}
+
+// purge removes expired items
+func (s *MemoryStore) purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, item := range s.items {
+		if item.expired(now) {
+			delete(s.items, key)
+		}
+	}
+	for key, idx := range s.indexes {
+		if idx.expired(now) {
+			delete(s.indexes, key)
+		}
+	}
+}
+
+// StartCleanup periodically removes expired items until Stop is called
+func (s *MemoryStore) StartCleanup(interval time.Duration) {
+	s.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.purge()
+			}
+		}
+	}()
+}
+
+func (s *MemoryStore) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+	}
+}
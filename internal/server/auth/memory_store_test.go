@@ -0,0 +1,14 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/gisquick/gisquick-server/internal/server/auth"
+	"github.com/gisquick/gisquick-server/internal/server/auth/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.RunSessionStoreTests(t, func(t *testing.T) auth.SessionStore {
+		return auth.NewMemoryStore()
+	})
+}
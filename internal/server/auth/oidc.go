@@ -0,0 +1,730 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidIDToken   = errors.New("invalid ID token")
+	ErrInvalidOIDCState = errors.New("invalid or expired OIDC login state")
+	ErrOIDCClaims       = errors.New("missing or invalid OIDC claims")
+	ErrAccountDisabled  = errors.New("account is disabled")
+	// local account with the same username or email exists, but it's not linked with the identity
+	ErrOIDCAccountExists = errors.New("account already exists")
+)
+
+const (
+	OIDCStateExpiration = 10 * time.Minute
+	clockSkew           = time.Minute
+)
+
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// claims mapping
+	UsernameClaim  string
+	EmailClaim     string
+	FirstNameClaim string
+	LastNameClaim  string
+	GroupsClaim    string
+	// members of this group (from GroupsClaim) are superusers, mapping is disabled when empty
+	SuperuserGroup string
+	// claim name -> profile key
+	ProfileClaims map[string]string
+	// frontend page finishing login of users with two-factor authentication
+	// (receives 'token' and 'next' query parameters)
+	TwoFactorURL string
+}
+
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// OIDCProvider is a minimal OpenID Connect relying party client (authorization code flow with PKCE)
+type OIDCProvider struct {
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu       sync.RWMutex
+	metadata *providerMetadata
+	keys     map[string]crypto.PublicKey
+	keysTime time.Time
+}
+
+func NewOIDCProvider(cfg OIDCConfig) *OIDCProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "preferred_username"
+	}
+	if cfg.TwoFactorURL == "" {
+		cfg.TwoFactorURL = "/login"
+	}
+	return &OIDCProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, data interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status code %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(data)
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*providerMetadata, error) {
+	p.mu.RLock()
+	metadata := p.metadata
+	p.mu.RUnlock()
+	if metadata != nil {
+		return metadata, nil
+	}
+	metadata = new(providerMetadata)
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, metadata); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch '%s'", metadata.Issuer)
+	}
+	p.mu.Lock()
+	p.metadata = metadata
+	p.mu.Unlock()
+	return metadata, nil
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func parseJWK(k jsonWebKey) (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+}
+
+func (p *OIDCProvider) fetchKeys(ctx context.Context) error {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, metadata.JwksURI, &jwks); err != nil {
+		return fmt.Errorf("oidc fetching keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(k)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.keysTime = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *OIDCProvider) getKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.RLock()
+	key, ok := p.keys[kid]
+	fresh := time.Since(p.keysTime) < time.Minute
+	p.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	// unknown key, provider may have rotated keys (limit refreshing to once per minute)
+	if !fresh {
+		if err := p.fetchKeys(ctx); err != nil {
+			return nil, err
+		}
+		p.mu.RLock()
+		key, ok = p.keys[kid]
+		p.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown signing key '%s'", ErrInvalidIDToken, kid)
+}
+
+// NewPKCEVerifier generates random code verifier
+func NewPKCEVerifier() (string, error) {
+	return randomString(32)
+}
+
+func PKCEChallenge(verifier string) string {
+	h := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", PKCEChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange exchanges authorization code for tokens and returns raw ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens); err != nil {
+		return "", fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return "", fmt.Errorf("oidc token request failed: %s %s", tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("%w: missing in token response", ErrInvalidIDToken)
+	}
+	return tokens.IDToken, nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	h := sha256.Sum256(signed)
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key type mismatch", ErrInvalidIDToken)
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, h[:], signature); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+		}
+		return nil
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidIDToken)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, h[:], r, s) {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidIDToken)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported algorithm '%s'", ErrInvalidIDToken, alg)
+}
+
+func numericDate(claims map[string]interface{}, name string) (time.Time, bool) {
+	v, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
+
+// VerifyIDToken validates signature and standard claims of ID token and returns its claims
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidIDToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	headerData, err := decodeBase64URL(parts[0])
+	if err != nil || json.Unmarshal(headerData, &header) != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidIDToken)
+	}
+	signature, err := decodeBase64URL(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidIDToken)
+	}
+	key, err := p.getKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+	payload, err := decodeBase64URL(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed payload", ErrInvalidIDToken)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed payload", ErrInvalidIDToken)
+	}
+
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != metadata.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch", ErrInvalidIDToken)
+	}
+	validAudience := false
+	switch aud := claims["aud"].(type) {
+	case string:
+		validAudience = aud == p.cfg.ClientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == p.cfg.ClientID {
+				validAudience = true
+			}
+		}
+	}
+	if !validAudience {
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidIDToken)
+	}
+	now := time.Now()
+	exp, ok := numericDate(claims, "exp")
+	if !ok || now.After(exp.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidIDToken)
+	}
+	if nbf, ok := numericDate(claims, "nbf"); ok && now.Before(nbf.Add(-clockSkew)) {
+		return nil, fmt.Errorf("%w: token not valid yet", ErrInvalidIDToken)
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+type oidcLoginState struct {
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	Next     string `json:"next"`
+	// logged in user, who links the identity with own account
+	Link string `json:"link,omitempty"`
+}
+
+// OIDCLogin is a result of completed authorization with OpenID Connect provider
+type OIDCLogin struct {
+	Account domain.Account
+	// redirect path stored at the start of the login
+	Next string
+	// identity was linked with account of already logged in user
+	Linked bool
+}
+
+// OIDCService implements login flow with OpenID Connect provider and just-in-time provisioning
+// of local accounts. Accounts are bound to provider's identities (issuer and subject claims),
+// existing local accounts can be only linked explicitly by their logged in users.
+type OIDCService struct {
+	log        *zap.SugaredLogger
+	provider   *OIDCProvider
+	cfg        OIDCConfig
+	store      SessionStore
+	accounts   domain.AccountsRepository
+	identities domain.OIDCIdentityRepository
+}
+
+func NewOIDCService(log *zap.SugaredLogger, cfg OIDCConfig, store SessionStore, accounts domain.AccountsRepository, identities domain.OIDCIdentityRepository) *OIDCService {
+	provider := NewOIDCProvider(cfg)
+	return &OIDCService{
+		log:        log,
+		provider:   provider,
+		cfg:        provider.cfg,
+		store:      store,
+		accounts:   accounts,
+		identities: identities,
+	}
+}
+
+// StartLogin creates login state and returns URL of the provider's authorization endpoint and
+// the state value, which must be kept by the browser (cookie) and passed into FinishLogin.
+// When linkUsername is set, the identity is linked with this (logged in) account instead of login.
+func (s *OIDCService) StartLogin(ctx context.Context, next, linkUsername string) (string, string, error) {
+	state, err := randomString(24)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString(24)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := NewPKCEVerifier()
+	if err != nil {
+		return "", "", err
+	}
+	data, err := json.Marshal(oidcLoginState{Verifier: verifier, Nonce: nonce, Next: next, Link: linkUsername})
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.store.Set(ctx, "oidc:"+state, string(data), OIDCStateExpiration); err != nil {
+		return "", "", fmt.Errorf("saving oidc login state: %w", err)
+	}
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, verifier)
+	return authURL, state, err
+}
+
+// FinishLogin completes authorization code flow. browserState is the state value kept by the browser
+// since the start of the login (protection against login CSRF), username is the currently logged in user.
+func (s *OIDCService) FinishLogin(ctx context.Context, state, browserState, code, username string) (OIDCLogin, error) {
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return OIDCLogin{}, ErrInvalidOIDCState
+	}
+	key := "oidc:" + state
+	data, err := s.store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSession) {
+			return OIDCLogin{}, ErrInvalidOIDCState
+		}
+		return OIDCLogin{}, err
+	}
+	if err := s.store.Del(ctx, key); err != nil {
+		s.log.Errorw("deleting oidc login state", zap.Error(err))
+	}
+	var loginState oidcLoginState
+	if err := json.Unmarshal([]byte(data), &loginState); err != nil {
+		return OIDCLogin{}, ErrInvalidOIDCState
+	}
+	if loginState.Link != "" && loginState.Link != username {
+		return OIDCLogin{}, ErrInvalidOIDCState
+	}
+	rawToken, err := s.provider.Exchange(ctx, code, loginState.Verifier)
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+	claims, err := s.provider.VerifyIDToken(ctx, rawToken, loginState.Nonce)
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+	login := OIDCLogin{Next: loginState.Next, Linked: loginState.Link != ""}
+	if login.Linked {
+		login.Account, err = s.linkIdentity(claims, loginState.Link)
+	} else {
+		login.Account, err = s.provisionAccount(claims)
+	}
+	return login, err
+}
+
+// TwoFactorURL returns URL of the frontend page, where the login of user with two-factor
+// authentication is finished
+func (s *OIDCService) TwoFactorURL(token, next string) string {
+	q := url.Values{}
+	q.Set("token", token)
+	q.Set("next", next)
+	return s.cfg.TwoFactorURL + "?" + q.Encode()
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	if name == "" {
+		return ""
+	}
+	v, _ := claims[name].(string)
+	return strings.TrimSpace(v)
+}
+
+func claimStrings(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// identityClaims returns identity of the user from ID token claims
+func identityClaims(claims map[string]interface{}) (domain.OIDCIdentity, error) {
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	if issuer == "" || subject == "" {
+		return domain.OIDCIdentity{}, fmt.Errorf("%w: 'iss' and 'sub' claims are required", ErrOIDCClaims)
+	}
+	return domain.OIDCIdentity{Issuer: issuer, Subject: subject, Created: time.Now()}, nil
+}
+
+// linkIdentity links identity from ID token claims with account of logged in user
+func (s *OIDCService) linkIdentity(claims map[string]interface{}, username string) (domain.Account, error) {
+	identity, err := identityClaims(claims)
+	if err != nil {
+		return domain.Account{}, err
+	}
+	account, err := s.accounts.GetByUsername(username)
+	if err != nil {
+		return account, err
+	}
+	if !account.Active {
+		return account, ErrAccountDisabled
+	}
+	linked, err := s.identities.Get(identity.Issuer, identity.Subject)
+	if err == nil {
+		if linked.Username != username {
+			return account, domain.ErrIdentityLinked
+		}
+		return account, nil
+	}
+	if !errors.Is(err, domain.ErrIdentityNotFound) {
+		return account, err
+	}
+	identity.Username = username
+	if err := s.identities.Create(identity); err != nil {
+		return account, fmt.Errorf("linking identity: %w", err)
+	}
+	s.log.Infow("oidc: linked identity", "username", username, "subject", identity.Subject)
+	return account, nil
+}
+
+// claimEmail returns verified email address from ID token claims, which is not used by another account
+func (s *OIDCService) claimEmail(username string, claims map[string]interface{}) (string, error) {
+	email := strings.ToLower(claimString(claims, s.cfg.EmailClaim))
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return "", nil
+	}
+	if email == "" {
+		return "", nil
+	}
+	other, err := s.accounts.GetByEmail(email)
+	if err == nil {
+		if other.Username != username {
+			s.log.Warnw("oidc: email address is used by another account", "username", username, "email", email)
+			return "", nil
+		}
+		return email, nil
+	}
+	if !errors.Is(err, domain.ErrAccountNotFound) {
+		return "", fmt.Errorf("checking email address: %w", err)
+	}
+	return email, nil
+}
+
+// applyClaims updates account's attributes from ID token claims. Email address is updated only
+// when it's not used by another account.
+func (s *OIDCService) applyClaims(account *domain.Account, claims map[string]interface{}) error {
+	email, err := s.claimEmail(account.Username, claims)
+	if err != nil {
+		return err
+	}
+	if email != "" {
+		account.Email = email
+	}
+	if firstName := claimString(claims, s.cfg.FirstNameClaim); firstName != "" {
+		account.FirstName = firstName
+	}
+	if lastName := claimString(claims, s.cfg.LastNameClaim); lastName != "" {
+		account.LastName = lastName
+	}
+	if s.cfg.SuperuserGroup != "" && s.cfg.GroupsClaim != "" {
+		account.Superuser = domain.StringArray(claimStrings(claims, s.cfg.GroupsClaim)).Has(s.cfg.SuperuserGroup)
+	}
+	for claim, key := range s.cfg.ProfileClaims {
+		if v, ok := claims[claim]; ok {
+			if account.Profile == nil {
+				account.Profile = make(domain.Profile)
+			}
+			account.Profile[key] = v
+		}
+	}
+	return nil
+}
+
+// provisionAccount returns account bound to the identity from ID token claims (updated from
+// the claims), or creates a new account for unknown identity
+func (s *OIDCService) provisionAccount(claims map[string]interface{}) (domain.Account, error) {
+	identity, err := identityClaims(claims)
+	if err != nil {
+		return domain.Account{}, err
+	}
+	linked, err := s.identities.Get(identity.Issuer, identity.Subject)
+	if err == nil {
+		account, err := s.accounts.GetByUsername(linked.Username)
+		if err != nil {
+			return account, err
+		}
+		if !account.Active {
+			return account, ErrAccountDisabled
+		}
+		email := account.Email
+		if err := s.applyClaims(&account, claims); err != nil {
+			return account, err
+		}
+		err = s.accounts.Update(account)
+		if errors.Is(err, domain.ErrEmailExists) {
+			// email address was taken concurrently
+			account.Email = email
+			err = s.accounts.Update(account)
+		}
+		if err != nil {
+			return account, fmt.Errorf("updating account: %w", err)
+		}
+		if len(s.cfg.ProfileClaims) > 0 && account.Profile != nil {
+			if err := s.accounts.UpdateProfile(account); err != nil {
+				return account, fmt.Errorf("updating account profile: %w", err)
+			}
+		}
+		return account, nil
+	}
+	if !errors.Is(err, domain.ErrIdentityNotFound) {
+		return domain.Account{}, err
+	}
+
+	username := claimString(claims, s.cfg.UsernameClaim)
+	if username == "" {
+		return domain.Account{}, fmt.Errorf("%w: '%s' claim is required", ErrOIDCClaims, s.cfg.UsernameClaim)
+	}
+	// existing accounts are never taken over by unknown identity, they must be linked by their users
+	if _, err := s.accounts.GetByUsername(username); err == nil {
+		return domain.Account{}, ErrOIDCAccountExists
+	} else if !errors.Is(err, domain.ErrAccountNotFound) {
+		return domain.Account{}, err
+	}
+	account, err := domain.NewAccount(username, "", "", "", "")
+	if err != nil {
+		return account, fmt.Errorf("%w: %v", ErrOIDCClaims, err)
+	}
+	now := time.Now()
+	account.Active = true
+	account.Confirmed = &now
+	if err := s.applyClaims(&account, claims); err != nil {
+		return account, err
+	}
+	if err := s.accounts.Create(account); err != nil {
+		if errors.Is(err, domain.ErrAccountExists) || errors.Is(err, domain.ErrEmailExists) {
+			return account, ErrOIDCAccountExists
+		}
+		return account, fmt.Errorf("creating account: %w", err)
+	}
+	identity.Username = account.Username
+	if err := s.identities.Create(identity); err != nil {
+		// account without identity would block following logins
+		if derr := s.accounts.Delete(account.Username); derr != nil {
+			s.log.Errorw("oidc: deleting account without identity", "username", username, zap.Error(derr))
+		}
+		return account, fmt.Errorf("saving identity: %w", err)
+	}
+	s.log.Infow("oidc: created account", "username", username)
+	return account, nil
+}
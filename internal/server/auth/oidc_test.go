@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/mock"
+	"go.uber.org/zap"
+)
+
+type accountsRepo struct {
+	domain.AccountsRepository
+	mu       sync.Mutex
+	accounts map[string]domain.Account
+}
+
+func (r *accountsRepo) GetByUsername(username string) (domain.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[username]
+	if !ok {
+		return account, domain.ErrAccountNotFound
+	}
+	return account, nil
+}
+
+func (r *accountsRepo) GetByEmail(email string) (domain.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, account := range r.accounts {
+		if email != "" && account.Email == email {
+			return account, nil
+		}
+	}
+	return domain.Account{}, domain.ErrAccountNotFound
+}
+
+func (r *accountsRepo) Delete(username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.accounts, username)
+	return nil
+}
+
+func (r *accountsRepo) Create(account domain.Account) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.accounts[account.Username]; ok {
+		return domain.ErrAccountExists
+	}
+	r.accounts[account.Username] = account
+	return nil
+}
+
+func (r *accountsRepo) Update(account domain.Account) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accounts[account.Username] = account
+	return nil
+}
+
+func (r *accountsRepo) UpdateProfile(account domain.Account) error {
+	return r.Update(account)
+}
+
+type identitiesRepo struct {
+	mu         sync.Mutex
+	identities map[string]domain.OIDCIdentity
+	createErr  error
+}
+
+func (r *identitiesRepo) Get(issuer, subject string) (domain.OIDCIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity, ok := r.identities[issuer+" "+subject]
+	if !ok {
+		return identity, domain.ErrIdentityNotFound
+	}
+	return identity, nil
+}
+
+func (r *identitiesRepo) Create(identity domain.OIDCIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.createErr != nil {
+		return r.createErr
+	}
+	key := identity.Issuer + " " + identity.Subject
+	if _, ok := r.identities[key]; ok {
+		return domain.ErrIdentityLinked
+	}
+	r.identities[key] = identity
+	return nil
+}
+
+type oidcTest struct {
+	provider   *mock.OIDCProvider
+	service    *OIDCService
+	accounts   *accountsRepo
+	identities *identitiesRepo
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	provider, err := mock.NewOIDCProvider("gisquick", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(provider.Close)
+	accounts := &accountsRepo{accounts: make(map[string]domain.Account)}
+	identities := &identitiesRepo{identities: make(map[string]domain.OIDCIdentity)}
+	cfg := OIDCConfig{
+		Issuer:      provider.URL,
+		ClientID:    "gisquick",
+		RedirectURL: "http://localhost/api/auth/oidc/callback",
+		EmailClaim:  "email",
+	}
+	service := NewOIDCService(zap.NewNop().Sugar(), cfg, NewMemoryStore(), accounts, identities)
+	return &oidcTest{provider: provider, service: service, accounts: accounts, identities: identities}
+}
+
+// authorize starts login and follows the redirect to the provider, returns the state
+// and authorization code from the callback URL
+func (o *oidcTest) authorize(t *testing.T, link string) (string, string) {
+	authURL, state, err := o.service.StartLogin(context.Background(), "/next", link)
+	if err != nil {
+		t.Fatalf("start login: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("invalid authorization response: %d %v", resp.StatusCode, err)
+	}
+	if callback.Query().Get("state") != state {
+		t.Fatalf("state = %q, want %q", callback.Query().Get("state"), state)
+	}
+	return state, callback.Query().Get("code")
+}
+
+func (o *oidcTest) login(t *testing.T, username string) (OIDCLogin, error) {
+	state, code := o.authorize(t, "")
+	return o.service.FinishLogin(context.Background(), state, state, code, username)
+}
+
+func TestOIDCLoginCreatesAccount(t *testing.T) {
+	o := newOIDCTest(t)
+	o.provider.Claims = map[string]interface{}{"sub": "id-1", "preferred_username": "jan", "email": "Jan@example.com"}
+
+	login, err := o.login(t, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if login.Account.Username != "jan" || login.Account.Email != "jan@example.com" || login.Next != "/next" || login.Linked {
+		t.Errorf("unexpected login result: %+v", login)
+	}
+	identity, err := o.identities.Get(o.provider.URL, "id-1")
+	if err != nil || identity.Username != "jan" {
+		t.Errorf("identity was not saved: %+v (%v)", identity, err)
+	}
+
+	// next login with the same identity, even with changed username claim
+	o.provider.Claims["preferred_username"] = "other"
+	login, err = o.login(t, "")
+	if err != nil || login.Account.Username != "jan" {
+		t.Errorf("expected login of linked account, got %+v (%v)", login.Account, err)
+	}
+}
+
+func TestOIDCLoginExistingAccount(t *testing.T) {
+	o := newOIDCTest(t)
+	o.accounts.accounts["admin"] = domain.Account{Username: "admin", Active: true, Superuser: true}
+	o.provider.Claims = map[string]interface{}{"sub": "attacker", "preferred_username": "admin"}
+
+	if _, err := o.login(t, ""); !errors.Is(err, ErrOIDCAccountExists) {
+		t.Fatalf("expected ErrOIDCAccountExists, got %v", err)
+	}
+	if _, err := o.identities.Get(o.provider.URL, "attacker"); !errors.Is(err, domain.ErrIdentityNotFound) {
+		t.Errorf("identity must not be linked, got %v", err)
+	}
+}
+
+func TestOIDCLinkIdentity(t *testing.T) {
+	o := newOIDCTest(t)
+	o.accounts.accounts["jan"] = domain.Account{Username: "jan", Active: true}
+	o.accounts.accounts["eva"] = domain.Account{Username: "eva", Active: true}
+	o.provider.Claims = map[string]interface{}{"sub": "id-1", "preferred_username": "someone"}
+
+	// link started by another user
+	state, code := o.authorize(t, "jan")
+	if _, err := o.service.FinishLogin(context.Background(), state, state, code, "eva"); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("expected ErrInvalidOIDCState, got %v", err)
+	}
+
+	state, code = o.authorize(t, "jan")
+	login, err := o.service.FinishLogin(context.Background(), state, state, code, "jan")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !login.Linked || login.Account.Username != "jan" {
+		t.Errorf("unexpected link result: %+v", login)
+	}
+
+	// identity linked with another account
+	state, code = o.authorize(t, "eva")
+	if _, err := o.service.FinishLogin(context.Background(), state, state, code, "eva"); !errors.Is(err, domain.ErrIdentityLinked) {
+		t.Fatalf("expected ErrIdentityLinked, got %v", err)
+	}
+
+	login, err = o.login(t, "")
+	if err != nil || login.Account.Username != "jan" {
+		t.Errorf("expected login of linked account, got %+v (%v)", login.Account, err)
+	}
+}
+
+func TestOIDCLoginState(t *testing.T) {
+	o := newOIDCTest(t)
+	o.provider.Claims = map[string]interface{}{"sub": "id-1", "preferred_username": "jan"}
+
+	state, code := o.authorize(t, "")
+	// state not bound to the browser (login CSRF)
+	if _, err := o.service.FinishLogin(context.Background(), state, "", code, ""); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("expected ErrInvalidOIDCState without browser state, got %v", err)
+	}
+	other, _ := o.authorize(t, "")
+	if _, err := o.service.FinishLogin(context.Background(), state, other, code, ""); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("expected ErrInvalidOIDCState with different browser state, got %v", err)
+	}
+
+	state, code = o.authorize(t, "")
+	if _, err := o.service.FinishLogin(context.Background(), state, state, code, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// state can be used only once
+	if _, err := o.service.FinishLogin(context.Background(), state, state, code, ""); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("expected ErrInvalidOIDCState on reused state, got %v", err)
+	}
+}
+
+func TestOIDCLoginIdentityFailure(t *testing.T) {
+	o := newOIDCTest(t)
+	o.provider.Claims = map[string]interface{}{"sub": "id-1", "preferred_username": "jan"}
+	o.identities.createErr = errors.New("write error")
+
+	if _, err := o.login(t, ""); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, ok := o.accounts.accounts["jan"]; ok {
+		t.Fatal("account without identity must be deleted")
+	}
+	o.identities.createErr = nil
+	if login, err := o.login(t, ""); err != nil || login.Account.Username != "jan" {
+		t.Errorf("expected login after failure, got %+v (%v)", login.Account, err)
+	}
+}
+
+func TestOIDCLoginEmailConflict(t *testing.T) {
+	o := newOIDCTest(t)
+	o.accounts.accounts["eva"] = domain.Account{Username: "eva", Email: "eva@example.com", Active: true}
+	o.provider.Claims = map[string]interface{}{"sub": "id-1", "preferred_username": "jan", "email": "jan@example.com"}
+	if _, err := o.login(t, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// email used by another account is not taken over
+	o.provider.Claims["email"] = "eva@example.com"
+	login, err := o.login(t, "")
+	if err != nil || login.Account.Email != "jan@example.com" {
+		t.Errorf("expected unchanged email, got %+v (%v)", login.Account, err)
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jellydator/ttlcache/v3"
+	"go.uber.org/zap"
+)
+
+func newMembershipsCache() *ttlcache.Cache[string, domain.Memberships] {
+	return ttlcache.New(
+		ttlcache.WithTTL[string, domain.Memberships](45*time.Second),
+		ttlcache.WithDisableTouchOnHit[string, domain.Memberships](),
+	)
+}
+
+// SetOrganizationsRepository enables resolving of organizations and teams memberships of users
+func (s *AuthService) SetOrganizationsRepository(repo domain.OrganizationsRepository) {
+	s.organizations = repo
+	if s.membershipsCache == nil {
+		s.membershipsCache = newMembershipsCache()
+	}
+}
+
+// SetGroupsRepository enables resolving of user groups memberships
+func (s *AuthService) SetGroupsRepository(repo domain.GroupsRepository) {
+	s.groups = repo
+	if s.membershipsCache == nil {
+		s.membershipsCache = newMembershipsCache()
+	}
+}
+
+// SetLDAPAccountsRepository enables resolving of LDAP groups memberships
+func (s *AuthService) SetLDAPAccountsRepository(repo domain.LDAPAccountsRepository) {
+	s.ldapAccounts = repo
+	if s.membershipsCache == nil {
+		s.membershipsCache = newMembershipsCache()
+	}
+}
+
+func (s *AuthService) loadMemberships(username string) (domain.Memberships, error) {
+	var memberships domain.Memberships
+	if s.organizations != nil {
+		var err error
+		memberships, err = s.organizations.GetUserMemberships(username)
+		if err != nil {
+			return memberships, fmt.Errorf("getting organizations: %w", err)
+		}
+	}
+	if s.groups != nil {
+		groups, err := s.groups.GetUserGroups(username)
+		if err != nil {
+			return memberships, fmt.Errorf("getting user groups: %w", err)
+		}
+		memberships.Groups = groups
+	}
+	if s.ldapAccounts != nil {
+		ldapAccount, err := s.ldapAccounts.Get(username)
+		if err != nil && !errors.Is(err, domain.ErrAccountNotFound) {
+			return memberships, fmt.Errorf("getting ldap groups: %w", err)
+		}
+		memberships.LDAPGroups = ldapAccount.Groups
+	}
+	return memberships, nil
+}
+
+// withMemberships returns user with resolved memberships in organizations, teams and user groups
+func (s *AuthService) withMemberships(user domain.User) domain.User {
+	if s.membershipsCache == nil || !user.IsAuthenticated {
+		return user
+	}
+	var memberships domain.Memberships
+	if item := s.membershipsCache.Get(user.Username); item != nil {
+		memberships = item.Value()
+	} else {
+		var err error
+		memberships, err = s.loadMemberships(user.Username)
+		if err != nil {
+			s.logger.Errorw("getting user memberships", "username", user.Username, zap.Error(err))
+			return user
+		}
+		s.membershipsCache.Set(user.Username, memberships, ttlcache.DefaultTTL)
+	}
+	user.Organizations = memberships.Organizations
+	user.Teams = memberships.Teams
+	user.Groups = memberships.Groups
+	user.LDAPGroups = memberships.LDAPGroups
+	return user
+}
+
+// AccountUser returns user of the account with resolved memberships
+func (s *AuthService) AccountUser(account domain.Account) domain.User {
+	return s.withMemberships(domain.AccountToUser(account))
+}
+
+// InvalidateMemberships removes cached memberships of the users
+func (s *AuthService) InvalidateMemberships(usernames ...string) {
+	if s.membershipsCache == nil {
+		return
+	}
+	for _, username := range usernames {
+		s.membershipsCache.Delete(username)
+	}
+}
@@ -0,0 +1,17 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+)
+
+// SetPasswordPolicy sets password policy used to check expiration of passwords on login
+func (s *AuthService) SetPasswordPolicy(policy domain.PasswordPolicy) {
+	s.passwordPolicy = policy
+}
+
+// PasswordExpired checks whether the password of the account expired and must be changed before login
+func (s *AuthService) PasswordExpired(account domain.Account) bool {
+	return s.passwordPolicy.PasswordExpired(account, time.Now())
+}
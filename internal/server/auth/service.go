@@ -37,6 +37,10 @@ type SessionStore interface {
 	Set(ctx context.Context, sessionID, data string, expiration time.Duration) error
 	Get(ctx context.Context, sessionID string) (string, error)
 	Del(ctx context.Context, sessionID string) error
+	// index of sessions (e.g. per user)
+	AddToIndex(ctx context.Context, index, sessionID string, expiration time.Duration) error
+	RemoveFromIndex(ctx context.Context, index string, sessionIDs ...string) error
+	IndexMembers(ctx context.Context, index string) ([]string, error)
 }
 
 type RedisSessionStore struct {
@@ -72,6 +76,36 @@ func (s *RedisSessionStore) Del(ctx context.Context, sessionID string) error {
 	return nil
 }
 
+func (s *RedisSessionStore) AddToIndex(ctx context.Context, index, sessionID string, expiration time.Duration) error {
+	pipe := s.rdb.TxPipeline()
+	pipe.SAdd(ctx, index, sessionID)
+	// index should live as long as the last added session
+	pipe.Expire(ctx, index, expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis add to session index: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) RemoveFromIndex(ctx context.Context, index string, sessionIDs ...string) error {
+	members := make([]interface{}, len(sessionIDs))
+	for i, id := range sessionIDs {
+		members[i] = id
+	}
+	if err := s.rdb.SRem(ctx, index, members...).Err(); err != nil {
+		return fmt.Errorf("redis remove from session index: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) IndexMembers(ctx context.Context, index string) ([]string, error) {
+	members, err := s.rdb.SMembers(ctx, index).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis get session index: %v", err)
+	}
+	return members, nil
+}
+
 type AuthService struct {
 	logger         *zap.SugaredLogger
 	expiration     time.Duration
@@ -79,6 +113,22 @@ type AuthService struct {
 	store          SessionStore
 	cache          *ttlcache.Cache[string, domain.User]
 	basicAuthCache *ttlcache.Cache[string, domain.User]
+	tokenCache     *ttlcache.Cache[string, domain.User]
+	authenticators []Authenticator
+	tokens         domain.APITokensRepository
+
+	twoFactor           domain.TwoFactorRepository
+	twoFactorCache      *ttlcache.Cache[string, bool]
+	requireSuperuser2FA bool
+
+	attempts       AttemptsLimiter
+	passwordPolicy domain.PasswordPolicy
+	audit          AuditRecorder
+
+	organizations    domain.OrganizationsRepository
+	groups           domain.GroupsRepository
+	ldapAccounts     domain.LDAPAccountsRepository
+	membershipsCache *ttlcache.Cache[string, domain.Memberships]
 }
 
 func NewAuthService(logger *zap.SugaredLogger, expiration time.Duration, accounts domain.AccountsRepository, store SessionStore) *AuthService {
@@ -103,6 +153,10 @@ func NewAuthService(logger *zap.SugaredLogger, expiration time.Duration, account
 		ttlcache.WithTTL[string, domain.User](45*time.Second),
 		ttlcache.WithDisableTouchOnHit[string, domain.User](),
 	)
+	tokenCache := ttlcache.New(
+		ttlcache.WithTTL[string, domain.User](tokenCacheTTL),
+		ttlcache.WithDisableTouchOnHit[string, domain.User](),
+	)
 	return &AuthService{
 		logger:         logger,
 		expiration:     expiration,
@@ -110,6 +164,8 @@ func NewAuthService(logger *zap.SugaredLogger, expiration time.Duration, account
 		store:          store,
 		cache:          cache,
 		basicAuthCache: basicAuthCache,
+		tokenCache:     tokenCache,
+		authenticators: []Authenticator{NewLocalAuthenticator(accounts)},
 	}
 }
 
@@ -123,7 +179,8 @@ func (s *AuthService) GetSessionInfo(c echo.Context) (*SessionInfo, error) {
 	if err == nil {
 		sessionid = cookie.Value
 	}
-	if sessionid == "" {
+	// other records in the store (login states) use prefixed keys
+	if sessionid == "" || strings.Contains(sessionid, ":") {
 		c.Set("session", nil)
 		return nil, nil
 	}
@@ -136,7 +193,21 @@ func (s *AuthService) GetSessionInfo(c echo.Context) (*SessionInfo, error) {
 		}
 		return nil, err
 	}
-	si = SessionInfo{ID: sessionid, Username: data}
+	record, ok := parseSessionRecord(data)
+	if ok {
+		s.touchSession(c, sessionid, record)
+	} else {
+		valid, err := s.checkLegacySession(c, sessionid, record.Username)
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			s.LogoutUser(c)
+			c.Set("session", nil)
+			return nil, nil
+		}
+	}
+	si = SessionInfo{ID: sessionid, Username: record.Username}
 	c.Set("session", si)
 	return &si, nil
 }
@@ -147,7 +218,13 @@ func (s *AuthService) GetUser(c echo.Context) (domain.User, error) {
 		return user, nil
 	}
 	auth := c.Request().Header.Get("Authorization")
-	if auth != "" {
+	if value, ok := BearerToken(c); ok {
+		var err error
+		user, err = s.authenticateAPIToken(value)
+		if err != nil {
+			return AnonymousUser, err
+		}
+	} else if auth != "" {
 		if item := s.basicAuthCache.Get(auth); item != nil {
 			user = item.Value()
 		} else {
@@ -159,10 +236,20 @@ func (s *AuthService) GetUser(c echo.Context) (domain.User, error) {
 				}
 				cred := strings.SplitN(string(b), ":", 2)
 				if len(cred) == 2 {
-					account, err := s.Authenticate(cred[0], cred[1])
+					account, err := s.AuthenticateRequest(c, cred[0], cred[1])
 					if err != nil {
 						return AnonymousUser, err
 					}
+					// password alone is not sufficient for accounts with 2FA, API tokens should be used instead
+					if enabled, err := s.TwoFactorEnabled(account.Username); err != nil || enabled {
+						if err == nil {
+							err = ErrTwoFactorRequired
+						}
+						return AnonymousUser, err
+					}
+					if s.PasswordExpired(account) {
+						return AnonymousUser, domain.ErrPasswordExpired
+					}
 					user = domain.AccountToUser(account)
 					s.basicAuthCache.Set(auth, user, ttlcache.DefaultTTL)
 				}
@@ -182,28 +269,36 @@ func (s *AuthService) GetUser(c echo.Context) (domain.User, error) {
 		}
 		user = item.Value()
 	}
+	user = s.withMemberships(user)
 	c.Set("user", user)
 	return user, nil
 }
 
+// SetAuthenticators sets authenticators used (in the given order) to verify user's credentials
+func (s *AuthService) SetAuthenticators(authenticators ...Authenticator) {
+	s.authenticators = authenticators
+}
+
 func (s *AuthService) Authenticate(login, password string) (domain.Account, error) {
-	var account domain.Account
-	var err error
-	if strings.Contains(login, "@") {
-		account, err = s.accounts.GetByEmail(login)
-	} else {
-		account, err = s.accounts.GetByUsername(login)
-	}
-	if err != nil {
-		return domain.Account{}, err
-	}
-	if !account.Active {
-		return domain.Account{}, ErrUserNotFound
+	var firstErr error
+	for _, a := range s.authenticators {
+		account, err := a.Authenticate(login, password)
+		if err == nil {
+			// memberships (LDAP groups) can be changed by the authenticator
+			s.InvalidateMemberships(account.Username)
+			return account, nil
+		}
+		if !errors.Is(err, ErrUserNotFound) && !errors.Is(err, ErrInvalidPassword) {
+			s.logger.Errorw("authentication", "login", login, zap.Error(err))
+		}
+		if firstErr == nil || errors.Is(firstErr, ErrUserNotFound) {
+			firstErr = err
+		}
 	}
-	if !account.CheckPassword(password) {
-		return domain.Account{}, ErrInvalidPassword
+	if firstErr == nil {
+		firstErr = ErrUserNotFound
 	}
-	return account, nil
+	return domain.Account{}, firstErr
 }
 
 func (s *AuthService) LoginUserWithExpiration(c echo.Context, userAccount domain.Account, expiration time.Duration) error {
@@ -213,12 +308,12 @@ func (s *AuthService) LoginUserWithExpiration(c echo.Context, userAccount domain
 	}
 	sessionid := token.String()
 	// sessionid := fmt.Sprintf("%s:%s", user.Username, token.String())
-	if err := s.store.Set(c.Request().Context(), sessionid, userAccount.Username, expiration); err != nil {
+	if err := s.createSession(c, sessionid, userAccount.Username, expiration); err != nil {
 		return fmt.Errorf("save session: %v", err)
 	}
 	oldCookie, err := c.Request().Cookie("gq_session")
-	if err == nil {
-		if err = s.store.Del(c.Request().Context(), oldCookie.Value); err != nil {
+	if err == nil && !strings.Contains(oldCookie.Value, ":") {
+		if err = s.deleteSession(c.Request().Context(), oldCookie.Value); err != nil {
 			s.logger.Errorw("deleting old session on login", zap.Error(err))
 		}
 	}
@@ -227,6 +322,7 @@ func (s *AuthService) LoginUserWithExpiration(c echo.Context, userAccount domain
 	if err := s.accounts.Update(userAccount); err != nil {
 		s.logger.Warnw("updating time of last login", zap.Error(err))
 	}
+	s.recordEvent(c, domain.AuditEvent{Type: domain.AuditLogin, Username: userAccount.Username})
 
 	// serverUrl.Hostname()
 	// c.Request().URL.Hostname()
@@ -247,8 +343,8 @@ func (s *AuthService) LoginUser(c echo.Context, userAccount domain.Account) erro
 
 func (s *AuthService) LogoutUser(c echo.Context) {
 	cookie, err := c.Request().Cookie("gq_session")
-	if err == nil {
-		if err = s.store.Del(c.Request().Context(), cookie.Value); err != nil {
+	if err == nil && !strings.Contains(cookie.Value, ":") {
+		if err = s.deleteSession(c.Request().Context(), cookie.Value); err != nil {
 			s.logger.Errorw("deleting session on logout", zap.Error(err))
 		}
 	}
@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	userSessionsIndex = "user_sessions:"
+	// time of the last revocation of all user's sessions, invalidates not indexed sessions
+	// created by older versions
+	sessionsRevokedKey = "sessions_revoked:"
+	// minimal interval between updates of session's last seen time
+	lastSeenInterval = time.Minute
+)
+
+var ErrSessionNotFound = errors.New("Session not found")
+
+// sessionRecord is stored in session store under session ID
+type sessionRecord struct {
+	Username  string    `json:"username"`
+	Created   time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen_at"`
+	Expires   time.Time `json:"expires_at"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// Session describes user's login session. ID is a public identifier derived from
+// the session key, which is never exposed.
+type Session struct {
+	ID        string    `json:"id"`
+	Created   time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen_at"`
+	Expires   time.Time `json:"expires_at"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Current   bool      `json:"current"`
+}
+
+func sessionPublicID(sessionID string) string {
+	h := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(h[:8])
+}
+
+// parseSessionRecord decodes session data. Sessions created by older versions contain
+// only the username.
+func parseSessionRecord(data string) (sessionRecord, bool) {
+	if !strings.HasPrefix(data, "{") {
+		return sessionRecord{Username: data}, false
+	}
+	var r sessionRecord
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return sessionRecord{}, false
+	}
+	return r, true
+}
+
+func (s *AuthService) saveSession(ctx context.Context, sessionID string, r sessionRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(r.Expires)
+	if ttl <= 0 {
+		return ErrInvalidSession
+	}
+	return s.store.Set(ctx, sessionID, string(data), ttl)
+}
+
+func (s *AuthService) createSession(c echo.Context, sessionID, username string, expiration time.Duration) error {
+	ctx := c.Request().Context()
+	now := time.Now().UTC()
+	r := sessionRecord{
+		Username:  username,
+		Created:   now,
+		LastSeen:  now,
+		Expires:   now.Add(expiration),
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
+	if err := s.saveSession(ctx, sessionID, r); err != nil {
+		return err
+	}
+	return s.store.AddToIndex(ctx, userSessionsIndex+username, sessionID, expiration)
+}
+
+// touchSession updates session's last seen time (at most once per lastSeenInterval)
+func (s *AuthService) touchSession(c echo.Context, sessionID string, r sessionRecord) {
+	now := time.Now().UTC()
+	if now.Sub(r.LastSeen) < lastSeenInterval {
+		return
+	}
+	r.LastSeen = now
+	r.IP = c.RealIP()
+	if err := s.saveSession(c.Request().Context(), sessionID, r); err != nil && !errors.Is(err, ErrInvalidSession) {
+		s.logger.Warnw("updating session last seen time", zap.Error(err))
+	}
+}
+
+// checkLegacySession handles session created by older version (only username is stored and
+// the session is not indexed). Session is deleted when user's sessions were revoked since, otherwise
+// it's converted to indexed session record, so it can be listed and revoked. Returns false when
+// the session is not valid.
+func (s *AuthService) checkLegacySession(c echo.Context, sessionID, username string) (bool, error) {
+	ctx := c.Request().Context()
+	if username == "" {
+		return false, s.store.Del(ctx, sessionID)
+	}
+	if _, err := s.store.Get(ctx, sessionsRevokedKey+username); err == nil {
+		if err := s.store.Del(ctx, sessionID); err != nil {
+			return false, err
+		}
+		return false, nil
+	} else if !errors.Is(err, ErrInvalidSession) {
+		return false, err
+	}
+	// original expiration time is unknown
+	if err := s.createSession(c, sessionID, username, s.expiration); err != nil {
+		return false, fmt.Errorf("converting legacy session: %w", err)
+	}
+	return true, nil
+}
+
+// invalidateBasicAuth removes cached users authenticated with basic auth credentials
+func (s *AuthService) invalidateBasicAuth(username string) {
+	for key, item := range s.basicAuthCache.Items() {
+		if item.Value().Username == username {
+			s.basicAuthCache.Delete(key)
+		}
+	}
+}
+
+func (s *AuthService) deleteSession(ctx context.Context, sessionID string) error {
+	data, err := s.store.Get(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSession) {
+			return nil
+		}
+		return err
+	}
+	if err := s.store.Del(ctx, sessionID); err != nil {
+		return err
+	}
+	r, _ := parseSessionRecord(data)
+	return s.store.RemoveFromIndex(ctx, userSessionsIndex+r.Username, sessionID)
+}
+
+// userSessions returns active sessions of the user (keyed by session ID) and removes
+// expired sessions from the index
+func (s *AuthService) userSessions(ctx context.Context, username string) (map[string]sessionRecord, error) {
+	index := userSessionsIndex + username
+	ids, err := s.store.IndexMembers(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make(map[string]sessionRecord, len(ids))
+	var expired []string
+	for _, id := range ids {
+		data, err := s.store.Get(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrInvalidSession) {
+				expired = append(expired, id)
+				continue
+			}
+			return nil, err
+		}
+		r, _ := parseSessionRecord(data)
+		if r.Username != username {
+			expired = append(expired, id)
+			continue
+		}
+		sessions[id] = r
+	}
+	if len(expired) > 0 {
+		if err := s.store.RemoveFromIndex(ctx, index, expired...); err != nil {
+			s.logger.Warnw("cleaning sessions index", "username", username, zap.Error(err))
+		}
+	}
+	return sessions, nil
+}
+
+// ListSessions returns active sessions of the user, most recently used first. Session of the
+// current request is marked.
+func (s *AuthService) ListSessions(c echo.Context, username string) ([]Session, error) {
+	records, err := s.userSessions(c.Request().Context(), username)
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+	currentID := ""
+	if si, err := s.GetSessionInfo(c); err == nil && si != nil {
+		currentID = si.ID
+	}
+	sessions := make([]Session, 0, len(records))
+	for id, r := range records {
+		sessions = append(sessions, Session{
+			ID:        sessionPublicID(id),
+			Created:   r.Created,
+			LastSeen:  r.LastSeen,
+			Expires:   r.Expires,
+			IP:        r.IP,
+			UserAgent: r.UserAgent,
+			Current:   id == currentID,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	return sessions, nil
+}
+
+// RevokeSession deletes user's session with given public ID
+func (s *AuthService) RevokeSession(ctx context.Context, username, id string) error {
+	records, err := s.userSessions(ctx, username)
+	if err != nil {
+		return fmt.Errorf("listing sessions: %w", err)
+	}
+	for sessionID := range records {
+		if sessionPublicID(sessionID) == id {
+			return s.deleteSession(ctx, sessionID)
+		}
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeUserSessions deletes all sessions of the user, except the session with
+// given ID (when not empty), and cached basic auth credentials. Returns number of deleted
+// (indexed) sessions.
+func (s *AuthService) RevokeUserSessions(ctx context.Context, username, exceptSessionID string) (int, error) {
+	s.invalidateBasicAuth(username)
+	// not indexed sessions of older versions are deleted on their next use
+	revoked := time.Now().UTC().Format(time.RFC3339)
+	if err := s.store.Set(ctx, sessionsRevokedKey+username, revoked, s.expiration); err != nil {
+		return 0, fmt.Errorf("saving sessions revocation: %w", err)
+	}
+	records, err := s.userSessions(ctx, username)
+	if err != nil {
+		return 0, fmt.Errorf("listing sessions: %w", err)
+	}
+	count := 0
+	for sessionID := range records {
+		if sessionID == exceptSessionID {
+			continue
+		}
+		if err := s.deleteSession(ctx, sessionID); err != nil {
+			return count, fmt.Errorf("deleting session: %w", err)
+		}
+		count++
+	}
+	if count > 0 {
+		s.logger.Infow("revoked user sessions", "username", username, "count", count)
+	}
+	return count, nil
+}
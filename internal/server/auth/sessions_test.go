@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+func newSessionsTest() (*AuthService, SessionStore) {
+	accounts := &accountsRepo{accounts: map[string]domain.Account{
+		"jan": {Username: "jan", Active: true},
+	}}
+	store := NewMemoryStore()
+	return NewAuthService(zap.NewNop().Sugar(), time.Hour, accounts, store), store
+}
+
+func sessionContext(sessionID string) echo.Context {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "gq_session", Value: sessionID})
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestLegacySessionRevocation(t *testing.T) {
+	s, store := newSessionsTest()
+	ctx := context.Background()
+	// sessions created by older versions store only username
+	store.Set(ctx, "legacy-1", "jan", time.Hour)
+	store.Set(ctx, "legacy-2", "jan", time.Hour)
+
+	// used session is converted to indexed session record
+	si, err := s.GetSessionInfo(sessionContext("legacy-1"))
+	if err != nil || si == nil || si.Username != "jan" {
+		t.Fatalf("expected valid legacy session, got %+v (%v)", si, err)
+	}
+	sessions, err := s.userSessions(ctx, "jan")
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("expected converted session in index, got %v (%v)", sessions, err)
+	}
+
+	count, err := s.RevokeUserSessions(ctx, "jan", "")
+	if err != nil || count != 1 {
+		t.Fatalf("expected 1 revoked session, got %d (%v)", count, err)
+	}
+	for _, id := range []string{"legacy-1", "legacy-2"} {
+		si, err := s.GetSessionInfo(sessionContext(id))
+		if err != nil || si != nil {
+			t.Errorf("session %s should be revoked, got %+v (%v)", id, si, err)
+		}
+		if _, err := store.Get(ctx, id); !errors.Is(err, ErrInvalidSession) {
+			t.Errorf("session %s should be deleted from store, got %v", id, err)
+		}
+	}
+}
+
+func TestRevokeUserSessionsExceptCurrent(t *testing.T) {
+	s, store := newSessionsTest()
+	ctx := context.Background()
+	store.Set(ctx, "current", "jan", time.Hour)
+	store.Set(ctx, "other", "jan", time.Hour)
+
+	// current legacy session is converted on use
+	if si, err := s.GetSessionInfo(sessionContext("current")); err != nil || si == nil {
+		t.Fatalf("expected valid session, got %+v (%v)", si, err)
+	}
+	if _, err := s.RevokeUserSessions(ctx, "jan", "current"); err != nil {
+		t.Fatal(err)
+	}
+	if si, err := s.GetSessionInfo(sessionContext("current")); err != nil || si == nil {
+		t.Errorf("current session should be kept, got %+v (%v)", si, err)
+	}
+	if si, err := s.GetSessionInfo(sessionContext("other")); err != nil || si != nil {
+		t.Errorf("other session should be revoked, got %+v (%v)", si, err)
+	}
+}
+
+func TestRevokeUserSessionsBasicAuth(t *testing.T) {
+	s, _ := newSessionsTest()
+	s.basicAuthCache.Set("Basic amFuOm9sZA==", domain.User{Username: "jan"}, ttlcache.DefaultTTL)
+	s.basicAuthCache.Set("Basic ZXZhOnB3ZA==", domain.User{Username: "eva"}, ttlcache.DefaultTTL)
+
+	if _, err := s.RevokeUserSessions(context.Background(), "jan", ""); err != nil {
+		t.Fatal(err)
+	}
+	if s.basicAuthCache.Get("Basic amFuOm9sZA==") != nil {
+		t.Error("cached basic auth credentials of the user should be removed")
+	}
+	if s.basicAuthCache.Get("Basic ZXZhOnB3ZA==") == nil {
+		t.Error("cached basic auth credentials of other users should be kept")
+	}
+}
@@ -0,0 +1,117 @@
+// Package storetest provides conformance tests shared by all auth.SessionStore implementations
+package storetest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/server/auth"
+)
+
+// RunSessionStoreTests runs conformance tests against session stores created by newStore
+func RunSessionStoreTests(t *testing.T, newStore func(t *testing.T) auth.SessionStore) {
+	t.Run("SetGetDel", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		if err := s.Set(ctx, "s1", "data", time.Minute); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+		val, err := s.Get(ctx, "s1")
+		if err != nil || val != "data" {
+			t.Fatalf("get: expected 'data', got %q (%v)", val, err)
+		}
+		if err := s.Set(ctx, "s1", "updated", time.Minute); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+		if val, _ := s.Get(ctx, "s1"); val != "updated" {
+			t.Fatalf("get: expected 'updated', got %q", val)
+		}
+		if err := s.Del(ctx, "s1"); err != nil {
+			t.Fatalf("del: %v", err)
+		}
+		if _, err := s.Get(ctx, "s1"); !errors.Is(err, auth.ErrInvalidSession) {
+			t.Fatalf("get deleted: expected ErrInvalidSession, got %v", err)
+		}
+		if err := s.Del(ctx, "missing"); err != nil {
+			t.Fatalf("del missing: %v", err)
+		}
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		if err := s.Set(ctx, "short", "data", time.Second); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+		if err := s.Set(ctx, "long", "data", time.Minute); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+		time.Sleep(1100 * time.Millisecond)
+		if _, err := s.Get(ctx, "short"); !errors.Is(err, auth.ErrInvalidSession) {
+			t.Fatalf("get expired: expected ErrInvalidSession, got %v", err)
+		}
+		if _, err := s.Get(ctx, "long"); err != nil {
+			t.Fatalf("get: %v", err)
+		}
+	})
+
+	t.Run("Index", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		members, err := s.IndexMembers(ctx, "idx")
+		if err != nil || len(members) != 0 {
+			t.Fatalf("empty index: expected no members, got %v (%v)", members, err)
+		}
+		for _, id := range []string{"a", "b", "c"} {
+			if err := s.AddToIndex(ctx, "idx", id, time.Minute); err != nil {
+				t.Fatalf("add to index: %v", err)
+			}
+		}
+		if err := s.AddToIndex(ctx, "idx", "a", time.Minute); err != nil {
+			t.Fatalf("add to index: %v", err)
+		}
+		if err := s.AddToIndex(ctx, "other", "x", time.Minute); err != nil {
+			t.Fatalf("add to index: %v", err)
+		}
+		assertMembers(t, s, "idx", "a", "b", "c")
+		if err := s.RemoveFromIndex(ctx, "idx", "a", "c", "missing"); err != nil {
+			t.Fatalf("remove from index: %v", err)
+		}
+		assertMembers(t, s, "idx", "b")
+		assertMembers(t, s, "other", "x")
+		if err := s.RemoveFromIndex(ctx, "idx", "b"); err != nil {
+			t.Fatalf("remove from index: %v", err)
+		}
+		assertMembers(t, s, "idx")
+	})
+
+	t.Run("IndexExpiration", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		if err := s.AddToIndex(ctx, "idx", "a", time.Second); err != nil {
+			t.Fatalf("add to index: %v", err)
+		}
+		time.Sleep(1100 * time.Millisecond)
+		assertMembers(t, s, "idx")
+	})
+}
+
+func assertMembers(t *testing.T, s auth.SessionStore, index string, expected ...string) {
+	t.Helper()
+	members, err := s.IndexMembers(context.Background(), index)
+	if err != nil {
+		t.Fatalf("index members: %v", err)
+	}
+	sort.Strings(members)
+	if len(members) != len(expected) {
+		t.Fatalf("index %s: expected %v, got %v", index, expected, members)
+	}
+	for i := range members {
+		if members[i] != expected[i] {
+			t.Fatalf("index %s: expected %v, got %v", index, expected, members)
+		}
+	}
+}
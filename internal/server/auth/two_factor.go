@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/infrastructure/security"
+	"github.com/gofrs/uuid"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	twoFactorLoginExpiration = 5 * time.Minute
+	twoFactorLoginAttempts   = 5
+	recoveryCodesCount       = 10
+	totpIssuer               = "Gisquick"
+)
+
+var (
+	ErrInvalidCode           = errors.New("Invalid verification code")
+	ErrTwoFactorEnabled      = errors.New("Two-factor authentication is already enabled")
+	ErrTwoFactorRequired     = errors.New("Two-factor authentication is required")
+	ErrInvalidTwoFactorLogin = errors.New("Invalid or expired two-factor login")
+)
+
+// SetTwoFactorRepository enables two-factor authentication. When requireSuperusers is set,
+// superusers without enabled 2FA are denied access to administration.
+func (s *AuthService) SetTwoFactorRepository(repo domain.TwoFactorRepository, requireSuperusers bool) {
+	s.twoFactor = repo
+	s.requireSuperuser2FA = requireSuperusers
+	s.twoFactorCache = ttlcache.New(
+		ttlcache.WithTTL[string, bool](45*time.Second),
+		ttlcache.WithDisableTouchOnHit[string, bool](),
+	)
+}
+
+func (s *AuthService) TwoFactorAvailable() bool {
+	return s.twoFactor != nil
+}
+
+func (s *AuthService) TwoFactorEnabled(username string) (bool, error) {
+	if s.twoFactor == nil {
+		return false, nil
+	}
+	if item := s.twoFactorCache.Get(username); item != nil {
+		return item.Value(), nil
+	}
+	cfg, err := s.twoFactor.Get(username)
+	if err != nil && !errors.Is(err, domain.ErrTwoFactorNotConfigured) {
+		return false, err
+	}
+	s.twoFactorCache.Set(username, cfg.Enabled, ttlcache.DefaultTTL)
+	return cfg.Enabled, nil
+}
+
+// TwoFactorRequired returns true when the user is required to use 2FA
+func (s *AuthService) TwoFactorRequired(user domain.User) bool {
+	return s.requireSuperuser2FA && user.IsSuperuser
+}
+
+// SuperuserTwoFactorMissing returns true when the user is superuser required to use 2FA,
+// but didn't enable it yet
+func (s *AuthService) SuperuserTwoFactorMissing(user domain.User) (bool, error) {
+	if !s.TwoFactorRequired(user) {
+		return false, nil
+	}
+	enabled, err := s.TwoFactorEnabled(user.Username)
+	return !enabled, err
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
+
+func generateRecoveryCodes() ([]string, domain.Flags, error) {
+	codes := make([]string, recoveryCodesCount)
+	hashes := make(domain.Flags, recoveryCodesCount)
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		c := strings.ToLower(enc.EncodeToString(b))[:10]
+		codes[i] = c[:5] + "-" + c[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// SetupTwoFactor generates new TOTP secret (replacing not yet enabled one) and returns
+// otpauth URI for authenticator apps
+func (s *AuthService) SetupTwoFactor(username string) (string, string, error) {
+	cfg, err := s.twoFactor.Get(username)
+	if err != nil && !errors.Is(err, domain.ErrTwoFactorNotConfigured) {
+		return "", "", err
+	}
+	if cfg.Enabled {
+		return "", "", ErrTwoFactorEnabled
+	}
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	cfg = domain.TwoFactorConfig{
+		Username: username,
+		Secret:   secret,
+		Created:  time.Now().UTC(),
+	}
+	if err := s.twoFactor.Save(cfg); err != nil {
+		return "", "", fmt.Errorf("saving 2fa config: %w", err)
+	}
+	return secret, security.TOTPURI(totpIssuer, username, secret), nil
+}
+
+// EnableTwoFactor confirms enrollment with a code from authenticator app and returns recovery codes
+func (s *AuthService) EnableTwoFactor(username, code string) ([]string, error) {
+	cfg, err := s.twoFactor.Get(username)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	step, ok := security.ValidateTOTP(cfg.Secret, code, time.Now(), 1)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Enabled = true
+	cfg.LastStep = step
+	cfg.RecoveryCodes = hashes
+	if err := s.twoFactor.Save(cfg); err != nil {
+		return nil, fmt.Errorf("saving 2fa config: %w", err)
+	}
+	s.twoFactorCache.Delete(username)
+	return codes, nil
+}
+
+// verifyCode checks TOTP or recovery code. Used recovery codes are removed.
+func (s *AuthService) verifyCode(cfg *domain.TwoFactorConfig, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == security.TOTPDigits {
+		step, ok := security.ValidateTOTP(cfg.Secret, code, time.Now(), 1)
+		if !ok || step <= cfg.LastStep {
+			return ErrInvalidCode
+		}
+		cfg.LastStep = step
+	} else {
+		hash := hashRecoveryCode(code)
+		if !cfg.RecoveryCodes.Has(hash) {
+			return ErrInvalidCode
+		}
+		cfg.RecoveryCodes = cfg.RecoveryCodes.Filter(func(h string) bool { return h != hash })
+		s.logger.Infow("2fa recovery code used", "username", cfg.Username, "remaining", len(cfg.RecoveryCodes))
+	}
+	if err := s.twoFactor.Save(*cfg); err != nil {
+		return fmt.Errorf("saving 2fa config: %w", err)
+	}
+	return nil
+}
+
+// VerifyTwoFactor checks TOTP or recovery code of user with enabled 2FA
+func (s *AuthService) VerifyTwoFactor(username, code string) error {
+	cfg, err := s.twoFactor.Get(username)
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled {
+		return domain.ErrTwoFactorNotConfigured
+	}
+	return s.verifyCode(&cfg, code)
+}
+
+func (s *AuthService) RecoveryCodesCount(username string) (int, error) {
+	cfg, err := s.twoFactor.Get(username)
+	if err != nil {
+		return 0, err
+	}
+	return len(cfg.RecoveryCodes), nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after verification with valid code
+func (s *AuthService) RegenerateRecoveryCodes(username, code string) ([]string, error) {
+	cfg, err := s.twoFactor.Get(username)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled {
+		return nil, domain.ErrTwoFactorNotConfigured
+	}
+	if err := s.verifyCode(&cfg, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	cfg.RecoveryCodes = hashes
+	if err := s.twoFactor.Save(cfg); err != nil {
+		return nil, fmt.Errorf("saving 2fa config: %w", err)
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor removes 2FA configuration (without verification, callers are responsible for it)
+func (s *AuthService) DisableTwoFactor(username string) error {
+	if err := s.twoFactor.Delete(username); err != nil {
+		return err
+	}
+	s.twoFactorCache.Delete(username)
+	return nil
+}
+
+// StartTwoFactorLogin is called after successful password verification of user with enabled 2FA.
+// Returns token identifying pending login, which is finished with FinishTwoFactorLogin.
+func (s *AuthService) StartTwoFactorLogin(ctx context.Context, username string) (string, error) {
+	return s.startTwoFactor(ctx, "2fa:", username)
+}
+
+func (s *AuthService) FinishTwoFactorLogin(c echo.Context, token, code string) (domain.Account, error) {
+	account, err := s.finishTwoFactor(c, "2fa:", token, code)
+	if err != nil {
+		return account, err
+	}
+	// login is completed, reset failed password attempts
+	if s.attempts != nil {
+		if err := s.attempts.Success(c.Request().Context(), ActionLogin, account.Username); err != nil {
+			s.logger.Errorw("resetting failed logins", zap.Error(err))
+		}
+	}
+	return account, nil
+}
+
+// StartTwoFactorPasswordChange is called after verification of expired password of user with
+// enabled 2FA. Returned token is finished with FinishTwoFactorPasswordChange and can't be used for login.
+func (s *AuthService) StartTwoFactorPasswordChange(ctx context.Context, username string) (string, error) {
+	return s.startTwoFactor(ctx, "2fa_password:", username)
+}
+
+func (s *AuthService) FinishTwoFactorPasswordChange(c echo.Context, token, code string) (domain.Account, error) {
+	return s.finishTwoFactor(c, "2fa_password:", token, code)
+}
+
+// startTwoFactor saves pending two-factor verification of the user under a new token
+func (s *AuthService) startTwoFactor(ctx context.Context, prefix, username string) (string, error) {
+	token, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	if err := s.store.Set(ctx, prefix+token.String(), "0:"+username, twoFactorLoginExpiration); err != nil {
+		return "", fmt.Errorf("saving 2fa login: %w", err)
+	}
+	return token.String(), nil
+}
+
+// finishTwoFactor verifies code of pending two-factor verification. Number of attempts is limited
+// per token and also per user by attempts limiter, as a new token is issued after each
+// successful password verification.
+func (s *AuthService) finishTwoFactor(c echo.Context, prefix, token, code string) (domain.Account, error) {
+	ctx := c.Request().Context()
+	key := prefix + token
+	data, err := s.store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSession) {
+			return domain.Account{}, ErrInvalidTwoFactorLogin
+		}
+		return domain.Account{}, err
+	}
+	attemptsStr, username, _ := strings.Cut(data, ":")
+	attempts, _ := strconv.Atoi(attemptsStr)
+	ip := c.RealIP()
+	if s.attempts != nil {
+		if err := s.attempts.Check(ctx, ActionTwoFactor, username, ip); err != nil {
+			s.logger.Warnw("security: 2fa attempt rejected", "username", username, "ip", ip, "reason", err.Error())
+			return domain.Account{}, err
+		}
+	}
+	if err := s.VerifyTwoFactor(username, code); err != nil {
+		if !errors.Is(err, ErrInvalidCode) {
+			return domain.Account{}, err
+		}
+		s.logger.Warnw("2fa login: invalid code", "username", username)
+		s.recordEvent(c, domain.AuditEvent{
+			Type:     domain.AuditLoginFailed,
+			Username: username,
+			Details:  map[string]interface{}{"login": username, "reason": "invalid_2fa_code"},
+		})
+		if s.attempts != nil {
+			status, lerr := s.attempts.Failure(ctx, ActionTwoFactor, username, ip)
+			if lerr != nil {
+				s.logger.Errorw("recording failed 2fa attempt", zap.Error(lerr))
+			}
+			if status.Locked {
+				s.logger.Warnw("security: 2fa locked", "username", username, "ip", ip, "until", status.Until)
+			}
+		}
+		attempts++
+		if attempts >= twoFactorLoginAttempts {
+			if err := s.store.Del(ctx, key); err != nil {
+				s.logger.Errorw("deleting 2fa login", zap.Error(err))
+			}
+		} else if err := s.store.Set(ctx, key, fmt.Sprintf("%d:%s", attempts, username), twoFactorLoginExpiration); err != nil {
+			s.logger.Errorw("updating 2fa login", zap.Error(err))
+		}
+		return domain.Account{}, ErrInvalidCode
+	}
+	if err := s.store.Del(ctx, key); err != nil {
+		s.logger.Errorw("deleting 2fa login", zap.Error(err))
+	}
+	if s.attempts != nil {
+		if err := s.attempts.Success(ctx, ActionTwoFactor, username); err != nil {
+			s.logger.Errorw("resetting failed 2fa attempts", zap.Error(err))
+		}
+	}
+	account, err := s.accounts.GetByUsername(username)
+	if err != nil {
+		return domain.Account{}, err
+	}
+	if !account.Active {
+		return domain.Account{}, ErrUserNotFound
+	}
+	return account, nil
+}
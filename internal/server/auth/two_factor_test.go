@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+)
+
+type twoFactorRepo struct {
+	domain.TwoFactorRepository
+}
+
+func (r twoFactorRepo) Get(username string) (domain.TwoFactorConfig, error) {
+	return domain.TwoFactorConfig{Username: username, Secret: "JBSWY3DPEHPK3PXP", Enabled: true}, nil
+}
+
+func TestTwoFactorPasswordChangeToken(t *testing.T) {
+	s, _ := newSessionsTest()
+	token, err := s.StartTwoFactorPasswordChange(context.Background(), "jan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// token of expired password change can't be used to finish login
+	if _, err := s.FinishTwoFactorLogin(sessionContext(""), token, "123456"); !errors.Is(err, ErrInvalidTwoFactorLogin) {
+		t.Errorf("expected ErrInvalidTwoFactorLogin, got %v", err)
+	}
+}
+
+func TestTwoFactorLoginLimit(t *testing.T) {
+	s, _ := newSessionsTest()
+	s.SetTwoFactorRepository(twoFactorRepo{}, false)
+	s.SetAttemptsLimiter(NewMemoryAttemptsLimiter(map[string]LimitRule{
+		ActionTwoFactor: {MaxAttempts: 3, Window: time.Hour, Lockout: time.Hour},
+	}))
+	ctx := context.Background()
+	// every successful password verification issues a new token
+	for i := 0; i < 3; i++ {
+		token, err := s.StartTwoFactorLogin(ctx, "jan")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.FinishTwoFactorLogin(sessionContext(""), token, "invalid"); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: expected ErrInvalidCode, got %v", i, err)
+		}
+	}
+	token, err := s.StartTwoFactorLogin(ctx, "jan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var limitErr *LimitError
+	if _, err := s.FinishTwoFactorLogin(sessionContext(""), token, "invalid"); !errors.As(err, &limitErr) || !limitErr.Locked {
+		t.Errorf("expected locked 2fa after failed attempts, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// AddCampaigns registers administration of email campaigns
+func (s *Server) AddCampaigns(campaigns *application.CampaignsService) {
+	s.campaigns = campaigns
+	e := s.echo
+	SuperuserRequired := s.middlewares.SuperuserRequired
+	e.GET("/api/admin/campaigns", s.handleGetCampaigns, SuperuserRequired)
+	e.POST("/api/admin/campaigns", s.handleCreateCampaign(), SuperuserRequired)
+	e.POST("/api/admin/campaigns/recipients", s.handleGetFilterRecipients(), SuperuserRequired)
+	e.GET("/api/admin/campaigns/:id", s.handleGetCampaign, SuperuserRequired)
+	e.PUT("/api/admin/campaigns/:id", s.handleUpdateCampaign(), SuperuserRequired)
+	e.DELETE("/api/admin/campaigns/:id", s.handleDeleteCampaign, SuperuserRequired)
+	e.POST("/api/admin/campaigns/:id/schedule", s.handleScheduleCampaign(), SuperuserRequired)
+	e.POST("/api/admin/campaigns/:id/cancel", s.handleCancelCampaign, SuperuserRequired)
+	e.GET("/api/admin/campaigns/:id/recipients", s.handleGetCampaignRecipients(), SuperuserRequired)
+}
+
+func campaignError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrCampaignNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Campaign not found")
+	case errors.Is(err, application.ErrCampaignNotEditable),
+		errors.Is(err, application.ErrCampaignState):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrInvalidFilter),
+		errors.Is(err, application.ErrInvalidTemplate):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func campaignID(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid campaign id")
+	}
+	return id, nil
+}
+
+type CampaignForm struct {
+	Subject      string                  `json:"subject" validate:"required"`
+	HtmlTemplate string                  `json:"html_template"`
+	TextTemplate string                  `json:"text_template"`
+	Style        string                  `json:"style"`
+	Filter       domain.RecipientsFilter `json:"filter"`
+}
+
+func (f CampaignForm) toCampaign() domain.Campaign {
+	return domain.Campaign{
+		Subject:      f.Subject,
+		HtmlTemplate: f.HtmlTemplate,
+		TextTemplate: f.TextTemplate,
+		Style:        f.Style,
+		Filter:       f.Filter,
+	}
+}
+
+func bindCampaignForm(c echo.Context, validate *validator.Validate) (CampaignForm, error) {
+	var form CampaignForm
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+	if err := (&echo.DefaultBinder{}).BindBody(c, &form); err != nil {
+		return form, echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+	}
+	if err := validate.Struct(form); err != nil {
+		return form, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return form, nil
+}
+
+func (s *Server) handleGetCampaigns(c echo.Context) error {
+	campaigns, err := s.campaigns.List()
+	if err != nil {
+		return fmt.Errorf("listing campaigns: %w", err)
+	}
+	return c.JSON(http.StatusOK, campaigns)
+}
+
+func (s *Server) handleGetCampaign(c echo.Context) error {
+	id, err := campaignID(c)
+	if err != nil {
+		return err
+	}
+	campaign, err := s.campaigns.Get(id)
+	if err != nil {
+		return campaignError(err, "getting campaign")
+	}
+	return c.JSON(http.StatusOK, campaign)
+}
+
+func (s *Server) handleCreateCampaign() func(echo.Context) error {
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		form, err := bindCampaignForm(c, validate)
+		if err != nil {
+			return err
+		}
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		campaign, err := s.campaigns.Create(form.toCampaign(), user.Username)
+		if err != nil {
+			return campaignError(err, "creating campaign")
+		}
+		return c.JSON(http.StatusCreated, campaign)
+	}
+}
+
+func (s *Server) handleUpdateCampaign() func(echo.Context) error {
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		id, err := campaignID(c)
+		if err != nil {
+			return err
+		}
+		form, err := bindCampaignForm(c, validate)
+		if err != nil {
+			return err
+		}
+		campaign := form.toCampaign()
+		campaign.ID = id
+		campaign, err = s.campaigns.Update(campaign)
+		if err != nil {
+			return campaignError(err, "updating campaign")
+		}
+		return c.JSON(http.StatusOK, campaign)
+	}
+}
+
+func (s *Server) handleDeleteCampaign(c echo.Context) error {
+	id, err := campaignID(c)
+	if err != nil {
+		return err
+	}
+	if err := s.campaigns.Delete(id); err != nil {
+		return campaignError(err, "deleting campaign")
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleScheduleCampaign() func(echo.Context) error {
+	type ScheduleForm struct {
+		// immediately when not set
+		SendAt *time.Time `json:"send_at"`
+	}
+	return func(c echo.Context) error {
+		id, err := campaignID(c)
+		if err != nil {
+			return err
+		}
+		form := new(ScheduleForm)
+		if c.Request().ContentLength != 0 {
+			if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+			}
+		}
+		campaign, err := s.campaigns.Schedule(id, form.SendAt)
+		if err != nil {
+			return campaignError(err, "scheduling campaign")
+		}
+		return c.JSON(http.StatusOK, campaign)
+	}
+}
+
+func (s *Server) handleCancelCampaign(c echo.Context) error {
+	id, err := campaignID(c)
+	if err != nil {
+		return err
+	}
+	campaign, err := s.campaigns.Cancel(id)
+	if err != nil {
+		return campaignError(err, "cancelling campaign")
+	}
+	return c.JSON(http.StatusOK, campaign)
+}
+
+func (s *Server) handleGetCampaignRecipients() func(echo.Context) error {
+	type Query struct {
+		Status string `query:"status"`
+		Limit  int    `query:"limit"`
+		Offset int    `query:"offset"`
+	}
+	return func(c echo.Context) error {
+		id, err := campaignID(c)
+		if err != nil {
+			return err
+		}
+		query := Query{Limit: 100}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &query); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid query parameters")
+		}
+		if query.Status != "" && !domain.RecipientStatuses.Has(query.Status) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid status")
+		}
+		if query.Limit <= 0 || query.Limit > 1000 || query.Offset < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid pagination parameters")
+		}
+		recipients, err := s.campaigns.GetRecipients(id, query.Status, query.Limit, query.Offset)
+		if err != nil {
+			return campaignError(err, "getting campaign recipients")
+		}
+		return c.JSON(http.StatusOK, recipients)
+	}
+}
+
+// handleGetFilterRecipients returns recipients matching the filter (preview of campaign recipients)
+func (s *Server) handleGetFilterRecipients() func(echo.Context) error {
+	return func(c echo.Context) error {
+		var filter domain.RecipientsFilter
+		if err := (&echo.DefaultBinder{}).BindBody(c, &filter); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		recipients, err := s.campaigns.Recipients(filter)
+		if err != nil {
+			return campaignError(err, "resolving recipients")
+		}
+		return c.JSON(http.StatusOK, recipients)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/infrastructure/email"
+	"github.com/labstack/echo/v4"
+)
+
+// AddEmailOutbox registers administration of the queue of outgoing emails
+func (s *Server) AddEmailOutbox(outbox *email.Outbox) {
+	s.outbox = outbox
+	e := s.echo
+	SuperuserRequired := s.middlewares.SuperuserRequired
+	e.GET("/api/admin/emails", s.handleGetOutboxMessages(), SuperuserRequired)
+	e.POST("/api/admin/emails/resend_failed", s.handleResendFailedMessages, SuperuserRequired)
+	e.GET("/api/admin/emails/:id", s.handleGetOutboxMessage, SuperuserRequired)
+	e.POST("/api/admin/emails/:id/resend", s.handleResendMessage, SuperuserRequired)
+}
+
+func outboxMessageID(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid message id")
+	}
+	return id, nil
+}
+
+func (s *Server) handleGetOutboxMessages() func(echo.Context) error {
+	type Query struct {
+		Status string `query:"status"`
+		Limit  int    `query:"limit"`
+		Offset int    `query:"offset"`
+	}
+	type Response struct {
+		Total    int                   `json:"total"`
+		Messages []email.OutboxMessage `json:"messages"`
+	}
+	return func(c echo.Context) error {
+		query := Query{Limit: 50}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &query); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid query parameters")
+		}
+		if query.Status != "" && !domain.StringArray(email.OutboxStatuses).Has(query.Status) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid status")
+		}
+		if query.Limit <= 0 || query.Limit > 500 || query.Offset < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid pagination parameters")
+		}
+		messages, total, err := s.outbox.List(query.Status, query.Limit, query.Offset)
+		if err != nil {
+			return fmt.Errorf("listing outbox messages: %w", err)
+		}
+		return c.JSON(http.StatusOK, Response{Total: total, Messages: messages})
+	}
+}
+
+func (s *Server) handleGetOutboxMessage(c echo.Context) error {
+	id, err := outboxMessageID(c)
+	if err != nil {
+		return err
+	}
+	msg, err := s.outbox.Get(id)
+	if err != nil {
+		if errors.Is(err, email.ErrMessageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return fmt.Errorf("getting outbox message: %w", err)
+	}
+	return c.JSON(http.StatusOK, msg)
+}
+
+func (s *Server) handleResendMessage(c echo.Context) error {
+	id, err := outboxMessageID(c)
+	if err != nil {
+		return err
+	}
+	if err := s.outbox.Resend(id); err != nil {
+		if errors.Is(err, email.ErrMessageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return fmt.Errorf("resending outbox message: %w", err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleResendFailedMessages(c echo.Context) error {
+	n, err := s.outbox.ResendFailed()
+	if err != nil {
+		return fmt.Errorf("resending failed outbox messages: %w", err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"count": n})
+}
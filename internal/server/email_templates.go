@@ -0,0 +1,126 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gisquick/gisquick-server/internal/infrastructure/email"
+	"github.com/labstack/echo/v4"
+)
+
+// AddEmailTemplates registers administration of email templates (overrides of templates
+// from the templates directory)
+func (s *Server) AddEmailTemplates(templates *email.Templates) {
+	s.emailTemplates = templates
+	e := s.echo
+	SuperuserRequired := s.middlewares.SuperuserRequired
+	e.GET("/api/admin/email_templates", s.handleGetEmailTemplates, SuperuserRequired)
+	e.GET("/api/admin/email_templates/:name", s.handleGetEmailTemplate, SuperuserRequired)
+	e.PUT("/api/admin/email_templates/:name", s.handleSaveEmailTemplate(), SuperuserRequired)
+	e.DELETE("/api/admin/email_templates/:name", s.handleDeleteEmailTemplate, SuperuserRequired)
+}
+
+func emailTemplateError(err error, msg string) error {
+	switch {
+	case errors.Is(err, email.ErrTemplateNotFound),
+		errors.Is(err, email.ErrOverrideNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, email.ErrInvalidLanguage),
+		errors.Is(err, email.ErrInvalidTemplate):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// templateLanguage returns language from 'lang' query parameter (empty for default templates)
+func templateLanguage(c echo.Context) (string, error) {
+	lang := c.QueryParam("lang")
+	if lang == "" {
+		return "", nil
+	}
+	if lang = email.NormalizeLanguage(lang); lang == "" {
+		return "", echo.NewHTTPError(http.StatusBadRequest, email.ErrInvalidLanguage.Error())
+	}
+	return lang, nil
+}
+
+func (s *Server) handleGetEmailTemplates(c echo.Context) error {
+	type Response struct {
+		Templates []string                 `json:"templates"`
+		Languages []string                 `json:"languages"`
+		Overrides []email.TemplateOverride `json:"overrides"`
+	}
+	languages, err := s.emailTemplates.Languages()
+	if err != nil {
+		return fmt.Errorf("listing email template languages: %w", err)
+	}
+	overrides, err := s.emailTemplates.ListOverrides()
+	if err != nil {
+		return fmt.Errorf("listing email template overrides: %w", err)
+	}
+	return c.JSON(http.StatusOK, Response{Templates: email.TemplateNames, Languages: languages, Overrides: overrides})
+}
+
+// handleGetEmailTemplate returns the effective source of the template in the given language
+func (s *Server) handleGetEmailTemplate(c echo.Context) error {
+	lang, err := templateLanguage(c)
+	if err != nil {
+		return err
+	}
+	src, err := s.emailTemplates.Source(c.Param("name"), lang)
+	if err != nil {
+		return emailTemplateError(err, "getting email template")
+	}
+	return c.JSON(http.StatusOK, src)
+}
+
+func (s *Server) handleSaveEmailTemplate() func(echo.Context) error {
+	type TemplateForm struct {
+		Subject string `json:"subject"`
+		HTML    string `json:"html"`
+		Text    string `json:"text"`
+	}
+	return func(c echo.Context) error {
+		lang, err := templateLanguage(c)
+		if err != nil {
+			return err
+		}
+		var form TemplateForm
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+		if err := (&echo.DefaultBinder{}).BindBody(c, &form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if form.Subject == "" && form.HTML == "" && form.Text == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Empty template")
+		}
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		override, err := s.emailTemplates.SaveOverride(email.TemplateOverride{
+			Name:      c.Param("name"),
+			Language:  lang,
+			Subject:   form.Subject,
+			HTML:      form.HTML,
+			Text:      form.Text,
+			UpdatedBy: user.Username,
+		})
+		if err != nil {
+			return emailTemplateError(err, "saving email template")
+		}
+		return c.JSON(http.StatusOK, override)
+	}
+}
+
+func (s *Server) handleDeleteEmailTemplate(c echo.Context) error {
+	lang, err := templateLanguage(c)
+	if err != nil {
+		return err
+	}
+	if err := s.emailTemplates.DeleteOverride(c.Param("name"), lang); err != nil {
+		return emailTemplateError(err, "deleting email template")
+	}
+	return c.NoContent(http.StatusOK)
+}
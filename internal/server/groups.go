@@ -0,0 +1,166 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *Server) AddUserGroups(groups domain.GroupsRepository) {
+	s.groups = groups
+	e := s.echo
+	// list of groups for project roles settings
+	e.GET("/api/groups", s.handleGetGroupsNames, s.middlewares.LoginRequired)
+
+	SuperuserRequired := s.middlewares.SuperuserRequired
+	e.GET("/api/admin/groups", s.handleGetGroups, SuperuserRequired)
+	e.POST("/api/admin/groups", s.handleCreateGroup(), SuperuserRequired)
+	e.GET("/api/admin/groups/:name", s.handleGetGroup, SuperuserRequired)
+	e.PUT("/api/admin/groups/:name", s.handleUpdateGroup(), SuperuserRequired)
+	e.DELETE("/api/admin/groups/:name", s.handleDeleteGroup, SuperuserRequired)
+}
+
+func groupError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrGroupNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Group not found")
+	case errors.Is(err, domain.ErrGroupExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrInvalidName):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrAccountNotFound):
+		return echo.NewHTTPError(http.StatusBadRequest, "User not found")
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func (s *Server) handleGetGroupsNames(c echo.Context) error {
+	type GroupInfo struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	groups, err := s.groups.List()
+	if err != nil {
+		return fmt.Errorf("listing user groups: %w", err)
+	}
+	data := make([]GroupInfo, len(groups))
+	for i, g := range groups {
+		data[i] = GroupInfo{Name: g.Name, Description: g.Description}
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *Server) handleGetGroups(c echo.Context) error {
+	groups, err := s.groups.List()
+	if err != nil {
+		return fmt.Errorf("listing user groups: %w", err)
+	}
+	return c.JSON(http.StatusOK, groups)
+}
+
+func (s *Server) handleGetGroup(c echo.Context) error {
+	group, err := s.groups.Get(c.Param("name"))
+	if err != nil {
+		return groupError(err, "getting user group")
+	}
+	return c.JSON(http.StatusOK, group)
+}
+
+type GroupForm struct {
+	Description string    `json:"description" validate:"max=255"`
+	Members     *[]string `json:"members"`
+}
+
+// saveGroupMembers replaces group members and invalidates cached memberships of affected users
+func (s *Server) saveGroupMembers(name string, previous, members []string) error {
+	if err := s.groups.SetMembers(name, members); err != nil {
+		return err
+	}
+	s.auth.InvalidateMemberships(previous...)
+	s.auth.InvalidateMemberships(members...)
+	return nil
+}
+
+func (s *Server) handleCreateGroup() func(echo.Context) error {
+	type CreateGroupForm struct {
+		GroupForm
+		Name string `json:"name" validate:"required"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		form := new(CreateGroupForm)
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		group, err := domain.NewGroup(form.Name, form.Description)
+		if err != nil {
+			return groupError(err, "creating user group")
+		}
+		if form.Members != nil {
+			group.Members = *form.Members
+		}
+		if err := s.groups.Create(group); err != nil {
+			return groupError(err, "creating user group")
+		}
+		s.auth.InvalidateMemberships(group.Members...)
+		group, err = s.groups.Get(group.Name)
+		if err != nil {
+			return groupError(err, "getting user group")
+		}
+		return c.JSON(http.StatusCreated, group)
+	}
+}
+
+func (s *Server) handleUpdateGroup() func(echo.Context) error {
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		form := new(GroupForm)
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		group, err := s.groups.Get(c.Param("name"))
+		if err != nil {
+			return groupError(err, "getting user group")
+		}
+		group.Description = form.Description
+		if err := s.groups.Update(group); err != nil {
+			return groupError(err, "updating user group")
+		}
+		if form.Members != nil {
+			if err := s.saveGroupMembers(group.Name, group.Members, *form.Members); err != nil {
+				return groupError(err, "saving group members")
+			}
+		}
+		group, err = s.groups.Get(group.Name)
+		if err != nil {
+			return groupError(err, "getting user group")
+		}
+		return c.JSON(http.StatusOK, group)
+	}
+}
+
+func (s *Server) handleDeleteGroup(c echo.Context) error {
+	group, err := s.groups.Get(c.Param("name"))
+	if err != nil {
+		return groupError(err, "getting user group")
+	}
+	if err := s.groups.Delete(group.Name); err != nil {
+		return groupError(err, "deleting user group")
+	}
+	s.auth.InvalidateMemberships(group.Members...)
+	return c.NoContent(http.StatusOK)
+}
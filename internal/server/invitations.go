@@ -0,0 +1,246 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// AddInvitations registers invitations of users into projects
+func (s *Server) AddInvitations(service *application.InvitationsService) {
+	s.invitations = service
+	e := s.echo
+	LoginRequired := s.middlewares.LoginRequired
+	SuperuserRequired := s.middlewares.SuperuserRequired
+	ProjectAdminAccess := s.middlewares.ProjectAdminAccess
+	e.GET("/api/project/invitations/:user/:name", s.handleGetProjectInvitations, ProjectAdminAccess)
+	e.POST("/api/project/invitations/:user/:name", s.handleCreateInvitation(), ProjectAdminAccess)
+	e.DELETE("/api/project/invitations/:user/:name/:id", s.handleRevokeInvitation, ProjectAdminAccess)
+	e.GET("/api/account/invitations", s.handleGetUserInvitations, LoginRequired)
+	e.POST("/api/account/invitations/:id/accept", s.handleAcceptInvitation, LoginRequired)
+	e.POST("/api/account/invitations/:id/decline", s.handleDeclineInvitation, LoginRequired)
+	e.GET("/api/admin/invitations", s.handleGetAllInvitations, SuperuserRequired)
+}
+
+func invitationError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrInvitationNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Invitation not found")
+	case errors.Is(err, domain.ErrInvitationExpired),
+		errors.Is(err, domain.ErrInvitationClosed):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, application.ErrInvitationPermission):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, domain.ErrInvalidGrant),
+		errors.Is(err, domain.ErrInvalidEmail),
+		errors.Is(err, domain.ErrAccountExists),
+		errors.Is(err, domain.ErrEmailExists),
+		errors.Is(err, application.ErrUsernameRequired):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func invitationID(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid invitation id")
+	}
+	return id, nil
+}
+
+// InvitationInfo is invitation with its current status
+type InvitationInfo struct {
+	domain.Invitation
+	Status string `json:"status"`
+}
+
+func toInvitationsInfo(invitations []domain.Invitation) []InvitationInfo {
+	now := time.Now()
+	data := make([]InvitationInfo, len(invitations))
+	for i, inv := range invitations {
+		data[i] = InvitationInfo{inv, inv.Status(now)}
+	}
+	return data
+}
+
+func toInvitationInfo(invitation domain.Invitation) InvitationInfo {
+	return InvitationInfo{invitation, invitation.Status(time.Now())}
+}
+
+// ProjectInvitationInfo is invitation as seen by administrators of the project, without
+// identity of the invited account (whether the email is registered and its username)
+type ProjectInvitationInfo struct {
+	ID        int64                    `json:"id"`
+	Email     string                   `json:"email"`
+	Project   string                   `json:"project"`
+	Grants    []domain.InvitationGrant `json:"grants"`
+	InvitedBy string                   `json:"invited_by"`
+	Created   time.Time                `json:"created_at"`
+	Expires   time.Time                `json:"expires_at"`
+	Accepted  *time.Time               `json:"accepted_at"`
+	Revoked   *time.Time               `json:"revoked_at"`
+	Status    string                   `json:"status"`
+}
+
+func toProjectInvitationInfo(i domain.Invitation, now time.Time) ProjectInvitationInfo {
+	return ProjectInvitationInfo{
+		ID:        i.ID,
+		Email:     i.Email,
+		Project:   i.Project,
+		Grants:    i.Grants,
+		InvitedBy: i.InvitedBy,
+		Created:   i.Created,
+		Expires:   i.Expires,
+		Accepted:  i.Accepted,
+		Revoked:   i.Revoked,
+		Status:    i.Status(now),
+	}
+}
+
+func toProjectInvitationsInfo(invitations []domain.Invitation) []ProjectInvitationInfo {
+	now := time.Now()
+	data := make([]ProjectInvitationInfo, len(invitations))
+	for i, inv := range invitations {
+		data[i] = toProjectInvitationInfo(inv, now)
+	}
+	return data
+}
+
+func (s *Server) handleGetProjectInvitations(c echo.Context) error {
+	invitations, err := s.invitations.ProjectInvitations(getProjectName(c))
+	if err != nil {
+		return invitationError(err, "listing project invitations")
+	}
+	return c.JSON(http.StatusOK, toProjectInvitationsInfo(invitations))
+}
+
+func (s *Server) handleCreateInvitation() func(echo.Context) error {
+	type InvitationForm struct {
+		Email string `json:"email" validate:"required,email"`
+		// required for a new user
+		Username  string                   `json:"username"`
+		FirstName string                   `json:"first_name"`
+		LastName  string                   `json:"last_name"`
+		Language  string                   `json:"lang"`
+		Grants    []domain.InvitationGrant `json:"grants" validate:"required"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		if !s.accountsService.SupportEmails() {
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "Email service not supported")
+		}
+		form := new(InvitationForm)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		if err := s.auth.CheckInvitationLimit(c, user.Username); err != nil {
+			return err
+		}
+		invitation, err := s.invitations.Invite(user, application.InvitationRequest{
+			Email:     form.Email,
+			Username:  form.Username,
+			FirstName: form.FirstName,
+			LastName:  form.LastName,
+			Profile:   s.languageProfile(form.Language),
+			Project:   getProjectName(c),
+			Grants:    form.Grants,
+		})
+		if err != nil {
+			return invitationError(err, "creating invitation")
+		}
+		return c.JSON(http.StatusOK, toProjectInvitationInfo(invitation, time.Now()))
+	}
+}
+
+func (s *Server) handleRevokeInvitation(c echo.Context) error {
+	id, err := invitationID(c)
+	if err != nil {
+		return err
+	}
+	invitation, err := s.invitations.Get(id)
+	if err != nil {
+		return invitationError(err, "getting invitation")
+	}
+	if invitation.Project != getProjectName(c) {
+		return echo.NewHTTPError(http.StatusNotFound, "Invitation not found")
+	}
+	invitation, err = s.invitations.Revoke(invitation)
+	if err != nil {
+		return invitationError(err, "revoking invitation")
+	}
+	return c.JSON(http.StatusOK, toProjectInvitationInfo(invitation, time.Now()))
+}
+
+func (s *Server) handleGetUserInvitations(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	invitations, err := s.invitations.UserInvitations(user.Username)
+	if err != nil {
+		return invitationError(err, "listing user invitations")
+	}
+	return c.JSON(http.StatusOK, toInvitationsInfo(invitations))
+}
+
+func (s *Server) handleAcceptInvitation(c echo.Context) error {
+	id, err := invitationID(c)
+	if err != nil {
+		return err
+	}
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	invitation, err := s.invitations.Accept(user.Username, id)
+	if err != nil {
+		return invitationError(err, "accepting invitation")
+	}
+	return c.JSON(http.StatusOK, toInvitationInfo(invitation))
+}
+
+func (s *Server) handleDeclineInvitation(c echo.Context) error {
+	id, err := invitationID(c)
+	if err != nil {
+		return err
+	}
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	invitation, err := s.invitations.Get(id)
+	if err != nil {
+		return invitationError(err, "getting invitation")
+	}
+	if invitation.Username != user.Username {
+		return echo.NewHTTPError(http.StatusNotFound, "Invitation not found")
+	}
+	invitation, err = s.invitations.Revoke(invitation)
+	if err != nil {
+		return invitationError(err, "declining invitation")
+	}
+	return c.JSON(http.StatusOK, toInvitationInfo(invitation))
+}
+
+func (s *Server) handleGetAllInvitations(c echo.Context) error {
+	invitations, err := s.invitations.AllInvitations()
+	if err != nil {
+		return invitationError(err, "listing invitations")
+	}
+	return c.JSON(http.StatusOK, toInvitationsInfo(invitations))
+}
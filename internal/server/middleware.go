@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"strings"
 
 	"github.com/gisquick/gisquick-server/internal/application"
 	"github.com/gisquick/gisquick-server/internal/domain"
@@ -23,6 +24,12 @@ func LoginRequiredMiddlewareWithConfig(a *auth.AuthService) echo.MiddlewareFunc
 			}
 			if si == nil {
 				// add support to basic auth here? (with a.GetUser())
+				if _, ok := auth.BearerToken(c); ok {
+					user, err := a.GetUser(c)
+					if err == nil && user.IsAuthenticated {
+						return next(c)
+					}
+				}
 				return echo.ErrUnauthorized
 			}
 			return next(c)
@@ -30,6 +37,81 @@ func LoginRequiredMiddlewareWithConfig(a *auth.AuthService) echo.MiddlewareFunc
 	}
 }
 
+// project routes used for uploading (publishing) of projects
+var projectUploadRoutes = []string{
+	"/api/project/upload/", "/api/project/files/", "/api/project/file/", "/api/project/download/",
+	"/api/project/inline/", "/api/project/script/", "/api/project/ows/", "/api/project/info/",
+	"/api/project/full-info/", "/api/project/meta/", "/api/project/thumbnail/", "/api/project/reload/",
+	"/api/project/validate/",
+}
+
+// project routes used for configuration of projects
+var projectSettingsRoutes = []string{
+	"/api/project/settings/", "/api/project/settings_schema", "/api/project/draft/",
+	"/api/project/schedule/", "/api/project/invitations/",
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// apiTokenScope returns API token scope required for the given request (route is the matched route path)
+func apiTokenScope(method, path, route string) string {
+	read := method == http.MethodGet || method == http.MethodHead
+	switch {
+	case method == http.MethodDelete && route == "/api/project/:user/:name":
+		return domain.ScopeProjectDelete
+	case strings.HasPrefix(path, "/api/map/ows/"), strings.HasPrefix(path, "/api/map/transform/"):
+		// WFS transactions are checked in OWS handler
+		return domain.ScopeMapRead
+	case read && (strings.HasPrefix(path, "/api/map/") || strings.HasPrefix(path, "/api/project/media/")):
+		return domain.ScopeMapRead
+	case read && (path == "/api/app" || path == "/api/projects" || path == "/api/account" || strings.HasPrefix(path, "/api/auth/is_") || path == "/api/auth/user"):
+		return domain.ScopeMapRead
+	case strings.HasPrefix(path, "/api/project/media/"):
+		return domain.ScopeAdmin
+	case method == http.MethodPost && route == "/api/project/:user/:name",
+		hasAnyPrefix(path, projectUploadRoutes), path == "/ws/plugin":
+		return domain.ScopeProjectUpload
+	case hasAnyPrefix(path, projectSettingsRoutes):
+		return domain.ScopeProjectSettings
+	}
+	return domain.ScopeAdmin
+}
+
+// APITokenMiddleware authenticates requests with API tokens and restricts them
+// to endpoints allowed by token's scopes
+func APITokenMiddleware(a *auth.AuthService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := auth.BearerToken(c); !ok {
+				return next(c)
+			}
+			user, err := a.GetUser(c)
+			if err != nil {
+				if errors.Is(err, auth.ErrInvalidAPIToken) {
+					return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API token")
+				}
+				return fmt.Errorf("APITokenMiddleware: %w", err)
+			}
+			path := c.Request().URL.Path
+			if strings.HasPrefix(path, "/api/account/tokens") {
+				// tokens cannot be used to manage tokens
+				return echo.ErrForbidden
+			}
+			if !user.HasScope(apiTokenScope(c.Request().Method, path, c.Path())) {
+				return echo.NewHTTPError(http.StatusForbidden, "Insufficient API token scope")
+			}
+			return next(c)
+		}
+	}
+}
+
 func SuperuserAccessMiddleware(a *auth.AuthService) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -43,6 +125,13 @@ func SuperuserAccessMiddleware(a *auth.AuthService) echo.MiddlewareFunc {
 			if !user.IsSuperuser {
 				return echo.ErrForbidden
 			}
+			missing2FA, err := a.SuperuserTwoFactorMissing(user)
+			if err != nil {
+				return fmt.Errorf("SuperuserAccessMiddleware: %w", err)
+			}
+			if missing2FA {
+				return echo.NewHTTPError(http.StatusForbidden, "Two-factor authentication is required")
+			}
 			return next(c)
 		}
 	}
@@ -57,7 +146,7 @@ func ProjectSuperuserAccessMiddleware(a *auth.AuthService, ps application.Projec
 			if err != nil {
 				return fmt.Errorf("ProjectSuperuserAccessMiddleware: %w", err)
 			}
-			if username != user.Username && !user.IsSuperuser {
+			if !user.CanManageNamespace(username) && !user.IsSuperuser {
 				return echo.ErrUnauthorized
 			}
 			c.Set("project", filepath.Join(username, name))
@@ -76,12 +165,12 @@ func ProjectAdminAccessMiddleware(a *auth.AuthService, ps application.ProjectSer
 			if err != nil {
 				return fmt.Errorf("ProjectAdminAccessMiddleware: %w", err)
 			}
-			if username != user.Username && !user.IsSuperuser {
+			if !user.CanManageNamespace(username) && !user.IsSuperuser {
 				settings, err := ps.GetSettings(projectName)
 				if err != nil {
 					return fmt.Errorf("[ProjectAdminAccessMiddleware] reading project settings: %w", err)
 				}
-				if !domain.StringArray(settings.SettingsAuth.AdminUsers).Has(user.Username) {
+				if !user.InList(settings.SettingsAuth.AdminUsers) {
 					return echo.ErrUnauthorized
 				}
 			}
@@ -117,7 +206,7 @@ func ProjectAccessMiddleware(a *auth.AuthService, ps application.ProjectService,
 				return fmt.Errorf("[ProjectAccessMiddleware] reading project info: %w", err)
 			}
 			access := false
-			if pInfo.Authentication == "public" {
+			if pInfo.Authentication == "public" && !pInfo.Unpublished() {
 				access = true
 			} else {
 				user, err := a.GetUser(c)
@@ -125,16 +214,22 @@ func ProjectAccessMiddleware(a *auth.AuthService, ps application.ProjectService,
 					return fmt.Errorf("[ProjectAccessMiddleware] getting user: %w", err)
 				}
 				if user.IsAuthenticated {
-					if pInfo.Authentication == "authenticated" {
+					if pInfo.Unpublished() {
+						// project which is not published is available only to its administrators (preview)
+						access, err = isProjectAdmin(ps, projectName, user)
+						if err != nil {
+							return fmt.Errorf("[ProjectAccessMiddleware] %w", err)
+						}
+					} else if pInfo.Authentication == "authenticated" {
 						access = true
 					} else {
-						access = user.Username == username || user.IsSuperuser
+						access = user.IsNamespaceMember(username) || user.IsSuperuser
 						if !access && pInfo.Authentication == "users" {
 							settings, err := ps.GetSettings(projectName)
 							if err != nil {
 								return fmt.Errorf("[ProjectAccessMiddleware] reading project settings: %w", err)
 							}
-							access = domain.StringArray(settings.Auth.Users).Has(user.Username)
+							access = user.InList(settings.Auth.Users)
 						}
 					}
 				}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/server/auth"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// safeRedirectPath accepts only local paths to prevent open redirects
+func safeRedirectPath(next string) string {
+	if next == "" || !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Host != "" || u.Scheme != "" {
+		return "/"
+	}
+	return next
+}
+
+const oidcStateCookie = "gq_oidc_state"
+
+// AddOIDCAuth enables login with OpenID Connect provider
+func (s *Server) AddOIDCAuth(oidc *auth.OIDCService) {
+	s.oidc = oidc
+	s.echo.GET("/api/auth/oidc/login", s.handleOIDCLogin)
+	s.echo.GET("/api/auth/oidc/link", s.handleOIDCLink, s.middlewares.LoginRequired)
+	s.echo.GET("/api/auth/oidc/callback", s.handleOIDCCallback)
+}
+
+// startOIDCLogin redirects to the identity provider. State of the login is bound to the browser
+// with short-lived cookie, which is checked in the callback (protection against login CSRF).
+func (s *Server) startOIDCLogin(c echo.Context, linkUsername string) error {
+	next := safeRedirectPath(c.QueryParam("next"))
+	authURL, state, err := s.oidc.StartLogin(c.Request().Context(), next, linkUsername)
+	if err != nil {
+		s.log.Errorw("oidc login", zap.Error(err))
+		return echo.NewHTTPError(http.StatusBadGateway, "Identity provider is not available")
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(auth.OIDCStateExpiration.Seconds()),
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+func (s *Server) handleOIDCLogin(c echo.Context) error {
+	return s.startOIDCLogin(c, "")
+}
+
+// handleOIDCLink links identity from the provider with account of logged in user
+func (s *Server) handleOIDCLink(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	return s.startOIDCLogin(c, user.Username)
+}
+
+func (s *Server) handleOIDCCallback(c echo.Context) error {
+	var browserState string
+	if cookie, err := c.Cookie(oidcStateCookie); err == nil {
+		browserState = cookie.Value
+	}
+	c.SetCookie(&http.Cookie{Name: oidcStateCookie, Path: "/api/auth/oidc", MaxAge: -1, HttpOnly: true})
+
+	if errCode := c.QueryParam("error"); errCode != "" {
+		s.log.Warnw("oidc callback", "error", errCode, "description", c.QueryParam("error_description"))
+		return echo.NewHTTPError(http.StatusUnauthorized, "Authentication failed")
+	}
+	var username string
+	if user, err := s.auth.GetUser(c); err == nil && user.IsAuthenticated {
+		username = user.Username
+	}
+	login, err := s.oidc.FinishLogin(c.Request().Context(), c.QueryParam("state"), browserState, c.QueryParam("code"), username)
+	if err != nil {
+		s.log.Warnw("oidc callback", zap.Error(err))
+		if errors.Is(err, auth.ErrInvalidOIDCState) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired login request")
+		}
+		if errors.Is(err, auth.ErrAccountDisabled) {
+			return echo.NewHTTPError(http.StatusForbidden, "Account is disabled")
+		}
+		if errors.Is(err, auth.ErrOIDCAccountExists) {
+			return echo.NewHTTPError(http.StatusConflict, "Account already exists, log in and link it with the identity provider")
+		}
+		if errors.Is(err, domain.ErrIdentityLinked) {
+			return echo.NewHTTPError(http.StatusConflict, "Identity is already linked to another account")
+		}
+		if errors.Is(err, auth.ErrInvalidIDToken) || errors.Is(err, auth.ErrOIDCClaims) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Authentication failed")
+		}
+		return err
+	}
+	next := safeRedirectPath(login.Next)
+	if login.Linked {
+		return c.Redirect(http.StatusFound, next)
+	}
+	// same two-factor authentication as with password login
+	token, err := s.startTwoFactorLogin(c, login.Account)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		return c.Redirect(http.StatusFound, s.oidc.TwoFactorURL(token, next))
+	}
+	if err := s.auth.LoginUser(c, login.Account); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusFound, next)
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *Server) AddOrganizations(orgs *application.OrganizationsService) {
+	s.organizations = orgs
+	orgs.OnMembershipsChange = s.auth.InvalidateMemberships
+
+	LoginRequired := s.middlewares.LoginRequired
+	SuperuserRequired := s.middlewares.SuperuserRequired
+	e := s.echo
+	e.GET("/api/organizations", s.handleGetOrganizations, LoginRequired)
+	e.GET("/api/organizations/:org", s.handleGetOrganization, LoginRequired)
+	e.PUT("/api/organizations/:org", s.handleUpdateOrganization(), LoginRequired)
+	e.DELETE("/api/organizations/:org", s.handleDeleteOrganization, LoginRequired)
+	e.GET("/api/organizations/:org/projects", s.handleGetOrganizationProjects, LoginRequired)
+	e.PUT("/api/organizations/:org/members/:user", s.handleSetOrganizationMember(), LoginRequired)
+	e.DELETE("/api/organizations/:org/members/:user", s.handleRemoveOrganizationMember, LoginRequired)
+	e.PUT("/api/organizations/:org/teams/:team", s.handleSaveTeam(), LoginRequired)
+	e.DELETE("/api/organizations/:org/teams/:team", s.handleDeleteTeam, LoginRequired)
+
+	e.GET("/api/admin/organizations", s.handleAdminGetOrganizations, SuperuserRequired)
+	e.POST("/api/admin/organizations", s.handleAdminCreateOrganization(), SuperuserRequired)
+	e.PUT("/api/admin/organizations/:org", s.handleAdminUpdateOrganization(), SuperuserRequired)
+}
+
+// organizationError converts errors of organizations service into HTTP errors
+func organizationError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrOrganizationNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Organization not found")
+	case errors.Is(err, domain.ErrTeamNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Team not found")
+	case errors.Is(err, domain.ErrAccountNotFound):
+		return echo.NewHTTPError(http.StatusBadRequest, "User not found")
+	case errors.Is(err, domain.ErrOrganizationExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, application.ErrOrganizationPermission):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, application.ErrOrganizationNotEmpty),
+		errors.Is(err, application.ErrLastOrganizationOwner):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrInvalidName),
+		errors.Is(err, application.ErrInvalidOrganizationRole),
+		errors.Is(err, application.ErrNotOrganizationMember):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func (s *Server) handleGetOrganizations(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	orgs, err := s.organizations.UserOrganizations(user.Username)
+	if err != nil {
+		return fmt.Errorf("listing user organizations [%s]: %w", user.Username, err)
+	}
+	return c.JSON(http.StatusOK, orgs)
+}
+
+func (s *Server) handleGetOrganization(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	org, err := s.organizations.Get(user, c.Param("org"))
+	if err != nil {
+		return organizationError(err, "getting organization")
+	}
+	return c.JSON(http.StatusOK, org)
+}
+
+func (s *Server) handleUpdateOrganization() func(echo.Context) error {
+	type OrganizationForm struct {
+		Title string `json:"title" validate:"max=100"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		form := new(OrganizationForm)
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		org, err := s.organizations.UpdateTitle(user, c.Param("org"), form.Title)
+		if err != nil {
+			return organizationError(err, "updating organization")
+		}
+		return c.JSON(http.StatusOK, org)
+	}
+}
+
+func (s *Server) handleDeleteOrganization(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	if err := s.organizations.Delete(user, c.Param("org")); err != nil {
+		return organizationError(err, "deleting organization")
+	}
+	s.log.Infow("organization deleted", "organization", c.Param("org"), "user", user.Username)
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleGetOrganizationProjects(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	projects, err := s.organizations.Projects(user, c.Param("org"))
+	if err != nil {
+		return organizationError(err, "listing organization projects")
+	}
+	return c.JSON(http.StatusOK, projects)
+}
+
+func (s *Server) handleSetOrganizationMember() func(echo.Context) error {
+	type MemberForm struct {
+		Role string `json:"role" validate:"required"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		form := new(MemberForm)
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err := s.organizations.SetMember(user, c.Param("org"), c.Param("user"), form.Role); err != nil {
+			return organizationError(err, "setting organization member")
+		}
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+func (s *Server) handleRemoveOrganizationMember(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	if err := s.organizations.RemoveMember(user, c.Param("org"), c.Param("user")); err != nil {
+		return organizationError(err, "removing organization member")
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleSaveTeam() func(echo.Context) error {
+	type TeamForm struct {
+		Members []string `json:"members"`
+	}
+	return func(c echo.Context) error {
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		form := new(TeamForm)
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		team, err := s.organizations.SaveTeam(user, c.Param("org"), c.Param("team"), form.Members)
+		if err != nil {
+			return organizationError(err, "saving team")
+		}
+		return c.JSON(http.StatusOK, team)
+	}
+}
+
+func (s *Server) handleDeleteTeam(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	if err := s.organizations.DeleteTeam(user, c.Param("org"), c.Param("team")); err != nil {
+		return organizationError(err, "deleting team")
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleAdminGetOrganizations(c echo.Context) error {
+	orgs, err := s.organizations.AllOrganizations()
+	if err != nil {
+		return fmt.Errorf("listing organizations: %w", err)
+	}
+	return c.JSON(http.StatusOK, orgs)
+}
+
+func (s *Server) handleAdminCreateOrganization() func(echo.Context) error {
+	type OrganizationForm struct {
+		Name  string `json:"name" validate:"required"`
+		Title string `json:"title" validate:"max=100"`
+		Owner string `json:"owner" validate:"required"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		form := new(OrganizationForm)
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		org, err := s.organizations.Create(form.Name, form.Title, form.Owner)
+		if err != nil {
+			return organizationError(err, "creating organization")
+		}
+		return c.JSON(http.StatusCreated, org)
+	}
+}
+
+// handleAdminUpdateOrganization updates title and limits of the organization
+func (s *Server) handleAdminUpdateOrganization() func(echo.Context) error {
+	type OrganizationForm struct {
+		Title            string           `json:"title" validate:"max=100"`
+		ProjectsLimit    *int             `json:"projects_limit"`
+		ProjectSizeLimit *domain.ByteSize `json:"project_size_limit"`
+		StorageLimit     *domain.ByteSize `json:"storage_limit"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		form := new(OrganizationForm)
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+		if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		org, err := s.organizations.Repository.Get(c.Param("org"))
+		if err != nil {
+			return organizationError(err, "getting organization")
+		}
+		org.Title = form.Title
+		org.ProjectsLimit = form.ProjectsLimit
+		org.ProjectSizeLimit = form.ProjectSizeLimit
+		org.StorageLimit = form.StorageLimit
+		if err := s.organizations.Update(org); err != nil {
+			return organizationError(err, "updating organization")
+		}
+		return c.JSON(http.StatusOK, org)
+	}
+}
@@ -262,6 +262,10 @@ func (s *Server) handleMapOws() func(c echo.Context) error {
 				}
 
 				if params.Request == "" && req.Method == "POST" { // GetFeature Insert/Update/Delete
+					if !user.HasScope(domain.ScopeAdmin) {
+						// API tokens with limited scopes have read-only access
+						return echo.ErrForbidden
+					}
 					var wfsTransaction Transaction
 					// read all bytes from content body and create new stream using it.
 					bodyBytes, _ := ioutil.ReadAll(req.Body)
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/gisquick/gisquick-server/internal/application"
 	"github.com/gisquick/gisquick-server/internal/domain"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -19,6 +20,25 @@ func getProjectName(c echo.Context) string {
 	return filepath.Join(user, name)
 }
 
+// isProjectAdmin checks whether user can manage project's settings (the same rules as ProjectAdminAccess middleware)
+func (s *Server) isProjectAdmin(projectName string, user domain.User) (bool, error) {
+	return isProjectAdmin(s.projects, projectName, user)
+}
+
+func isProjectAdmin(ps application.ProjectService, projectName string, user domain.User) (bool, error) {
+	if !user.IsAuthenticated {
+		return false, nil
+	}
+	if user.IsSuperuser || user.CanManageNamespace(strings.Split(projectName, "/")[0]) {
+		return true, nil
+	}
+	settings, err := ps.GetSettings(projectName)
+	if err != nil {
+		return false, fmt.Errorf("reading project settings: %w", err)
+	}
+	return user.InList(settings.SettingsAuth.AdminUsers), nil
+}
+
 func (s *Server) handleGetProject() func(c echo.Context) error {
 	type Notification struct {
 		ID      string `json:"id"`
@@ -36,7 +56,20 @@ func (s *Server) handleGetProject() func(c echo.Context) error {
 			}
 			return err
 		}
-		if info.State != "published" {
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		_, draft := c.QueryParams()["draft"]
+		if draft {
+			isAdmin, err := s.isProjectAdmin(projectName, user)
+			if err != nil {
+				return err
+			}
+			if !isAdmin {
+				return echo.ErrForbidden
+			}
+		} else if info.State != "published" {
 			return echo.NewHTTPError(http.StatusBadRequest, "Project not valid")
 		}
 
@@ -44,8 +77,12 @@ func (s *Server) handleGetProject() func(c echo.Context) error {
 		// 	return echo.ErrForbidden
 		// }
 
-		user, err := s.auth.GetUser(c)
-		data, err := s.projects.GetMapConfig(projectName, user)
+		var data map[string]interface{}
+		if draft {
+			data, err = s.projects.GetDraftMapConfig(projectName, user)
+		} else {
+			data, err = s.projects.GetMapConfig(projectName, user)
+		}
 		if err != nil {
 			return err
 		}
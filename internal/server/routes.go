@@ -20,17 +20,25 @@ func (s *Server) AddRoutes(e *echo.Echo) {
 	ProjectAccessOWS := s.middlewares.ProjectAccessOWS
 
 	e.POST("/api/auth/login", s.handleLogin())
+	e.POST("/api/auth/login/2fa", s.handleLoginTwoFactor())
 	e.POST("/api/auth/logout", s.handleLogout)
 	e.GET("/api/auth/logout", s.handleLogout) // Just for compatibility!!!
 
-	e.GET("/api/users", s.handleGetUsers, LoginRequired)
+	e.GET("/api/users", s.handleGetUsers(), LoginRequired)
 
 	e.GET("/api/admin/config", s.handleAdminConfig, SuperuserRequired)
-	e.GET("/api/admin/users", s.handleGetAllUsers, SuperuserRequired)
+	e.GET("/api/admin/users", s.handleGetAllUsers(), SuperuserRequired)
+	e.GET("/api/admin/users/export", s.handleExportUsers, SuperuserRequired)
+	e.POST("/api/admin/users/bulk", s.handleUsersBulkAction(), SuperuserRequired)
+	e.POST("/api/admin/users/import", s.handleImportUsers(), SuperuserRequired)
 	e.GET("/api/admin/users/:user", s.handleGetUser, SuperuserRequired)
 	e.PUT("/api/admin/users/:user", s.handleUpdateUser(), SuperuserRequired)
 	e.PUT("/api/admin/users/profile/:user", s.handleAdminUpdateUserProfile, SuperuserRequired)
 	e.DELETE("/api/admin/users/:user", s.handleDeleteUser, SuperuserRequired)
+	e.DELETE("/api/admin/users/2fa/:user", s.handleAdminResetTwoFactor, SuperuserRequired)
+	e.DELETE("/api/admin/users/sessions/:user", s.handleAdminDeleteUserSessions, SuperuserRequired)
+	e.GET("/api/admin/users/lock/:user", s.handleGetUserLoginLock, SuperuserRequired)
+	e.DELETE("/api/admin/users/lock/:user", s.handleUnlockUserLogin, SuperuserRequired)
 	e.POST("/api/admin/user", s.handleCreateUser(), SuperuserRequired)
 	e.POST("/api/admin/email_preview", s.handleGetEmailPreview(), SuperuserRequired)
 	e.POST("/api/admin/email", s.handleSendEmail(), SuperuserRequired)
@@ -48,8 +56,23 @@ func (s *Server) AddRoutes(e *echo.Echo) {
 	e.POST("/api/accounts/password_reset", s.handlePasswordReset())
 	e.POST("/api/accounts/new_password", s.handleNewPassword())
 	e.POST("/api/accounts/change_password", s.handleChangePassword(), LoginRequired)
+	e.POST("/api/accounts/change_expired_password", s.handleChangeExpiredPassword())
+	e.POST("/api/account/email", s.handleRequestEmailChange(), LoginRequired)
+	e.POST("/api/accounts/email/confirm", s.handleConfirmEmailChange())
 	e.GET("/api/account", s.handleGetAccountInfo(), LoginRequired)
 	e.PUT("/api/account/profile", s.handleUpdateAccountProfile, LoginRequired)
+	e.GET("/api/account/export", s.handleExportAccountData, LoginRequired)
+	e.GET("/api/account/tokens", s.handleGetAPITokens, LoginRequired)
+	e.POST("/api/account/tokens", s.handleCreateAPIToken(), LoginRequired)
+	e.DELETE("/api/account/tokens/:id", s.handleDeleteAPIToken, LoginRequired)
+	e.GET("/api/account/sessions", s.handleGetSessions, LoginRequired)
+	e.DELETE("/api/account/sessions", s.handleDeleteOtherSessions, LoginRequired)
+	e.DELETE("/api/account/sessions/:id", s.handleDeleteSession, LoginRequired)
+	e.GET("/api/account/2fa", s.handleGetTwoFactorStatus, LoginRequired)
+	e.POST("/api/account/2fa/setup", s.handleSetupTwoFactor, LoginRequired)
+	e.POST("/api/account/2fa/enable", s.handleEnableTwoFactor, LoginRequired)
+	e.POST("/api/account/2fa/disable", s.handleDisableTwoFactor, LoginRequired)
+	e.POST("/api/account/2fa/recovery_codes", s.handleRegenerateRecoveryCodes, LoginRequired)
 
 	e.GET("/api/auth/user", s.handleGetSessionUser)
 	e.GET("/api/auth/is_authenticated", s.handleGetSessionUser, LoginRequired)
@@ -71,6 +94,8 @@ func (s *Server) AddRoutes(e *echo.Echo) {
 	e.DELETE("/api/project/files/:user/:name", s.handleDeleteProjectFiles(), ProjectAdminAccess)
 	e.GET("/api/project/info/:user/:name", s.handleGetProjectInfo, ProjectAdminAccess)
 	e.GET("/api/project/full-info/:user/:name", s.handleGetProjectFullInfo(), ProjectAdminAccess)
+	e.POST("/api/project/schedule/:user/:name", s.handleSetPublishSchedule, ProjectAdminAccess)
+	e.DELETE("/api/project/schedule/:user/:name", s.handleDeletePublishSchedule, ProjectAdminAccess)
 
 	e.GET("/api/project/media/:user/:name/*", s.mediaFileHandler("/tmp/thumbnails"), ProjectAccess)
 	e.GET("/api/project/media/:user/:name/web/app/*", s.appMediaFileHandler)
@@ -87,6 +112,14 @@ func (s *Server) AddRoutes(e *echo.Echo) {
 	e.POST("/api/project/meta/:user/:name", s.handleUpdateProjectMeta(), ProjectAdminAccess)
 
 	e.POST("/api/project/settings/:user/:name", s.handleSaveProjectSettings, ProjectAdminAccess)
+	e.GET("/api/project/draft/:user/:name", s.handleGetDraftSettings, ProjectAdminAccess)
+	e.POST("/api/project/draft/:user/:name", s.handleSaveDraftSettings, ProjectAdminAccess)
+	e.DELETE("/api/project/draft/:user/:name", s.handleDeleteDraftSettings, ProjectAdminAccess)
+	e.POST("/api/project/draft/promote/:user/:name", s.handlePromoteDraftSettings, ProjectAdminAccess)
+	e.POST("/api/project/settings/rollback/:user/:name", s.handleRollbackSettings, ProjectAdminAccess)
+	e.GET("/api/project/settings_schema", s.handleGetSettingsSchema, LoginRequired)
+	e.GET("/api/project/validate/:user/:name", s.handleValidateProject, ProjectAdminAccess)
+	e.POST("/api/project/validate/:user/:name", s.handleValidateSettings, ProjectAdminAccess)
 	e.POST("/api/project/thumbnail/:user/:name", s.handleUploadThumbnail, ProjectAdminAccess)
 	e.GET("/api/project/thumbnail/:user/:name", s.handleGetThumbnail)
 	e.GET("/api/map/project/:user/:name", s.handleGetProject(), MiddlewareErrorHandler(ProjectAccess, func(e error, c echo.Context) error {
@@ -130,6 +163,8 @@ func (s *Server) AddRoutes(e *echo.Echo) {
 	e.POST("/api/map/ows/:user/:name", owsHandler, ProjectAccessOWS)
 	e.GET("/api/map/capabilities/:user/:name", s.handleGetLayerCapabilities(), ProjectAccess)
 	e.GET("/api/map/search/:user/:name/*", s.handleSearch(), ProjectAccess)
+	e.GET("/api/map/projections/:user/:name", s.handleGetProjections, ProjectAccess)
+	e.POST("/api/map/transform/:user/:name", s.handleTransform(), ProjectAccess)
 
 	e.POST("/api/project/reload/:user/:name", s.handleProjectReload, ProjectAdminAccess)
 
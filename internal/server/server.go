@@ -3,11 +3,17 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/infrastructure/email"
 	"github.com/gisquick/gisquick-server/internal/infrastructure/project"
 	"github.com/gisquick/gisquick-server/internal/infrastructure/ws"
 	"github.com/gisquick/gisquick-server/internal/server/auth"
@@ -35,6 +41,10 @@ type Config struct {
 	PluginsURL           string
 	MaxProjectSize       int64
 	ProjectCustomization bool
+	StrictValidation     bool
+	// networks of reverse proxies trusted to set X-Forwarded-For header (in addition
+	// to loopback and private networks)
+	TrustedProxies []*net.IPNet
 }
 
 var extensions = make(map[string]func(s *Server) error, 0)
@@ -56,12 +66,22 @@ type Server struct {
 	auth              *auth.AuthService
 	accountsService   *application.AccountsService
 	projects          application.ProjectService
-	notifications     *project.RedisNotificationStore
+	notifications     project.NotificationStore
 	middlewares       Middlewares
 	sws               *ws.SettingsWS
 	limiter           application.AccountsLimiter
 	shutdownCallbacks []func()
 	db                *sqlx.DB
+	oidc              *auth.OIDCService
+	organizations     *application.OrganizationsService
+	groups            domain.GroupsRepository
+	outbox            *email.Outbox
+	campaigns         *application.CampaignsService
+	emailTemplates    *email.Templates
+	accountDeletion   *application.AccountDeletionService
+	invitations       *application.InvitationsService
+	audit             *application.AuditService
+	usage             *application.UsageService
 }
 
 type JSONSerializer struct{}
@@ -93,15 +113,37 @@ func (d JSONSerializer) Deserialize(c echo.Context, i interface{}) error {
 
 func NewServer(log *zap.SugaredLogger, cfg Config, db *sqlx.DB,
 	as *auth.AuthService, signUpService *application.AccountsService, projects application.ProjectService,
-	sws *ws.SettingsWS, limiter application.AccountsLimiter, notifications *project.RedisNotificationStore) *Server {
+	sws *ws.SettingsWS, limiter application.AccountsLimiter, notifications project.NotificationStore) *Server {
 	e := echo.New()
 	e.HideBanner = true
+	trustOptions := make([]echo.TrustOption, len(cfg.TrustedProxies))
+	for i, ipNet := range cfg.TrustedProxies {
+		trustOptions[i] = echo.TrustIPRange(ipNet)
+	}
+	// client's IP address from X-Forwarded-For header is used only when sent by trusted proxy
+	e.IPExtractor = echo.ExtractIPFromXFFHeader(trustOptions...)
 
 	p := prometheus.NewPrometheus("api", nil)
 	p.Use(e)
 
 	// e.JSONSerializer = &JSONSerializer{}
 	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		var limitErr *auth.LimitError
+		if errors.As(err, &limitErr) {
+			c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+			if limitErr.Locked {
+				err = echo.NewHTTPError(http.StatusTooManyRequests, "Account is temporarily locked")
+			} else {
+				err = echo.NewHTTPError(http.StatusTooManyRequests, "Too many attempts, try again later")
+			}
+		}
+		var policyErr *domain.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			err = echo.NewHTTPError(http.StatusBadRequest, echo.Map{
+				"message":    "Password doesn't satisfy password policy",
+				"violations": policyErr.Violations,
+			})
+		}
 		e.DefaultHTTPErrorHandler(err, c)
 		code := http.StatusInternalServerError
 		if he, ok := err.(*echo.HTTPError); ok {
@@ -138,6 +180,7 @@ func NewServer(log *zap.SugaredLogger, cfg Config, db *sqlx.DB,
 			},
 		}),
 		// SessionMiddlewareWithConfig(as.rdb),
+		APITokenMiddleware(as),
 	)
 	s := &Server{
 		Config:          cfg,
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gisquick/gisquick-server/internal/server/auth"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *Server) handleGetSessions(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	sessions, err := s.auth.ListSessions(c, user.Username)
+	if err != nil {
+		return fmt.Errorf("listing sessions [%s]: %w", user.Username, err)
+	}
+	return c.JSON(http.StatusOK, sessions)
+}
+
+func (s *Server) handleDeleteSession(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	if err := s.auth.RevokeSession(c.Request().Context(), user.Username, c.Param("id")); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return fmt.Errorf("revoking session [%s]: %w", user.Username, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// handleDeleteOtherSessions revokes all user's sessions except the current one
+func (s *Server) handleDeleteOtherSessions(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	current := ""
+	if si, err := s.auth.GetSessionInfo(c); err == nil && si != nil {
+		current = si.ID
+	}
+	if _, err := s.auth.RevokeUserSessions(c.Request().Context(), user.Username, current); err != nil {
+		return fmt.Errorf("revoking sessions [%s]: %w", user.Username, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleAdminDeleteUserSessions(c echo.Context) error {
+	type Payload struct {
+		Revoked int `json:"revoked"`
+	}
+	username := c.Param("user")
+	count, err := s.auth.RevokeUserSessions(c.Request().Context(), username, "")
+	if err != nil {
+		return fmt.Errorf("revoking sessions [%s]: %w", username, err)
+	}
+	return c.JSON(http.StatusOK, Payload{Revoked: count})
+}
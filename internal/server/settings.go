@@ -102,7 +102,7 @@ func (s *Server) handleGetProjects() func(echo.Context) error {
 			return c.JSON(http.StatusOK, data)
 		}
 		if strings.EqualFold(queryParams.Filter, "accessible") {
-			data, err := s.projects.AccessibleProjects(user.Username, true)
+			data, err := s.projects.AccessibleProjects(user, true)
 			if err != nil {
 				return fmt.Errorf("getting list of user accessible projects: %w", err)
 			}
@@ -136,6 +136,37 @@ func (s *Server) handleDeleteProject(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+func (s *Server) handleSetPublishSchedule(c echo.Context) error {
+	projectName := c.Get("project").(string)
+	schedule := new(domain.PublishSchedule)
+	if err := (&echo.DefaultBinder{}).BindBody(c, schedule); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	info, err := s.projects.SetPublishSchedule(projectName, schedule)
+	if err != nil {
+		if errors.Is(err, application.ErrInvalidSchedule) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if errors.Is(err, domain.ErrProjectNotExists) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Project does not exists")
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, info)
+}
+
+func (s *Server) handleDeletePublishSchedule(c echo.Context) error {
+	projectName := c.Get("project").(string)
+	info, err := s.projects.SetPublishSchedule(projectName, nil)
+	if err != nil {
+		if errors.Is(err, domain.ErrProjectNotExists) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Project does not exists")
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, info)
+}
+
 // ProgressReader export
 type ProgressReader struct {
 	Reader   io.ReadCloser
@@ -399,6 +430,14 @@ func (s *Server) handleCreateProject() func(echo.Context) error {
 		}
 		username := c.Param("user")
 		name := c.Param("name")
+		user, err := s.auth.GetUser(c)
+		if err != nil {
+			return err
+		}
+		// own namespace or namespace of organization administrated by the user
+		if !user.CanManageNamespace(username) && !user.IsSuperuser {
+			return echo.ErrUnauthorized
+		}
 		projName := filepath.Join(username, name)
 		info, err := s.projects.Create(projName, data)
 		if err != nil {
@@ -528,7 +567,167 @@ func (s *Server) handleSaveProjectSettings(c echo.Context) error {
 		s.log.Errorw("decoding project settings", "project", projectName, zap.Error(err))
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data:", err.Error())
 	}
-	return s.projects.UpdateSettings(projectName, data)
+	report, err := s.checkSettingsDataValid(projectName, data)
+	if err != nil {
+		return err
+	}
+	if report != nil {
+		return c.JSON(http.StatusBadRequest, report)
+	}
+	if err := s.projects.UpdateSettings(projectName, data); err != nil {
+		if errors.Is(err, domain.ErrInvalidSettingsDocument) || errors.Is(err, domain.ErrUnsupportedSettingsVersion) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// checkSettingsDataValid decodes and validates settings data when strict validation is enabled
+func (s *Server) checkSettingsDataValid(projectName string, data json.RawMessage) (*application.ValidationReport, error) {
+	if !s.Config.StrictValidation {
+		return nil, nil
+	}
+	var settings domain.ProjectSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid project settings")
+	}
+	return s.checkSettingsValid(projectName, settings)
+}
+
+// checkSettingsValid validates settings when strict validation is enabled. Returns validation report
+// of invalid settings or nil.
+func (s *Server) checkSettingsValid(projectName string, settings domain.ProjectSettings) (*application.ValidationReport, error) {
+	if !s.Config.StrictValidation {
+		return nil, nil
+	}
+	report, err := s.projects.ValidateSettings(projectName, settings)
+	if err != nil {
+		return nil, fmt.Errorf("validating project settings: %w", err)
+	}
+	if !report.Valid() {
+		return &report, nil
+	}
+	return nil, nil
+}
+
+func (s *Server) handleGetDraftSettings(c echo.Context) error {
+	projectName := c.Get("project").(string)
+	settings, err := s.projects.GetDraftSettings(projectName)
+	if err != nil {
+		if errors.Is(err, domain.ErrNoDraftSettings) {
+			return echo.NewHTTPError(http.StatusNotFound, "Project has no draft settings")
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+func (s *Server) handleSaveDraftSettings(c echo.Context) error {
+	projectName := c.Get("project").(string)
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+	defer req.Body.Close()
+
+	var data json.RawMessage
+	d := json.NewDecoder(req.Body)
+	if err := d.Decode(&data); err != nil {
+		s.log.Errorw("decoding draft settings", "project", projectName, zap.Error(err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data:", err.Error())
+	}
+	if err := s.projects.SaveDraftSettings(projectName, data); err != nil {
+		if errors.Is(err, domain.ErrProjectNotExists) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Project does not exists")
+		}
+		if errors.Is(err, domain.ErrInvalidSettingsDocument) || errors.Is(err, domain.ErrUnsupportedSettingsVersion) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return err
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleDeleteDraftSettings(c echo.Context) error {
+	projectName := c.Get("project").(string)
+	if err := s.projects.DeleteDraftSettings(projectName); err != nil {
+		if errors.Is(err, domain.ErrNoDraftSettings) {
+			return echo.NewHTTPError(http.StatusNotFound, "Project has no draft settings")
+		}
+		return err
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handlePromoteDraftSettings(c echo.Context) error {
+	projectName := c.Get("project").(string)
+	settings, err := s.projects.GetDraftSettings(projectName)
+	if err != nil {
+		if errors.Is(err, domain.ErrNoDraftSettings) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Project has no draft settings")
+		}
+		return err
+	}
+	report, err := s.checkSettingsValid(projectName, settings)
+	if err != nil {
+		return err
+	}
+	if report != nil {
+		return c.JSON(http.StatusBadRequest, report)
+	}
+	if err := s.projects.PromoteDraftSettings(projectName); err != nil {
+		if errors.Is(err, domain.ErrNoDraftSettings) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Project has no draft settings")
+		}
+		return err
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleRollbackSettings(c echo.Context) error {
+	projectName := c.Get("project").(string)
+	if err := s.projects.RollbackSettings(projectName); err != nil {
+		if errors.Is(err, domain.ErrNoPreviousSettings) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Project has no previous settings")
+		}
+		return err
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleGetSettingsSchema(c echo.Context) error {
+	return c.JSON(http.StatusOK, application.SettingsSchema())
+}
+
+func (s *Server) handleValidateProject(c echo.Context) error {
+	projectName := c.Get("project").(string)
+	report, err := s.projects.ValidateProject(projectName)
+	if err != nil {
+		if errors.Is(err, domain.ErrProjectNotExists) {
+			return echo.ErrNotFound
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func (s *Server) handleValidateSettings(c echo.Context) error {
+	projectName := c.Get("project").(string)
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+	defer req.Body.Close()
+
+	var settings domain.ProjectSettings
+	if err := json.NewDecoder(req.Body).Decode(&settings); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data:", err.Error())
+	}
+	report, err := s.projects.ValidateSettings(projectName, settings)
+	if err != nil {
+		if errors.Is(err, domain.ErrProjectNotExists) {
+			return echo.ErrNotFound
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, report)
 }
 
 func (s *Server) handleUploadThumbnail(c echo.Context) error {
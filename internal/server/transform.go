@@ -0,0 +1,117 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/proj"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *Server) getProjectCRS(projectName, code string) (*proj.CRS, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	projections, err := s.projects.GetProjections(projectName)
+	if err != nil {
+		return nil, err
+	}
+	def := ""
+	if p, ok := projections[code]; ok && p.Proj4 != "" {
+		def = p.Proj4
+	} else if wk, ok := proj.WellKnown[code]; ok {
+		def = wk
+	}
+	if def == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unknown CRS: %s", code))
+	}
+	crs, err := proj.Parse(def)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unsupported CRS: %s", code)).SetInternal(err)
+	}
+	return crs, nil
+}
+
+func (s *Server) handleGetProjections(c echo.Context) error {
+	projectName := c.Get("project").(string)
+	projections, err := s.projects.GetProjections(projectName)
+	if err != nil {
+		if errors.Is(err, domain.ErrProjectNotExists) {
+			return echo.ErrNotFound
+		}
+		return err
+	}
+	data := make(map[string]domain.Projection, len(projections)+len(proj.WellKnown))
+	for code, def := range proj.WellKnown {
+		data[code] = domain.Projection{Proj4: def, IsGeografic: code == "EPSG:4326"}
+	}
+	for code, p := range projections {
+		data[code] = p
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *Server) handleTransform() func(echo.Context) error {
+	type TransformData struct {
+		From    string                 `json:"from"`
+		To      string                 `json:"to"`
+		Points  [][]float64            `json:"points,omitempty"`
+		Bbox    []float64              `json:"bbox,omitempty"`
+		GeoJSON map[string]interface{} `json:"geojson,omitempty"`
+	}
+	return func(c echo.Context) error {
+		projectName := c.Get("project").(string)
+		req := c.Request()
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxJSONSize)
+		defer req.Body.Close()
+
+		data := new(TransformData)
+		if err := (&echo.DefaultBinder{}).BindBody(c, data); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+		}
+		if data.Points == nil && data.Bbox == nil && data.GeoJSON == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Nothing to transform")
+		}
+		if data.From == "" || data.To == "" {
+			info, err := s.projects.GetProjectInfo(projectName)
+			if err != nil {
+				return fmt.Errorf("reading project info: %w", err)
+			}
+			if data.From == "" {
+				data.From = info.Projection
+			}
+			if data.To == "" {
+				data.To = info.Projection
+			}
+		}
+		src, err := s.getProjectCRS(projectName, data.From)
+		if err != nil {
+			return err
+		}
+		dst, err := s.getProjectCRS(projectName, data.To)
+		if err != nil {
+			return err
+		}
+		t := proj.NewTransformer(src, dst)
+		if data.Points != nil {
+			if err := t.TransformPoints(data.Points); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+		}
+		if data.Bbox != nil {
+			data.Bbox, err = t.TransformBounds(data.Bbox)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+		}
+		if data.GeoJSON != nil {
+			if err := t.TransformGeoJSON(data.GeoJSON); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+		}
+		data.From = strings.ToUpper(data.From)
+		data.To = strings.ToUpper(data.To)
+		return c.JSON(http.StatusOK, data)
+	}
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/gisquick/gisquick-server/internal/server/auth"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// TwoFactorChallenge is login response of users with enabled 2FA, the login is finished
+// by sending the token with verification code
+type TwoFactorChallenge struct {
+	Required bool   `json:"two_factor_required"`
+	Token    string `json:"token"`
+}
+
+type codeForm struct {
+	Code string `json:"code" validate:"required"`
+}
+
+func bindCodeForm(c echo.Context) (string, error) {
+	form := new(codeForm)
+	if err := (&echo.DefaultBinder{}).BindBody(c, form); err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Invalid data")
+	}
+	if err := validator.New().Struct(form); err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return form.Code, nil
+}
+
+func twoFactorError(err error) error {
+	if errors.Is(err, auth.ErrInvalidCode) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid verification code")
+	}
+	if errors.Is(err, auth.ErrTwoFactorEnabled) {
+		return echo.NewHTTPError(http.StatusConflict, "Two-factor authentication is already enabled")
+	}
+	if errors.Is(err, domain.ErrTwoFactorNotConfigured) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Two-factor authentication is not enabled")
+	}
+	return err
+}
+
+func (s *Server) handleLoginTwoFactor() func(echo.Context) error {
+	type LoginForm struct {
+		Token string `json:"token" validate:"required"`
+		Code  string `json:"code" validate:"required"`
+	}
+	var validate = validator.New()
+	return func(c echo.Context) error {
+		form := new(LoginForm)
+		if err := c.Bind(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err := validate.Struct(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		account, err := s.auth.FinishTwoFactorLogin(c, form.Token, form.Code)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidCode) || errors.Is(err, auth.ErrInvalidTwoFactorLogin) || errors.Is(err, auth.ErrUserNotFound) {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			return fmt.Errorf("2fa login: %w", err)
+		}
+		return s.completeLogin(c, account)
+	}
+}
+
+func (s *Server) twoFactorUser(c echo.Context) (domain.User, error) {
+	if !s.auth.TwoFactorAvailable() {
+		return domain.User{}, echo.NewHTTPError(http.StatusNotImplemented, "Two-factor authentication is not available")
+	}
+	return s.auth.GetUser(c)
+}
+
+func (s *Server) handleGetTwoFactorStatus(c echo.Context) error {
+	type Payload struct {
+		Enabled       bool `json:"enabled"`
+		RecoveryCodes int  `json:"recovery_codes"`
+		Required      bool `json:"required"`
+	}
+	user, err := s.twoFactorUser(c)
+	if err != nil {
+		return err
+	}
+	enabled, err := s.auth.TwoFactorEnabled(user.Username)
+	if err != nil {
+		return err
+	}
+	data := Payload{Enabled: enabled, Required: s.auth.TwoFactorRequired(user)}
+	if enabled {
+		if data.RecoveryCodes, err = s.auth.RecoveryCodesCount(user.Username); err != nil {
+			return err
+		}
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *Server) handleSetupTwoFactor(c echo.Context) error {
+	type Payload struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}
+	user, err := s.twoFactorUser(c)
+	if err != nil {
+		return err
+	}
+	secret, uri, err := s.auth.SetupTwoFactor(user.Username)
+	if err != nil {
+		return twoFactorError(err)
+	}
+	return c.JSON(http.StatusOK, Payload{Secret: secret, URI: uri})
+}
+
+type recoveryCodesPayload struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (s *Server) handleEnableTwoFactor(c echo.Context) error {
+	user, err := s.twoFactorUser(c)
+	if err != nil {
+		return err
+	}
+	code, err := bindCodeForm(c)
+	if err != nil {
+		return err
+	}
+	codes, err := s.auth.EnableTwoFactor(user.Username, code)
+	if err != nil {
+		return twoFactorError(err)
+	}
+	s.log.Infow("2fa enabled", "username", user.Username)
+	return c.JSON(http.StatusOK, recoveryCodesPayload{RecoveryCodes: codes})
+}
+
+func (s *Server) handleRegenerateRecoveryCodes(c echo.Context) error {
+	user, err := s.twoFactorUser(c)
+	if err != nil {
+		return err
+	}
+	code, err := bindCodeForm(c)
+	if err != nil {
+		return err
+	}
+	codes, err := s.auth.RegenerateRecoveryCodes(user.Username, code)
+	if err != nil {
+		return twoFactorError(err)
+	}
+	return c.JSON(http.StatusOK, recoveryCodesPayload{RecoveryCodes: codes})
+}
+
+func (s *Server) handleDisableTwoFactor(c echo.Context) error {
+	user, err := s.twoFactorUser(c)
+	if err != nil {
+		return err
+	}
+	code, err := bindCodeForm(c)
+	if err != nil {
+		return err
+	}
+	if err := s.auth.VerifyTwoFactor(user.Username, code); err != nil {
+		return twoFactorError(err)
+	}
+	if err := s.auth.DisableTwoFactor(user.Username); err != nil {
+		return fmt.Errorf("disabling 2fa [%s]: %w", user.Username, err)
+	}
+	s.log.Infow("2fa disabled", "username", user.Username)
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleAdminResetTwoFactor(c echo.Context) error {
+	if !s.auth.TwoFactorAvailable() {
+		return echo.NewHTTPError(http.StatusNotImplemented, "Two-factor authentication is not available")
+	}
+	username := c.Param("user")
+	if err := s.auth.DisableTwoFactor(username); err != nil {
+		return fmt.Errorf("resetting 2fa [%s]: %w", username, err)
+	}
+	s.log.Infow("2fa reset by admin", "username", username)
+	return c.NoContent(http.StatusOK)
+}
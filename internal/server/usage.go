@@ -0,0 +1,114 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gisquick/gisquick-server/internal/application"
+	"github.com/gisquick/gisquick-server/internal/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// AddUsage registers reports of storage usage of accounts
+func (s *Server) AddUsage(service *application.UsageService) {
+	s.usage = service
+	e := s.echo
+	LoginRequired := s.middlewares.LoginRequired
+	SuperuserRequired := s.middlewares.SuperuserRequired
+	e.GET("/api/account/usage", s.handleGetAccountUsage, LoginRequired)
+	e.GET("/api/admin/usage", s.handleGetUsageOverview(), SuperuserRequired)
+	e.GET("/api/admin/usage/:user", s.handleGetUserUsage, SuperuserRequired)
+}
+
+// parseUsageOptions parses number of the largest files and start of usage history (last 90 days by default)
+func parseUsageOptions(c echo.Context) (application.UsageOptions, error) {
+	type Params struct {
+		Files int    `query:"files"`
+		From  string `query:"from"`
+	}
+	params := Params{Files: 10}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &params); err != nil || params.Files < 0 || params.Files > 100 {
+		return application.UsageOptions{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid files parameter")
+	}
+	opts := application.UsageOptions{
+		LargestFiles: params.Files,
+		HistoryFrom:  time.Now().AddDate(0, 0, -90),
+	}
+	from, err := parseTimeParam(params.From, false)
+	if err != nil {
+		return opts, echo.NewHTTPError(http.StatusBadRequest, "Invalid from parameter")
+	}
+	if from != nil {
+		opts.HistoryFrom = *from
+	}
+	return opts, nil
+}
+
+func (s *Server) accountUsage(c echo.Context, username string) error {
+	opts, err := parseUsageOptions(c)
+	if err != nil {
+		return err
+	}
+	usage, err := s.usage.AccountUsage(username, opts)
+	if err != nil {
+		return fmt.Errorf("getting account usage: %w", err)
+	}
+	return c.JSON(http.StatusOK, usage)
+}
+
+// handleGetAccountUsage returns usage of the current user against account limits
+func (s *Server) handleGetAccountUsage(c echo.Context) error {
+	user, err := s.auth.GetUser(c)
+	if err != nil {
+		return err
+	}
+	return s.accountUsage(c, user.Username)
+}
+
+func (s *Server) handleGetUserUsage(c echo.Context) error {
+	return s.accountUsage(c, c.Param("user"))
+}
+
+func (s *Server) handleGetUsageOverview() func(echo.Context) error {
+	type Params struct {
+		// sort field, prefixed with '-' for descending order
+		Sort   string `query:"sort"`
+		Limit  int    `query:"limit"`
+		Offset int    `query:"offset"`
+	}
+	type Page struct {
+		Total    int                               `json:"total"`
+		Limit    int                               `json:"limit"`
+		Offset   int                               `json:"offset"`
+		Accounts []application.AccountUsageSummary `json:"accounts"`
+	}
+	return func(c echo.Context) error {
+		params := Params{Sort: "-storage", Limit: 100}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &params); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid query parameters")
+		}
+		if params.Limit <= 0 || params.Limit > 1000 || params.Offset < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid pagination parameters")
+		}
+		descending := strings.HasPrefix(params.Sort, "-")
+		accounts, err := s.usage.Overview(strings.TrimPrefix(params.Sort, "-"), descending)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidQuery) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			return fmt.Errorf("getting usage overview: %w", err)
+		}
+		page := Page{Total: len(accounts), Limit: params.Limit, Offset: params.Offset, Accounts: []application.AccountUsageSummary{}}
+		if params.Offset < len(accounts) {
+			end := params.Offset + params.Limit
+			if end > len(accounts) {
+				end = len(accounts)
+			}
+			page.Accounts = accounts[params.Offset:end]
+		}
+		return c.JSON(http.StatusOK, page)
+	}
+}
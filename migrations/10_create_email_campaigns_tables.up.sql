@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS email_campaigns(
+  id bigserial PRIMARY KEY,
+  subject text NOT NULL DEFAULT '',
+  html_template text NOT NULL DEFAULT '',
+  text_template text NOT NULL DEFAULT '',
+  style text NOT NULL DEFAULT '',
+  filter JSONB NOT NULL DEFAULT '{}',
+  status varchar(16) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'scheduled', 'sending', 'completed', 'cancelled')),
+  send_at timestamptz,
+  created_by varchar(30) NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT now(),
+  started_at timestamptz,
+  finished_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS email_campaign_recipients(
+  campaign_id bigint NOT NULL REFERENCES email_campaigns(id) ON DELETE CASCADE,
+  username varchar(30) NOT NULL,
+  email varchar(255) NOT NULL,
+  status varchar(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+  error text NOT NULL DEFAULT '',
+  sent_at timestamptz,
+  PRIMARY KEY (campaign_id, username)
+);
+
+CREATE INDEX IF NOT EXISTS email_campaign_recipients_status_idx ON email_campaign_recipients(campaign_id, status);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS email_templates(
+  name varchar(64) NOT NULL,
+  lang varchar(32) NOT NULL DEFAULT '',
+  subject text NOT NULL DEFAULT '',
+  html text NOT NULL DEFAULT '',
+  text text NOT NULL DEFAULT '',
+  updated_at timestamptz NOT NULL DEFAULT now(),
+  updated_by varchar(30) NOT NULL DEFAULT '',
+  PRIMARY KEY (name, lang)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS account_deletions(
+  username varchar(30) PRIMARY KEY REFERENCES users(username) ON DELETE CASCADE ON UPDATE CASCADE,
+  transfer_to varchar(30) NOT NULL DEFAULT '',
+  requested_at timestamptz NOT NULL DEFAULT now(),
+  confirmed_at timestamptz,
+  delete_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS account_deletions_delete_at_idx ON account_deletions(delete_at);
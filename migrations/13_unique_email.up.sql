@@ -0,0 +1,14 @@
+-- duplicate email addresses must be resolved manually before applying this migration
+DO $$
+DECLARE
+  duplicates text;
+BEGIN
+  SELECT string_agg(e, ', ') INTO duplicates FROM (
+    SELECT lower(email) AS e FROM users WHERE email <> '' GROUP BY lower(email) HAVING count(*) > 1
+  ) AS d;
+  IF duplicates IS NOT NULL THEN
+    RAISE EXCEPTION 'Email addresses used by multiple accounts: %', duplicates;
+  END IF;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_unique_idx ON users (lower(email)) WHERE email <> '';
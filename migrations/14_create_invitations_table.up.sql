@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS invitations(
+  id bigserial PRIMARY KEY,
+  username varchar(30) NOT NULL REFERENCES users(username) ON DELETE CASCADE ON UPDATE CASCADE,
+  email varchar(255) NOT NULL,
+  project varchar(255) NOT NULL,
+  grants JSONB NOT NULL DEFAULT '[]',
+  new_account boolean NOT NULL DEFAULT false,
+  invited_by varchar(30) NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL,
+  accepted_at timestamptz,
+  revoked_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS invitations_username_idx ON invitations(username);
+CREATE INDEX IF NOT EXISTS invitations_project_idx ON invitations(project);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS audit_events(
+  id bigserial PRIMARY KEY,
+  type varchar(40) NOT NULL,
+  username varchar(30) NOT NULL DEFAULT '',
+  actor varchar(30) NOT NULL DEFAULT '',
+  ip varchar(64) NOT NULL DEFAULT '',
+  user_agent text NOT NULL DEFAULT '',
+  details JSONB,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_username_idx ON audit_events(username, created_at);
+CREATE INDEX IF NOT EXISTS audit_events_created_idx ON audit_events(created_at);
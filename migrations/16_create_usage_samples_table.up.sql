@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS usage_samples(
+  username varchar(30) NOT NULL,
+  sampled_at timestamptz NOT NULL,
+  projects_count integer NOT NULL,
+  storage_size bigint NOT NULL,
+  PRIMARY KEY (username, sampled_at)
+);
+
+CREATE INDEX IF NOT EXISTS usage_samples_sampled_idx ON usage_samples(sampled_at);
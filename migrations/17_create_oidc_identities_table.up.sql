@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS oidc_identities(
+  issuer varchar(255) NOT NULL,
+  subject varchar(255) NOT NULL,
+  username varchar(30) NOT NULL REFERENCES users(username) ON DELETE CASCADE ON UPDATE CASCADE,
+  created_at timestamptz NOT NULL,
+  PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS oidc_identities_username_idx ON oidc_identities (username);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS ldap_accounts(
+  username varchar(30) PRIMARY KEY REFERENCES users(username) ON DELETE CASCADE ON UPDATE CASCADE,
+  groups varchar(255)[] NOT NULL DEFAULT '{}',
+  synced_at timestamptz NOT NULL
+);
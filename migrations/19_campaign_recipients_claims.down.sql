@@ -0,0 +1,2 @@
+ALTER TABLE email_campaign_recipients
+DROP COLUMN IF EXISTS claimed_until;
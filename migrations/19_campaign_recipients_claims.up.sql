@@ -0,0 +1,2 @@
+ALTER TABLE email_campaign_recipients
+ADD COLUMN claimed_until timestamptz NULL;
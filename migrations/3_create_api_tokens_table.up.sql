@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS api_tokens(
+  id SERIAL PRIMARY KEY,
+  username varchar(30) NOT NULL REFERENCES users(username) ON DELETE CASCADE ON UPDATE CASCADE,
+  name varchar(100) NOT NULL,
+  token_hash char(64) NOT NULL UNIQUE,
+  token_prefix varchar(16) NOT NULL,
+  scopes TEXT[] NOT NULL,
+  created_at timestamptz NOT NULL,
+  expires_at timestamptz,
+  last_used_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_username_idx ON api_tokens(username);
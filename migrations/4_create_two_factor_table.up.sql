@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS two_factor(
+  username varchar(30) PRIMARY KEY REFERENCES users(username) ON DELETE CASCADE ON UPDATE CASCADE,
+  secret varchar(64) NOT NULL,
+  enabled boolean NOT NULL DEFAULT false,
+  recovery_codes TEXT[] NOT NULL DEFAULT '{}',
+  last_step bigint NOT NULL DEFAULT 0,
+  created_at timestamptz NOT NULL
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS notifications(
+  id varchar(64) PRIMARY KEY,
+  data JSONB NOT NULL,
+  expires_at timestamptz
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS organization_team_members;
+DROP TABLE IF EXISTS organization_teams;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS organizations(
+  name varchar(30) PRIMARY KEY,
+  title varchar(100) NOT NULL DEFAULT '',
+  projects_limit integer NULL,
+  project_size_limit bigint NULL,
+  storage_limit bigint NULL,
+  created_at timestamptz NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS organization_members(
+  organization varchar(30) NOT NULL REFERENCES organizations(name) ON DELETE CASCADE ON UPDATE CASCADE,
+  username varchar(30) NOT NULL REFERENCES users(username) ON DELETE CASCADE ON UPDATE CASCADE,
+  role varchar(10) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+  created_at timestamptz NOT NULL,
+  PRIMARY KEY (organization, username)
+);
+
+CREATE INDEX IF NOT EXISTS organization_members_username_idx ON organization_members USING btree (username);
+
+CREATE TABLE IF NOT EXISTS organization_teams(
+  organization varchar(30) NOT NULL REFERENCES organizations(name) ON DELETE CASCADE ON UPDATE CASCADE,
+  name varchar(30) NOT NULL,
+  PRIMARY KEY (organization, name)
+);
+
+CREATE TABLE IF NOT EXISTS organization_team_members(
+  organization varchar(30) NOT NULL,
+  team varchar(30) NOT NULL,
+  username varchar(30) NOT NULL,
+  PRIMARY KEY (organization, team, username),
+  FOREIGN KEY (organization, team) REFERENCES organization_teams(organization, name) ON DELETE CASCADE ON UPDATE CASCADE,
+  FOREIGN KEY (organization, username) REFERENCES organization_members(organization, username) ON DELETE CASCADE ON UPDATE CASCADE
+);
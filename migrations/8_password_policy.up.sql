@@ -0,0 +1,3 @@
+ALTER TABLE users
+ADD COLUMN password_changed_at timestamptz NULL,
+ADD COLUMN password_expiry boolean NOT NULL DEFAULT false;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS email_outbox(
+  id bigserial PRIMARY KEY,
+  sender text NOT NULL,
+  recipients text[] NOT NULL,
+  subject text NOT NULL DEFAULT '',
+  message text NOT NULL,
+  status varchar(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'sent', 'failed')),
+  attempts integer NOT NULL DEFAULT 0,
+  last_error text NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT now(),
+  next_attempt_at timestamptz NOT NULL DEFAULT now(),
+  sent_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS email_outbox_status_idx ON email_outbox(status, next_attempt_at);